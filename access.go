@@ -0,0 +1,200 @@
+package pgkit
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// TableAccess is the number of statements reading and writing a table, see AccessTracker.
+type TableAccess struct {
+	Table     string
+	Reads     int64
+	Writes    int64
+	LastRead  time.Time
+	LastWrite time.Time
+}
+
+// AccessTracker tallies the reads and writes of each table from the statements run through
+// its middleware, ie. to find unused tables and hot paths:
+//
+//	tracker := pgkit.NewAccessTracker()
+//	db.Use(tracker.Middleware())
+//
+// The tables are read from the SQL text: the targets of INSERT, UPDATE, DELETE, MERGE and
+// TRUNCATE are writes, the tables after FROM, JOIN and USING are reads. Functions and CTEs
+// are skipped, and names are counted as they appear, without resolving the search_path.
+type AccessTracker struct {
+	mu     sync.Mutex
+	tables map[string]*TableAccess
+	parsed map[string][]tableRef
+}
+
+// maxParsedStatements bounds the cache of parsed statements of an AccessTracker.
+const maxParsedStatements = 1024
+
+type tableRef struct {
+	table string
+	write bool
+}
+
+// NewAccessTracker creates an empty AccessTracker.
+func NewAccessTracker() *AccessTracker {
+	return &AccessTracker{tables: make(map[string]*TableAccess), parsed: make(map[string][]tableRef)}
+}
+
+// Middleware returns the middleware recording the statements.
+func (t *AccessTracker) Middleware() Middleware {
+	return func(next Executor) Executor {
+		return accessExecutor{tracker: t, next: next}
+	}
+}
+
+// Snapshot returns the access counts of the tables, the most accessed first.
+func (t *AccessTracker) Snapshot() []TableAccess {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	list := make([]TableAccess, 0, len(t.tables))
+	for _, a := range t.tables {
+		list = append(list, *a)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if a, b := list[i].Reads+list[i].Writes, list[j].Reads+list[j].Writes; a != b {
+			return a > b
+		}
+		return list[i].Table < list[j].Table
+	})
+	return list
+}
+
+// Reset clears the counts.
+func (t *AccessTracker) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.tables = make(map[string]*TableAccess)
+}
+
+func (t *AccessTracker) record(sql string) {
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	refs, ok := t.parsed[sql]
+	if !ok {
+		refs = tableRefs(tokenizeSQL(sql))
+		if len(t.parsed) >= maxParsedStatements {
+			t.parsed = make(map[string][]tableRef)
+		}
+		t.parsed[sql] = refs
+	}
+	for _, ref := range refs {
+		a := t.tables[ref.table]
+		if a == nil {
+			a = &TableAccess{Table: ref.table}
+			t.tables[ref.table] = a
+		}
+		if ref.write {
+			a.Writes++
+			a.LastWrite = now
+		} else {
+			a.Reads++
+			a.LastRead = now
+		}
+	}
+}
+
+// tableRefs returns the tables accessed by a tokenized statement, each one once per kind of
+// access.
+func tableRefs(tokens []string) []tableRef {
+	ctes := map[string]bool{}
+	for i := 0; i+2 < len(tokens); i++ {
+		if tokens[i+1] == "as" && tokens[i+2] == "(" {
+			ctes[tokens[i]] = true
+		}
+	}
+
+	var refs []tableRef
+	seen := map[tableRef]bool{}
+	add := func(i int, write bool) {
+		for i < len(tokens) && (tokens[i] == "only" || tokens[i] == "lateral" || tokens[i] == "table") {
+			i++
+		}
+		name, next := tableName(tokens, i)
+		if name == "" || ctes[name] || (next < len(tokens) && tokens[next] == "(" && !write) {
+			return
+		}
+		ref := tableRef{table: name, write: write}
+		if !seen[ref] {
+			seen[ref] = true
+			refs = append(refs, ref)
+		}
+	}
+
+	for i, tok := range tokens {
+		prev := ""
+		if i > 0 {
+			prev = tokens[i-1]
+		}
+		switch {
+		case tok == "into" && (prev == "insert" || prev == "merge"),
+			tok == "from" && prev == "delete",
+			tok == "update" && prev != "for" && prev != "do" && prev != "key",
+			tok == "truncate":
+			add(i+1, true)
+		case (tok == "from" && prev != "distinct") || tok == "join" || tok == "using":
+			add(i+1, false)
+		}
+	}
+	return refs
+}
+
+// tableName returns the possibly qualified name starting at i, and the position after it.
+func tableName(tokens []string, i int) (string, int) {
+	var parts []string
+	for i < len(tokens) {
+		tok := tokens[i]
+		if tok == "" || !(isIdent(tok[0]) || tok[0] == '"') || isDigit(tok[0]) {
+			break
+		}
+		parts = append(parts, strings.Trim(tok, `"`))
+		if i+1 < len(tokens) && tokens[i+1] == "." {
+			i += 2
+			continue
+		}
+		i++
+		break
+	}
+	return strings.Join(parts, "."), i
+}
+
+type accessExecutor struct {
+	tracker *AccessTracker
+	next    Executor
+}
+
+func (e accessExecutor) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	e.tracker.record(sql)
+	return e.next.Exec(ctx, sql, args...)
+}
+
+func (e accessExecutor) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	e.tracker.record(sql)
+	return e.next.Query(ctx, sql, args...)
+}
+
+func (e accessExecutor) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	e.tracker.record(sql)
+	return e.next.QueryRow(ctx, sql, args...)
+}
+
+func (e accessExecutor) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	for _, q := range b.QueuedQueries {
+		e.tracker.record(q.SQL)
+	}
+	return e.next.SendBatch(ctx, b)
+}
@@ -0,0 +1,44 @@
+package pgkit_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/goware/pgkit/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessTracker(t *testing.T) {
+	tracker := pgkit.NewAccessTracker()
+	exec := tracker.Middleware()(sleepExecutor{})
+
+	for _, sql := range []string{
+		`SELECT * FROM accounts a JOIN public.reviews r ON r.account_id = a.id WHERE a.id = $1`,
+		`SELECT * FROM accounts JOIN reviews USING (id) WHERE name IS DISTINCT FROM $1`,
+		`WITH recent AS (SELECT * FROM "Logs" WHERE id > $1) SELECT * FROM recent, jsonb_to_recordset($2) AS x(a int)`,
+		`INSERT INTO accounts (name) SELECT name FROM staging ON CONFLICT (name) DO UPDATE SET name = excluded.name`,
+		`UPDATE accounts SET disabled = true WHERE id IN (SELECT account_id FROM reviews)`,
+		`DELETE FROM reviews USING accounts WHERE reviews.account_id = accounts.id`,
+		`SELECT * FROM accounts FOR UPDATE`,
+		`TRUNCATE TABLE staging`,
+	} {
+		_, err := exec.Exec(context.Background(), sql)
+		require.NoError(t, err)
+	}
+
+	counts := map[string][2]int64{}
+	for _, a := range tracker.Snapshot() {
+		counts[a.Table] = [2]int64{a.Reads, a.Writes}
+	}
+	require.Equal(t, map[string][2]int64{
+		"accounts":       {4, 2},
+		"reviews":        {2, 1},
+		"public.reviews": {1, 0},
+		"Logs":           {1, 0},
+		"staging":        {1, 1},
+	}, counts)
+	require.Equal(t, "accounts", tracker.Snapshot()[0].Table)
+
+	tracker.Reset()
+	require.Empty(t, tracker.Snapshot())
+}
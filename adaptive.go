@@ -0,0 +1,146 @@
+package pgkit
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// AdaptiveSizePolicy configures AdaptiveSize.
+type AdaptiveSizePolicy struct {
+	// Budget is the p95 latency above which the max page size of a query is shrunk.
+	Budget time.Duration
+	// GrowBelow is the ratio of the budget under which the p95 latency must be for the max
+	// page size to grow back, defaults to 0.5. The gap with the budget avoids flapping.
+	GrowBelow float64
+	// Samples is the number of queries observed before each adjustment, defaults to 50.
+	Samples int
+	// MinSize is the lowest max page size, defaults to 1.
+	MinSize uint32
+}
+
+// AdaptiveSize adjusts the max page size of each query class, named with WithQueryConfig,
+// from the latency of its queries: it's halved when their p95 exceeds the budget, and grows
+// back gradually once it's well under it. Queries are observed through the Timing middleware
+// and the max size is applied with WithAdaptiveSize, ie.
+//
+//	adaptive := pgkit.NewAdaptiveSize(pgkit.AdaptiveSizePolicy{Budget: 200 * time.Millisecond})
+//	db.Use(pgkit.Timing(adaptive.Observe))
+//	paginator := pgkit.NewPaginator[T](pgkit.WithAdaptiveSize(adaptive))
+//
+// It's safe for concurrent use.
+type AdaptiveSize struct {
+	policy AdaptiveSizePolicy
+
+	mu      sync.Mutex
+	queries map[string]*adaptiveQuery
+}
+
+// minAdaptiveScale bounds the shrinking, so it doesn't take forever to grow back.
+const minAdaptiveScale = 1.0 / 64
+
+type adaptiveQuery struct {
+	scale   float64
+	samples []time.Duration
+	p95     time.Duration
+	shrinks int
+	grows   int
+}
+
+// AdaptiveSizeStat reports the state of a query class, see AdaptiveSize.Stats.
+type AdaptiveSizeStat struct {
+	Name string
+	// Scale is the ratio of the paginator max size in use, 1 when not reduced.
+	Scale float64
+	// P95 is the p95 latency of the last adjustment.
+	P95     time.Duration
+	Shrinks int
+	Grows   int
+}
+
+// NewAdaptiveSize creates an AdaptiveSize with the given policy.
+func NewAdaptiveSize(policy AdaptiveSizePolicy) *AdaptiveSize {
+	if policy.GrowBelow <= 0 {
+		policy.GrowBelow = 0.5
+	}
+	if policy.Samples <= 0 {
+		policy.Samples = 50
+	}
+	if policy.MinSize == 0 {
+		policy.MinSize = 1
+	}
+	return &AdaptiveSize{policy: policy, queries: make(map[string]*adaptiveQuery)}
+}
+
+// Observe records the latency of a query, it can be passed to Timing. Queries without a
+// name are ignored.
+func (a *AdaptiveSize) Observe(ctx context.Context, t QueryTiming) {
+	if t.Config.Name == "" || t.Err != nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	q := a.queries[t.Config.Name]
+	if q == nil {
+		q = &adaptiveQuery{scale: 1}
+		a.queries[t.Config.Name] = q
+	}
+	q.samples = append(q.samples, t.Total)
+	if len(q.samples) < a.policy.Samples {
+		return
+	}
+
+	sort.Slice(q.samples, func(i, j int) bool { return q.samples[i] < q.samples[j] })
+	q.p95 = q.samples[len(q.samples)*95/100]
+	q.samples = q.samples[:0]
+	switch {
+	case q.p95 > a.policy.Budget:
+		if q.scale > minAdaptiveScale {
+			q.scale /= 2
+		}
+		q.shrinks++
+	case q.scale < 1 && float64(q.p95) < float64(a.policy.Budget)*a.policy.GrowBelow:
+		if q.scale *= 1.25; q.scale > 1 {
+			q.scale = 1
+		}
+		q.grows++
+	}
+}
+
+// MaxSize returns the max page size of the query class, given the paginator max size.
+func (a *AdaptiveSize) MaxSize(name string, max uint32) uint32 {
+	scale := 1.0
+	a.mu.Lock()
+	if q := a.queries[name]; q != nil {
+		scale = q.scale
+	}
+	a.mu.Unlock()
+	size := uint32(float64(max) * scale)
+	if size < a.policy.MinSize {
+		size = a.policy.MinSize
+	}
+	if size > max {
+		size = max
+	}
+	return size
+}
+
+// Stats returns the state of the observed query classes, sorted by name.
+func (a *AdaptiveSize) Stats() []AdaptiveSizeStat {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	stats := make([]AdaptiveSizeStat, 0, len(a.queries))
+	for name, q := range a.queries {
+		stats = append(stats, AdaptiveSizeStat{Name: name, Scale: q.scale, P95: q.p95, Shrinks: q.shrinks, Grows: q.grows})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
+}
+
+// WithAdaptiveSize limits the max page size in PrepareQueryContext by the one of the query
+// class carried by the context, see AdaptiveSize.
+func WithAdaptiveSize(a *AdaptiveSize) func(*PaginatorOption) {
+	return func(o *PaginatorOption) { o.adaptiveSize = a }
+}
@@ -0,0 +1,51 @@
+package pgkit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/goware/pgkit/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdaptiveSize(t *testing.T) {
+	adaptive := pgkit.NewAdaptiveSize(pgkit.AdaptiveSizePolicy{Budget: 100 * time.Millisecond, Samples: 10, MinSize: 5})
+	observe := func(name string, d time.Duration) {
+		for i := 0; i < 10; i++ {
+			adaptive.Observe(context.Background(), pgkit.QueryTiming{Config: pgkit.QueryConfig{Name: name}, Total: d})
+		}
+	}
+
+	observe("list", 300*time.Millisecond)
+	require.Equal(t, uint32(25), adaptive.MaxSize("list", 50))
+	require.Equal(t, uint32(50), adaptive.MaxSize("other", 50))
+
+	observe("list", 300*time.Millisecond)
+	observe("list", 300*time.Millisecond)
+	observe("list", 300*time.Millisecond)
+	require.Equal(t, uint32(5), adaptive.MaxSize("list", 50))
+
+	// within the hysteresis band nothing changes
+	observe("list", 80*time.Millisecond)
+	require.Equal(t, uint32(5), adaptive.MaxSize("list", 50))
+
+	observe("list", 10*time.Millisecond)
+	require.Equal(t, uint32(31), adaptive.MaxSize("list", 400))
+
+	stats := adaptive.Stats()
+	require.Len(t, stats, 1)
+	require.Equal(t, "list", stats[0].Name)
+	require.Equal(t, 4, stats[0].Shrinks)
+	require.Equal(t, 1, stats[0].Grows)
+	require.Equal(t, 10*time.Millisecond, stats[0].P95)
+
+	paginator := pgkit.NewPaginator[T](pgkit.WithAdaptiveSize(adaptive))
+	ctx := pgkit.WithQueryConfig(context.Background(), pgkit.QueryConfig{Name: "list"})
+	page := pgkit.NewPage(50, 1)
+	_, query := paginator.PrepareQueryContext(ctx, sq.Select("*").From("t"), page)
+	sql, _, err := query.ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "SELECT * FROM t LIMIT 6 OFFSET 0", sql)
+}
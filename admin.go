@@ -0,0 +1,228 @@
+package pgkit
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// _AdminReserved are the query parameters of AdminBrowser, the columns with these names
+// can't be filtered on.
+var _AdminReserved = map[string]bool{"table": true, "page": true, "size": true, "sort": true, "cursor": true}
+
+// AdminBrowser is an http.Handler rendering a read-only browser of the tables of DB, for
+// debugging: the list of the tables, and the rows of one, paginated with a Paginator and
+// filtered as in FilterSchema, ie. `?table=public.accounts&sort=-id&name=like:a%`. It has no
+// authentication, so it must not be served in production:
+//
+//	if env != "production" {
+//		mux.Handle("/debug/db/", http.StripPrefix("/debug/db", pgkit.AdminBrowser{DB: db}))
+//	}
+//
+// The queries run in read only transactions.
+type AdminBrowser struct {
+	DB *DB
+	// Schemas are the browsable schemas, all but the system ones by default.
+	Schemas []string
+	// PageSize defaults to 50.
+	PageSize uint32
+}
+
+type adminView struct {
+	Tables  []TableInfo
+	Table   *TableInfo
+	Columns []adminColumn
+	Filters []adminFilter
+	Sort    string
+	Rows    [][]string
+	Page    *Page
+	Links   PageLinks
+	Error   string
+}
+
+type adminColumn struct {
+	Name, Type, SortURL string
+}
+
+type adminFilter struct {
+	Name, Type, Value string
+}
+
+// ServeHTTP implements http.Handler.
+func (b AdminBrowser) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	ctx := r.Context()
+	tables, err := Tables(ctx, b.DB, b.Schemas...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	view := adminView{Tables: tables}
+	status := http.StatusOK
+	if name := r.URL.Query().Get("table"); name != "" {
+		for i := range tables {
+			if tables[i].Schema+"."+tables[i].Name == name {
+				view.Table = &tables[i]
+			}
+		}
+		if view.Table == nil {
+			status = http.StatusNotFound
+			view.Error = fmt.Sprintf("table %q not found", name)
+		} else if err := b.rows(r, &view); err != nil {
+			status = http.StatusBadRequest
+			view.Error = err.Error()
+		}
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	_adminTemplate.Execute(w, view)
+}
+
+// rows reads the requested page of the rows of the table of view.
+func (b AdminBrowser) rows(r *http.Request, view *adminView) error {
+	ctx := r.Context()
+	values := r.URL.Query()
+	// the empty inputs of the filter form
+	for k, list := range values {
+		kept := list[:0]
+		for _, v := range list {
+			if v != "" {
+				kept = append(kept, v)
+			}
+		}
+		values[k] = kept
+	}
+	view.Sort = values.Get("sort")
+	size := b.PageSize
+	if size == 0 {
+		size = 50
+	}
+
+	columns := make(map[string]string, len(view.Table.Columns))
+	schema := FilterSchema{}
+	for _, c := range view.Table.Columns {
+		columns[c.Name] = quoteIdent(c.Name)
+		// sorting by a column toggles its direction, keeping the filters
+		link := url.Values{}
+		for k, v := range values {
+			link[k] = v
+		}
+		link.Del("page")
+		link.Del("cursor")
+		sort := c.Name
+		if values.Get("sort") == c.Name {
+			sort = "-" + c.Name
+		}
+		link.Set("sort", sort)
+		view.Columns = append(view.Columns, adminColumn{Name: c.Name, Type: c.Type, SortURL: "?" + link.Encode()})
+		filter := adminFilter{Name: c.Name, Type: c.Type, Value: values.Get(c.Name)}
+		if t, ok := c.FilterType(); ok && !_AdminReserved[c.Name] {
+			schema[c.Name] = FilterField{Column: columns[c.Name], Type: t}
+			view.Filters = append(view.Filters, filter)
+		}
+	}
+	options := []func(*PaginatorOption){WithColumnMap(columns), WithDefaultSize(size), WithMaxSize(10 * size)}
+	if len(view.Table.Columns) > 0 {
+		options = append(options, WithSort(columns[view.Table.Columns[0].Name]))
+	}
+	page, err := PageFromValues(values, options...)
+	if err != nil {
+		return err
+	}
+	filters, err := schema.FiltersFromValues(values)
+	if err != nil {
+		return err
+	}
+
+	tx, err := b.DB.Pool().BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return wrapErr(err)
+	}
+	defer tx.Rollback(ctx)
+
+	q := b.DB.SQL.Select("*").From(view.Table.String())
+	if len(filters) > 0 {
+		q = q.Where(filters)
+	}
+	rows, err := NewPaginator[map[string]interface{}](options...).Query(ctx, q, page, tx)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		cells := make([]string, len(view.Table.Columns))
+		for i, c := range view.Table.Columns {
+			cells[i] = adminFormat(row[c.Name])
+		}
+		view.Rows = append(view.Rows, cells)
+	}
+	view.Page, view.Links = page, page.Links("?"+r.URL.RawQuery)
+	return nil
+}
+
+// adminFormat formats a value of a row.
+func adminFormat(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case []byte:
+		return fmt.Sprintf("\\x%x", v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case [16]byte:
+		return fmt.Sprintf("%x-%x-%x-%x-%x", v[0:4], v[4:6], v[6:8], v[8:10], v[10:16])
+	}
+	return fmt.Sprint(v)
+}
+
+var _adminTemplate = template.Must(template.New("admin").Funcs(template.FuncMap{
+	"query": func(table string) string { return "?" + url.Values{"table": {table}}.Encode() },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{with .Table}}{{.Schema}}.{{.Name}} - {{end}}pgkit</title>
+<style>
+body { font-family: sans-serif; font-size: 14px; display: flex; margin: 0; }
+nav { padding: 1em; border-right: 1px solid #ddd; min-width: 12em; }
+nav a { display: block; }
+main { padding: 1em; overflow-x: auto; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ddd; padding: 2px 6px; text-align: left; white-space: nowrap; }
+.error { color: #b00; }
+</style>
+</head>
+<body>
+<nav>
+{{range .Tables}}<a href="{{query (printf "%s.%s" .Schema .Name)}}">{{.Schema}}.{{.Name}}</a>
+{{end}}</nav>
+<main>
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+{{with .Table}}
+<h1>{{.Schema}}.{{.Name}}</h1>
+<form method="get">
+<input type="hidden" name="table" value="{{.Schema}}.{{.Name}}">
+{{with $.Sort}}<input type="hidden" name="sort" value="{{.}}">{{end}}
+{{range $.Filters}}<label>{{.Name}} <input name="{{.Name}}" value="{{.Value}}" placeholder="op:value" title="{{.Type}}"></label>
+{{end}}<button type="submit">Filter</button>
+</form>
+<table>
+<tr>{{range $.Columns}}<th><a href="{{.SortURL}}">{{.Name}}</a> <small>{{.Type}}</small></th>{{end}}</tr>
+{{range $.Rows}}<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</table>
+{{with $.Page}}<p>
+{{with $.Links.Prev}}<a href="{{.}}">previous</a>{{end}}
+page {{.Page}}
+{{with $.Links.Next}}<a href="{{.}}">next</a>{{end}}
+</p>{{end}}
+{{else}}{{if not .Error}}<p>Pick a table.</p>{{end}}{{end}}
+</main>
+</body>
+</html>
+`))
@@ -0,0 +1,186 @@
+package pgkit
+
+import (
+	"context"
+	"sort"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
+)
+
+// Aggregate is an aggregate expression selected as an alias, ie. Avg("latency", "avg"),
+// see TruncBuckets and Aggregates. The column and the alias are used as is.
+type Aggregate struct {
+	expr   string
+	args   []interface{}
+	alias  string
+	filter sq.Sqlizer
+}
+
+// Filter returns the aggregate computed over the rows matching cond only, with a FILTER
+// clause, ie. Count("failed").Filter(sq.GtOrEq{"status": 500}).
+func (a Aggregate) Filter(cond sq.Sqlizer) Aggregate {
+	a.filter = cond
+	return a
+}
+
+// ToSql implements sq.Sqlizer.
+func (a Aggregate) ToSql() (string, []interface{}, error) {
+	sql, args := a.expr, append([]interface{}(nil), a.args...)
+	if a.filter != nil {
+		filter, filterArgs, err := a.filter.ToSql()
+		if err != nil {
+			return "", nil, err
+		}
+		sql += " FILTER (WHERE " + filter + ")"
+		args = append(args, filterArgs...)
+	}
+	return sql + " AS " + a.alias, args, nil
+}
+
+// Count returns the aggregate counting the rows, as alias.
+func Count(alias string) Aggregate {
+	return Aggregate{expr: "count(*)", alias: alias}
+}
+
+// Avg returns the aggregate averaging column, as alias.
+func Avg(column, alias string) Aggregate {
+	return Aggregate{expr: "avg(" + column + ")", alias: alias}
+}
+
+// Min returns the aggregate selecting the minimum of column, as alias.
+func Min(column, alias string) Aggregate {
+	return Aggregate{expr: "min(" + column + ")", alias: alias}
+}
+
+// Max returns the aggregate selecting the maximum of column, as alias.
+func Max(column, alias string) Aggregate {
+	return Aggregate{expr: "max(" + column + ")", alias: alias}
+}
+
+// Sum returns the aggregate summing column, as alias.
+func Sum(column, alias string) Aggregate {
+	return Aggregate{expr: "sum(" + column + ")", alias: alias}
+}
+
+// Stddev returns the aggregate selecting the sample standard deviation of column, as alias.
+func Stddev(column, alias string) Aggregate {
+	return Aggregate{expr: "stddev_samp(" + column + ")", alias: alias}
+}
+
+// Variance returns the aggregate selecting the sample variance of column, as alias.
+func Variance(column, alias string) Aggregate {
+	return Aggregate{expr: "var_samp(" + column + ")", alias: alias}
+}
+
+// Percentile returns the aggregate selecting the continuous percentile p of column, between
+// 0 and 1, as alias. It's interpolated between the values, see PercentileDisc.
+func Percentile(p float64, column, alias string) Aggregate {
+	return Aggregate{expr: "percentile_cont(?) WITHIN GROUP (ORDER BY " + column + ")", args: []interface{}{p}, alias: alias}
+}
+
+// PercentileDisc returns the aggregate selecting the first value of column whose position
+// is at least the percentile p, as alias.
+func PercentileDisc(p float64, column, alias string) Aggregate {
+	return Aggregate{expr: "percentile_disc(?) WITHIN GROUP (ORDER BY " + column + ")", args: []interface{}{p}, alias: alias}
+}
+
+// Percentiles returns the aggregate selecting the continuous percentiles ps of column as an
+// array, as alias, which scans into a []float64.
+func Percentiles(ps []float64, column, alias string) Aggregate {
+	return Aggregate{expr: "percentile_cont(?::float8[]) WITHIN GROUP (ORDER BY " + column + ")", args: []interface{}{ps}, alias: alias}
+}
+
+// Mode returns the aggregate selecting the most frequent value of column, as alias.
+func Mode(column, alias string) Aggregate {
+	return Aggregate{expr: "mode() WITHIN GROUP (ORDER BY " + column + ")", alias: alias}
+}
+
+// Aggregates returns the query selecting the aggregates over the rows of q, in a single row
+// which can be scanned into a struct with the aliases as db tags.
+func Aggregates(q sq.SelectBuilder, aggregates ...Aggregate) sq.SelectBuilder {
+	b := sq.Select()
+	for _, a := range aggregates {
+		b = b.Column(a)
+	}
+	return b.FromSelect(removeOrderBy(q), "pgkit_aggregates").PlaceholderFormat(sq.Dollar)
+}
+
+// Summary is the distribution of the values of a numeric column, see Summarize. The values
+// are nil without rows.
+type Summary struct {
+	Count  int64    `db:"count" json:"count"`
+	Min    *float64 `db:"min" json:"min"`
+	Max    *float64 `db:"max" json:"max"`
+	Avg    *float64 `db:"avg" json:"avg"`
+	Stddev *float64 `db:"stddev" json:"stddev"`
+	P50    *float64 `db:"p50" json:"p50"`
+	P90    *float64 `db:"p90" json:"p90"`
+	P95    *float64 `db:"p95" json:"p95"`
+	P99    *float64 `db:"p99" json:"p99"`
+}
+
+// Summarize returns the query selecting the Summary of the non-NULL values of column over
+// the rows of q, ie.
+//
+//	var s pgkit.Summary
+//	err := db.Query.GetOne(ctx, pgkit.Summarize(q, "latency"), &s)
+func Summarize(q sq.SelectBuilder, column string) sq.SelectBuilder {
+	f := "(" + column + ")::float8"
+	return Aggregates(q,
+		Aggregate{expr: "count(" + column + ")", alias: "count"},
+		Min(f, "min"), Max(f, "max"), Avg(f, "avg"), Stddev(f, "stddev"),
+		Percentile(0.5, f, "p50"), Percentile(0.9, f, "p90"), Percentile(0.95, f, "p95"), Percentile(0.99, f, "p99"),
+	)
+}
+
+// WithAggregates makes Paginator.Query compute the aggregates over all the rows matched by
+// the query, not only the ones of the page, ie. the totals row of a table. They're set in
+// Page.Aggregates by alias, ie. {"total": "sum(amount)", "paid": "count(*) FILTER (WHERE
+// paid)"}. When Query isn't run in a transaction, it starts a REPEATABLE READ one, so the
+// page and its aggregates are consistent.
+func WithAggregates(aggregates map[string]string) func(*PaginatorOption) {
+	copied := make(map[string]string, len(aggregates))
+	for alias, expr := range aggregates {
+		copied[alias] = expr
+	}
+	return func(o *PaginatorOption) { o.aggregates = copied }
+}
+
+// PrepareAggregatesQuery returns the query selecting the aggregates of the paginator over
+// the rows of q, ignoring its limit, offset and order, see WithAggregates.
+func (p Paginator[T]) PrepareAggregatesQuery(q sq.SelectBuilder) sq.SelectBuilder {
+	aliases := make([]string, 0, len(p.aggregates))
+	for alias := range p.aggregates {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	list := make([]Aggregate, len(aliases))
+	for i, alias := range aliases {
+		list[i] = Aggregate{expr: p.aggregates[alias], alias: quoteIdent(alias)}
+	}
+	return Aggregates(q.RemoveLimit().RemoveOffset(), list...)
+}
+
+// queryAggregates runs the aggregates query of q and sets the page aggregates.
+func (p Paginator[T]) queryAggregates(ctx context.Context, querier *Querier, q sq.SelectBuilder, page *Page) error {
+	if len(p.aggregates) == 0 {
+		return nil
+	}
+	rows, err := querier.QueryRows(ctx, p.PrepareAggregatesQuery(q))
+	if err != nil {
+		return err
+	}
+	values, err := pgx.CollectOneRow(rows, pgx.RowToMap)
+	if err != nil {
+		return wrapErr(err)
+	}
+	page.Aggregates = values
+	return nil
+}
+
+// txBeginner is implemented by the executors which can start a transaction with options,
+// ie. *pgxpool.Pool and *pgx.Conn, unlike pgx.Tx.
+type txBeginner interface {
+	BeginTx(ctx context.Context, options pgx.TxOptions) (pgx.Tx, error)
+}
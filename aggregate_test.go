@@ -0,0 +1,51 @@
+package pgkit_test
+
+import (
+	"testing"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/goware/pgkit/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAggregates(t *testing.T) {
+	requests := sq.Select("*").From("requests").Where(sq.Eq{"path": "/"}).OrderBy("id")
+
+	q := pgkit.Aggregates(requests,
+		pgkit.Count("n"),
+		pgkit.Count("errors").Filter(sq.GtOrEq{"status": 500}),
+		pgkit.PercentileDisc(0.5, "latency", "median").Filter(sq.Eq{"method": "GET"}),
+		pgkit.Percentiles([]float64{0.5, 0.99}, "latency", "ps"),
+		pgkit.Mode("user_agent", "agent"),
+		pgkit.Variance("latency", "var"),
+	)
+	sql, args, err := q.ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "SELECT count(*) AS n, count(*) FILTER (WHERE status >= $1) AS errors, "+
+		"percentile_disc($2) WITHIN GROUP (ORDER BY latency) FILTER (WHERE method = $3) AS median, "+
+		"percentile_cont($4::float8[]) WITHIN GROUP (ORDER BY latency) AS ps, mode() WITHIN GROUP (ORDER BY user_agent) AS agent, "+
+		"var_samp(latency) AS var FROM (SELECT * FROM requests WHERE path = $5) AS pgkit_aggregates", sql)
+	require.Equal(t, []interface{}{500, 0.5, "GET", []float64{0.5, 0.99}, "/"}, args)
+
+	sql, _, err = pgkit.Summarize(requests, "latency").ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "SELECT count(latency) AS count, min((latency)::float8) AS min, max((latency)::float8) AS max, avg((latency)::float8) AS avg, "+
+		"stddev_samp((latency)::float8) AS stddev, percentile_cont($1) WITHIN GROUP (ORDER BY (latency)::float8) AS p50, "+
+		"percentile_cont($2) WITHIN GROUP (ORDER BY (latency)::float8) AS p90, percentile_cont($3) WITHIN GROUP (ORDER BY (latency)::float8) AS p95, "+
+		"percentile_cont($4) WITHIN GROUP (ORDER BY (latency)::float8) AS p99 FROM (SELECT * FROM requests WHERE path = $5) AS pgkit_aggregates", sql)
+}
+
+func TestPaginatorAggregates(t *testing.T) {
+	paginator := pgkit.NewPaginator[T](pgkit.WithSort("id"), pgkit.WithAggregates(map[string]string{
+		"total":  "sum(amount)",
+		"paid":   "count(*) FILTER (WHERE paid)",
+		"Status": "max(status)",
+	}))
+	_, query := paginator.PrepareQuery(sq.Select("*").From("invoices").Where(sq.Eq{"account_id": 1}), &pgkit.Page{Page: 2})
+
+	sql, args, err := paginator.PrepareAggregatesQuery(query).ToSql()
+	require.NoError(t, err)
+	require.Equal(t, `SELECT max(status) AS "Status", count(*) FILTER (WHERE paid) AS "paid", sum(amount) AS "total" `+
+		`FROM (SELECT * FROM invoices WHERE account_id = $1) AS pgkit_aggregates`, sql)
+	require.Equal(t, []interface{}{1}, args)
+}
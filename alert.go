@@ -0,0 +1,102 @@
+package pgkit
+
+import (
+	"context"
+	"time"
+)
+
+// AlertKind identifies a notable event of the query layer.
+type AlertKind string
+
+const (
+	// AlertCircuitOpen, AlertReplicaDropped and AlertMigrationDrift are never fired by pgkit,
+	// which has no circuit breaker, replicas or migrations: they're sent with DB.Alert by the
+	// components of the application guarding the DB, so all the alerts of the query layer
+	// reach the same Alerter.
+
+	// AlertCircuitOpen is sent when a circuit breaker opens, failing the queries fast.
+	AlertCircuitOpen AlertKind = "circuit_open"
+	// AlertReplicaDropped is sent when a replica is dropped from the rotation, ie. lagging.
+	AlertReplicaDropped AlertKind = "replica_dropped"
+	// AlertMigrationDrift is sent when the schema doesn't match the applied migrations.
+	AlertMigrationDrift AlertKind = "migration_drift"
+
+	// AlertSerializationRetriesExhausted is fired when SerializableTx gives up.
+	AlertSerializationRetriesExhausted AlertKind = "serialization_retries_exhausted"
+
+	// The kinds below aren't part of DefaultAlertKinds, see AlertKinds.
+
+	// AlertSheddingStarted is fired when LoadShedding starts rejecting queries.
+	AlertSheddingStarted AlertKind = "shedding_started"
+	// AlertSheddingStopped is fired when LoadShedding stops rejecting queries.
+	AlertSheddingStopped AlertKind = "shedding_stopped"
+	// AlertQueriesCanceled is fired when DB.CancelInFlight cancels queries.
+	AlertQueriesCanceled AlertKind = "queries_canceled"
+	// AlertLockTimeoutExhausted is fired when WithLockTimeout gives up.
+	AlertLockTimeoutExhausted AlertKind = "lock_timeout_exhausted"
+)
+
+// DefaultAlertKinds are the kinds of alerts sent to an Alerter, the ones requiring an
+// operator attention. Use AlertKinds to receive others.
+var DefaultAlertKinds = []AlertKind{AlertCircuitOpen, AlertReplicaDropped, AlertMigrationDrift, AlertSerializationRetriesExhausted}
+
+// Alert is a notable event, which may require an operator attention.
+type Alert struct {
+	Kind    AlertKind
+	Message string
+	Time    time.Time
+	Err     error
+}
+
+// Alerter receives the alerts, ie. to page operators. Alert is called synchronously by the
+// goroutine running into the event, so it should not block.
+type Alerter interface {
+	Alert(ctx context.Context, alert Alert)
+}
+
+// AlertFunc is an Alerter function.
+type AlertFunc func(ctx context.Context, alert Alert)
+
+func (f AlertFunc) Alert(ctx context.Context, alert Alert) { f(ctx, alert) }
+
+// AlertKinds returns an Alerter receiving the given kinds of alerts, instead of
+// DefaultAlertKinds, ie. AlertKinds(a, append(DefaultAlertKinds, AlertSheddingStarted)...).
+func AlertKinds(a Alerter, kinds ...AlertKind) Alerter {
+	return kindsAlerter{Alerter: a, kinds: alertKindSet(kinds)}
+}
+
+type kindsAlerter struct {
+	Alerter
+	kinds map[AlertKind]bool
+}
+
+func alertKindSet(kinds []AlertKind) map[AlertKind]bool {
+	set := make(map[AlertKind]bool, len(kinds))
+	for _, k := range kinds {
+		set[k] = true
+	}
+	return set
+}
+
+// Alert sends the alert to the Alerter of the DB, if it receives its kind, see
+// DefaultAlertKinds. It's used by the components of the application guarding the DB, ie.
+// a circuit breaker sending AlertCircuitOpen, to alert through the same Alerter.
+func (d *DB) Alert(ctx context.Context, alert Alert) {
+	fireAlert(ctx, d.Alerter, alert)
+}
+
+// fireAlert sends the alert to a, if not nil and if it receives its kind.
+func fireAlert(ctx context.Context, a Alerter, alert Alert) {
+	if a == nil {
+		return
+	}
+	kinds, ok := a.(kindsAlerter)
+	if !ok {
+		kinds = kindsAlerter{Alerter: a, kinds: alertKindSet(DefaultAlertKinds)}
+	}
+	if !kinds.kinds[alert.Kind] {
+		return
+	}
+	alert.Time = time.Now()
+	kinds.Alerter.Alert(ctx, alert)
+}
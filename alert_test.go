@@ -0,0 +1,35 @@
+package pgkit_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/goware/pgkit/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertKinds(t *testing.T) {
+	ctx := context.Background()
+	var kinds []pgkit.AlertKind
+	alerter := pgkit.AlertFunc(func(ctx context.Context, alert pgkit.Alert) {
+		require.False(t, alert.Time.IsZero())
+		kinds = append(kinds, alert.Kind)
+	})
+
+	db := &pgkit.DB{Alerter: alerter}
+	for _, kind := range []pgkit.AlertKind{pgkit.AlertCircuitOpen, pgkit.AlertSheddingStarted, pgkit.AlertMigrationDrift, pgkit.AlertQueriesCanceled} {
+		db.Alert(ctx, pgkit.Alert{Kind: kind})
+	}
+	require.Equal(t, []pgkit.AlertKind{pgkit.AlertCircuitOpen, pgkit.AlertMigrationDrift}, kinds)
+
+	kinds = nil
+	db.Alerter = pgkit.AlertKinds(alerter, pgkit.AlertSheddingStarted)
+	for _, kind := range []pgkit.AlertKind{pgkit.AlertCircuitOpen, pgkit.AlertSheddingStarted} {
+		db.Alert(ctx, pgkit.Alert{Kind: kind})
+	}
+	require.Equal(t, []pgkit.AlertKind{pgkit.AlertSheddingStarted}, kinds)
+
+	// no alerter
+	db.Alerter = nil
+	db.Alert(ctx, pgkit.Alert{Kind: pgkit.AlertCircuitOpen})
+}
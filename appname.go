@@ -0,0 +1,75 @@
+package pgkit
+
+import (
+	"context"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// maxAppNameLen is the maximum length of application_name accepted by postgres
+// (NAMEDATALEN - 1), longer values are silently truncated by the server.
+const maxAppNameLen = 63
+
+type requestMetaKey struct{}
+
+// RequestMeta describes the origin of a query, it's carried through the context and
+// used to tag the connection's application_name so activity can be segmented per
+// endpoint from the db side (ie. pg_stat_activity).
+type RequestMeta struct {
+	Service   string
+	Route     string
+	RequestID string
+}
+
+// WithRequestMeta returns a copy of ctx carrying the request metadata.
+func WithRequestMeta(ctx context.Context, meta RequestMeta) context.Context {
+	return context.WithValue(ctx, requestMetaKey{}, meta)
+}
+
+// GetRequestMeta returns the request metadata carried by ctx, if any.
+func GetRequestMeta(ctx context.Context) (RequestMeta, bool) {
+	meta, ok := ctx.Value(requestMetaKey{}).(RequestMeta)
+	return meta, ok
+}
+
+// AppName builds the application_name for the request, ie. "api:users:GET /users:abc123".
+// Empty parts are skipped and the result is truncated to the postgres limit, on a character
+// boundary.
+func (m RequestMeta) AppName(base string) string {
+	parts := make([]string, 0, 4)
+	for _, p := range []string{base, m.Service, m.Route, m.RequestID} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	name := strings.Join(parts, ":")
+	if len(name) > maxAppNameLen {
+		n := maxAppNameLen
+		for n > 0 && !utf8.RuneStart(name[n]) {
+			n--
+		}
+		name = name[:n]
+	}
+	return name
+}
+
+// contextAppName returns a pgxpool BeforeAcquire hook which sets the application_name
+// of the acquired connection from the RequestMeta carried by the context, falling back
+// to appName when there is none. The setting is only sent when it differs from the
+// value currently reported by the server.
+func contextAppName(appName string) func(context.Context, *pgx.Conn) bool {
+	return func(ctx context.Context, conn *pgx.Conn) bool {
+		name := appName
+		if meta, ok := GetRequestMeta(ctx); ok {
+			name = meta.AppName(appName)
+		}
+		if conn.PgConn().ParameterStatus("application_name") == name {
+			return true
+		}
+		_, err := conn.Exec(ctx, `SELECT set_config('application_name', $1, false)`, name)
+		// returning false destroys the connection, which is what we want if it's broken
+		return err == nil
+	}
+}
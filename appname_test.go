@@ -0,0 +1,30 @@
+package pgkit_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/goware/pgkit/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestMeta(t *testing.T) {
+	ctx := context.Background()
+	_, ok := pgkit.GetRequestMeta(ctx)
+	require.False(t, ok)
+
+	ctx = pgkit.WithRequestMeta(ctx, pgkit.RequestMeta{Service: "users", RequestID: "abc123"})
+	meta, ok := pgkit.GetRequestMeta(ctx)
+	require.True(t, ok)
+	require.Equal(t, "api:users:abc123", meta.AppName("api"))
+
+	meta.Route = strings.Repeat("x", 100)
+	require.Len(t, meta.AppName("api"), 63)
+
+	// a multi-byte character isn't split
+	meta.Route = strings.Repeat("é", 50)
+	require.Len(t, meta.AppName("api"), 62)
+	require.True(t, utf8.ValidString(meta.AppName("api")))
+}
@@ -0,0 +1,49 @@
+package pgkit
+
+import (
+	"fmt"
+	"reflect"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// AnyArray is a slice bound as a single postgres array parameter, see Any.
+type AnyArray struct {
+	Values interface{}
+}
+
+// Any wraps a slice so it's compared with `= ANY($1)` instead of being expanded into
+// an `IN ($1, $2, ...)` list, which keeps the query text stable and avoids hitting the
+// parameters limit with large lists. It can be used as a Cond value:
+//
+//	Cond{"id": pgkit.Any(ids)} // id = ANY($1)
+func Any(slice interface{}) AnyArray {
+	return AnyArray{Values: slice}
+}
+
+// Eq returns the `column = ANY(?)` predicate.
+func (a AnyArray) Eq(column string) sq.Sqlizer {
+	return anyPredicate{column: column, array: a}
+}
+
+// NotEq returns the `NOT (column = ANY(?))` predicate.
+func (a AnyArray) NotEq(column string) sq.Sqlizer {
+	return anyPredicate{column: column, array: a, not: true}
+}
+
+type anyPredicate struct {
+	column string
+	array  AnyArray
+	not    bool
+}
+
+func (p anyPredicate) ToSql() (string, []interface{}, error) {
+	v := reflect.ValueOf(p.array.Values)
+	if !v.IsValid() || (v.Kind() != reflect.Slice && v.Kind() != reflect.Array) {
+		return "", nil, fmt.Errorf("pgkit: Any expects a slice, got %T", p.array.Values)
+	}
+	if p.not {
+		return fmt.Sprintf("NOT (%s = ANY(?))", p.column), []interface{}{p.array.Values}, nil
+	}
+	return fmt.Sprintf("%s = ANY(?)", p.column), []interface{}{p.array.Values}, nil
+}
@@ -0,0 +1,25 @@
+package pgkit_test
+
+import (
+	"testing"
+
+	"github.com/goware/pgkit/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAny(t *testing.T) {
+	ids := []int64{1, 2, 3}
+
+	sql, args, err := pgkit.Cond{"id": pgkit.Any(ids), "disabled": false}.ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "(disabled = ? AND id = ANY(?))", sql)
+	require.Equal(t, []interface{}{false, ids}, args)
+
+	sql, args, err = pgkit.Any(ids).NotEq("id").ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "NOT (id = ANY(?))", sql)
+	require.Equal(t, []interface{}{ids}, args)
+
+	_, _, err = pgkit.Cond{"id": pgkit.Any(1)}.ToSql()
+	require.Error(t, err)
+}
@@ -0,0 +1,206 @@
+package pgkit
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ErrAuditViolation is returned for the statements rejected by an enforcing SQLAudit.
+var ErrAuditViolation = errors.New("pgkit: statement violates the audit rules")
+
+// SQLAudit checks every statement sent to the database against rules showing the values
+// were bound as arguments rather than concatenated to the SQL, and logs a signed line per
+// statement, ie. for compliance reviews of the data access layer. The rules are:
+//
+//   - a single statement, without a `;` followed by another one
+//   - no comments, which are often used to cut injected SQL
+//   - no string literals, the values being arguments, unless AllowLiterals
+//   - well formed quoted identifiers, as written by the quoting helpers
+//   - the placeholders matching the arguments, $1 to $n for n arguments
+//
+// Unquoted identifiers can't be told apart from concatenated ones, dynamic identifiers
+// should be quoted, ie. by the StatementBuilder.
+type SQLAudit struct {
+	// Key signs the lines with HMAC-SHA256, see Verify.
+	Key []byte
+	// Log receives the line of each statement, it's optional.
+	Log func(ctx context.Context, line string)
+	// Enforce rejects the statements breaking the rules with ErrAuditViolation, instead of
+	// only logging them.
+	Enforce bool
+	// AllowLiterals allows string literals, ie. `'{}'::jsonb`.
+	AllowLiterals bool
+}
+
+// Middleware returns the middleware auditing the statements.
+func (a SQLAudit) Middleware() Middleware {
+	return func(next Executor) Executor {
+		return auditExecutor{audit: a, next: next}
+	}
+}
+
+// Check returns the rules broken by the statement, nil when there's none.
+func (a SQLAudit) Check(sql string, args int) []string {
+	var violations []string
+	add := func(v string) {
+		for _, existing := range violations {
+			if existing == v {
+				return
+			}
+		}
+		violations = append(violations, v)
+	}
+	placeholders, ended := 0, false
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		if ended && !isSpace(c) && c != ';' {
+			add("multiple statements")
+			ended = false
+		}
+		switch {
+		case c == ';':
+			ended = true
+			i++
+		case c == '-' && i+1 < len(sql) && sql[i+1] == '-', c == '/' && i+1 < len(sql) && sql[i+1] == '*':
+			add("comment")
+			i += 2
+		case c == '\'':
+			if !a.AllowLiterals {
+				add("string literal")
+			}
+			i = skipQuoted(sql, i, '\'', false)
+		case c == '"':
+			j := skipQuoted(sql, i, '"', false)
+			if j == len(sql) && (j-i < 2 || sql[j-1] != '"') {
+				add("unterminated quoted identifier")
+			}
+			i = j
+		case c == '$' && i+1 < len(sql) && isDigit(sql[i+1]):
+			j := i + 1
+			for j < len(sql) && isDigit(sql[j]) {
+				j++
+			}
+			if n, _ := strconv.Atoi(sql[i+1 : j]); n > placeholders {
+				placeholders = n
+			}
+			i = j
+		case c == '$':
+			// dollar quoted string
+			j := i + 1
+			for j < len(sql) && isIdent(sql[j]) {
+				j++
+			}
+			if j < len(sql) && sql[j] == '$' {
+				if !a.AllowLiterals {
+					add("string literal")
+				}
+				tag := sql[i : j+1]
+				if end := strings.Index(sql[j+1:], tag); end >= 0 {
+					i = j + 1 + end + len(tag)
+				} else {
+					i = len(sql)
+				}
+				continue
+			}
+			i = j
+		case isIdent(c):
+			j := i
+			for j < len(sql) && isIdent(sql[j]) {
+				j++
+			}
+			// E'...', B'...' and X'...' string constants, only E'...' has backslash escapes
+			if j < len(sql) && sql[j] == '\'' && j-i == 1 {
+				if !a.AllowLiterals {
+					add("string literal")
+				}
+				j = skipQuoted(sql, j, '\'', c == 'E' || c == 'e')
+			}
+			i = j
+		default:
+			i++
+		}
+	}
+	if placeholders != args {
+		add(fmt.Sprintf("%d placeholders for %d arguments", placeholders, args))
+	}
+	return violations
+}
+
+// audit checks and logs the statement, returning an error when it's rejected.
+func (a SQLAudit) audit(ctx context.Context, sql string, args int) error {
+	violations := a.Check(sql, args)
+	if a.Log != nil {
+		verdict := "ok"
+		if len(violations) > 0 {
+			verdict = "violation"
+		}
+		line := fmt.Sprintf("time=%s query=%s fingerprint=%s args=%d verdict=%s violations=%s",
+			time.Now().UTC().Format(time.RFC3339Nano), strconv.Quote(GetQueryConfig(ctx).Name), Fingerprint(sql),
+			args, verdict, strconv.Quote(strings.Join(violations, "; ")))
+		a.Log(ctx, line+" sig="+a.sign(line))
+	}
+	if a.Enforce && len(violations) > 0 {
+		return fmt.Errorf("%w: %s", ErrAuditViolation, strings.Join(violations, "; "))
+	}
+	return nil
+}
+
+func (a SQLAudit) sign(line string) string {
+	mac := hmac.New(sha256.New, a.Key)
+	mac.Write([]byte(line))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify returns true when the line was logged by an audit with the same key, unchanged.
+func (a SQLAudit) Verify(line string) bool {
+	i := strings.LastIndex(line, " sig=")
+	if i < 0 {
+		return false
+	}
+	return hmac.Equal([]byte(line[i+len(" sig="):]), []byte(a.sign(line[:i])))
+}
+
+type auditExecutor struct {
+	audit SQLAudit
+	next  Executor
+}
+
+func (e auditExecutor) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	if err := e.audit.audit(ctx, sql, len(args)); err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	return e.next.Exec(ctx, sql, args...)
+}
+
+func (e auditExecutor) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	if err := e.audit.audit(ctx, sql, len(args)); err != nil {
+		return nil, err
+	}
+	return e.next.Query(ctx, sql, args...)
+}
+
+func (e auditExecutor) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	if err := e.audit.audit(ctx, sql, len(args)); err != nil {
+		return errRow{err}
+	}
+	return e.next.QueryRow(ctx, sql, args...)
+}
+
+func (e auditExecutor) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	for _, q := range b.QueuedQueries {
+		if err := e.audit.audit(ctx, q.SQL, len(q.Arguments)); err != nil {
+			return errBatchResults{err}
+		}
+	}
+	return e.next.SendBatch(ctx, b)
+}
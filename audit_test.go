@@ -0,0 +1,53 @@
+package pgkit_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/goware/pgkit/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLAudit(t *testing.T) {
+	audit := pgkit.SQLAudit{}
+	for _, tc := range []struct {
+		sql        string
+		args       int
+		violations []string
+	}{
+		{`SELECT * FROM "accounts" WHERE id = $1 AND name = $2`, 2, nil},
+		{`SELECT * FROM t WHERE id = 1;`, 0, nil},
+		{`SELECT * FROM t WHERE name = 'joe'`, 0, []string{"string literal"}},
+		{`SELECT * FROM t WHERE name = E'joe' OR name = $$x$$`, 0, []string{"string literal"}},
+		{`SELECT * FROM t WHERE id = 1; DROP TABLE t`, 0, []string{"multiple statements"}},
+		{`SELECT * FROM t WHERE path = 'C:\'; DROP TABLE t`, 0, []string{"string literal", "multiple statements"}},
+		{`SELECT * FROM t WHERE id = $1 -- AND owner = $2`, 1, []string{"comment", "2 placeholders for 1 arguments"}},
+		{`SELECT * FROM "t`, 0, []string{"unterminated quoted identifier"}},
+		{`SELECT * FROM t WHERE id = $1`, 2, []string{"1 placeholders for 2 arguments"}},
+	} {
+		require.Equal(t, tc.violations, audit.Check(tc.sql, tc.args), tc.sql)
+	}
+	require.Empty(t, pgkit.SQLAudit{AllowLiterals: true}.Check(`SELECT '{}'::jsonb`, 0))
+
+	var lines []string
+	audit = pgkit.SQLAudit{Key: []byte("secret"), Enforce: true, Log: func(ctx context.Context, line string) {
+		lines = append(lines, line)
+	}}
+	querier := pgkit.NewQuerier(sleepExecutor{}).With(audit.Middleware())
+	ctx := pgkit.WithQueryConfig(context.Background(), pgkit.QueryConfig{Name: "rename"})
+
+	_, err := querier.Exec(ctx, sq.Update("accounts").Set("name", "joe").Where(sq.Eq{"id": 1}).PlaceholderFormat(sq.Dollar))
+	require.NoError(t, err)
+	_, err = querier.Exec(ctx, sq.Expr("UPDATE accounts SET name = 'joe'"))
+	require.ErrorIs(t, err, pgkit.ErrAuditViolation)
+
+	require.Len(t, lines, 2)
+	require.Contains(t, lines[0], `query="rename"`)
+	require.Contains(t, lines[0], `verdict=ok violations=""`)
+	require.Contains(t, lines[1], `verdict=violation violations="string literal"`)
+	require.True(t, audit.Verify(lines[0]))
+	require.False(t, audit.Verify(strings.Replace(lines[1], "violation ", "ok ", 1)))
+	require.False(t, pgkit.SQLAudit{Key: []byte("other")}.Verify(lines[0]))
+}
@@ -0,0 +1,193 @@
+package pgkit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrBackfillPaused is returned by Backfill.Run when the backfill is paused.
+var ErrBackfillPaused = errors.New("pgkit: backfill paused")
+
+// Backfill processes the rows of a query in batches, ie. to repair or fill a read model,
+// checkpointing its progress in a table, so it can be paused, resumed or restarted after a
+// crash without processing a batch twice: each batch is processed in a transaction which
+// also saves the checkpoint. See BackfillColumn for the simple case of filling a column.
+//
+// The batches are read with a CursorPaginator, so the query is sorted by Sort, which should
+// end with a unique column, and the sort columns must be selected.
+type Backfill[T any] struct {
+	Name  string
+	Query sq.SelectBuilder
+	// Sort defaults to "id".
+	Sort []string
+	// BatchSize defaults to 1000.
+	BatchSize uint32
+	// Rate limits the rows processed per second, zero is unlimited.
+	Rate float64
+	// Process is called with the rows of each batch, in the transaction saving the
+	// checkpoint, an error stops the backfill and the batch is processed again next time.
+	Process func(ctx context.Context, q *Querier, rows []T) error
+	// Progress is called after each batch, it's optional.
+	Progress func(progress BackfillProgress)
+	// Table stores the checkpoints, it defaults to "pgkit_backfills", see Install.
+	Table string
+}
+
+// BackfillProgress is the progress of a backfill.
+type BackfillProgress struct {
+	Name      string    `db:"name" json:"name"`
+	Processed int64     `db:"processed" json:"processed"`
+	Paused    bool      `db:"paused" json:"paused"`
+	Done      bool      `db:"done" json:"done"`
+	UpdatedAt time.Time `db:"updated_at" json:"updatedAt"`
+}
+
+func (b Backfill[T]) table() string {
+	if b.Table == "" {
+		return "pgkit_backfills"
+	}
+	return b.Table
+}
+
+// Install creates the checkpoints table, if it doesn't exist. It can be shared by several
+// backfills.
+func (b Backfill[T]) Install(ctx context.Context, db *DB) error {
+	_, err := db.Pool().Exec(ctx, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			name TEXT PRIMARY KEY,
+			next_cursor TEXT NOT NULL DEFAULT '',
+			processed BIGINT NOT NULL DEFAULT 0,
+			paused BOOLEAN NOT NULL DEFAULT false,
+			done BOOLEAN NOT NULL DEFAULT false,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		)`, quoteIdent(b.table())))
+	return wrapErr(err)
+}
+
+// Run processes the remaining batches, from the last checkpoint, until the end of the query,
+// or until the backfill is paused, returning ErrBackfillPaused. A done backfill does nothing.
+func (b Backfill[T]) Run(ctx context.Context, db *DB) error {
+	_, err := db.Pool().Exec(ctx, fmt.Sprintf(`INSERT INTO %s (name) VALUES ($1) ON CONFLICT (name) DO NOTHING`,
+		quoteIdent(b.table())), b.Name)
+	if err != nil {
+		return wrapErr(err)
+	}
+	sort := b.Sort
+	if len(sort) == 0 {
+		sort = []string{"id"}
+	}
+	size := b.BatchSize
+	if size == 0 {
+		size = 1000
+	}
+	paginator := NewCursorPaginator[T](WithSort(sort...), WithMaxSize(size))
+	for {
+		start := time.Now()
+		progress, n, err := b.batch(ctx, db, paginator, size)
+		if err != nil {
+			return err
+		}
+		if b.Progress != nil {
+			b.Progress(progress)
+		}
+		if progress.Done {
+			return nil
+		}
+		if b.Rate <= 0 {
+			continue
+		}
+		wait := time.Duration(float64(n)/b.Rate*float64(time.Second)) - time.Since(start)
+		select {
+		case <-ctx.Done():
+			return wrapErr(ctx.Err())
+		case <-time.After(wait):
+		}
+	}
+}
+
+// batch processes the batch after the checkpoint and saves the next one.
+func (b Backfill[T]) batch(ctx context.Context, db *DB, paginator CursorPaginator[T], size uint32) (BackfillProgress, int, error) {
+	progress := BackfillProgress{Name: b.Name}
+	tx, err := db.Pool().Begin(ctx)
+	if err != nil {
+		return progress, 0, wrapErr(err)
+	}
+	defer tx.Rollback(ctx)
+
+	table := quoteIdent(b.table())
+	var cursor string
+	err = tx.QueryRow(ctx, fmt.Sprintf(`SELECT next_cursor, processed, paused, done FROM %s WHERE name = $1 FOR UPDATE`, table), b.Name).
+		Scan(&cursor, &progress.Processed, &progress.Paused, &progress.Done)
+	if err != nil {
+		return progress, 0, wrapErr(err)
+	}
+	if progress.Paused {
+		return progress, 0, ErrBackfillPaused
+	}
+	if progress.Done {
+		return progress, 0, nil
+	}
+
+	querier := db.TxQuery(tx)
+	page := &Page{Size: size, Cursor: cursor}
+	_, query, err := paginator.PrepareQueryContext(ctx, b.Query, page)
+	if err != nil {
+		return progress, 0, err
+	}
+	var rows []T
+	if err := querier.GetAll(ctx, query, &rows); err != nil {
+		return progress, 0, err
+	}
+	if rows, err = paginator.PrepareResult(rows, page); err != nil {
+		return progress, 0, err
+	}
+	if len(rows) > 0 {
+		if err := b.Process(ctx, querier, rows); err != nil {
+			return progress, 0, err
+		}
+		cursor = page.NextCursor
+	}
+	progress.Processed += int64(len(rows))
+	progress.Done = !page.More
+
+	err = tx.QueryRow(ctx, fmt.Sprintf(`UPDATE %s SET next_cursor = $2, processed = $3, done = $4, updated_at = now() WHERE name = $1 RETURNING updated_at`, table),
+		b.Name, cursor, progress.Processed, progress.Done).Scan(&progress.UpdatedAt)
+	if err != nil {
+		return progress, 0, wrapErr(err)
+	}
+	return progress, len(rows), wrapErr(tx.Commit(ctx))
+}
+
+// Pause pauses the backfill, stopping Run after the current batch.
+func (b Backfill[T]) Pause(ctx context.Context, db *DB) error {
+	return b.setPaused(ctx, db, true)
+}
+
+// Resume allows the backfill to be run again after Pause.
+func (b Backfill[T]) Resume(ctx context.Context, db *DB) error {
+	return b.setPaused(ctx, db, false)
+}
+
+func (b Backfill[T]) setPaused(ctx context.Context, db *DB, paused bool) error {
+	_, err := db.Pool().Exec(ctx, fmt.Sprintf(`
+		INSERT INTO %s (name, paused) VALUES ($1, $2)
+		ON CONFLICT (name) DO UPDATE SET paused = EXCLUDED.paused, updated_at = now()`,
+		quoteIdent(b.table())), b.Name, paused)
+	return wrapErr(err)
+}
+
+// Status returns the progress of the backfill, pgx.ErrNoRows if it never ran.
+func (b Backfill[T]) Status(ctx context.Context, db *DB) (BackfillProgress, error) {
+	rows, err := db.Pool().Query(ctx, fmt.Sprintf(`SELECT name, processed, paused, done, updated_at FROM %s WHERE name = $1`,
+		quoteIdent(b.table())), b.Name)
+	if err != nil {
+		return BackfillProgress{}, wrapErr(err)
+	}
+	progress, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[BackfillProgress])
+	return progress, wrapErr(err)
+}
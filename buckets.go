@@ -0,0 +1,44 @@
+package pgkit
+
+import (
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// TruncBuckets returns the query aggregating the rows of q by the time column truncated to
+// the unit, ie. "hour" or "day" as in date_trunc, one row per bucket sorted by its start in
+// a "bucket" column, followed by the aggregates, ie.
+//
+//	TruncBuckets(q, "created_at", "hour", Avg("latency", "avg_latency"), Percentile(0.95, "latency", "p95"))
+//
+// The rows are filtered by q, ie. with a time range, and buckets without rows are omitted.
+// The result can be paginated, sorting by "bucket" or an aggregate alias.
+func TruncBuckets(q sq.SelectBuilder, column, unit string, aggregates ...sq.Sqlizer) sq.SelectBuilder {
+	return buckets(q, sq.Expr("date_trunc("+quoteLiteral(unit)+", "+column+") AS bucket"), aggregates)
+}
+
+// WidthBuckets is like TruncBuckets, splitting the time range [from, to) in n buckets of the
+// same width, ie. to downsample a series to the points of a chart. The rows of q outside the
+// range are ignored.
+func WidthBuckets(q sq.SelectBuilder, column string, from, to time.Time, n int, aggregates ...sq.Sqlizer) sq.SelectBuilder {
+	if n < 1 {
+		n = 1
+	}
+	width := to.Sub(from) / time.Duration(n)
+	starts := make([]time.Time, n)
+	for i := range starts {
+		starts[i] = from.Add(time.Duration(i) * width)
+	}
+	q = q.Where(sq.GtOrEq{column: from}).Where(sq.Lt{column: to})
+	bucket := sq.Expr("(?::timestamptz[])[width_bucket("+column+", ?::timestamptz[])] AS bucket", starts, starts)
+	return buckets(q, bucket, aggregates)
+}
+
+func buckets(q sq.SelectBuilder, bucket sq.Sqlizer, aggregates []sq.Sqlizer) sq.SelectBuilder {
+	b := sq.Select().Column(bucket)
+	for _, a := range aggregates {
+		b = b.Column(a)
+	}
+	return b.FromSelect(removeOrderBy(q), "pgkit_buckets").GroupBy("1").OrderBy("1").PlaceholderFormat(sq.Dollar)
+}
@@ -0,0 +1,33 @@
+package pgkit_test
+
+import (
+	"testing"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/goware/pgkit/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeBuckets(t *testing.T) {
+	requests := sq.Select("created_at", "latency").From("requests").Where(sq.Eq{"path": "/"}).OrderBy("created_at")
+
+	sql, args, err := pgkit.TruncBuckets(requests, "created_at", "hour", pgkit.Count("n"), pgkit.Avg("latency", "avg"), pgkit.Percentile(0.95, "latency", "p95")).ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "SELECT date_trunc('hour', created_at) AS bucket, count(*) AS n, avg(latency) AS avg, percentile_cont($1) WITHIN GROUP (ORDER BY latency) AS p95 FROM (SELECT created_at, latency FROM requests WHERE path = $2) AS pgkit_buckets GROUP BY 1 ORDER BY 1", sql)
+	require.Equal(t, []interface{}{0.95, "/"}, args)
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	q := pgkit.WidthBuckets(requests, "created_at", from, from.Add(time.Hour), 4, pgkit.Min("latency", "min"), pgkit.Max("latency", "max"))
+	sql, args, err = q.ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "SELECT ($1::timestamptz[])[width_bucket(created_at, $2::timestamptz[])] AS bucket, min(latency) AS min, max(latency) AS max FROM (SELECT created_at, latency FROM requests WHERE path = $3 AND created_at >= $4 AND created_at < $5) AS pgkit_buckets GROUP BY 1 ORDER BY 1", sql)
+	starts := []time.Time{from, from.Add(15 * time.Minute), from.Add(30 * time.Minute), from.Add(45 * time.Minute)}
+	require.Equal(t, []interface{}{starts, starts, "/", from, from.Add(time.Hour)}, args)
+
+	// the buckets can be paginated
+	_, q = pgkit.NewPaginator[T](pgkit.WithSort("-bucket")).PrepareQuery(pgkit.TruncBuckets(requests, "created_at", "day", pgkit.Sum("latency", "total")), nil)
+	sql, _, err = q.ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "SELECT * FROM (SELECT date_trunc('day', created_at) AS bucket, sum(latency) AS total FROM (SELECT created_at, latency FROM requests WHERE path = $1) AS pgkit_buckets GROUP BY 1) AS pgkit_page ORDER BY bucket DESC LIMIT 11 OFFSET 0", sql)
+}
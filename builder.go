@@ -9,10 +9,18 @@ import (
 
 type StatementBuilder struct {
 	sq.StatementBuilderType
+
+	// searchPath and qualifyTables resolve the table names, see WithSearchPath.
+	searchPath    []string
+	qualifyTables bool
+}
+
+func newStatementBuilder() *StatementBuilder {
+	return &StatementBuilder{StatementBuilderType: sq.StatementBuilder.PlaceholderFormat(sq.Dollar)}
 }
 
 func (s *StatementBuilder) InsertRecord(record interface{}, optTableName ...string) InsertBuilder {
-	tableName := getTableName(record, optTableName...)
+	tableName := s.Table(getTableName(record, optTableName...))
 	insert := sq.InsertBuilder(s.StatementBuilderType)
 
 	cols, vals, err := Map(record)
@@ -60,7 +68,7 @@ func (s StatementBuilder) InsertRecords(recordsSlice interface{}, optTableName .
 		}
 	}
 
-	return InsertBuilder{InsertBuilder: insert.Into(tableName)}
+	return InsertBuilder{InsertBuilder: insert.Into(s.Table(tableName))}
 }
 
 func (s StatementBuilder) UpdateRecord(record interface{}, whereExpr sq.Eq, optTableName ...string) UpdateBuilder {
@@ -68,7 +76,7 @@ func (s StatementBuilder) UpdateRecord(record interface{}, whereExpr sq.Eq, optT
 }
 
 func (s StatementBuilder) UpdateRecordColumns(record interface{}, whereExpr sq.Eq, filterCols []string, optTableName ...string) UpdateBuilder {
-	tableName := getTableName(record, optTableName...)
+	tableName := s.Table(getTableName(record, optTableName...))
 	update := sq.UpdateBuilder(s.StatementBuilderType)
 
 	cols, vals, err := Map(record)
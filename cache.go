@@ -0,0 +1,184 @@
+package pgkit
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Cache stores the pages of the paginators created with WithCache, ie. a MemoryCache or an
+// adapter to Redis or memcached. Get returns false when the key isn't found or expired.
+type Cache interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// WithCache caches the pages returned by Paginator.Query for ttl, keyed by the SQL and the
+// arguments of the paginated query, so the same page of the same query is served from the
+// cache, with its metadata, ie. More and Total. The rows are stored in JSON, so T must
+// survive a round trip. The cache is an optimization, its errors are ignored and the query
+// is run. A page can skip the cache with Page.NoCache.
+func WithCache(cache Cache, ttl time.Duration) func(*PaginatorOption) {
+	return func(o *PaginatorOption) { o.cache, o.cacheTTL = cache, ttl }
+}
+
+// cachedPage is the value stored in the cache for a page.
+type cachedPage[T any] struct {
+	Rows []T  `json:"rows"`
+	Page Page `json:"page"`
+}
+
+// cacheKey returns the key of the page of query.
+func cacheKey(query Sqlizer) (string, error) {
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return "", wrapErr(err)
+	}
+	h := sha256.New()
+	writeKeyPart(h, "sql", []byte(sql))
+	for _, arg := range args {
+		tag, value, err := cacheArg(arg)
+		if err != nil {
+			return "", fmt.Errorf("pgkit: argument can't be cached: %w", err)
+		}
+		writeKeyPart(h, tag, value)
+	}
+	return "pgkit:page:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// writeKeyPart writes a tagged part of a key, length prefixed so the parts of two keys
+// can't be confused, ie. the arguments "x y", "z" and "x", "y z".
+func writeKeyPart(w io.Writer, tag string, value []byte) {
+	fmt.Fprintf(w, "%d:%s%d:", len(tag), tag, len(value))
+	w.Write(value)
+}
+
+// cacheArg returns the type and the canonical encoding of an argument of a key: the pointers
+// are dereferenced, the driver.Valuers replaced by their value, and the rest is JSON.
+func cacheArg(arg interface{}) (string, []byte, error) {
+	for i := 0; i < 8; i++ {
+		v := reflect.ValueOf(arg)
+		if arg == nil || v.Kind() == reflect.Pointer && v.IsNil() {
+			return "nil", nil, nil
+		}
+		if valuer, ok := arg.(driver.Valuer); ok {
+			value, err := valuer.Value()
+			if err != nil {
+				return "", nil, err
+			}
+			arg = value
+			continue
+		}
+		if v.Kind() != reflect.Pointer {
+			break
+		}
+		arg = v.Elem().Interface()
+	}
+	data, err := json.Marshal(arg)
+	if err != nil {
+		return "", nil, err
+	}
+	return fmt.Sprintf("%T", arg), data, nil
+}
+
+// getCached reads the page of key from the cache, setting page and returning the rows.
+func (p Paginator[T]) getCached(ctx context.Context, key string, page *Page) ([]T, bool) {
+	data, ok, err := p.cache.Get(ctx, key)
+	if err != nil || !ok {
+		return nil, false
+	}
+	var cached cachedPage[T]
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return nil, false
+	}
+	*page = cached.Page
+	return cached.Rows, true
+}
+
+// setCached stores the rows of page in the cache.
+func (p Paginator[T]) setCached(ctx context.Context, key string, result []T, page *Page) {
+	data, err := json.Marshal(cachedPage[T]{Rows: result, Page: *page})
+	if err != nil {
+		return
+	}
+	_ = p.cache.Set(ctx, key, data, p.cacheTTL)
+}
+
+// MemoryCache is an in-memory Cache, which evicts the least recently used entries once it
+// holds its max number of entries. It's safe for concurrent use.
+type MemoryCache struct {
+	max int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+type memoryCacheEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// NewMemoryCache creates a MemoryCache holding up to max entries.
+func NewMemoryCache(max int) *MemoryCache {
+	if max < 1 {
+		max = 1
+	}
+	return &MemoryCache{max: max, entries: make(map[string]*list.Element), lru: list.New()}
+}
+
+// Get implements Cache.
+func (c *MemoryCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	entry := el.Value.(*memoryCacheEntry)
+	if !entry.expires.IsZero() && time.Now().After(entry.expires) {
+		c.lru.Remove(el)
+		delete(c.entries, key)
+		return nil, false, nil
+	}
+	c.lru.MoveToFront(el)
+	return entry.value, true, nil
+}
+
+// Set implements Cache, a zero ttl never expires.
+func (c *MemoryCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := &memoryCacheEntry{key: key, value: value}
+	if ttl > 0 {
+		entry.expires = time.Now().Add(ttl)
+	}
+	if el, ok := c.entries[key]; ok {
+		el.Value = entry
+		c.lru.MoveToFront(el)
+		return nil
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.max {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*memoryCacheEntry).key)
+	}
+	return nil
+}
+
+// Len returns the number of entries, including the expired ones not evicted yet.
+func (c *MemoryCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
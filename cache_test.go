@@ -0,0 +1,83 @@
+package pgkit_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/goware/pgkit/v2"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryCache(t *testing.T) {
+	ctx := context.Background()
+	cache := pgkit.NewMemoryCache(2)
+
+	require.NoError(t, cache.Set(ctx, "a", []byte("1"), 0))
+	require.NoError(t, cache.Set(ctx, "b", []byte("2"), 0))
+	value, ok, err := cache.Get(ctx, "a")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, []byte("1"), value)
+
+	// b is the least recently used
+	require.NoError(t, cache.Set(ctx, "c", []byte("3"), 0))
+	require.Equal(t, 2, cache.Len())
+	_, ok, _ = cache.Get(ctx, "b")
+	require.False(t, ok)
+	_, ok, _ = cache.Get(ctx, "a")
+	require.True(t, ok)
+
+	require.NoError(t, cache.Set(ctx, "a", []byte("4"), time.Millisecond))
+	time.Sleep(5 * time.Millisecond)
+	_, ok, _ = cache.Get(ctx, "a")
+	require.False(t, ok)
+	require.Equal(t, 1, cache.Len())
+}
+
+// keysCache records the keys read by the paginator.
+type keysCache struct {
+	keys []string
+}
+
+func (c *keysCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	c.keys = append(c.keys, key)
+	return nil, false, nil
+}
+
+func (c *keysCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return nil
+}
+
+// failExecutor fails the queries, after the cache is read.
+type failExecutor struct{ sleepExecutor }
+
+func (failExecutor) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	return nil, errors.New("no database")
+}
+
+func TestCacheKey(t *testing.T) {
+	cache := &keysCache{}
+	paginator := pgkit.NewPaginator[T](pgkit.WithSort("id"), pgkit.WithCache(cache, time.Minute))
+	key := func(args ...interface{}) string {
+		cache.keys = nil
+		paginator.Query(context.Background(), sq.Select("*").From("t").Where("a = ? AND b = ?", args...), nil, failExecutor{})
+		require.Len(t, cache.keys, 1)
+		return cache.keys[0]
+	}
+
+	require.Equal(t, key("x", "y"), key("x", "y"))
+	require.NotEqual(t, key("x y", "z"), key("x", "y z"))
+	require.NotEqual(t, key(1, "1"), key("1", 1))
+	require.NotEqual(t, key(nil, "x"), key("", "x"))
+
+	// pointers are hashed by value
+	a, b := "x", "x"
+	require.Equal(t, key(&a, 1), key(&b, 1))
+	require.Equal(t, key(&a, 1), key("x", 1))
+	var nilString *string
+	require.Equal(t, key(nilString, 1), key(nil, 1))
+}
@@ -0,0 +1,123 @@
+package pgkit
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// CreateDatabase creates the database name. When template is given, the new database is
+// cloned from it (`CREATE DATABASE ... TEMPLATE`), which is much faster than replaying a
+// schema, note postgres requires the template to have no other active connections.
+func CreateDatabase(ctx context.Context, db *DB, name string, template string) error {
+	query := "CREATE DATABASE " + quoteIdent(name)
+	if template != "" {
+		query += " TEMPLATE " + quoteIdent(template)
+	}
+	_, err := db.Pool().Exec(ctx, query)
+	return wrapErr(err)
+}
+
+// DropDatabase drops the database name if it exists. With force, the connections to it
+// are terminated first (postgres 13+).
+func DropDatabase(ctx context.Context, db *DB, name string, force bool) error {
+	query := "DROP DATABASE IF EXISTS " + quoteIdent(name)
+	if force {
+		query += " WITH (FORCE)"
+	}
+	_, err := db.Pool().Exec(ctx, query)
+	return wrapErr(err)
+}
+
+// DatabaseExists reports whether the database name exists.
+func DatabaseExists(ctx context.Context, db *DB, name string) (bool, error) {
+	var exists bool
+	err := db.Pool().QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM pg_database WHERE datname = $1)`, name).Scan(&exists)
+	return exists, wrapErr(err)
+}
+
+// TableInfo describes a table or a view, see Tables.
+type TableInfo struct {
+	Schema  string       `json:"schema"`
+	Name    string       `json:"name"`
+	Columns []ColumnInfo `json:"columns"`
+}
+
+// ColumnInfo describes a column, Type is its data type as in information_schema, ie.
+// "integer", "timestamp with time zone", "ARRAY" or "USER-DEFINED" for an enum.
+type ColumnInfo struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Nullable bool   `json:"nullable"`
+	// SQLType is the type as declared, ie. "integer[]", "character varying(64)" or the name
+	// of an enum, and Element the data type of the elements of an array, ie. "integer".
+	SQLType string `json:"sqlType,omitempty"`
+	Element string `json:"element,omitempty"`
+	// Enum is true for an enum, or an array of one.
+	Enum bool `json:"enum,omitempty"`
+}
+
+// _FilterTypes are the filter types of the column data types.
+var _FilterTypes = map[string]FilterType{
+	"smallint":                    FilterInt,
+	"integer":                     FilterInt,
+	"bigint":                      FilterInt,
+	"numeric":                     FilterDecimal,
+	"real":                        FilterDecimal,
+	"double precision":            FilterDecimal,
+	"boolean":                     FilterBool,
+	"uuid":                        FilterUUID,
+	"date":                        FilterTime,
+	"timestamp with time zone":    FilterTime,
+	"timestamp without time zone": FilterTime,
+	"text":                        FilterString,
+	"character varying":           FilterString,
+	"character":                   FilterString,
+}
+
+// FilterType returns the type of the filters on the column, false when its data type can't
+// be filtered on, see FilterSchema.
+func (c ColumnInfo) FilterType() (FilterType, bool) {
+	t, ok := _FilterTypes[c.Type]
+	return t, ok
+}
+
+// String returns the quoted name of the table, qualified by its schema.
+func (t TableInfo) String() string {
+	return pgx.Identifier{t.Schema, t.Name}.Sanitize()
+}
+
+// Tables returns the tables and views of the schemas the current user can access, all but
+// the system ones when none is given, sorted by schema and name, with their columns in order.
+func Tables(ctx context.Context, db *DB, schemas ...string) ([]TableInfo, error) {
+	rows, err := db.Pool().Query(ctx, `
+		SELECT c.table_schema, c.table_name, c.column_name, c.data_type, c.is_nullable = 'YES',
+			format_type(a.atttypid, a.atttypmod), coalesce(format_type(el.oid, NULL), ''), coalesce(el.typtype, ty.typtype) = 'e'
+		FROM information_schema.columns c
+		JOIN information_schema.tables t ON t.table_schema = c.table_schema AND t.table_name = c.table_name
+		JOIN pg_attribute a ON a.attrelid = (quote_ident(c.table_schema) || '.' || quote_ident(c.table_name))::regclass AND a.attname = c.column_name
+		JOIN pg_type ty ON ty.oid = a.atttypid
+		LEFT JOIN pg_type el ON el.oid = ty.typelem AND ty.typcategory = 'A'
+		WHERE c.table_schema NOT IN ('pg_catalog', 'information_schema') AND ($1::text[] IS NULL OR c.table_schema = ANY($1))
+		ORDER BY c.table_schema, c.table_name, c.ordinal_position`, schemas)
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	defer rows.Close()
+
+	var tables []TableInfo
+	for rows.Next() {
+		var schema, table string
+		var column ColumnInfo
+		if err := rows.Scan(&schema, &table, &column.Name, &column.Type, &column.Nullable,
+			&column.SQLType, &column.Element, &column.Enum); err != nil {
+			return nil, wrapErr(err)
+		}
+		if n := len(tables); n == 0 || tables[n-1].Schema != schema || tables[n-1].Name != table {
+			tables = append(tables, TableInfo{Schema: schema, Name: table})
+		}
+		last := &tables[len(tables)-1]
+		last.Columns = append(last.Columns, column)
+	}
+	return tables, wrapErr(rows.Err())
+}
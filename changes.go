@@ -0,0 +1,290 @@
+package pgkit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/goware/pgkit/v2/internal/reflectx"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// RowDiff is the payload of a change notification sent by the trigger of a ChangeFeed. It
+// only holds the changed columns: all the non NULL ones of an inserted or deleted row, the
+// ones which changed, with their old and new values, for an updated row. The values are in
+// the text format of their types, nil for NULL.
+type RowDiff struct {
+	Table string `json:"table"`
+	// Op is "INSERT", "UPDATE" or "DELETE".
+	Op  string             `json:"op"`
+	Key map[string]*string `json:"key"`
+	New map[string]*string `json:"new,omitempty"`
+	Old map[string]*string `json:"old,omitempty"`
+	// Truncated is set when the diff didn't fit a notification, only the key is sent.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// ChangeFeed notifies the changes of tables to the application, ie. to invalidate a cache or
+// update a read model: the triggers installed by Install send the RowDiff of each change on
+// a channel, and Listen decodes them for the handlers registered with OnChange. As with any
+// LISTEN, the changes happening while nobody listens are lost.
+type ChangeFeed struct {
+	// Channel defaults to "pgkit_changes".
+	Channel string
+
+	mu       sync.Mutex
+	handlers map[string][]func(diff RowDiff, columns map[string]uint32, typeMap *pgtype.Map) error
+}
+
+func (f *ChangeFeed) channel() string {
+	if f.Channel == "" {
+		return "pgkit_changes"
+	}
+	return f.Channel
+}
+
+// Install creates, or replaces, the trigger of the table sending its changes. The columns
+// are listed in the trigger, so it must be installed again when they change. The table must
+// have a primary key, which identifies the rows in the diffs.
+func (f *ChangeFeed) Install(ctx context.Context, db *DB, table string) error {
+	// the table is resolved once, to its canonical name, so the trigger is created on the
+	// table whose columns are listed
+	rows, err := db.Pool().Query(ctx, `
+		SELECT a.attrelid::regclass::text, a.attname, coalesce(a.attnum = ANY(i.indkey), false)
+		FROM pg_attribute a
+		LEFT JOIN pg_index i ON i.indrelid = a.attrelid AND i.indisprimary
+		WHERE a.attrelid = $1::regclass AND a.attnum > 0 AND NOT a.attisdropped
+		ORDER BY a.attnum`, quoteIdent(table))
+	if err != nil {
+		return wrapErr(err)
+	}
+	defer rows.Close()
+	var ident string
+	var columns, key []string
+	for rows.Next() {
+		var (
+			name    string
+			primary bool
+		)
+		if err := rows.Scan(&ident, &name, &primary); err != nil {
+			return wrapErr(err)
+		}
+		columns = append(columns, name)
+		if primary {
+			key = append(key, name)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return wrapErr(err)
+	}
+	if len(key) == 0 {
+		return fmt.Errorf("pgkit: table %q has no primary key", table)
+	}
+
+	object := func(record string, columns []string) string {
+		// the functions take up to 100 arguments
+		var objects []string
+		for len(columns) > 0 {
+			n := len(columns)
+			if n > 50 {
+				n = 50
+			}
+			pairs := make([]string, n)
+			for i, c := range columns[:n] {
+				pairs[i] = fmt.Sprintf("%s, %s.%s::text", quoteLiteral(c), record, quoteIdent(c))
+			}
+			objects = append(objects, "jsonb_build_object("+strings.Join(pairs, ", ")+")")
+			columns = columns[n:]
+		}
+		return strings.Join(objects, " || ")
+	}
+	name := quoteIdent(strings.ReplaceAll("pgkit_changes_"+table, ".", "_"))
+	_, err = db.Pool().Exec(ctx, fmt.Sprintf(`
+		CREATE OR REPLACE FUNCTION %[1]s() RETURNS trigger AS $$
+		DECLARE
+			n jsonb;
+			o jsonb;
+			payload jsonb;
+		BEGIN
+			IF TG_OP = 'INSERT' THEN
+				n := jsonb_strip_nulls(%[2]s);
+				payload := jsonb_build_object('key', %[4]s, 'new', n);
+			ELSIF TG_OP = 'DELETE' THEN
+				o := jsonb_strip_nulls(%[3]s);
+				payload := jsonb_build_object('key', %[5]s, 'old', o);
+			ELSE
+				n := %[2]s;
+				o := %[3]s;
+				SELECT jsonb_object_agg(x.key, x.value), jsonb_object_agg(x.key, o -> x.key) INTO n, o
+				FROM jsonb_each(n) x WHERE x.value IS DISTINCT FROM o -> x.key;
+				IF n IS NULL THEN
+					RETURN NULL;
+				END IF;
+				payload := jsonb_build_object('key', %[4]s, 'new', n, 'old', o);
+			END IF;
+			payload := payload || jsonb_build_object('table', %[6]s, 'op', TG_OP);
+			IF octet_length(payload::text) > 7900 THEN
+				payload := payload - 'new' - 'old' || '{"truncated": true}';
+			END IF;
+			PERFORM pg_notify(%[7]s, payload::text);
+			RETURN NULL;
+		END
+		$$ LANGUAGE plpgsql;
+		DROP TRIGGER IF EXISTS pgkit_changes ON %[8]s;
+		CREATE TRIGGER pgkit_changes AFTER INSERT OR UPDATE OR DELETE ON %[8]s
+		FOR EACH ROW EXECUTE FUNCTION %[1]s();`,
+		name, object("NEW", columns), object("OLD", columns), object("NEW", key), object("OLD", key),
+		quoteLiteral(table), quoteLiteral(f.channel()), ident))
+	return wrapErr(err)
+}
+
+// Uninstall removes the trigger of the table.
+func (f *ChangeFeed) Uninstall(ctx context.Context, db *DB, table string) error {
+	_, err := db.Pool().Exec(ctx, fmt.Sprintf(`
+		DROP TRIGGER IF EXISTS pgkit_changes ON %s;
+		DROP FUNCTION IF EXISTS %s();`, quoteIdent(table), quoteIdent(strings.ReplaceAll("pgkit_changes_"+table, ".", "_"))))
+	return wrapErr(err)
+}
+
+// OnChange registers fn to be called by Listen with the changes of the table, as named in
+// Install, decoded into T with the type map of the connection, so the registered types are
+// supported, see RegisterEnum. The columns are mapped to the fields of T as in Scan.
+func OnChange[T any](f *ChangeFeed, table string, fn func(change Change[T])) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.handlers == nil {
+		f.handlers = map[string][]func(RowDiff, map[string]uint32, *pgtype.Map) error{}
+	}
+	f.handlers[table] = append(f.handlers[table], func(diff RowDiff, columns map[string]uint32, typeMap *pgtype.Map) error {
+		change := Change[T]{Op: diff.Op, Truncated: diff.Truncated}
+		if diff.Op != "INSERT" {
+			change.Old = new(T)
+			if err := decodeDiff(change.Old, diff.Key, diff.Old, columns, typeMap); err != nil {
+				return err
+			}
+		}
+		if diff.Op != "DELETE" {
+			change.New = new(T)
+			if err := decodeDiff(change.New, diff.Key, diff.New, columns, typeMap); err != nil {
+				return err
+			}
+		}
+		fn(change)
+		return nil
+	})
+}
+
+// Change is a change of a row, decoded from its RowDiff by OnChange. Old is nil for an insert,
+// and New for a delete. As diffs only hold the changed columns, the other fields of the rows
+// are zero, but the primary key, which is always set.
+type Change[T any] struct {
+	// Op is "INSERT", "UPDATE" or "DELETE".
+	Op       string
+	Old, New *T
+	// Truncated is set when the diff didn't fit a notification: only the primary key of the
+	// rows is set, the changed columns are unknown and should be read again.
+	Truncated bool
+}
+
+// decodeDiff sets the fields of dst, a pointer to a struct, from the values of the diff.
+func decodeDiff(dst interface{}, key, values map[string]*string, columns map[string]uint32, typeMap *pgtype.Map) error {
+	v := reflect.ValueOf(dst).Elem()
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("pgkit: expecting a struct to decode the changes, got %T", dst)
+	}
+	fields := Mapper.TypeMap(v.Type()).Names
+	for _, values := range []map[string]*string{key, values} {
+		for column, value := range values {
+			field, ok := fields[column]
+			if !ok || value == nil {
+				continue
+			}
+			oid, ok := columns[column]
+			if !ok {
+				return fmt.Errorf("pgkit: unknown column %q", column)
+			}
+			target := reflectx.FieldByIndexes(v, field.Index).Addr().Interface()
+			if err := typeMap.Scan(oid, pgx.TextFormatCode, []byte(*value), target); err != nil {
+				return fmt.Errorf("pgkit: decoding column %q: %w", column, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Listen listens to the channel on a connection of db, and calls the handlers of the tables
+// with their changes, until ctx is done or the connection fails. The handlers are called one
+// at a time, in the order of the changes. The diffs of the tables without handler, or which
+// can't be decoded, are passed to onError, if any, which stops Listen by returning an error.
+func (f *ChangeFeed) Listen(ctx context.Context, db *DB, onError func(diff RowDiff, err error) error) error {
+	conn, err := db.Pool().Acquire(ctx)
+	if err != nil {
+		return wrapErr(err)
+	}
+	defer conn.Release()
+	if _, err := conn.Exec(ctx, "LISTEN "+quoteIdent(f.channel())); err != nil {
+		return wrapErr(err)
+	}
+	defer conn.Exec(context.Background(), "UNLISTEN "+quoteIdent(f.channel()))
+
+	// the types of the columns by table, loaded on the first change
+	types := map[string]map[string]uint32{}
+	for {
+		n, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return wrapErr(err)
+		}
+		var diff RowDiff
+		err = json.Unmarshal([]byte(n.Payload), &diff)
+		if err == nil {
+			err = f.dispatch(ctx, conn.Conn(), diff, types)
+		}
+		if err != nil && onError != nil {
+			if err := onError(diff, err); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (f *ChangeFeed) dispatch(ctx context.Context, conn *pgx.Conn, diff RowDiff, types map[string]map[string]uint32) error {
+	f.mu.Lock()
+	handlers := f.handlers[diff.Table]
+	f.mu.Unlock()
+	if len(handlers) == 0 {
+		return fmt.Errorf("pgkit: no handler for the changes of %q", diff.Table)
+	}
+	columns, ok := types[diff.Table]
+	if !ok {
+		rows, err := conn.Query(ctx, `SELECT attname, atttypid FROM pg_attribute WHERE attrelid = $1::regclass AND attnum > 0 AND NOT attisdropped`, quoteIdent(diff.Table))
+		if err != nil {
+			return wrapErr(err)
+		}
+		defer rows.Close()
+		columns = map[string]uint32{}
+		for rows.Next() {
+			var (
+				name string
+				oid  uint32
+			)
+			if err := rows.Scan(&name, &oid); err != nil {
+				return wrapErr(err)
+			}
+			columns[name] = oid
+		}
+		if err := rows.Err(); err != nil {
+			return wrapErr(err)
+		}
+		types[diff.Table] = columns
+	}
+	for _, handler := range handlers {
+		if err := handler(diff, columns, conn.TypeMap()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"text/tabwriter"
+	"time"
+
+	"github.com/goware/pgkit/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBench(t *testing.T) {
+	ctx := context.Background()
+	db, err := pgkit.Connect("pgkitbench", pgkit.Config{
+		Database: "pgkit_test",
+		Host:     "localhost",
+		Username: "postgres",
+		Password: "postgres",
+	})
+	require.NoError(t, err)
+	defer db.Conn.Close()
+
+	require.NoError(t, seedTable(ctx, db, 95))
+	t.Cleanup(func() { db.Conn.Exec(ctx, `DROP TABLE `+tableName) })
+
+	for name, bench := range map[string]func(context.Context, *pgkit.DB, int, int) ([]time.Duration, error){
+		"offset": benchOffset,
+		"keyset": benchKeyset,
+		"cursor": benchCursor,
+	} {
+		// 95 rows are walked in 10 pages, the last one partial
+		samples, err := bench(ctx, db, 10, 100)
+		require.NoError(t, err, name)
+		assert.Len(t, samples, 10, name)
+
+		samples, err = bench(ctx, db, 10, 3)
+		require.NoError(t, err, name)
+		assert.Len(t, samples, 3, name)
+	}
+
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	report(w, "offset", []time.Duration{3 * time.Millisecond, time.Millisecond, 2 * time.Millisecond})
+	report(w, "keyset", nil)
+	w.Flush()
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.Equal(t, []string{"offset", "3", "2ms", "2ms", "2ms", "3ms", "6ms"}, strings.Fields(lines[0]))
+	assert.Equal(t, []string{"keyset", "0", "-", "-", "-", "-", "-"}, strings.Fields(lines[1]))
+}
@@ -0,0 +1,139 @@
+package pgkit
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// PageCodec converts pages to and from a JSON wire format, see WithCodec.
+type PageCodec interface {
+	Marshal(page *Page) ([]byte, error)
+	Unmarshal(data []byte, page *Page) error
+}
+
+// The built-in codecs, the sort is a list of Sort in all of them.
+var (
+	// LimitOffsetCodec uses `{"limit": 25, "offset": 50}`, the offset must be a multiple of
+	// the limit.
+	LimitOffsetCodec PageCodec = limitOffsetCodec{}
+	// PageNumberCodec uses `{"page": 3, "per_page": 25}`.
+	PageNumberCodec PageCodec = pageNumberCodec{}
+	// CursorCodec uses `{"cursor": "...", "limit": 25}`, with a "next_cursor" once the page
+	// is prepared, for a CursorPaginator.
+	CursorCodec PageCodec = cursorCodec{}
+)
+
+// WithCodec sets the wire format of the pages read and written by Paginator.UnmarshalPage
+// and MarshalPage, which default to the JSON encoding of Page.
+func WithCodec(codec PageCodec) func(*PaginatorOption) {
+	return func(o *PaginatorOption) { o.codec = codec }
+}
+
+// MarshalPage encodes the page with the codec of the paginator.
+func (p Paginator[T]) MarshalPage(page *Page) ([]byte, error) {
+	if p.codec == nil {
+		return json.Marshal(page)
+	}
+	return p.codec.Marshal(page)
+}
+
+// UnmarshalPage decodes a page with the codec of the paginator.
+func (p Paginator[T]) UnmarshalPage(data []byte) (*Page, error) {
+	page := &Page{}
+	var err error
+	if p.codec == nil {
+		err = json.Unmarshal(data, page)
+	} else {
+		err = p.codec.Unmarshal(data, page)
+	}
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	return page, nil
+}
+
+type limitOffsetPage struct {
+	Limit  uint64 `json:"limit"`
+	Offset uint64 `json:"offset"`
+	More   bool   `json:"more,omitempty"`
+	Sort   []Sort `json:"sort,omitempty"`
+	Total  uint64 `json:"total,omitempty"`
+}
+
+type limitOffsetCodec struct{}
+
+func (limitOffsetCodec) Marshal(page *Page) ([]byte, error) {
+	return json.Marshal(limitOffsetPage{Limit: page.size(), Offset: page.offset(page.size()), More: page.More, Sort: page.GetOrder(), Total: page.Total})
+}
+
+func (limitOffsetCodec) Unmarshal(data []byte, page *Page) error {
+	var v limitOffsetPage
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if v.Limit == 0 {
+		v.Limit = DefaultPageSize
+	}
+	if v.Offset%v.Limit != 0 || v.Limit > 1<<32-1 || v.Offset/v.Limit >= 1<<32-1 {
+		return fmt.Errorf("invalid offset %d, expecting a multiple of the limit %d", v.Offset, v.Limit)
+	}
+	*page = Page{Size: uint32(v.Limit), Page: uint32(v.Offset/v.Limit) + 1, More: v.More, Order: v.Sort}
+	if v.Total != 0 {
+		page.setTotal(v.Total, page.size())
+	}
+	return nil
+}
+
+type pageNumberPage struct {
+	Page       uint32 `json:"page"`
+	PerPage    uint32 `json:"per_page"`
+	More       bool   `json:"more,omitempty"`
+	Sort       []Sort `json:"sort,omitempty"`
+	Total      uint64 `json:"total,omitempty"`
+	TotalPages uint32 `json:"total_pages,omitempty"`
+}
+
+type pageNumberCodec struct{}
+
+func (pageNumberCodec) Marshal(page *Page) ([]byte, error) {
+	n := page.Page
+	if n == 0 {
+		n = 1
+	}
+	return json.Marshal(pageNumberPage{Page: n, PerPage: uint32(page.size()), More: page.More, Sort: page.GetOrder(), Total: page.Total, TotalPages: page.TotalPages})
+}
+
+func (pageNumberCodec) Unmarshal(data []byte, page *Page) error {
+	var v pageNumberPage
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*page = Page{Size: v.PerPage, Page: v.Page, More: v.More, Order: v.Sort}
+	if v.Total != 0 {
+		page.setTotal(v.Total, page.size())
+	}
+	return nil
+}
+
+type cursorPage struct {
+	Cursor     string `json:"cursor,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Limit      uint32 `json:"limit"`
+	More       bool   `json:"more,omitempty"`
+	Sort       []Sort `json:"sort,omitempty"`
+}
+
+type cursorCodec struct{}
+
+func (cursorCodec) Marshal(page *Page) ([]byte, error) {
+	return json.Marshal(cursorPage{Cursor: page.Cursor, NextCursor: page.NextCursor, Limit: uint32(page.size()), More: page.More, Sort: page.GetOrder()})
+}
+
+func (cursorCodec) Unmarshal(data []byte, page *Page) error {
+	var v cursorPage
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*page = Page{Size: v.Limit, Page: 1, Cursor: v.Cursor, NextCursor: v.NextCursor, More: v.More, Order: v.Sort}
+	return nil
+}
@@ -0,0 +1,55 @@
+package pgkit_test
+
+import (
+	"testing"
+
+	"github.com/goware/pgkit/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPageCodec(t *testing.T) {
+	sort := []pgkit.Sort{{Column: "created_at", Order: pgkit.Desc}}
+	page := &pgkit.Page{Page: 3, Size: 25, More: true, Order: sort}
+	page.SetTotal(120)
+
+	for _, tc := range []struct {
+		codec    pgkit.PageCodec
+		expected string
+	}{
+		{pgkit.LimitOffsetCodec, `{"limit":25,"offset":50,"more":true,"sort":[{"column":"created_at","order":"DESC"}],"total":120}`},
+		{pgkit.PageNumberCodec, `{"page":3,"per_page":25,"more":true,"sort":[{"column":"created_at","order":"DESC"}],"total":120,"total_pages":5}`},
+	} {
+		paginator := pgkit.NewPaginator[T](pgkit.WithCodec(tc.codec))
+		data, err := paginator.MarshalPage(page)
+		require.NoError(t, err)
+		require.JSONEq(t, tc.expected, string(data))
+
+		decoded, err := paginator.UnmarshalPage(data)
+		require.NoError(t, err)
+		require.Equal(t, page, decoded)
+	}
+
+	paginator := pgkit.NewPaginator[T](pgkit.WithCodec(pgkit.CursorCodec))
+	data, err := paginator.MarshalPage(&pgkit.Page{Page: 1, Size: 10, More: true, Cursor: "a", NextCursor: "b"})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"cursor":"a","next_cursor":"b","limit":10,"more":true}`, string(data))
+	decoded, err := paginator.UnmarshalPage([]byte(`{"cursor":"b","limit":10}`))
+	require.NoError(t, err)
+	require.Equal(t, &pgkit.Page{Page: 1, Size: 10, Cursor: "b"}, decoded)
+
+	// the default codec is the JSON encoding of Page
+	decoded, err = pgkit.NewPaginator[T]().UnmarshalPage([]byte(`{"page":2,"size":5}`))
+	require.NoError(t, err)
+	require.Equal(t, &pgkit.Page{Page: 2, Size: 5}, decoded)
+
+	// a page sized by a paginator above the max page size, see WithMaxSize
+	paginator = pgkit.NewPaginator[T](pgkit.WithCodec(pgkit.LimitOffsetCodec))
+	data, err = paginator.MarshalPage(&pgkit.Page{Page: 2, Size: 100})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"limit":100,"offset":100}`, string(data))
+
+	_, err = pgkit.NewPaginator[T](pgkit.WithCodec(pgkit.LimitOffsetCodec)).UnmarshalPage([]byte(`{"limit":25,"offset":30}`))
+	require.Error(t, err)
+	_, err = pgkit.NewPaginator[T](pgkit.WithCodec(pgkit.PageNumberCodec)).UnmarshalPage([]byte(`{"page":"x"}`))
+	require.Error(t, err)
+}
@@ -0,0 +1,66 @@
+package pgkit
+
+// Connection is a Relay connection, the list type of GraphQL APIs following the Relay
+// cursor connections specification.
+type Connection[T any] struct {
+	Edges    []Edge[T] `json:"edges"`
+	PageInfo PageInfo  `json:"pageInfo"`
+	// TotalCount is set when the page total is known, see WithTotalCount.
+	TotalCount *uint64 `json:"totalCount,omitempty"`
+}
+
+// Edge is an item of a Connection.
+type Edge[T any] struct {
+	Node   T      `json:"node"`
+	Cursor string `json:"cursor"`
+}
+
+// PageInfo is the pagination state of a Connection.
+type PageInfo struct {
+	HasNextPage     bool   `json:"hasNextPage"`
+	HasPreviousPage bool   `json:"hasPreviousPage"`
+	StartCursor     string `json:"startCursor"`
+	EndCursor       string `json:"endCursor"`
+}
+
+// ToConnection converts the result of PrepareResult into a connection. Offset pagination
+// has no cursors, the edge cursors are empty, see CursorPaginator.ToConnection.
+func (p Paginator[T]) ToConnection(result []T, page *Page) Connection[T] {
+	conn := newConnection(result, page)
+	conn.PageInfo.HasPreviousPage = page != nil && page.Page > 1
+	return conn
+}
+
+// ToConnection converts the result of PrepareResult into a connection, with the cursor
+// of each edge.
+func (p CursorPaginator[T]) ToConnection(result []T, page *Page) (Connection[T], error) {
+	conn := newConnection(result, page)
+	conn.PageInfo.HasPreviousPage = page != nil && page.Cursor != ""
+	sort := p.getSort(page)
+	for i := range conn.Edges {
+		token, err := rowCursor(result[i], sort)
+		if err != nil {
+			return Connection[T]{}, err
+		}
+		conn.Edges[i].Cursor = token
+	}
+	if n := len(conn.Edges); n > 0 {
+		conn.PageInfo.StartCursor, conn.PageInfo.EndCursor = conn.Edges[0].Cursor, conn.Edges[n-1].Cursor
+	}
+	return conn, nil
+}
+
+func newConnection[T any](result []T, page *Page) Connection[T] {
+	conn := Connection[T]{Edges: make([]Edge[T], len(result))}
+	for i := range result {
+		conn.Edges[i].Node = result[i]
+	}
+	if page != nil {
+		conn.PageInfo.HasNextPage = page.More
+		if page.Total > 0 || page.TotalPages > 0 {
+			total := page.Total
+			conn.TotalCount = &total
+		}
+	}
+	return conn
+}
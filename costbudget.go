@@ -0,0 +1,112 @@
+package pgkit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ErrOverBudget is returned for the queries rejected by a CostBudget.
+var ErrOverBudget = errors.New("pgkit: query over its cost budget")
+
+// QueryEstimate is the planner estimate of a query, from the top node of its plan.
+type QueryEstimate struct {
+	// Cost is the total cost, in the planner arbitrary units.
+	Cost float64
+	// Rows is the number of rows returned.
+	Rows float64
+}
+
+// CostBudget rejects the queries tagged Expensive with WithQueryConfig whose estimate is
+// above the ceilings, before they run, ie. the ones built from the filters of end users.
+// A zero ceiling isn't checked. Each tagged query is planned twice, once by EXPLAIN.
+type CostBudget struct {
+	MaxCost float64
+	MaxRows float64
+}
+
+// Middleware returns the middleware checking the expensive queries.
+func (b CostBudget) Middleware() Middleware {
+	return func(next Executor) Executor {
+		return costBudgetExecutor{budget: b, next: next}
+	}
+}
+
+// check estimates the query when it's tagged Expensive, returning ErrOverBudget when the
+// estimate is above the ceilings.
+func (b CostBudget) check(ctx context.Context, exec Executor, sql string, args []interface{}) error {
+	if !GetQueryConfig(ctx).Expensive {
+		return nil
+	}
+	estimate, err := estimate(ctx, exec, sql, args)
+	if err != nil {
+		return err
+	}
+	if (b.MaxCost > 0 && estimate.Cost > b.MaxCost) || (b.MaxRows > 0 && estimate.Rows > b.MaxRows) {
+		return fmt.Errorf("%w: estimated cost %.0f and rows %.0f", ErrOverBudget, estimate.Cost, estimate.Rows)
+	}
+	return nil
+}
+
+// Estimate returns the planner estimate of query, without running it.
+func (q *Querier) Estimate(ctx context.Context, query Sqlizer) (QueryEstimate, error) {
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return QueryEstimate{}, wrapErr(err)
+	}
+	return estimate(ctx, q.executor(), sql, args)
+}
+
+func estimate(ctx context.Context, exec Executor, sql string, args []interface{}) (QueryEstimate, error) {
+	var plans []struct {
+		Plan struct {
+			TotalCost float64 `json:"Total Cost"`
+			PlanRows  float64 `json:"Plan Rows"`
+		}
+	}
+	if err := exec.QueryRow(ctx, "EXPLAIN (FORMAT JSON) "+sql, args...).Scan(&plans); err != nil {
+		return QueryEstimate{}, wrapErr(err)
+	}
+	if len(plans) == 0 {
+		return QueryEstimate{}, fmt.Errorf("pgkit: empty plan")
+	}
+	return QueryEstimate{Cost: plans[0].Plan.TotalCost, Rows: plans[0].Plan.PlanRows}, nil
+}
+
+type costBudgetExecutor struct {
+	budget CostBudget
+	next   Executor
+}
+
+func (e costBudgetExecutor) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	if err := e.budget.check(ctx, e.next, sql, args); err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	return e.next.Exec(ctx, sql, args...)
+}
+
+func (e costBudgetExecutor) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	if err := e.budget.check(ctx, e.next, sql, args); err != nil {
+		return nil, err
+	}
+	return e.next.Query(ctx, sql, args...)
+}
+
+func (e costBudgetExecutor) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	if err := e.budget.check(ctx, e.next, sql, args); err != nil {
+		return errRow{err}
+	}
+	return e.next.QueryRow(ctx, sql, args...)
+}
+
+func (e costBudgetExecutor) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	for _, q := range b.QueuedQueries {
+		if err := e.budget.check(ctx, e.next, q.SQL, q.Arguments); err != nil {
+			return errBatchResults{err}
+		}
+	}
+	return e.next.SendBatch(ctx, b)
+}
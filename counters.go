@@ -0,0 +1,109 @@
+package pgkit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// CounterSpec declares a denormalized counter: Column on the Parent table holds the number
+// of Child rows referencing it, ie. CounterSpec{Parent: "posts", Child: "comments", Column: "comment_count"}.
+type CounterSpec struct {
+	Parent string
+	Child  string
+	Column string
+	// ParentKey is the key of Parent referenced by ForeignKey, defaults to the one of the
+	// foreign key constraint when ForeignKey is empty, or "id".
+	ParentKey string
+	// ForeignKey is the column of Child referencing Parent, defaults to the one of the single
+	// column foreign key constraint of Child referencing Parent.
+	ForeignKey string
+}
+
+func (s CounterSpec) withDefaults(ctx context.Context, db *DB) (CounterSpec, error) {
+	if s.ForeignKey == "" {
+		rows, err := db.Pool().Query(ctx, `
+			SELECT a.attname, pa.attname FROM pg_constraint c
+			JOIN pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = c.conkey[1]
+			JOIN pg_attribute pa ON pa.attrelid = c.confrelid AND pa.attnum = c.confkey[1]
+			WHERE c.contype = 'f' AND c.conrelid = $1::regclass AND c.confrelid = $2::regclass
+			AND cardinality(c.conkey) = 1 AND ($3 = '' OR pa.attname = $3)`,
+			quoteIdent(s.Child), quoteIdent(s.Parent), s.ParentKey)
+		if err != nil {
+			return s, wrapErr(err)
+		}
+		defer rows.Close()
+		var n int
+		for rows.Next() {
+			if err := rows.Scan(&s.ForeignKey, &s.ParentKey); err != nil {
+				return s, wrapErr(err)
+			}
+			n++
+		}
+		if err := rows.Err(); err != nil {
+			return s, wrapErr(err)
+		}
+		if n != 1 {
+			return s, fmt.Errorf("pgkit: %d foreign keys of %s referencing %s, set the ForeignKey of the counter", n, s.Child, s.Parent)
+		}
+	}
+	if s.ParentKey == "" {
+		s.ParentKey = "id"
+	}
+	return s, nil
+}
+
+// InstallCounter creates, or replaces, the trigger on the Child table keeping the counter
+// consistent on insert, delete and re-parenting updates. Run Recount afterwards to fix the
+// existing counts.
+func InstallCounter(ctx context.Context, db *DB, spec CounterSpec) error {
+	spec, err := spec.withDefaults(ctx, db)
+	if err != nil {
+		return err
+	}
+	name := strings.ReplaceAll(fmt.Sprintf("pgkit_counter_%s_%s", spec.Child, spec.Column), ".", "_")
+
+	_, err = db.Pool().Exec(ctx, fmt.Sprintf(`
+		CREATE OR REPLACE FUNCTION %[1]s() RETURNS trigger AS $$
+		BEGIN
+			IF TG_OP IN ('UPDATE', 'DELETE') AND OLD.%[5]s IS NOT NULL
+				AND (TG_OP = 'DELETE' OR NEW.%[5]s IS DISTINCT FROM OLD.%[5]s) THEN
+				UPDATE %[2]s SET %[3]s = %[3]s - 1 WHERE %[4]s = OLD.%[5]s;
+			END IF;
+			IF TG_OP IN ('UPDATE', 'INSERT') AND NEW.%[5]s IS NOT NULL
+				AND (TG_OP = 'INSERT' OR NEW.%[5]s IS DISTINCT FROM OLD.%[5]s) THEN
+				UPDATE %[2]s SET %[3]s = %[3]s + 1 WHERE %[4]s = NEW.%[5]s;
+			END IF;
+			RETURN NULL;
+		END
+		$$ LANGUAGE plpgsql;
+		DROP TRIGGER IF EXISTS %[1]s ON %[6]s;
+		CREATE TRIGGER %[1]s AFTER INSERT OR UPDATE OF %[5]s OR DELETE ON %[6]s
+		FOR EACH ROW EXECUTE FUNCTION %[1]s();`,
+		quoteIdent(name), quoteIdent(spec.Parent), quoteIdent(spec.Column),
+		quoteIdent(spec.ParentKey), quoteIdent(spec.ForeignKey), quoteIdent(spec.Child)))
+	return wrapErr(err)
+}
+
+// Recount recomputes the counter of every parent row, returning the number of rows which
+// had drifted and were fixed.
+func Recount(ctx context.Context, db *DB, spec CounterSpec) (int64, error) {
+	spec, err := spec.withDefaults(ctx, db)
+	if err != nil {
+		return 0, err
+	}
+	tag, err := db.Pool().Exec(ctx, fmt.Sprintf(`
+		UPDATE %[1]s p SET %[2]s = c.n
+		FROM (
+			SELECT p.%[3]s AS key, count(c.%[4]s) AS n
+			FROM %[1]s p LEFT JOIN %[5]s c ON c.%[4]s = p.%[3]s
+			GROUP BY p.%[3]s
+		) c
+		WHERE p.%[3]s = c.key AND p.%[2]s IS DISTINCT FROM c.n`,
+		quoteIdent(spec.Parent), quoteIdent(spec.Column), quoteIdent(spec.ParentKey),
+		quoteIdent(spec.ForeignKey), quoteIdent(spec.Child)))
+	if err != nil {
+		return 0, wrapErr(err)
+	}
+	return tag.RowsAffected(), nil
+}
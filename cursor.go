@@ -0,0 +1,201 @@
+package pgkit
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/goware/pgkit/v2/internal/reflectx"
+)
+
+// CursorPaginator is a keyset paginator: instead of skipping the rows of the previous pages
+// with OFFSET, which gets slower the deeper the page, it seeks after the last row returned,
+// see Keyset. The position is passed around as an opaque cursor, set in Page.NextCursor by
+// PrepareResult and read from Page.Cursor by PrepareQuery.
+//
+// The values of the sort columns are read from the rows using their `db` tags, so the sort
+// columns must be selected, and the sort should end with a unique column, ie. "id".
+type CursorPaginator[T any] struct {
+	PaginatorOption
+}
+
+// NewCursorPaginator creates a new cursor paginator, it takes the same options as NewPaginator.
+func NewCursorPaginator[T any](options ...func(*PaginatorOption)) CursorPaginator[T] {
+	return CursorPaginator[T]{PaginatorOption: NewPaginator[T](options...).PaginatorOption}
+}
+
+type cursor struct {
+	Sort   []string      `json:"s"`
+	Values []interface{} `json:"v"`
+}
+
+// PrepareQuery adds the seek predicate of the page cursor, the sort and the limit to the
+// query. It sets the number of max rows to limit+1. A nil page is treated as the first page.
+// A scoped paginator fails with ErrScopeWithoutContext, see PrepareQueryContext.
+func (p CursorPaginator[T]) PrepareQuery(q sq.SelectBuilder, page *Page) ([]T, sq.SelectBuilder, error) {
+	if page == nil {
+		page = &Page{}
+	}
+	if p.scope != nil {
+		return nil, q, ErrScopeWithoutContext
+	}
+	Paginator[T]{p.PaginatorOption}.setDefaults(page)
+	sort := p.getSort(page)
+	if err := p.indexHints.check(p.filters, sort); err != nil {
+		return nil, q, err
+	}
+	q = p.wrapGrouped(p.search.where(where(q, p.filters)))
+
+	if page.Cursor != "" {
+		c, err := decodeCursor(page.Cursor)
+		if err != nil {
+			return nil, q, err
+		}
+		if strings.Join(c.Sort, ",") != strings.Join(sortStrings(sort), ",") {
+			return nil, q, fmt.Errorf("pgkit: cursor doesn't match the sort order")
+		}
+		q = q.Where(Keyset(sort, c.Values...))
+	}
+
+	limit := p.limit(page)
+	q = q.Limit(limit + 1).OrderBy(sortStrings(sort)...)
+	return make([]T, 0, limit+1), q, nil
+}
+
+// PrepareResult removes the extra row fetched by PrepareQuery, setting Page.More, and sets
+// Page.NextCursor to the position of the last row.
+func (p CursorPaginator[T]) PrepareResult(result []T, page *Page) ([]T, error) {
+	if page == nil {
+		page = &Page{}
+	}
+	Paginator[T]{p.PaginatorOption}.setDefaults(page)
+	limit := int(p.limit(page))
+	page.Size = uint32(limit)
+	page.More = len(result) > limit
+	if page.More {
+		result = result[:limit]
+	}
+
+	page.NextCursor = ""
+	if len(result) == 0 {
+		return result, nil
+	}
+	token, err := rowCursor(result[len(result)-1], p.getSort(page))
+	if err != nil {
+		return nil, err
+	}
+	page.NextCursor = token
+	return result, nil
+}
+
+// rowCursor returns the cursor of the position of row, reading the values of the sort
+// columns from its fields.
+func rowCursor(row interface{}, sort []Sort) (string, error) {
+	values, err := sortValues(row, sort)
+	if err != nil {
+		return "", err
+	}
+	return encodeCursor(cursor{Sort: sortStrings(sort), Values: values})
+}
+
+// sortValues returns the values of the sort columns of row, read from its fields.
+func sortValues(row interface{}, sort []Sort) ([]interface{}, error) {
+	values := make([]interface{}, len(sort))
+	v := reflect.Indirect(reflect.ValueOf(row))
+	if v.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("pgkit: expecting struct rows to read the sort columns, got %T", row)
+	}
+	typeMap := Mapper.TypeMap(v.Type())
+	for i, s := range sort {
+		name := s.Column[strings.LastIndex(s.Column, ".")+1:]
+		field := typeMap.GetByPath(strings.Trim(name, `"`))
+		if field == nil {
+			return nil, fmt.Errorf("pgkit: sort column %q not found in %s", s.Column, v.Type())
+		}
+		values[i] = reflectx.FieldByIndexesReadOnly(v, field.Index).Interface()
+	}
+	return values, nil
+}
+
+func sortStrings(sort []Sort) []string {
+	list := make([]string, len(sort))
+	for i, s := range sort {
+		list[i] = s.String()
+	}
+	return list
+}
+
+func encodeCursor(c cursor) (string, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", wrapErr(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor decodes a cursor, numbers are decoded as strings, which are sent in text
+// format and parsed by postgres according to the column type, preserving their precision.
+func decodeCursor(token string) (cursor, error) {
+	var c cursor
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return c, fmt.Errorf("pgkit: invalid cursor: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&c); err != nil {
+		return c, fmt.Errorf("pgkit: invalid cursor: %w", err)
+	}
+	for i, v := range c.Values {
+		if n, ok := v.(json.Number); ok {
+			c.Values[i] = n.String()
+		}
+	}
+	if len(c.Values) != len(c.Sort) {
+		return c, fmt.Errorf("pgkit: invalid cursor")
+	}
+	return c, nil
+}
+
+// PrepareQueryContext is like PrepareQuery, applying the scope of ctx, see WithScope.
+func (p CursorPaginator[T]) PrepareQueryContext(ctx context.Context, q sq.SelectBuilder, page *Page) ([]T, sq.SelectBuilder, error) {
+	p.PaginatorOption = p.withScope(ctx)
+	return p.PrepareQuery(q, page)
+}
+
+// ForEachPage runs q page by page, with pages of the given size, calling fn with the rows
+// of each page until the last one. An error returned by fn stops the iteration and is
+// returned.
+func (p CursorPaginator[T]) ForEachPage(ctx context.Context, q sq.SelectBuilder, size uint32, querier *Querier, fn func(rows []T, page *Page) error) error {
+	p.PaginatorOption = p.withScope(ctx)
+	page := &Page{Page: 1, Size: size}
+	for {
+		_, query, err := p.PrepareQuery(q, page)
+		if err != nil {
+			return err
+		}
+		rows, err := p.observe(querier, page, false).QueryRows(ctx, query)
+		if err != nil {
+			return err
+		}
+		result, err := Scan[T](rows)
+		if err != nil {
+			return err
+		}
+		if result, err = p.PrepareResult(result, page); err != nil {
+			return err
+		}
+		if err := fn(result, page); err != nil {
+			return err
+		}
+		if !page.More {
+			return nil
+		}
+		page = &Page{Page: page.Page + 1, Size: page.Size, Cursor: page.NextCursor}
+	}
+}
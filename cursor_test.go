@@ -0,0 +1,93 @@
+package pgkit_test
+
+import (
+	"testing"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/goware/pgkit/v2"
+	"github.com/stretchr/testify/require"
+)
+
+type event struct {
+	ID        int64     `db:"id"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+func TestCursorPaginator(t *testing.T) {
+	paginator := pgkit.NewCursorPaginator[*event](pgkit.WithDefaultSize(2), pgkit.WithSort("-created_at", "-id"))
+
+	page := &pgkit.Page{}
+	result, query, err := paginator.PrepareQuery(sq.Select("*").From("events"), page)
+	require.NoError(t, err)
+	require.Len(t, result, 0)
+	sql, args, err := query.ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "SELECT * FROM events ORDER BY created_at DESC, id DESC LIMIT 3", sql)
+	require.Empty(t, args)
+
+	t0 := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	rows := []*event{
+		{ID: 9007199254740993, CreatedAt: t0.Add(2 * time.Hour)},
+		{ID: 9007199254740992, CreatedAt: t0},
+		{ID: 1, CreatedAt: t0},
+	}
+	result, err = paginator.PrepareResult(rows, page)
+	require.NoError(t, err)
+	require.Len(t, result, 2)
+	require.True(t, page.More)
+	require.NotEmpty(t, page.NextCursor)
+
+	next := &pgkit.Page{Cursor: page.NextCursor}
+	_, query, err = paginator.PrepareQuery(sq.Select("*").From("events"), next)
+	require.NoError(t, err)
+	sql, args, err = query.ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "SELECT * FROM events WHERE (created_at, id) < (?, ?) ORDER BY created_at DESC, id DESC LIMIT 3", sql)
+	require.Equal(t, []interface{}{"2024-01-02T03:04:05Z", "9007199254740992"}, args)
+
+	result, err = paginator.PrepareResult(nil, next)
+	require.NoError(t, err)
+	require.Empty(t, result)
+	require.False(t, next.More)
+	require.Empty(t, next.NextCursor)
+
+	// the cursor is bound to the sort
+	_, _, err = paginator.PrepareQuery(sq.Select("*").From("events"), &pgkit.Page{Cursor: page.NextCursor, Column: "id"})
+	require.Error(t, err)
+
+	_, _, err = paginator.PrepareQuery(sq.Select("*").From("events"), &pgkit.Page{Cursor: "invalid"})
+	require.Error(t, err)
+}
+
+func TestCursorConnection(t *testing.T) {
+	type row struct {
+		ID   int64  `db:"id"`
+		Name string `db:"name"`
+	}
+	paginator := pgkit.NewCursorPaginator[row](pgkit.WithDefaultSize(2), pgkit.WithSort("id"))
+	page := &pgkit.Page{}
+	result, err := paginator.PrepareResult([]row{{1, "a"}, {2, "b"}, {3, "c"}}, page)
+	require.NoError(t, err)
+
+	conn, err := paginator.ToConnection(result, page)
+	require.NoError(t, err)
+	require.Len(t, conn.Edges, 2)
+	require.Equal(t, row{2, "b"}, conn.Edges[1].Node)
+	require.Equal(t, page.NextCursor, conn.Edges[1].Cursor)
+	require.Equal(t, pgkit.PageInfo{HasNextPage: true, StartCursor: conn.Edges[0].Cursor, EndCursor: page.NextCursor}, conn.PageInfo)
+	require.Nil(t, conn.TotalCount)
+
+	// the edge cursors resume after their row
+	_, q, err := paginator.PrepareQuery(sq.Select("*").From("t"), &pgkit.Page{Cursor: conn.Edges[0].Cursor})
+	require.NoError(t, err)
+	_, args, err := q.ToSql()
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{"1"}, args)
+
+	offset := pgkit.NewPaginator[row]()
+	offsetPage := &pgkit.Page{Page: 2, Size: 2, Total: 5}
+	oconn := offset.ToConnection([]row{{3, "c"}}, offsetPage)
+	require.Equal(t, pgkit.PageInfo{HasPreviousPage: true}, oconn.PageInfo)
+	require.Equal(t, uint64(5), *oconn.TotalCount)
+}
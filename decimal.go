@@ -0,0 +1,163 @@
+package pgkit
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Decimal is an exact decimal number, ie. an amount of money, scanned from and passed as a
+// numeric without going through float64, so sorting and filtering on it never rounds. It's
+// the coefficient times 10 to the exponent, keeping the scale it's parsed or scanned with,
+// ie. "12.50". The zero value is 0, use *Decimal for nullable columns.
+type Decimal struct {
+	coef *big.Int
+	exp  int32
+}
+
+// NewDecimal returns the decimal coef * 10^exp, ie. NewDecimal(1250, -2) is 12.50.
+func NewDecimal(coef int64, exp int32) Decimal {
+	return Decimal{coef: big.NewInt(coef), exp: exp}
+}
+
+// ParseDecimal parses a decimal such as "-12.50" or "1.5e3".
+func ParseDecimal(s string) (Decimal, error) {
+	mantissa, exp := s, int64(0)
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		e, err := strconv.ParseInt(s[i+1:], 10, 32)
+		if err != nil {
+			return Decimal{}, fmt.Errorf("pgkit: invalid decimal %q", s)
+		}
+		mantissa, exp = s[:i], e
+	}
+	whole, frac, _ := strings.Cut(mantissa, ".")
+	coef, ok := new(big.Int).SetString(whole+frac, 10)
+	if !ok || strings.ContainsAny(frac, "+-") {
+		return Decimal{}, fmt.Errorf("pgkit: invalid decimal %q", s)
+	}
+	exp -= int64(len(frac))
+	if exp < -1<<31 || exp > 1<<31-1 {
+		return Decimal{}, fmt.Errorf("pgkit: invalid decimal %q, out of range", s)
+	}
+	return Decimal{coef: coef, exp: int32(exp)}, nil
+}
+
+func (d Decimal) int() *big.Int {
+	if d.coef == nil {
+		return new(big.Int)
+	}
+	return d.coef
+}
+
+// String returns the decimal without exponent, ie. "-12.50".
+func (d Decimal) String() string {
+	digits := new(big.Int).Abs(d.int()).String()
+	switch {
+	case d.exp > 0:
+		digits += strings.Repeat("0", int(d.exp))
+	case d.exp < 0:
+		scale := int(-d.exp)
+		if len(digits) <= scale {
+			digits = strings.Repeat("0", scale-len(digits)+1) + digits
+		}
+		digits = digits[:len(digits)-scale] + "." + digits[len(digits)-scale:]
+	}
+	if d.int().Sign() < 0 {
+		return "-" + digits
+	}
+	return digits
+}
+
+// Sign returns -1, 0 or +1 depending on the sign of d.
+func (d Decimal) Sign() int {
+	return d.int().Sign()
+}
+
+// Cmp compares d and other, returning -1, 0 or +1. The scale doesn't matter, ie. 1.50 and
+// 1.5 are equal.
+func (d Decimal) Cmp(other Decimal) int {
+	a, b, _ := align(d, other)
+	return a.Cmp(b)
+}
+
+// Add returns d + other.
+func (d Decimal) Add(other Decimal) Decimal {
+	a, b, exp := align(d, other)
+	return Decimal{coef: new(big.Int).Add(a, b), exp: exp}
+}
+
+// Sub returns d - other.
+func (d Decimal) Sub(other Decimal) Decimal {
+	a, b, exp := align(d, other)
+	return Decimal{coef: new(big.Int).Sub(a, b), exp: exp}
+}
+
+// align returns the coefficients of a and b with the smallest of their exponents.
+func align(a, b Decimal) (*big.Int, *big.Int, int32) {
+	x, y := a.int(), b.int()
+	switch {
+	case a.exp > b.exp:
+		x = new(big.Int).Mul(x, pow10(a.exp-b.exp))
+		return x, y, b.exp
+	case b.exp > a.exp:
+		y = new(big.Int).Mul(y, pow10(b.exp-a.exp))
+	}
+	return x, y, a.exp
+}
+
+func pow10(n int32) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// ScanNumeric implements pgtype.NumericScanner.
+func (d *Decimal) ScanNumeric(v pgtype.Numeric) error {
+	switch {
+	case !v.Valid:
+		return fmt.Errorf("pgkit: cannot scan NULL into Decimal, use *Decimal")
+	case v.NaN || v.InfinityModifier != pgtype.Finite:
+		return fmt.Errorf("pgkit: cannot scan NaN or infinity into Decimal")
+	}
+	*d = Decimal{coef: new(big.Int).Set(v.Int), exp: v.Exp}
+	return nil
+}
+
+// NumericValue implements pgtype.NumericValuer.
+func (d Decimal) NumericValue() (pgtype.Numeric, error) {
+	return pgtype.Numeric{Int: new(big.Int).Set(d.int()), Exp: d.exp, Valid: true}, nil
+}
+
+// MarshalText implements encoding.TextMarshaler, JSON values are strings so they're not
+// read as float64 by clients.
+func (d Decimal) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (d *Decimal) UnmarshalText(text []byte) error {
+	v, err := ParseDecimal(string(text))
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler, accepting both strings and numbers.
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		var n json.Number
+		if err := json.Unmarshal(data, &n); err != nil {
+			return fmt.Errorf("pgkit: invalid decimal %s", data)
+		}
+		s = n.String()
+	}
+	return d.UnmarshalText([]byte(s))
+}
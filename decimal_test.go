@@ -0,0 +1,64 @@
+package pgkit_test
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/goware/pgkit/v2"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecimal(t *testing.T) {
+	for input, expected := range map[string]string{
+		"12.50":  "12.50",
+		"-0.05":  "-0.05",
+		"-.5":    "-0.5",
+		"1.5e3":  "1500",
+		"15E-4":  "0.0015",
+		"+7":     "7",
+		"100.":   "100",
+		"0.0000": "0.0000",
+	} {
+		d, err := pgkit.ParseDecimal(input)
+		require.NoError(t, err, input)
+		require.Equal(t, expected, d.String(), input)
+	}
+	for _, input := range []string{"", ".", "1.2.3", "1.-5", "abc", "1e", "1e99999999999", "0x10"} {
+		_, err := pgkit.ParseDecimal(input)
+		require.Error(t, err, input)
+	}
+
+	a, b := pgkit.NewDecimal(1250, -2), pgkit.NewDecimal(125, -1)
+	require.Equal(t, 0, a.Cmp(b))
+	require.Equal(t, -1, a.Cmp(pgkit.NewDecimal(1251, -2)))
+	require.Equal(t, 1, a.Cmp(pgkit.NewDecimal(-1, 3)))
+	// 0.1 + 0.2 is exact, unlike float64
+	sum := pgkit.NewDecimal(1, -1).Add(pgkit.NewDecimal(2, -1))
+	require.Equal(t, "0.3", sum.String())
+	require.Equal(t, "-0.80", pgkit.NewDecimal(1, 0).Sub(pgkit.NewDecimal(180, -2)).String())
+	require.Equal(t, "0", pgkit.Decimal{}.String())
+	require.Equal(t, 0, pgkit.Decimal{}.Sign())
+
+	var d pgkit.Decimal
+	require.NoError(t, d.ScanNumeric(pgtype.Numeric{Int: big.NewInt(-12345), Exp: -3, Valid: true}))
+	require.Equal(t, "-12.345", d.String())
+	n, err := d.NumericValue()
+	require.NoError(t, err)
+	require.Equal(t, pgtype.Numeric{Int: big.NewInt(-12345), Exp: -3, Valid: true}, n)
+	require.Error(t, d.ScanNumeric(pgtype.Numeric{}))
+	require.Error(t, d.ScanNumeric(pgtype.Numeric{NaN: true, Valid: true}))
+
+	var v struct {
+		Price pgkit.Decimal  `json:"price"`
+		Fee   *pgkit.Decimal `json:"fee"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(`{"price": 19.99, "fee": "0.30"}`), &v))
+	require.Equal(t, "19.99", v.Price.String())
+	require.Equal(t, "0.30", v.Fee.String())
+	data, err := json.Marshal(v)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"price": "19.99", "fee": "0.30"}`, string(data))
+	require.Error(t, json.Unmarshal([]byte(`{"price": true}`), &v))
+}
@@ -0,0 +1,190 @@
+package pgkit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ErrSunset is returned for the uses of a deprecated query or sort column past its sunset.
+var ErrSunset = errors.New("pgkit: deprecated and past its sunset")
+
+// Deprecation marks a query or a sort column as deprecated.
+type Deprecation struct {
+	Message string
+	// Sunset is the time after which uses are rejected with ErrSunset, zero never rejects.
+	Sunset time.Time
+}
+
+// DeprecationUse is a use of something deprecated, reported by Deprecations.
+type DeprecationUse struct {
+	// Kind is "query" or "sort".
+	Kind        string
+	Name        string
+	Deprecation Deprecation
+	// Caller is the first function outside of pgkit and the libraries it uses, ie.
+	// "main.listAccounts (/src/main.go:42)".
+	Caller   string
+	Rejected bool
+}
+
+// DeprecationCount is the number of uses of something deprecated, see Deprecations.Counts.
+type DeprecationCount struct {
+	Kind     string
+	Name     string
+	Uses     int64
+	Rejected int64
+}
+
+// Deprecations registers deprecated queries, by QueryConfig.Name, and sort columns, by the
+// names used in pages. Their uses are reported and counted, and rejected after their sunset:
+// queries through the middleware, and sort columns by the paginators created with
+// WithDeprecations, whose queries then fail.
+type Deprecations struct {
+	Queries map[string]Deprecation
+	Sorts   map[string]Deprecation
+	// Report is called for each use, it's optional.
+	Report func(use DeprecationUse)
+
+	mu     sync.Mutex
+	counts map[[2]string]*DeprecationCount
+}
+
+// Middleware returns the middleware checking the deprecated queries.
+func (d *Deprecations) Middleware() Middleware {
+	return func(next Executor) Executor {
+		return deprecationExecutor{deprecations: d, next: next}
+	}
+}
+
+// Counts returns the uses of deprecated things so far, sorted by kind and name.
+func (d *Deprecations) Counts() []DeprecationCount {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	list := make([]DeprecationCount, 0, len(d.counts))
+	for _, c := range d.counts {
+		list = append(list, *c)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Kind != list[j].Kind {
+			return list[i].Kind < list[j].Kind
+		}
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// use records the use of name, returning ErrSunset when it's rejected.
+func (d *Deprecations) use(kind, name string, deprecation Deprecation) error {
+	rejected := !deprecation.Sunset.IsZero() && time.Now().After(deprecation.Sunset)
+
+	d.mu.Lock()
+	if d.counts == nil {
+		d.counts = make(map[[2]string]*DeprecationCount)
+	}
+	c := d.counts[[2]string{kind, name}]
+	if c == nil {
+		c = &DeprecationCount{Kind: kind, Name: name}
+		d.counts[[2]string{kind, name}] = c
+	}
+	c.Uses++
+	if rejected {
+		c.Rejected++
+	}
+	d.mu.Unlock()
+
+	if d.Report != nil {
+		d.Report(DeprecationUse{Kind: kind, Name: name, Deprecation: deprecation, Caller: caller(), Rejected: rejected})
+	}
+	if rejected {
+		return fmt.Errorf("%w: %s %q: %s", ErrSunset, kind, name, deprecation.Message)
+	}
+	return nil
+}
+
+func (d *Deprecations) checkQuery(ctx context.Context) error {
+	name := GetQueryConfig(ctx).Name
+	if deprecation, ok := d.Queries[name]; ok && name != "" {
+		return d.use("query", name, deprecation)
+	}
+	return nil
+}
+
+func (d *Deprecations) checkSort(sort []Sort) error {
+	for _, s := range sort {
+		if deprecation, ok := d.Sorts[s.Column]; ok {
+			if err := d.use("sort", s.Column, deprecation); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// libraries are the packages skipped when looking for the caller.
+var libraries = []string{"github.com/goware/pgkit/v2.", "github.com/georgysavva/scany/", "github.com/jackc/pgx/", "github.com/Masterminds/squirrel", "runtime."}
+
+func caller() string {
+	pc := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pc[:runtime.Callers(2, pc)])
+	for {
+		frame, more := frames.Next()
+		library := false
+		for _, prefix := range libraries {
+			library = library || strings.HasPrefix(frame.Function, prefix)
+		}
+		if !library {
+			return fmt.Sprintf("%s (%s:%d)", frame.Function, frame.File, frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+// WithDeprecations reports the uses of the deprecated sort columns, and makes the queries
+// sorted by the ones past their sunset fail with ErrSunset.
+func WithDeprecations(d *Deprecations) func(*PaginatorOption) {
+	return func(o *PaginatorOption) { o.deprecations = d }
+}
+
+type deprecationExecutor struct {
+	deprecations *Deprecations
+	next         Executor
+}
+
+func (e deprecationExecutor) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	if err := e.deprecations.checkQuery(ctx); err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	return e.next.Exec(ctx, sql, args...)
+}
+
+func (e deprecationExecutor) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	if err := e.deprecations.checkQuery(ctx); err != nil {
+		return nil, err
+	}
+	return e.next.Query(ctx, sql, args...)
+}
+
+func (e deprecationExecutor) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	if err := e.deprecations.checkQuery(ctx); err != nil {
+		return errRow{err}
+	}
+	return e.next.QueryRow(ctx, sql, args...)
+}
+
+func (e deprecationExecutor) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	if err := e.deprecations.checkQuery(ctx); err != nil {
+		return errBatchResults{err}
+	}
+	return e.next.SendBatch(ctx, b)
+}
@@ -0,0 +1,51 @@
+package pgkit_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/goware/pgkit/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeprecations(t *testing.T) {
+	var uses []pgkit.DeprecationUse
+	deprecations := &pgkit.Deprecations{
+		Queries: map[string]pgkit.Deprecation{
+			"old_report": {Message: "use new_report", Sunset: time.Now().Add(-time.Hour)},
+		},
+		Sorts: map[string]pgkit.Deprecation{
+			"legacy_rank": {Message: "sort by score"},
+			"rank":        {Message: "sort by score", Sunset: time.Now().Add(-time.Hour)},
+		},
+		Report: func(use pgkit.DeprecationUse) { uses = append(uses, use) },
+	}
+
+	paginator := pgkit.NewPaginator[T](pgkit.WithDeprecations(deprecations))
+	_, query := paginator.PrepareQuery(sq.Select("*").From("t"), &pgkit.Page{Column: "legacy_rank"})
+	_, _, err := query.ToSql()
+	require.NoError(t, err)
+	require.Len(t, uses, 1)
+	require.Equal(t, "sort", uses[0].Kind)
+	require.False(t, uses[0].Rejected)
+	require.True(t, strings.HasPrefix(uses[0].Caller, "github.com/goware/pgkit/v2_test.TestDeprecations"), uses[0].Caller)
+
+	_, query = paginator.PrepareQuery(sq.Select("*").From("t"), &pgkit.Page{Column: "-rank"})
+	_, _, err = query.ToSql()
+	require.ErrorIs(t, err, pgkit.ErrSunset)
+
+	exec := deprecations.Middleware()(sleepExecutor{})
+	_, err = exec.Exec(pgkit.WithQueryConfig(context.Background(), pgkit.QueryConfig{Name: "old_report"}), "SELECT 1")
+	require.ErrorIs(t, err, pgkit.ErrSunset)
+	_, err = exec.Exec(pgkit.WithQueryConfig(context.Background(), pgkit.QueryConfig{Name: "new_report"}), "SELECT 1")
+	require.NoError(t, err)
+
+	require.Equal(t, []pgkit.DeprecationCount{
+		{Kind: "query", Name: "old_report", Uses: 1, Rejected: 1},
+		{Kind: "sort", Name: "legacy_rank", Uses: 1},
+		{Kind: "sort", Name: "rank", Uses: 1, Rejected: 1},
+	}, deprecations.Counts())
+}
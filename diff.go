@@ -0,0 +1,69 @@
+package pgkit
+
+import (
+	"context"
+	"reflect"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// ResultDiff lists the differences between two results, see DiffResults.
+type ResultDiff[T any] struct {
+	// Missing are the rows of the first result missing from the second one.
+	Missing []T
+	// Extra are the rows of the second result missing from the first one.
+	Extra []T
+	// Changed are the rows of both results with the same key but different values.
+	Changed []RowChange[T]
+}
+
+// RowChange is a row with different values in two results.
+type RowChange[T any] struct {
+	Old, New T
+}
+
+// Equal reports whether both results hold the same rows.
+func (d ResultDiff[T]) Equal() bool {
+	return len(d.Missing) == 0 && len(d.Extra) == 0 && len(d.Changed) == 0
+}
+
+// DiffResults compares two results, ie. the rows returned by an old query and by its
+// rewrite, matching their rows by key. Matching rows are compared with reflect.DeepEqual,
+// the order of the rows is ignored.
+func DiffResults[T any, K comparable](a, b []T, key func(T) K) ResultDiff[T] {
+	var diff ResultDiff[T]
+	rows := make(map[K]int, len(b))
+	for i, row := range b {
+		rows[key(row)] = i
+	}
+	found := make([]bool, len(b))
+	for _, row := range a {
+		i, ok := rows[key(row)]
+		if !ok {
+			diff.Missing = append(diff.Missing, row)
+			continue
+		}
+		found[i] = true
+		if !reflect.DeepEqual(row, b[i]) {
+			diff.Changed = append(diff.Changed, RowChange[T]{Old: row, New: b[i]})
+		}
+	}
+	for i, row := range b {
+		if !found[i] {
+			diff.Extra = append(diff.Extra, row)
+		}
+	}
+	return diff
+}
+
+// DiffQueries runs both queries and compares their rows, see DiffResults.
+func DiffQueries[T any, K comparable](ctx context.Context, querier *Querier, a, b sq.Sqlizer, key func(T) K) (ResultDiff[T], error) {
+	var ra, rb []T
+	if err := querier.GetAll(ctx, a, &ra); err != nil {
+		return ResultDiff[T]{}, err
+	}
+	if err := querier.GetAll(ctx, b, &rb); err != nil {
+		return ResultDiff[T]{}, err
+	}
+	return DiffResults(ra, rb, key), nil
+}
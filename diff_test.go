@@ -0,0 +1,27 @@
+package pgkit_test
+
+import (
+	"testing"
+
+	"github.com/goware/pgkit/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffResults(t *testing.T) {
+	type row struct {
+		ID   int
+		Name string
+	}
+	key := func(r row) int { return r.ID }
+
+	a := []row{{1, "a"}, {2, "b"}, {3, "c"}}
+	require.True(t, pgkit.DiffResults(a, []row{{3, "c"}, {1, "a"}, {2, "b"}}, key).Equal())
+
+	diff := pgkit.DiffResults(a, []row{{4, "d"}, {2, "B"}, {1, "a"}}, key)
+	require.False(t, diff.Equal())
+	require.Equal(t, pgkit.ResultDiff[row]{
+		Missing: []row{{3, "c"}},
+		Extra:   []row{{4, "d"}},
+		Changed: []pgkit.RowChange[row]{{Old: row{2, "b"}, New: row{2, "B"}}},
+	}, diff)
+}
@@ -0,0 +1,60 @@
+package pgkit
+
+import (
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/lann/builder"
+)
+
+// WithDistinctOn tells the paginator the query selects DISTINCT ON the expressions, when it
+// can't be detected, ie. when the clause is written with the columns rather than with
+// SelectBuilder.Options. See PrepareQuery.
+func WithDistinctOn(expressions ...string) func(*PaginatorOption) {
+	expressions = append([]string(nil), expressions...)
+	return func(o *PaginatorOption) { o.distinctOn = expressions }
+}
+
+// wrapGrouped wraps a query selecting DISTINCT, DISTINCT ON or with a GROUP BY in a
+// subselect, so the page sort applies to its rows rather than mixing with the clauses:
+// postgres requires the ORDER BY of a DISTINCT ON to start with its expressions, and the one
+// of a DISTINCT or a GROUP BY to use selected or grouped columns. The sort columns must then
+// be the names of selected columns. A DISTINCT ON query keeps its own ORDER BY, choosing the
+// row kept of each group, which defaults to the DISTINCT ON expressions.
+func (o PaginatorOption) wrapGrouped(q sq.SelectBuilder) sq.SelectBuilder {
+	distinct, distinctOn := false, strings.Join(o.distinctOn, ", ")
+	if options, ok := get(q, "Options").([]string); ok {
+		for _, option := range options {
+			option = strings.TrimSpace(option)
+			if !strings.HasPrefix(strings.ToUpper(option), "DISTINCT") {
+				continue
+			}
+			distinct = true
+			if i, j := strings.Index(option, "("), strings.LastIndex(option, ")"); distinctOn == "" && i >= 0 && j > i {
+				distinctOn = strings.TrimSpace(option[i+1 : j])
+			}
+		}
+	}
+	groupBy, _ := get(q, "GroupBys").([]string)
+	if !distinct && distinctOn == "" && len(groupBy) == 0 {
+		return q
+	}
+
+	orderBy, _ := get(q, "OrderByParts").([]sq.Sqlizer)
+	if distinctOn == "" {
+		q = removeOrderBy(q)
+	} else if len(orderBy) == 0 {
+		q = q.OrderBy(distinctOn)
+	}
+	wrapped := sq.Select("*").FromSelect(q, "pgkit_page")
+	if format, ok := get(q, "PlaceholderFormat").(sq.PlaceholderFormat); ok {
+		wrapped = wrapped.PlaceholderFormat(format)
+	}
+	return wrapped
+}
+
+// get returns a field of a builder, or nil when it's not set.
+func get(b interface{}, name string) interface{} {
+	v, _ := builder.Get(b, name)
+	return v
+}
@@ -0,0 +1,98 @@
+package pgkit
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DualWrite keeps old and new columns of a table in sync while they're being renamed, so
+// application versions writing either of them can run side by side. The rename goes:
+// add the new columns, Install, Copy, deploy the code using the new columns, Verify, then
+// Uninstall and drop the old columns.
+type DualWrite struct {
+	Table string
+	// Columns maps the old column names to the new ones.
+	Columns map[string]string
+}
+
+func (d DualWrite) name() string {
+	return quoteIdent(strings.ReplaceAll("pgkit_dualwrite_"+d.Table, ".", "_"))
+}
+
+// pairs returns the quoted old and new columns, sorted by old name.
+func (d DualWrite) pairs() [][2]string {
+	pairs := make([][2]string, 0, len(d.Columns))
+	for from, to := range d.Columns {
+		pairs = append(pairs, [2]string{quoteIdent(from), quoteIdent(to)})
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i][0] < pairs[j][0] })
+	return pairs
+}
+
+// Install creates, or replaces, the trigger mirroring the writes: an insert fills the
+// column left NULL from its counterpart, an update copies the new column to the old one
+// when it changed, or the old column to the new one otherwise.
+func (d DualWrite) Install(ctx context.Context, db *DB) error {
+	var insert, update []string
+	for _, p := range d.pairs() {
+		insert = append(insert, fmt.Sprintf(`
+			IF NEW.%[2]s IS NULL THEN NEW.%[2]s := NEW.%[1]s; ELSIF NEW.%[1]s IS NULL THEN NEW.%[1]s := NEW.%[2]s; END IF;`, p[0], p[1]))
+		update = append(update, fmt.Sprintf(`
+			IF NEW.%[2]s IS DISTINCT FROM OLD.%[2]s THEN NEW.%[1]s := NEW.%[2]s; ELSE NEW.%[2]s := NEW.%[1]s; END IF;`, p[0], p[1]))
+	}
+
+	_, err := db.Pool().Exec(ctx, fmt.Sprintf(`
+		CREATE OR REPLACE FUNCTION %[1]s() RETURNS trigger AS $$
+		BEGIN
+			IF TG_OP = 'INSERT' THEN %[2]s
+			ELSE %[3]s
+			END IF;
+			RETURN NEW;
+		END
+		$$ LANGUAGE plpgsql;
+		DROP TRIGGER IF EXISTS pgkit_dualwrite ON %[4]s;
+		CREATE TRIGGER pgkit_dualwrite BEFORE INSERT OR UPDATE ON %[4]s
+		FOR EACH ROW EXECUTE FUNCTION %[1]s();`,
+		d.name(), strings.Join(insert, ""), strings.Join(update, ""), quoteIdent(d.Table)))
+	return wrapErr(err)
+}
+
+// Copy copies the old columns to the new ones for the existing rows, returning the number
+// of rows updated.
+func (d DualWrite) Copy(ctx context.Context, db *DB) (int64, error) {
+	var set []string
+	for _, p := range d.pairs() {
+		set = append(set, fmt.Sprintf("%s = %s", p[1], p[0]))
+	}
+	tag, err := db.Pool().Exec(ctx, fmt.Sprintf("UPDATE %s SET %s WHERE %s", quoteIdent(d.Table), strings.Join(set, ", "), d.differ()))
+	if err != nil {
+		return 0, wrapErr(err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// Verify returns the number of rows whose old and new columns differ.
+func (d DualWrite) Verify(ctx context.Context, db *DB) (int64, error) {
+	var n int64
+	err := db.Pool().QueryRow(ctx, fmt.Sprintf("SELECT count(*) FROM %s WHERE %s", quoteIdent(d.Table), d.differ())).Scan(&n)
+	return n, wrapErr(err)
+}
+
+// Uninstall removes the trigger, once the old columns are no longer used.
+func (d DualWrite) Uninstall(ctx context.Context, db *DB) error {
+	_, err := db.Pool().Exec(ctx, fmt.Sprintf(`
+		DROP TRIGGER IF EXISTS pgkit_dualwrite ON %s;
+		DROP FUNCTION IF EXISTS %s();`, quoteIdent(d.Table), d.name()))
+	return wrapErr(err)
+}
+
+// differ returns the condition matching the rows whose old and new columns differ.
+func (d DualWrite) differ() string {
+	var where []string
+	for _, p := range d.pairs() {
+		where = append(where, fmt.Sprintf("%s IS DISTINCT FROM %s", p[0], p[1]))
+	}
+	return strings.Join(where, " OR ")
+}
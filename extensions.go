@@ -0,0 +1,101 @@
+package pgkit
+
+import (
+	"context"
+	"fmt"
+)
+
+// Extension is an optional extension some features depend on, see DB.Capabilities. Restricted
+// managed offerings don't allow all of them, so the features have fallbacks.
+type Extension string
+
+const (
+	// ExtensionTrigram is pg_trgm, see SearchTrigram.
+	ExtensionTrigram Extension = "pg_trgm"
+	// ExtensionVector is pgvector.
+	ExtensionVector Extension = "vector"
+	// ExtensionPostGIS is PostGIS.
+	ExtensionPostGIS Extension = "postgis"
+	// ExtensionLtree is ltree.
+	ExtensionLtree Extension = "ltree"
+)
+
+// ErrMissingExtension is returned when a feature requires an extension which isn't
+// installed, and has no fallback.
+type ErrMissingExtension struct {
+	Extension Extension
+	Feature   string
+}
+
+func (e ErrMissingExtension) Error() string {
+	return fmt.Sprintf("pgkit: %s requires the %s extension", e.Feature, e.Extension)
+}
+
+// Capabilities are the extensions installed in a database, and the ones which could be,
+// see DB.Capabilities.
+type Capabilities struct {
+	// Installed are the versions of the installed extensions.
+	Installed map[Extension]string
+	// Available are the extensions the server provides, installed or not.
+	Available map[Extension]bool
+}
+
+// Has returns true when the extension is installed.
+func (c Capabilities) Has(ext Extension) bool {
+	_, ok := c.Installed[ext]
+	return ok
+}
+
+// Require fails with ErrMissingExtension for the first extension of the feature which isn't
+// installed.
+func (c Capabilities) Require(feature string, extensions ...Extension) error {
+	for _, ext := range extensions {
+		if !c.Has(ext) {
+			return ErrMissingExtension{Extension: ext, Feature: feature}
+		}
+	}
+	return nil
+}
+
+// Fallback returns with when the extension is installed, without otherwise, to pick the
+// strategy of a feature of the application, ie. a vector search or a keyword search:
+//
+//	find := pgkit.Fallback(caps, pgkit.ExtensionVector, findByEmbedding, findByKeywords)
+func Fallback[T any](c Capabilities, ext Extension, with, without T) T {
+	if c.Has(ext) {
+		return with
+	}
+	return without
+}
+
+// Capabilities probes the extensions of the database. They're read once, and again after
+// ApplyConfig, so the probe is cheap enough to run per request.
+func (d *DB) Capabilities(ctx context.Context) (Capabilities, error) {
+	if c := d.capabilities.Load(); c != nil {
+		return *c, nil
+	}
+	rows, err := d.Pool().Query(ctx, `SELECT name, installed_version FROM pg_available_extensions`)
+	if err != nil {
+		return Capabilities{}, wrapErr(err)
+	}
+	defer rows.Close()
+	c := Capabilities{Installed: map[Extension]string{}, Available: map[Extension]bool{}}
+	for rows.Next() {
+		var (
+			name    string
+			version *string
+		)
+		if err := rows.Scan(&name, &version); err != nil {
+			return Capabilities{}, wrapErr(err)
+		}
+		c.Available[Extension(name)] = true
+		if version != nil {
+			c.Installed[Extension(name)] = *version
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return Capabilities{}, wrapErr(err)
+	}
+	d.capabilities.Store(&c)
+	return c, nil
+}
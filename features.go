@@ -0,0 +1,59 @@
+package pgkit
+
+import (
+	"context"
+	"fmt"
+)
+
+// Feature is a server feature depending on the PostgreSQL version, see DB.Supports.
+type Feature string
+
+const (
+	// FeatureNotNullFromCheck is SET NOT NULL skipping the table scan when a valid CHECK
+	// constraint proves the column has no NULL values.
+	FeatureNotNullFromCheck Feature = "SET NOT NULL using CHECK"
+	// FeatureWithTies is FETCH FIRST n ROWS WITH TIES.
+	FeatureWithTies Feature = "WITH TIES"
+	// FeatureMultirange is the multirange types, ie. tstzmultirange.
+	FeatureMultirange Feature = "multirange"
+	// FeatureMerge is the MERGE statement.
+	FeatureMerge Feature = "MERGE"
+	// FeatureJSONTable is the JSON_TABLE function.
+	FeatureJSONTable Feature = "JSON_TABLE"
+)
+
+// featureVersions are the server_version_num introducing each feature.
+var featureVersions = map[Feature]int{
+	FeatureNotNullFromCheck: 120000,
+	FeatureWithTies:         130000,
+	FeatureMultirange:       140000,
+	FeatureMerge:            150000,
+	FeatureJSONTable:        170000,
+}
+
+// ServerVersion returns the server version in the server_version_num format, ie. 140005
+// for PostgreSQL 14.5. It's read once, and again after ApplyConfig.
+func (d *DB) ServerVersion(ctx context.Context) (int, error) {
+	if v := d.serverVersion.Load(); v != 0 {
+		return int(v), nil
+	}
+	var version int
+	if err := d.Pool().QueryRow(ctx, `SELECT current_setting('server_version_num')::int`).Scan(&version); err != nil {
+		return 0, wrapErr(err)
+	}
+	d.serverVersion.Store(int64(version))
+	return version, nil
+}
+
+// Supports reports whether the server supports the feature.
+func (d *DB) Supports(ctx context.Context, feature Feature) (bool, error) {
+	min, ok := featureVersions[feature]
+	if !ok {
+		return false, fmt.Errorf("pgkit: unknown feature %q", feature)
+	}
+	version, err := d.ServerVersion(ctx)
+	if err != nil {
+		return false, err
+	}
+	return version >= min, nil
+}
@@ -0,0 +1,284 @@
+package pgkit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// FilterOp is a filter operator.
+type FilterOp string
+
+const (
+	OpEq   FilterOp = "eq"
+	OpNe   FilterOp = "ne"
+	OpLt   FilterOp = "lt"
+	OpLte  FilterOp = "lte"
+	OpGt   FilterOp = "gt"
+	OpGte  FilterOp = "gte"
+	OpLike FilterOp = "like" // case insensitive, the value is a LIKE pattern
+	OpIn   FilterOp = "in"   // comma separated values
+)
+
+// FilterType is the type the values of a filter are converted to.
+type FilterType int
+
+const (
+	FilterString FilterType = iota
+	FilterInt
+	FilterTime // RFC 3339 or 2006-01-02
+	FilterUUID
+	FilterBool
+	FilterDecimal // exact, see Decimal
+)
+
+// String returns the name of the type, ie. "time".
+func (t FilterType) String() string {
+	switch t {
+	case FilterString:
+		return "string"
+	case FilterInt:
+		return "int"
+	case FilterTime:
+		return "time"
+	case FilterUUID:
+		return "uuid"
+	case FilterBool:
+		return "bool"
+	case FilterDecimal:
+		return "decimal"
+	}
+	return fmt.Sprintf("FilterType(%d)", int(t))
+}
+
+// defaultOps are the operators allowed by type when FilterField.Ops is empty.
+var defaultOps = map[FilterType][]FilterOp{
+	FilterString:  {OpEq, OpNe, OpIn, OpLike},
+	FilterInt:     {OpEq, OpNe, OpLt, OpLte, OpGt, OpGte, OpIn},
+	FilterTime:    {OpEq, OpNe, OpLt, OpLte, OpGt, OpGte},
+	FilterUUID:    {OpEq, OpNe, OpIn},
+	FilterBool:    {OpEq, OpNe},
+	FilterDecimal: {OpEq, OpNe, OpLt, OpLte, OpGt, OpGte, OpIn},
+}
+
+// FilterField is a field which can be filtered on.
+type FilterField struct {
+	// Column is the filtered column, defaults to the field name.
+	Column string
+	Type   FilterType
+	// Ops are the allowed operators, defaults to the ones suited to Type.
+	Ops []FilterOp
+}
+
+// AllowedOps returns the operators allowed on the field.
+func (f FilterField) AllowedOps() []FilterOp {
+	if len(f.Ops) == 0 {
+		return append([]FilterOp(nil), defaultOps[f.Type]...)
+	}
+	return append([]FilterOp(nil), f.Ops...)
+}
+
+// FilterSchema maps the filterable fields, by name, to their definition. Filters are
+// written `field=op:value`, ie. `status=eq:active&created_at=gte:2024-01-01`, the operator
+// defaulting to eq when omitted. A field can be repeated to add more conditions.
+type FilterSchema map[string]FilterField
+
+// Filter is a condition on a field, see FilterSchema.
+type Filter struct {
+	Column string
+	Op     FilterOp
+	Value  interface{}
+}
+
+// ToSql implements sq.Sqlizer.
+func (f Filter) ToSql() (string, []interface{}, error) {
+	switch f.Op {
+	case OpEq, OpIn:
+		return sq.Eq{f.Column: f.Value}.ToSql()
+	case OpNe:
+		return sq.NotEq{f.Column: f.Value}.ToSql()
+	case OpLt:
+		return sq.Lt{f.Column: f.Value}.ToSql()
+	case OpLte:
+		return sq.LtOrEq{f.Column: f.Value}.ToSql()
+	case OpGt:
+		return sq.Gt{f.Column: f.Value}.ToSql()
+	case OpGte:
+		return sq.GtOrEq{f.Column: f.Value}.ToSql()
+	case OpLike:
+		return sq.ILike{f.Column: f.Value}.ToSql()
+	}
+	return "", nil, fmt.Errorf("pgkit: unknown filter operator %q", f.Op)
+}
+
+// Filters are the conditions of a request, all of which must match. It can be passed to
+// Paginator.PrepareQuery with WithFilters.
+type Filters []Filter
+
+// ToSql implements sq.Sqlizer.
+func (f Filters) ToSql() (string, []interface{}, error) {
+	and := make(sq.And, len(f))
+	for i := range f {
+		and[i] = f[i]
+	}
+	return and.ToSql()
+}
+
+// FiltersFromRequest parses the filters from the query parameters of r, see FiltersFromValues.
+func (s FilterSchema) FiltersFromRequest(r *http.Request) (Filters, error) {
+	return s.FiltersFromValues(r.URL.Query())
+}
+
+// FiltersFromValues parses the filters from query parameters, the parameters which are not
+// fields of the schema, ie. page and sort, are ignored.
+func (s FilterSchema) FiltersFromValues(values url.Values) (Filters, error) {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		if _, ok := s[name]; ok {
+			names = append(names, name)
+		}
+	}
+	// keep the query text stable
+	sort.Strings(names)
+
+	var filters Filters
+	for _, name := range names {
+		for _, v := range values[name] {
+			f, err := s.parse(name, v)
+			if err != nil {
+				return nil, err
+			}
+			filters = append(filters, f)
+		}
+	}
+	return filters, nil
+}
+
+// FiltersFromJSON parses the filters from a JSON object with the same format as the query
+// parameters, the values being a string or a list of strings, ie.
+// `{"status": "eq:active", "created_at": ["gte:2024-01-01", "lt:2025-01-01"]}`.
+// Unlike in FiltersFromValues, unknown fields are rejected.
+func (s FilterSchema) FiltersFromJSON(data []byte) (Filters, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("pgkit: invalid filters: %w", err)
+	}
+	values := make(url.Values, len(raw))
+	for name, v := range raw {
+		if _, ok := s[name]; !ok {
+			return nil, fmt.Errorf("pgkit: unknown filter field %q", name)
+		}
+		var list []string
+		if err := json.Unmarshal(v, &list); err != nil {
+			var one string
+			if err := json.Unmarshal(v, &one); err != nil {
+				return nil, fmt.Errorf("pgkit: invalid filter %q, expecting a string or a list of strings", name)
+			}
+			list = []string{one}
+		}
+		values[name] = list
+	}
+	return s.FiltersFromValues(values)
+}
+
+// parse parses the `op:value` filter of a field.
+func (s FilterSchema) parse(name, v string) (Filter, error) {
+	field := s[name]
+	f := Filter{Column: field.Column, Op: OpEq}
+	if f.Column == "" {
+		f.Column = name
+	}
+	// the value may contain colons, ie. a time, the prefix is the operator only if it's known
+	if i := strings.Index(v, ":"); i >= 0 {
+		if FilterOp(v[:i]).valid() {
+			f.Op, v = FilterOp(v[:i]), v[i+1:]
+		}
+	}
+
+	allowed := false
+	for _, op := range field.AllowedOps() {
+		allowed = allowed || op == f.Op
+	}
+	if !allowed {
+		return f, fmt.Errorf("pgkit: filter operator %q is not allowed on %q", f.Op, name)
+	}
+
+	if f.Op == OpIn {
+		parts := strings.Split(v, ",")
+		list := make([]interface{}, len(parts))
+		for i, part := range parts {
+			value, err := field.Type.convert(part)
+			if err != nil {
+				return f, fmt.Errorf("pgkit: invalid filter %q: %w", name, err)
+			}
+			list[i] = value
+		}
+		f.Value = list
+		return f, nil
+	}
+	if f.Op == OpLike {
+		f.Value = v
+		return f, nil
+	}
+	value, err := field.Type.convert(v)
+	if err != nil {
+		return f, fmt.Errorf("pgkit: invalid filter %q: %w", name, err)
+	}
+	f.Value = value
+	return f, nil
+}
+
+func (op FilterOp) valid() bool {
+	switch op {
+	case OpEq, OpNe, OpLt, OpLte, OpGt, OpGte, OpLike, OpIn:
+		return true
+	}
+	return false
+}
+
+var _MatcherUUID = regexp.MustCompile(`^[0-9a-fA-F]{8}-?[0-9a-fA-F]{4}-?[0-9a-fA-F]{4}-?[0-9a-fA-F]{4}-?[0-9a-fA-F]{12}$`)
+
+// convert converts a filter value to the type.
+func (t FilterType) convert(v string) (interface{}, error) {
+	switch t {
+	case FilterString:
+		return v, nil
+	case FilterInt:
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("expecting an integer, got %q", v)
+		}
+		return n, nil
+	case FilterTime:
+		t, err := parseTime(v)
+		if err != nil {
+			return nil, fmt.Errorf("expecting a time, got %q", v)
+		}
+		return t, nil
+	case FilterUUID:
+		if !_MatcherUUID.MatchString(v) {
+			return nil, fmt.Errorf("expecting a uuid, got %q", v)
+		}
+		return v, nil
+	case FilterBool:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("expecting a boolean, got %q", v)
+		}
+		return b, nil
+	case FilterDecimal:
+		d, err := ParseDecimal(v)
+		if err != nil {
+			return nil, fmt.Errorf("expecting a decimal, got %q", v)
+		}
+		return d, nil
+	}
+	return nil, fmt.Errorf("unknown filter type %d", t)
+}
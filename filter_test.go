@@ -0,0 +1,79 @@
+package pgkit_test
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/goware/pgkit/v2"
+	"github.com/stretchr/testify/require"
+)
+
+var filterSchema = pgkit.FilterSchema{
+	"status":     {},
+	"created_at": {Type: pgkit.FilterTime},
+	"rank":       {Column: "score", Type: pgkit.FilterInt},
+	"account":    {Column: "account_id", Type: pgkit.FilterUUID, Ops: []pgkit.FilterOp{pgkit.OpEq}},
+	"disabled":   {Type: pgkit.FilterBool},
+	"balance":    {Type: pgkit.FilterDecimal},
+}
+
+func TestFiltersFromValues(t *testing.T) {
+	values, err := url.ParseQuery("status=active&created_at=gte:2024-01-01&created_at=lt:2024-02-01T10:00:00Z&rank=in:1,2&disabled=false&page=2&sort=-id")
+	require.NoError(t, err)
+
+	filters, err := filterSchema.FiltersFromValues(values)
+	require.NoError(t, err)
+	require.Equal(t, pgkit.Filters{
+		{Column: "created_at", Op: pgkit.OpGte, Value: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{Column: "created_at", Op: pgkit.OpLt, Value: time.Date(2024, 2, 1, 10, 0, 0, 0, time.UTC)},
+		{Column: "disabled", Op: pgkit.OpEq, Value: false},
+		{Column: "score", Op: pgkit.OpIn, Value: []interface{}{int64(1), int64(2)}},
+		{Column: "status", Op: pgkit.OpEq, Value: "active"},
+	}, filters)
+
+	paginator := pgkit.NewPaginator[T]()
+	_, query := paginator.PrepareQuery(sq.Select("*").From("t"), nil, pgkit.WithFilters(filters))
+	sql, args, err := query.ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "SELECT * FROM t WHERE (created_at >= ? AND created_at < ? AND disabled = ? AND score IN (?,?) AND status = ?) LIMIT 11 OFFSET 0", sql)
+	require.Len(t, args, 6)
+
+	_, query = paginator.PrepareQuery(sq.Select("*").From("t"), nil, pgkit.WithFilters(pgkit.Filters{}))
+	sql, _, err = query.ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "SELECT * FROM t LIMIT 11 OFFSET 0", sql)
+}
+
+func TestFiltersErrors(t *testing.T) {
+	for _, query := range []string{
+		"rank=ten",
+		"created_at=yesterday",
+		"created_at=like:2024",
+		"account=ne:0b6e1bc4-1fa5-4a52-9bd8-4f3d8e0c7f1e",
+		"account=nope",
+		"disabled=maybe",
+		"balance=gt:1,5",
+	} {
+		values, err := url.ParseQuery(query)
+		require.NoError(t, err)
+		_, err = filterSchema.FiltersFromValues(values)
+		require.Error(t, err, query)
+	}
+}
+
+func TestFiltersFromJSON(t *testing.T) {
+	filters, err := filterSchema.FiltersFromJSON([]byte(`{"status": "like:act%", "account": ["0b6e1bc4-1fa5-4a52-9bd8-4f3d8e0c7f1e"], "balance": "gte:10.05"}`))
+	require.NoError(t, err)
+	require.Equal(t, pgkit.Filters{
+		{Column: "account_id", Op: pgkit.OpEq, Value: "0b6e1bc4-1fa5-4a52-9bd8-4f3d8e0c7f1e"},
+		{Column: "balance", Op: pgkit.OpGte, Value: pgkit.NewDecimal(1005, -2)},
+		{Column: "status", Op: pgkit.OpLike, Value: "act%"},
+	}, filters)
+
+	_, err = filterSchema.FiltersFromJSON([]byte(`{"owner": "joe"}`))
+	require.Error(t, err)
+	_, err = filterSchema.FiltersFromJSON([]byte(`{"status": 1}`))
+	require.Error(t, err)
+}
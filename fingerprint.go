@@ -0,0 +1,149 @@
+package pgkit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// Fingerprint identifies the shape of a statement: literals and placeholders are replaced
+// by `?`, lists of values are collapsed, comments are dropped, and whitespace and the case
+// of keywords are normalized, then the result is hashed. Statements differing only by their
+// values share the same fingerprint, ie. `SELECT * FROM t WHERE id IN (1, 2)` and
+// `select * from t where id in ($1,$2,$3)`.
+func Fingerprint(sql string) string {
+	sum := sha256.Sum256([]byte(normalizeSQL(sql)))
+	return hex.EncodeToString(sum[:8])
+}
+
+// normalizeSQL returns the normalized statement hashed by Fingerprint.
+func normalizeSQL(sql string) string {
+	return strings.Join(tokenizeSQL(sql), " ")
+}
+
+// tokenizeSQL splits the statement in normalized tokens, see Fingerprint.
+func tokenizeSQL(sql string) []string {
+	tokens := make([]string, 0, 32)
+	push := func(tok string) {
+		// collapse lists of values: ? , ? , ? => ?
+		if tok == "?" && len(tokens) >= 2 && tokens[len(tokens)-1] == "," && tokens[len(tokens)-2] == "?" {
+			tokens = tokens[:len(tokens)-1]
+			return
+		}
+		tokens = append(tokens, tok)
+	}
+
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		switch {
+		case isSpace(c):
+			i++
+
+		case c == '-' && i+1 < len(sql) && sql[i+1] == '-':
+			for i < len(sql) && sql[i] != '\n' {
+				i++
+			}
+
+		case c == '/' && i+1 < len(sql) && sql[i+1] == '*':
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				i = len(sql)
+			} else {
+				i += end + 4
+			}
+
+		case c == '\'':
+			i = skipQuoted(sql, i, '\'', false)
+			push("?")
+
+		case c == '"':
+			j := skipQuoted(sql, i, '"', false)
+			push(sql[i:j])
+			i = j
+
+		case c == '$' && i+1 < len(sql) && isDigit(sql[i+1]):
+			for i++; i < len(sql) && isDigit(sql[i]); i++ {
+			}
+			push("?")
+
+		case c == '$':
+			// dollar quoted string: $$...$$ or $tag$...$tag$
+			j := i + 1
+			for j < len(sql) && isIdent(sql[j]) {
+				j++
+			}
+			if j < len(sql) && sql[j] == '$' {
+				tag := sql[i : j+1]
+				if end := strings.Index(sql[j+1:], tag); end >= 0 {
+					i = j + 1 + end + len(tag)
+				} else {
+					i = len(sql)
+				}
+				push("?")
+				continue
+			}
+			push("$")
+			i++
+
+		case isDigit(c) || (c == '.' && i+1 < len(sql) && isDigit(sql[i+1])):
+			for i < len(sql) && (isDigit(sql[i]) || sql[i] == '.' || sql[i] == 'e' || sql[i] == 'E') {
+				i++
+			}
+			push("?")
+
+		case isIdent(c):
+			j := i
+			for j < len(sql) && isIdent(sql[j]) {
+				j++
+			}
+			// E'...', B'...' and X'...' string constants, only E'...' has backslash escapes
+			if j < len(sql) && sql[j] == '\'' && j-i == 1 {
+				i = skipQuoted(sql, j, '\'', c == 'E' || c == 'e')
+				push("?")
+				continue
+			}
+			push(strings.ToLower(sql[i:j]))
+			i = j
+
+		case strings.IndexByte("+-*/<>=~!@#%^&|`:", c) >= 0:
+			j := i + 1
+			for j < len(sql) && strings.IndexByte("+-*/<>=~!@#%^&|`:", sql[j]) >= 0 {
+				j++
+			}
+			push(sql[i:j])
+			i = j
+
+		default:
+			push(string(c))
+			i++
+		}
+	}
+	return tokens
+}
+
+// skipQuoted returns the position after the quoted string starting at i, doubled quotes
+// escape the quote, as do backslashes when backslash is set, ie. in E'...' strings: plain
+// strings don't have backslash escapes, as standard_conforming_strings is on by default.
+func skipQuoted(s string, i int, quote byte, backslash bool) int {
+	for i++; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if backslash {
+				i++
+			}
+		case quote:
+			if i+1 < len(s) && s[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+func isSpace(c byte) bool { return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' }
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+func isIdent(c byte) bool {
+	return c == '_' || isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
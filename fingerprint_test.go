@@ -0,0 +1,43 @@
+package pgkit_test
+
+import (
+	"testing"
+
+	"github.com/goware/pgkit/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFingerprint(t *testing.T) {
+	same := [][]string{
+		{
+			`SELECT * FROM accounts WHERE id IN (1, 2) AND name = 'joe'`,
+			"select *\n  from accounts -- comment\n  where id in ($1,$2,$3) and name=$4",
+			`SELECT /* hint */ * FROM accounts WHERE id IN (?) AND name = E'it''s'`,
+		},
+		{
+			`INSERT INTO reviews (name, rating) VALUES ($1, 4.5)`,
+			`insert into reviews (name,rating) values ('x', 1)`,
+		},
+		{
+			// backslashes only escape in E'...' strings
+			`SELECT * FROM files WHERE path = 'C:\' AND id = 1`,
+			`select * from files where path = E'C:\\' and id = $1`,
+			`SELECT * FROM files WHERE path = E'it\'s' AND id = 2`,
+		},
+		{
+			`SELECT $$a 'quoted' body$$::text`,
+			`SELECT $tag$other$tag$::text`,
+		},
+	}
+	for _, group := range same {
+		for _, sql := range group[1:] {
+			require.Equal(t, pgkit.Fingerprint(group[0]), pgkit.Fingerprint(sql), sql)
+		}
+	}
+
+	require.NotEqual(t, pgkit.Fingerprint(`SELECT * FROM accounts`), pgkit.Fingerprint(`SELECT * FROM reviews`))
+	require.NotEqual(t, pgkit.Fingerprint(`SELECT "Name" FROM t`), pgkit.Fingerprint(`SELECT "name" FROM t`))
+	require.NotEqual(t, pgkit.Fingerprint(`SELECT a FROM t1`), pgkit.Fingerprint(`SELECT a FROM t2`))
+	require.NotEqual(t, pgkit.Fingerprint(`SELECT * FROM files WHERE path = 'C:\' AND id = 1`), pgkit.Fingerprint(`SELECT * FROM files WHERE path = 'C:\' AND owner = 1`))
+	require.Len(t, pgkit.Fingerprint(`SELECT 1`), 16)
+}
@@ -0,0 +1,242 @@
+package pgkit
+
+import (
+	"encoding"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/goware/pgkit/v2/internal/reflectx"
+)
+
+// Grid serves the rows of a query to admin "data grid" frontends, from the request parameters:
+//
+//	fields=id,name           the columns returned, defaults to all of them
+//	name=joe&id=gt:10        filters, see FilterSchema
+//	sort=-created_at,id      the sort, see Page.Column
+//	page=2&size=25           the page
+//	format=csv               exports all the matching rows as CSV instead of a JSON page
+type Grid[T any] struct {
+	Querier *Querier
+	// Query selects the rows, its columns are replaced by the requested ones.
+	Query sq.SelectBuilder
+	// Columns are the columns which can be returned, filtered and sorted, they must match
+	// the `db` tags of T.
+	Columns []string
+	// Filters are the fields the rows can be filtered on. It defaults to the columns, as
+	// strings parsed by postgres according to the column type, with all the operators.
+	Filters   FilterSchema
+	Paginator Paginator[T]
+}
+
+// GridPage is the JSON response of a Grid.
+type GridPage struct {
+	Page *Page                    `json:"page"`
+	Rows []map[string]interface{} `json:"rows"`
+}
+
+// Handle serves the request.
+func (g Grid[T]) Handle(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	fields, err := g.fields(params.Get("fields"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	q, err := g.filter(g.Query.RemoveColumns().Columns(fields...), params)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if params.Get("format") == "csv" {
+		out := &countWriter{ResponseWriter: w}
+		if err := g.exportCSV(out, r, q, fields, params.Get("sort")); err != nil {
+			if out.n > 0 {
+				// the client mustn't mistake a truncated file for a complete one
+				panic(http.ErrAbortHandler)
+			}
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	paginator := g.paginator()
+	page, err := PageFromValues(params, func(o *PaginatorOption) { *o = paginator.PaginatorOption })
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	result, q := paginator.PrepareQueryContext(r.Context(), q, page)
+	if err := g.Querier.GetAll(r.Context(), q, &result); err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	result = paginator.PrepareResult(result, page)
+
+	resp := GridPage{Page: page, Rows: make([]map[string]interface{}, len(result))}
+	for i := range result {
+		values := g.values(result[i], fields)
+		resp.Rows[i] = make(map[string]interface{}, len(fields))
+		for j, f := range fields {
+			resp.Rows[i][f] = values[j]
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// exportCSV streams all the rows matching q. Once it fails, part of the file may have been
+// written to w.
+func (g Grid[T]) exportCSV(w http.ResponseWriter, r *http.Request, q sq.SelectBuilder, fields []string, sortBy string) error {
+	q = q.OrderBy(g.paginator().getOrder(&Page{Column: sortBy})...)
+	rows, err := g.Querier.QueryRows(r.Context(), q)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	w.Header().Set("Content-Type", "text/csv")
+	out := csv.NewWriter(w)
+	out.Write(fields)
+	scanner := pgxscan.NewRowScanner(rows)
+	record := make([]string, len(fields))
+	for rows.Next() {
+		var row T
+		if err := scanner.Scan(&row); err != nil {
+			return wrapErr(err)
+		}
+		for i, v := range g.values(row, fields) {
+			record[i] = csvValue(v)
+		}
+		if err := out.Write(record); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return wrapErr(err)
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// countWriter counts the bytes written to the response.
+type countWriter struct {
+	http.ResponseWriter
+	n int
+}
+
+func (w *countWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	w.n += n
+	return n, err
+}
+
+// fields returns the requested columns.
+func (g Grid[T]) fields(list string) ([]string, error) {
+	if list == "" {
+		return g.Columns, nil
+	}
+	allowed := g.allowed()
+	fields := strings.Split(list, ",")
+	for _, f := range fields {
+		if _, ok := allowed[f]; !ok {
+			return nil, fmt.Errorf("unknown field %q", f)
+		}
+	}
+	return fields, nil
+}
+
+// filter adds the filters found in params to q, rejecting the unknown parameters.
+func (g Grid[T]) filter(q sq.SelectBuilder, params url.Values) (sq.SelectBuilder, error) {
+	schema := g.filters()
+	for key := range params {
+		switch key {
+		case "fields", "sort", "page", "size", "format":
+			continue
+		}
+		if _, ok := schema[key]; !ok {
+			return q, fmt.Errorf("unknown filter %q", key)
+		}
+	}
+	filters, err := schema.FiltersFromValues(params)
+	if err != nil {
+		return q, err
+	}
+	return where(q, []sq.Sqlizer{filters}), nil
+}
+
+// filters returns the filter schema of the grid.
+func (g Grid[T]) filters() FilterSchema {
+	if g.Filters != nil {
+		return g.Filters
+	}
+	ops := []FilterOp{OpEq, OpNe, OpLt, OpLte, OpGt, OpGte, OpLike, OpIn}
+	schema := make(FilterSchema, len(g.Columns))
+	for _, c := range g.Columns {
+		schema[c] = FilterField{Type: FilterString, Ops: ops}
+	}
+	return schema
+}
+
+// paginator returns the grid paginator, restricted to the grid columns.
+func (g Grid[T]) paginator() Paginator[T] {
+	p := g.Paginator
+	p.allowedColumns = g.allowed()
+	return p
+}
+
+func (g Grid[T]) allowed() map[string]string {
+	m := make(map[string]string, len(g.Columns))
+	for _, c := range g.Columns {
+		m[c] = c
+	}
+	return m
+}
+
+// values returns the values of the fields of row.
+func (g Grid[T]) values(row T, fields []string) []interface{} {
+	v := reflect.Indirect(reflect.ValueOf(row))
+	typeMap := Mapper.TypeMap(v.Type())
+	values := make([]interface{}, len(fields))
+	for i, f := range fields {
+		if field := typeMap.GetByPath(f); field != nil {
+			values[i] = reflectx.FieldByIndexesReadOnly(v, field.Index).Interface()
+		}
+	}
+	return values
+}
+
+func csvValue(v interface{}) string {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return ""
+	}
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return ""
+		}
+		rv = rv.Elem()
+	}
+	switch s := rv.Interface().(type) {
+	case encoding.TextMarshaler:
+		if text, err := s.MarshalText(); err == nil {
+			return string(text)
+		}
+	case fmt.Stringer:
+		return s.String()
+	}
+	if rv.Kind() == reflect.Struct || rv.Kind() == reflect.Map || rv.Kind() == reflect.Slice {
+		if data, err := json.Marshal(rv.Interface()); err == nil {
+			return string(data)
+		}
+	}
+	return fmt.Sprint(rv.Interface())
+}
@@ -0,0 +1,71 @@
+package pgkit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/lann/builder"
+)
+
+// ETagQuery returns the query computing the max of the updatedAt column and the number of
+// rows matched by q, regardless of its limit, offset and order, see ListETag.
+func ETagQuery(q sq.SelectBuilder, updatedAtColumn string) sq.SelectBuilder {
+	inner := removeOrderBy(q.RemoveLimit().RemoveOffset()).RemoveColumns().Columns(updatedAtColumn + " AS updated_at")
+	return sq.Select("max(updated_at)", "count(*)").FromSelect(inner, "etag").PlaceholderFormat(sq.Dollar)
+}
+
+// ListETag computes a weak ETag for a list response from the query serving it, which
+// usually includes the pagination, and the state of the rows it reads: the last time one
+// was updated and how many they are, as returned by ETagQuery.
+func ListETag(query Sqlizer, maxUpdatedAt *time.Time, count int64) (string, error) {
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return "", wrapErr(err)
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%v\n%d\n", sql, args, count)
+	if maxUpdatedAt != nil {
+		fmt.Fprint(h, maxUpdatedAt.UnixNano())
+	}
+	return `W/"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`, nil
+}
+
+// ListETag runs ETagQuery for query and returns its ListETag.
+func (q *Querier) ListETag(ctx context.Context, query sq.SelectBuilder, updatedAtColumn string) (string, error) {
+	var (
+		maxUpdatedAt *time.Time
+		count        int64
+	)
+	if err := q.QueryRow(ctx, ETagQuery(query, updatedAtColumn)).Scan(&maxUpdatedAt, &count); err != nil {
+		return "", wrapErr(err)
+	}
+	return ListETag(query, maxUpdatedAt, count)
+}
+
+// CheckNotModified sets the ETag header of the response and, when the request
+// If-None-Match header matches it, writes a 304 Not Modified response and returns true.
+func CheckNotModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set("ETag", etag)
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	for _, candidate := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// removeOrderBy removes the ORDER BY clause of q, squirrel doesn't expose it.
+func removeOrderBy(q sq.SelectBuilder) sq.SelectBuilder {
+	return builder.Delete(q, "OrderByParts").(sq.SelectBuilder)
+}
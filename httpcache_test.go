@@ -0,0 +1,38 @@
+package pgkit_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/goware/pgkit/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListETag(t *testing.T) {
+	q := sq.Select("id", "name").From("accounts").Where(sq.Eq{"disabled": false}).OrderBy("name").Limit(10).Offset(20).PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := pgkit.ETagQuery(q, "created_at").ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "SELECT max(updated_at), count(*) FROM (SELECT created_at AS updated_at FROM accounts WHERE disabled = $1) AS etag", sql)
+	require.Equal(t, []interface{}{false}, args)
+
+	now := time.Now()
+	etag, err := pgkit.ListETag(q, &now, 134)
+	require.NoError(t, err)
+	other, err := pgkit.ListETag(q, &now, 135)
+	require.NoError(t, err)
+	require.NotEqual(t, etag, other)
+
+	r := httptest.NewRequest(http.MethodGet, "/accounts", nil)
+	r.Header.Set("If-None-Match", `"abc", `+etag)
+	w := httptest.NewRecorder()
+	require.True(t, pgkit.CheckNotModified(w, r, etag))
+	require.Equal(t, http.StatusNotModified, w.Code)
+
+	w = httptest.NewRecorder()
+	require.False(t, pgkit.CheckNotModified(w, r, other))
+	require.Equal(t, other, w.Header().Get("ETag"))
+}
@@ -0,0 +1,272 @@
+package pgkit
+
+import (
+	"bufio"
+	"context"
+	"encoding"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/goware/pgkit/v2/internal/reflectx"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Importer loads records of type T from CSV or NDJSON streams into a table. Records are
+// validated, then upserted in batches, each batch in its own transaction. When a batch
+// fails on a record, with a data exception or an integrity constraint violation, its records
+// are retried one by one within savepoints so a single bad record only rejects itself. Every
+// rejected record is reported with its line number, the other errors abort the import.
+type Importer[T any] struct {
+	Table string
+	// Conflict lists the columns of the unique constraint used to upsert, when empty the
+	// records are just inserted.
+	Conflict []string
+	// BatchSize is the number of records per batch, defaults to 500.
+	BatchSize int
+	// Validate rejects invalid records before they reach the database.
+	Validate func(record T) error
+}
+
+// ImportError is a rejected record.
+type ImportError struct {
+	Line int
+	Err  error
+}
+
+func (e ImportError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+// ImportReport summarizes an import.
+type ImportReport struct {
+	Imported int
+	Errors   []ImportError
+}
+
+type importRecord[T any] struct {
+	line   int
+	record T
+}
+
+// ImportNDJSON imports one JSON encoded record per line, decoded with encoding/json.
+func (im Importer[T]) ImportNDJSON(ctx context.Context, db *DB, r io.Reader) (ImportReport, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	return im.run(ctx, db, func() (importRecord[T], error) {
+		for scanner.Scan() {
+			line++
+			if strings.TrimSpace(scanner.Text()) == "" {
+				continue
+			}
+			rec := importRecord[T]{line: line}
+			err := json.Unmarshal(scanner.Bytes(), &rec.record)
+			return rec, err
+		}
+		if err := scanner.Err(); err != nil {
+			return importRecord[T]{}, err
+		}
+		return importRecord[T]{}, io.EOF
+	})
+}
+
+// ImportCSV imports CSV records, the first line being a header with the column names,
+// which are matched with the `db` tags of T. Empty values of pointer fields are NULL.
+func (im Importer[T]) ImportCSV(ctx context.Context, db *DB, r io.Reader) (ImportReport, error) {
+	reader := csv.NewReader(r)
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+	if err != nil {
+		return ImportReport{}, wrapErr(fmt.Errorf("failed to read csv header: %w", err))
+	}
+
+	fields := make([]*reflectx.FieldInfo, len(header))
+	typeMap := Mapper.TypeMap(reflect.TypeOf((*T)(nil)).Elem())
+	for i, name := range header {
+		if fields[i] = typeMap.GetByPath(strings.TrimSpace(name)); fields[i] == nil {
+			return ImportReport{}, wrapErr(fmt.Errorf("unknown csv column %q", name))
+		}
+	}
+
+	return im.run(ctx, db, func() (importRecord[T], error) {
+		values, err := reader.Read()
+		if err != nil {
+			return importRecord[T]{}, err
+		}
+		line, _ := reader.FieldPos(0)
+		rec := importRecord[T]{line: line}
+		v := reflect.ValueOf(&rec.record).Elem()
+		for i, value := range values {
+			if err := setField(reflectx.FieldByIndexes(v, fields[i].Index), value); err != nil {
+				return rec, fmt.Errorf("column %q: %w", header[i], err)
+			}
+		}
+		return rec, nil
+	})
+}
+
+func (im Importer[T]) run(ctx context.Context, db *DB, next func() (importRecord[T], error)) (ImportReport, error) {
+	var report ImportReport
+	size := im.BatchSize
+	if size <= 0 {
+		size = 500
+	}
+
+	batch := make([]importRecord[T], 0, size)
+	for {
+		rec, err := next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if rec.line == 0 && !errors.As(err, &parseErr) {
+				return report, wrapErr(err)
+			}
+			if parseErr != nil {
+				rec.line = parseErr.Line
+			}
+			report.Errors = append(report.Errors, ImportError{Line: rec.line, Err: err})
+			continue
+		}
+		if im.Validate != nil {
+			if err := im.Validate(rec.record); err != nil {
+				report.Errors = append(report.Errors, ImportError{Line: rec.line, Err: err})
+				continue
+			}
+		}
+		if batch = append(batch, rec); len(batch) == size {
+			if err := im.flush(ctx, db, batch, &report); err != nil {
+				return report, err
+			}
+			batch = batch[:0]
+		}
+	}
+	if len(batch) > 0 {
+		if err := im.flush(ctx, db, batch, &report); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+func (im Importer[T]) flush(ctx context.Context, db *DB, batch []importRecord[T], report *ImportReport) error {
+	return pgx.BeginFunc(ctx, db.Pool(), func(tx pgx.Tx) error {
+		records := make([]T, len(batch))
+		for i := range batch {
+			records[i] = batch[i].record
+		}
+		err := im.exec(ctx, db, tx, records)
+		if err == nil {
+			report.Imported += len(batch)
+			return nil
+		}
+		if !isDataError(err) {
+			return err
+		}
+		for _, rec := range batch {
+			if err := im.exec(ctx, db, tx, []T{rec.record}); err != nil {
+				if !isDataError(err) {
+					return err
+				}
+				report.Errors = append(report.Errors, ImportError{Line: rec.line, Err: err})
+				continue
+			}
+			report.Imported++
+		}
+		return nil
+	})
+}
+
+// isDataError reports whether err is caused by the values of a record: a data exception
+// (class 22), ie. an invalid value, or an integrity constraint violation (class 23).
+func isDataError(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && (strings.HasPrefix(pgErr.Code, "22") || strings.HasPrefix(pgErr.Code, "23"))
+}
+
+// exec upserts the records within a savepoint of tx.
+func (im Importer[T]) exec(ctx context.Context, db *DB, tx pgx.Tx, records []T) error {
+	q := db.SQL.InsertRecords(records, im.Table)
+	if len(im.Conflict) > 0 && q.Err() == nil {
+		cols, _, err := Map(records[0])
+		if err != nil {
+			return err
+		}
+		conflict := make(map[string]bool, len(im.Conflict))
+		target := make([]string, len(im.Conflict))
+		for i, c := range im.Conflict {
+			conflict[c] = true
+			target[i] = pgx.Identifier{c}.Sanitize()
+		}
+		set := make([]string, 0, len(cols))
+		for _, c := range cols {
+			if !conflict[c] {
+				ident := pgx.Identifier{c}.Sanitize()
+				set = append(set, fmt.Sprintf("%s = EXCLUDED.%s", ident, ident))
+			}
+		}
+		suffix := fmt.Sprintf("ON CONFLICT (%s) DO NOTHING", strings.Join(target, ", "))
+		if len(set) > 0 {
+			suffix = fmt.Sprintf("ON CONFLICT (%s) DO UPDATE SET %s", strings.Join(target, ", "), strings.Join(set, ", "))
+		}
+		q.InsertBuilder = q.Suffix(suffix)
+	}
+	return pgx.BeginFunc(ctx, tx, func(sp pgx.Tx) error {
+		_, err := db.TxQuery(sp).Exec(ctx, q)
+		return err
+	})
+}
+
+// setField sets v from its text representation.
+func setField(v reflect.Value, s string) error {
+	if v.Kind() == reflect.Ptr {
+		if s == "" {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		v.Set(reflect.New(v.Type().Elem()))
+		v = v.Elem()
+	}
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	default:
+		return json.Unmarshal([]byte(s), v.Addr().Interface())
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package pgkit
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// ErrUnindexed is returned for the filters and sorts not served by any declared index, see
+// IndexHints.
+var ErrUnindexed = errors.New("pgkit: not served by an index")
+
+// IndexHints declares the indexed column combinations of a table, so the filters and sorts
+// which would scan it whole can be reported, in development, or rejected. The check is a
+// heuristic on btree indexes: filters must constrain the first column of an index, with an
+// operator other than ne or like, and without filters the first sort column must be the
+// first column of an index. Conditions other than Filter are ignored.
+type IndexHints struct {
+	// Indexes are the columns of each index, in order, ie. {{"account_id", "created_at"}}.
+	Indexes [][]string
+	// Strict makes the queries of the paginators created with WithIndexHints fail with
+	// ErrUnindexed, rather than only reporting it.
+	Strict bool
+	// Report is called with the error of each unindexed query, it's optional.
+	Report func(err error)
+}
+
+// Check returns an ErrUnindexed error when the filters and the sort aren't served by any
+// index.
+func (h IndexHints) Check(filters Filters, sort []Sort) error {
+	if len(filters) == 0 && len(sort) == 0 {
+		return nil
+	}
+	indexable := map[string]bool{}
+	for _, f := range filters {
+		switch f.Op {
+		case OpEq, OpIn, OpLt, OpLte, OpGt, OpGte:
+			indexable[f.Column] = true
+		}
+	}
+	for _, index := range h.Indexes {
+		if len(index) == 0 {
+			continue
+		}
+		if len(filters) > 0 {
+			if indexable[index[0]] {
+				return nil
+			}
+			continue
+		}
+		if strings.EqualFold(index[0], strings.Trim(sort[0].Column, `"`)) {
+			return nil
+		}
+	}
+	if len(filters) > 0 {
+		columns := make([]string, len(filters))
+		for i, f := range filters {
+			columns[i] = f.Column
+		}
+		return fmt.Errorf("%w: filtering by %s", ErrUnindexed, strings.Join(columns, ", "))
+	}
+	return fmt.Errorf("%w: sorting by %s", ErrUnindexed, sort[0].Column)
+}
+
+// check reports the unindexed queries, returning the error to fail them with when strict.
+func (h *IndexHints) check(filters []sq.Sqlizer, sort []Sort) error {
+	if h == nil {
+		return nil
+	}
+	var all Filters
+	for _, f := range filters {
+		switch f := f.(type) {
+		case Filters:
+			all = append(all, f...)
+		case Filter:
+			all = append(all, f)
+		}
+	}
+	err := h.Check(all, sort)
+	if err == nil {
+		return nil
+	}
+	if h.Report != nil {
+		h.Report(err)
+	}
+	if h.Strict {
+		return err
+	}
+	return nil
+}
+
+// WithIndexHints checks the filters and the sort of the queries against the indexes, see
+// IndexHints.
+func WithIndexHints(h IndexHints) func(*PaginatorOption) {
+	return func(o *PaginatorOption) { o.indexHints = &h }
+}
@@ -0,0 +1,47 @@
+package pgkit_test
+
+import (
+	"errors"
+	"testing"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/goware/pgkit/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndexHints(t *testing.T) {
+	hints := pgkit.IndexHints{Indexes: [][]string{{"account_id", "created_at"}, {"id"}}}
+	byDate := []pgkit.Sort{{Column: "created_at", Order: pgkit.Desc}}
+
+	require.NoError(t, hints.Check(nil, nil))
+	require.NoError(t, hints.Check(pgkit.Filters{{Column: "account_id", Op: pgkit.OpEq, Value: 1}}, byDate))
+	require.NoError(t, hints.Check(nil, []pgkit.Sort{{Column: "id"}}))
+	require.ErrorIs(t, hints.Check(nil, byDate), pgkit.ErrUnindexed)
+	require.ErrorIs(t, hints.Check(pgkit.Filters{{Column: "created_at", Op: pgkit.OpGte, Value: 1}}, nil), pgkit.ErrUnindexed)
+	require.ErrorIs(t, hints.Check(pgkit.Filters{{Column: "account_id", Op: pgkit.OpNe, Value: 1}}, nil), pgkit.ErrUnindexed)
+
+	var reported []error
+	hints.Report = func(err error) { reported = append(reported, err) }
+	paginator := pgkit.NewPaginator[T](pgkit.WithSort("-created_at"), pgkit.WithIndexHints(hints))
+
+	// reported only
+	_, q := paginator.PrepareQuery(sq.Select("*").From("posts"), nil)
+	_, _, err := q.ToSql()
+	require.NoError(t, err)
+	require.Len(t, reported, 1)
+	require.EqualError(t, reported[0], "pgkit: not served by an index: sorting by created_at")
+
+	filters := pgkit.Filters{{Column: "account_id", Op: pgkit.OpEq, Value: 1}}
+	_, q = paginator.PrepareQuery(sq.Select("*").From("posts"), nil, pgkit.WithFilters(filters))
+	_, _, err = q.ToSql()
+	require.NoError(t, err)
+	require.Len(t, reported, 1)
+
+	// rejected
+	hints.Strict = true
+	_, q = paginator.PrepareQuery(sq.Select("*").From("posts"), nil, pgkit.WithIndexHints(hints))
+	_, _, err = q.ToSql()
+	require.True(t, errors.Is(err, pgkit.ErrUnindexed))
+	_, _, err = pgkit.NewCursorPaginator[T](pgkit.WithSort("-created_at"), pgkit.WithIndexHints(hints)).PrepareQuery(sq.Select("*").From("posts"), nil)
+	require.ErrorIs(t, err, pgkit.ErrUnindexed)
+}
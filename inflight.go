@@ -0,0 +1,192 @@
+package pgkit
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// CancelInFlight cancels all the queries currently run by the DB queriers, ie. on shutdown,
+// returning how many were canceled. The canceled queries fail with an error mentioning
+// reason. Queries run with PriorityCritical, see WithQueryConfig, are left running, as are
+// queries run directly on DB.Pool.
+func (d *DB) CancelInFlight(reason string) int {
+	if d.inflight == nil {
+		return 0
+	}
+	n := d.inflight.cancelAll(reason)
+	if n > 0 {
+		fireAlert(context.Background(), d.Alerter, Alert{
+			Kind:    AlertQueriesCanceled,
+			Message: fmt.Sprintf("%d queries canceled: %s", n, reason),
+		})
+	}
+	return n
+}
+
+// inflight tracks the running queries so they can be canceled.
+type inflight struct {
+	mu      sync.Mutex
+	next    uint64
+	queries map[uint64]*inflightQuery
+}
+
+type inflightQuery struct {
+	inflight *inflight
+	id       uint64
+	cancel   context.CancelFunc
+	reason   string
+	once     sync.Once
+}
+
+func newInflight() *inflight {
+	return &inflight{queries: map[uint64]*inflightQuery{}}
+}
+
+func (f *inflight) middleware(next Executor) Executor {
+	return inflightExecutor{inflight: f, next: next}
+}
+
+// track returns a cancelable context for a query, and the query handle which must be
+// done once the query is over. Critical queries are not tracked.
+func (f *inflight) track(ctx context.Context) (context.Context, *inflightQuery) {
+	if GetQueryConfig(ctx).Priority >= PriorityCritical {
+		return ctx, &inflightQuery{}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	q := &inflightQuery{inflight: f, cancel: cancel}
+
+	f.mu.Lock()
+	q.id = f.next
+	f.next++
+	f.queries[q.id] = q
+	f.mu.Unlock()
+	return ctx, q
+}
+
+func (f *inflight) cancelAll(reason string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := len(f.queries)
+	for id, q := range f.queries {
+		q.reason = reason
+		q.cancel()
+		delete(f.queries, id)
+	}
+	return n
+}
+
+// done stops tracking the query, returning err with the cancellation reason.
+func (q *inflightQuery) done(err error) error {
+	if q.inflight == nil {
+		return err
+	}
+	q.once.Do(func() {
+		q.inflight.mu.Lock()
+		delete(q.inflight.queries, q.id)
+		q.inflight.mu.Unlock()
+		q.cancel()
+	})
+	return q.err(err)
+}
+
+// err returns err with the cancellation reason, if the query was canceled.
+func (q *inflightQuery) err(err error) error {
+	if err == nil || q.inflight == nil {
+		return err
+	}
+	q.inflight.mu.Lock()
+	reason := q.reason
+	q.inflight.mu.Unlock()
+	if reason == "" {
+		return err
+	}
+	return fmt.Errorf("pgkit: query canceled: %s: %w", reason, err)
+}
+
+type inflightExecutor struct {
+	inflight *inflight
+	next     Executor
+}
+
+func (e inflightExecutor) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	ctx, q := e.inflight.track(ctx)
+	tag, err := e.next.Exec(ctx, sql, args...)
+	return tag, q.done(err)
+}
+
+func (e inflightExecutor) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	ctx, q := e.inflight.track(ctx)
+	rows, err := e.next.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, q.done(err)
+	}
+	return &inflightRows{Rows: rows, query: q}, nil
+}
+
+func (e inflightExecutor) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	ctx, q := e.inflight.track(ctx)
+	return inflightRow{row: e.next.QueryRow(ctx, sql, args...), done: q.done}
+}
+
+func (e inflightExecutor) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	ctx, q := e.inflight.track(ctx)
+	return &inflightBatch{BatchResults: e.next.SendBatch(ctx, b), query: q}
+}
+
+type inflightRows struct {
+	pgx.Rows
+	query *inflightQuery
+}
+
+func (r *inflightRows) Next() bool {
+	if r.Rows.Next() {
+		return true
+	}
+	r.query.done(nil)
+	return false
+}
+
+func (r *inflightRows) Close() {
+	r.Rows.Close()
+	r.query.done(nil)
+}
+
+func (r *inflightRows) Err() error {
+	return r.query.err(r.Rows.Err())
+}
+
+type inflightRow struct {
+	row  pgx.Row
+	done func(error) error
+}
+
+func (r inflightRow) Scan(dest ...interface{}) error {
+	return r.done(r.row.Scan(dest...))
+}
+
+type inflightBatch struct {
+	pgx.BatchResults
+	query *inflightQuery
+}
+
+func (b *inflightBatch) Exec() (pgconn.CommandTag, error) {
+	tag, err := b.BatchResults.Exec()
+	return tag, b.query.err(err)
+}
+
+func (b *inflightBatch) Query() (pgx.Rows, error) {
+	rows, err := b.BatchResults.Query()
+	return rows, b.query.err(err)
+}
+
+func (b *inflightBatch) QueryRow() pgx.Row {
+	return inflightRow{row: b.BatchResults.QueryRow(), done: b.query.err}
+}
+
+func (b *inflightBatch) Close() error {
+	return b.query.done(b.BatchResults.Close())
+}
@@ -0,0 +1,60 @@
+package pgkit
+
+import (
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// inlineCountColumn is the column added by WithInlineCount, it's the last one selected.
+const inlineCountColumn = "count(*) OVER () AS pgkit_total"
+
+// ScanInlineCount scans the rows of a query prepared by a paginator created with
+// WithInlineCount into dest, and sets the page total from the count column, which is
+// stripped from the rows. The total is zero when there's no row, ie. past the last page.
+func ScanInlineCount[T any](rows pgx.Rows, dest *[]T, page *Page) error {
+	r := &inlineCountRows{Rows: rows}
+	result, err := Scan[T](r)
+	if err != nil {
+		return err
+	}
+	*dest = append(*dest, result...)
+	if page != nil {
+		// the page was sized by the paginator, which may exceed the max page size
+		page.setTotal(uint64(r.total), page.size())
+	}
+	return nil
+}
+
+// inlineCountRows hides the last column of the rows, scanning it into total.
+type inlineCountRows struct {
+	pgx.Rows
+	total int64
+}
+
+func (r *inlineCountRows) FieldDescriptions() []pgconn.FieldDescription {
+	fields := r.Rows.FieldDescriptions()
+	if len(fields) == 0 {
+		return fields
+	}
+	return fields[:len(fields)-1]
+}
+
+func (r *inlineCountRows) Scan(dest ...interface{}) error {
+	return r.Rows.Scan(append(dest, &r.total)...)
+}
+
+func (r *inlineCountRows) Values() ([]interface{}, error) {
+	values, err := r.Rows.Values()
+	if err != nil || len(values) == 0 {
+		return values, err
+	}
+	return values[:len(values)-1], nil
+}
+
+func (r *inlineCountRows) RawValues() [][]byte {
+	values := r.Rows.RawValues()
+	if len(values) == 0 {
+		return values
+	}
+	return values[:len(values)-1]
+}
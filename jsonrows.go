@@ -0,0 +1,137 @@
+package pgkit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/goware/pgkit/v2/internal/reflectx"
+)
+
+// JSONColumn is a column projected from the objects of a JSON document, see JSONRecordset.
+type JSONColumn struct {
+	// Name is the column name, and the key of the objects it's read from.
+	Name string
+	// Type is the SQL type of the column, ie. "bigint" or "timestamptz".
+	Type string
+	// Path is the SQL/JSON path of the value in JSON_TABLE, defaults to the Name key.
+	Path string
+}
+
+// JSONColumnsOf returns the columns matching the `db` tags of the fields of T, so the rows
+// projected with them can be scanned into T. The SQL types are derived from the Go types,
+// falling back to jsonb for structs, maps and slices.
+func JSONColumnsOf[T any]() []JSONColumn {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	var columns []JSONColumn
+	var walk func(fields []*reflectx.FieldInfo)
+	walk = func(fields []*reflectx.FieldInfo) {
+		for _, f := range fields {
+			if f == nil {
+				continue
+			}
+			if f.Embedded {
+				walk(f.Children)
+				continue
+			}
+			columns = append(columns, JSONColumn{Name: f.Name, Type: sqlType(f.Field.Type)})
+		}
+	}
+	walk(Mapper.TypeMap(typ).Tree.Children)
+	return columns
+}
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	ipType      = reflect.TypeOf(net.IP{})
+	rawJSONType = reflect.TypeOf(json.RawMessage{})
+	decimalType = reflect.TypeOf(Decimal{})
+)
+
+// sqlType returns the SQL type of the Go type.
+func sqlType(t reflect.Type) string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t {
+	case timeType:
+		return "timestamptz"
+	case ipType:
+		return "inet"
+	case rawJSONType:
+		return "jsonb"
+	case decimalType:
+		return "numeric"
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int8, reflect.Int16, reflect.Uint8:
+		return "smallint"
+	case reflect.Int32, reflect.Uint16:
+		return "integer"
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return "bigint"
+	case reflect.Float32:
+		return "real"
+	case reflect.Float64:
+		return "double precision"
+	case reflect.String:
+		return "text"
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "bytea"
+		}
+	}
+	return "jsonb"
+}
+
+// JSONRows is a set of rows projected from a JSON document, to be used as a FROM item: it's
+// joined to the rows holding the document with Join.
+type JSONRows struct {
+	sql string
+}
+
+// ToSql implements sq.Sqlizer.
+func (r JSONRows) ToSql() (string, []interface{}, error) {
+	return r.sql, nil, nil
+}
+
+// Join returns the lateral join of the rows, ie.
+//
+//	DB.SQL.Select("o.id", "i.*").From("orders o").JoinClause(JSONRecordset("o.items", "i", columns...).Join())
+func (r JSONRows) Join() sq.Sqlizer {
+	return sq.Expr("CROSS JOIN LATERAL " + r.sql)
+}
+
+// JSONRecordset expands the array of objects of the JSONB expression doc, ie. a column, into
+// rows with the given columns with jsonb_to_recordset. Missing keys are NULL.
+func JSONRecordset(doc, alias string, columns ...JSONColumn) JSONRows {
+	defs := make([]string, len(columns))
+	for i, c := range columns {
+		defs[i] = quoteIdent(c.Name) + " " + c.Type
+	}
+	return JSONRows{sql: fmt.Sprintf("jsonb_to_recordset(%s) AS %s(%s)", doc, quoteIdent(alias), strings.Join(defs, ", "))}
+}
+
+// JSONTable projects the items matched by the SQL/JSON path of the JSONB expression doc, ie.
+// "$.items[*]", into rows with the given columns using JSON_TABLE. It requires PostgreSQL
+// 17, see FeatureJSONTable.
+func JSONTable(doc, path, alias string, columns ...JSONColumn) JSONRows {
+	defs := make([]string, len(columns))
+	for i, c := range columns {
+		p := c.Path
+		if p == "" {
+			p = fmt.Sprintf("$.%q", c.Name)
+		}
+		defs[i] = fmt.Sprintf("%s %s PATH %s", quoteIdent(c.Name), c.Type, quoteLiteral(p))
+	}
+	return JSONRows{sql: fmt.Sprintf("JSON_TABLE(%s, %s COLUMNS (%s)) AS %s", doc, quoteLiteral(path), strings.Join(defs, ", "), quoteIdent(alias))}
+}
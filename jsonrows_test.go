@@ -0,0 +1,46 @@
+package pgkit_test
+
+import (
+	"testing"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/goware/pgkit/v2"
+	"github.com/stretchr/testify/require"
+)
+
+type lineItem struct {
+	SKU      string            `db:"sku"`
+	Quantity int               `db:"quantity"`
+	Price    *float64          `db:"price"`
+	Meta     map[string]string `db:"meta"`
+	Skipped  string            `db:"-"`
+	lineTimes
+}
+
+type lineTimes struct {
+	ShippedAt time.Time `db:"shipped_at"`
+}
+
+func TestJSONColumnsOf(t *testing.T) {
+	require.Equal(t, []pgkit.JSONColumn{
+		{Name: "sku", Type: "text"},
+		{Name: "quantity", Type: "bigint"},
+		{Name: "price", Type: "double precision"},
+		{Name: "meta", Type: "jsonb"},
+		{Name: "shipped_at", Type: "timestamptz"},
+	}, pgkit.JSONColumnsOf[*lineItem]())
+}
+
+func TestJSONRows(t *testing.T) {
+	columns := []pgkit.JSONColumn{{Name: "sku", Type: "text"}, {Name: "quantity", Type: "int", Path: "$.qty"}}
+
+	q := sq.Select("o.id", "i.*").From("orders o").JoinClause(pgkit.JSONRecordset("o.items", "i", columns...).Join())
+	sql, _, err := q.ToSql()
+	require.NoError(t, err)
+	require.Equal(t, `SELECT o.id, i.* FROM orders o CROSS JOIN LATERAL jsonb_to_recordset(o.items) AS "i"("sku" text, "quantity" int)`, sql)
+
+	sql, _, err = pgkit.JSONTable("o.doc", "$.items[*]", "i", columns...).ToSql()
+	require.NoError(t, err)
+	require.Equal(t, `JSON_TABLE(o.doc, '$.items[*]' COLUMNS ("sku" text PATH '$."sku"', "quantity" int PATH '$.qty')) AS "i"`, sql)
+}
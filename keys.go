@@ -0,0 +1,41 @@
+package pgkit
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// MissingKeys reports which of the keys are absent from the keyCol column of table, in
+// the same order they were given. The keys are bound as a single array, unnested and
+// anti-joined with the table in one round trip, which makes it cheap to decide between
+// insert and update paths when importing large sets of records.
+func MissingKeys[K any](ctx context.Context, q *Querier, table, keyCol string, keys []K) ([]K, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	// the array type can't be inferred from the parameter, use the column's, and the
+	// canonical name of the table so both queries address the same one
+	var ident, keyType string
+	err := q.QueryRow(ctx, RawSQL{
+		Query: `SELECT attrelid::regclass::text, format_type(atttypid, atttypmod) FROM pg_attribute WHERE attrelid = ?::regclass AND attname = ? AND NOT attisdropped`,
+		Args:  []interface{}{quoteIdent(table), keyCol},
+	}).Scan(&ident, &keyType)
+	if err != nil {
+		return nil, wrapErr(fmt.Errorf("failed to lookup type of %s.%s: %w", table, keyCol, err))
+	}
+
+	rows, err := q.QueryRows(ctx, RawSQL{
+		Query: fmt.Sprintf(`SELECT u.k FROM unnest(?::%s[]) WITH ORDINALITY AS u(k, n)
+			WHERE NOT EXISTS (SELECT 1 FROM %s t WHERE t.%s = u.k) ORDER BY u.n`,
+			keyType, ident, pgx.Identifier{keyCol}.Sanitize()),
+		Args: []interface{}{keys},
+	})
+	if err != nil {
+		return nil, err
+	}
+	missing, err := pgx.CollectRows(rows, pgx.RowTo[K])
+	return missing, wrapErr(err)
+}
@@ -0,0 +1,78 @@
+package pgkit
+
+import (
+	"fmt"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// Keyset returns the predicate selecting the rows which follow, under sort, the row whose
+// sort columns have the given values, to seek the next page instead of skipping rows with
+// OFFSET. The sort should end with a unique column, ie. the primary key, and its columns
+// must not be NULL.
+//
+// When all columns are sorted in the same direction a row comparison is used, which can be
+// served by a multicolumn index:
+//
+//	Keyset([]Sort{{"created_at", Desc}, {"id", Desc}}, t, id) // (created_at, id) < (?, ?)
+//
+// Mixed directions are expanded:
+//
+//	Keyset([]Sort{{"name", Asc}, {"id", Desc}}, name, id) // (name > ? OR (name = ? AND id < ?))
+func Keyset(sort []Sort, values ...interface{}) sq.Sqlizer {
+	return keysetPredicate{sort: normalizeSorts(sort), values: values}
+}
+
+type keysetPredicate struct {
+	sort   []Sort
+	values []interface{}
+}
+
+func (k keysetPredicate) ToSql() (string, []interface{}, error) {
+	if len(k.sort) == 0 || len(k.sort) != len(k.values) {
+		return "", nil, fmt.Errorf("pgkit: keyset expects one value per sort column, got %d columns and %d values", len(k.sort), len(k.values))
+	}
+
+	mixed := false
+	for _, s := range k.sort[1:] {
+		mixed = mixed || (s.Order == Desc) != (k.sort[0].Order == Desc)
+	}
+
+	if !mixed {
+		columns := make([]string, len(k.sort))
+		for i, s := range k.sort {
+			columns[i] = s.Column
+		}
+		op := keysetOp(k.sort[0])
+		if len(columns) == 1 {
+			return fmt.Sprintf("%s %s ?", columns[0], op), k.values, nil
+		}
+		marks := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+		return fmt.Sprintf("(%s) %s (%s)", strings.Join(columns, ", "), op, marks), k.values, nil
+	}
+
+	terms := make([]string, len(k.sort))
+	var args []interface{}
+	for i, s := range k.sort {
+		parts := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			parts = append(parts, k.sort[j].Column+" = ?")
+			args = append(args, k.values[j])
+		}
+		parts = append(parts, fmt.Sprintf("%s %s ?", s.Column, keysetOp(s)))
+		args = append(args, k.values[i])
+		terms[i] = strings.Join(parts, " AND ")
+		if i > 0 {
+			terms[i] = "(" + terms[i] + ")"
+		}
+	}
+	return "(" + strings.Join(terms, " OR ") + ")", args, nil
+}
+
+func keysetOp(s Sort) string {
+	if s.Order == Desc {
+		return "<"
+	}
+	return ">"
+}
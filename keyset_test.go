@@ -0,0 +1,51 @@
+package pgkit_test
+
+import (
+	"testing"
+
+	"github.com/goware/pgkit/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyset(t *testing.T) {
+	tests := []struct {
+		sort   []pgkit.Sort
+		values []interface{}
+		sql    string
+		args   []interface{}
+	}{
+		{
+			sort:   []pgkit.Sort{{Column: "id"}},
+			values: []interface{}{1},
+			sql:    "id > ?",
+			args:   []interface{}{1},
+		},
+		{
+			sort:   []pgkit.Sort{{Column: "created_at", Order: pgkit.Desc}, {Column: "id", Order: pgkit.Desc}},
+			values: []interface{}{"t", 1},
+			sql:    "(created_at, id) < (?, ?)",
+			args:   []interface{}{"t", 1},
+		},
+		{
+			sort:   []pgkit.Sort{{Column: "created_at", Order: "desc"}, {Column: "id", Order: pgkit.Desc}},
+			values: []interface{}{"t", 1},
+			sql:    "(created_at, id) < (?, ?)",
+			args:   []interface{}{"t", 1},
+		},
+		{
+			sort:   []pgkit.Sort{{Column: "name", Order: pgkit.Asc}, {Column: "rank", Order: pgkit.Desc}, {Column: "id"}},
+			values: []interface{}{"a", 2, 3},
+			sql:    "(name > ? OR (name = ? AND rank < ?) OR (name = ? AND rank = ? AND id > ?))",
+			args:   []interface{}{"a", "a", 2, "a", 2, 3},
+		},
+	}
+	for _, tt := range tests {
+		sql, args, err := pgkit.Keyset(tt.sort, tt.values...).ToSql()
+		require.NoError(t, err)
+		require.Equal(t, tt.sql, sql)
+		require.Equal(t, tt.args, args)
+	}
+
+	_, _, err := pgkit.Keyset([]pgkit.Sort{{Column: "a"}, {Column: "b"}}, 1).ToSql()
+	require.Error(t, err)
+}
@@ -0,0 +1,204 @@
+package pgkit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// SetConstraintsDeferred defers the checking of the given constraints, or all of them when
+// none is given, until the transaction commits. Only constraints declared DEFERRABLE are
+// affected and the setting never outlives tx.
+func SetConstraintsDeferred(ctx context.Context, tx pgx.Tx, constraints ...string) error {
+	target := "ALL"
+	if len(constraints) > 0 {
+		names := make([]string, len(constraints))
+		for i, c := range constraints {
+			names[i] = quoteIdent(c)
+		}
+		target = strings.Join(names, ", ")
+	}
+	_, err := tx.Exec(ctx, "SET CONSTRAINTS "+target+" DEFERRED")
+	return wrapErr(err)
+}
+
+// DisableTriggers disables the user triggers of table while fn runs, re-enabling them
+// before returning, whether fn fails or panics. fn runs in a savepoint of tx, rolled back
+// when it fails, so the triggers can be re-enabled and tx used afterwards, and the error
+// of fn is returned along with the one re-enabling them if any. Both statements run in tx,
+// so the triggers are never left disabled once the transaction ends, whether it's committed
+// or rolled back. Note that altering the table takes an ACCESS EXCLUSIVE lock on it until
+// the end of the transaction, and that the current user must own the table.
+func DisableTriggers(ctx context.Context, tx pgx.Tx, table string, fn func() error) (err error) {
+	// the table is resolved once, to its canonical name, so both statements alter it
+	var (
+		ident string
+		owner bool
+	)
+	err = tx.QueryRow(ctx, `SELECT oid::regclass::text, pg_has_role(current_user, relowner, 'USAGE') FROM pg_class WHERE oid = $1::regclass`,
+		quoteIdent(table)).Scan(&ident, &owner)
+	if err != nil {
+		return wrapErr(err)
+	}
+	if !owner {
+		return fmt.Errorf("pgkit: current user must own table %q to disable its triggers", table)
+	}
+
+	if _, err := tx.Exec(ctx, "ALTER TABLE "+ident+" DISABLE TRIGGER USER"); err != nil {
+		return wrapErr(err)
+	}
+	defer func() {
+		_, enableErr := tx.Exec(ctx, "ALTER TABLE "+ident+" ENABLE TRIGGER USER")
+		switch {
+		case enableErr == nil:
+		case err == nil:
+			err = wrapErr(enableErr)
+		default:
+			err = fmt.Errorf("%w (re-enabling the triggers: %v)", err, wrapErr(enableErr))
+		}
+	}()
+
+	sp, err := tx.Begin(ctx)
+	if err != nil {
+		return wrapErr(err)
+	}
+	// a no-op once committed
+	defer sp.Rollback(ctx)
+	if err := fn(); err != nil {
+		return err
+	}
+	return wrapErr(sp.Commit(ctx))
+}
+
+// quoteIdent quotes a possibly schema qualified identifier, ie. `public.users`.
+func quoteIdent(name string) string {
+	return pgx.Identifier(strings.Split(name, ".")).Sanitize()
+}
+
+// AnalyzeThreshold is the default number of rows loaded by Querier.CopyFrom above which the
+// table is analyzed right away, so queries on freshly loaded tables don't suffer from stale
+// planner statistics while waiting for autovacuum, see Querier.WithAnalyzeThreshold.
+const AnalyzeThreshold = 10000
+
+// AnalyzeAfter updates the planner statistics of the given tables.
+func AnalyzeAfter(ctx context.Context, db *DB, tables ...string) error {
+	return analyze(ctx, db.Pool(), tables...)
+}
+
+type execer interface {
+	Exec(context.Context, string, ...interface{}) (pgconn.CommandTag, error)
+}
+
+func analyze(ctx context.Context, exec execer, tables ...string) error {
+	if len(tables) == 0 {
+		return nil
+	}
+	idents := make([]string, len(tables))
+	for i, t := range tables {
+		idents[i] = quoteIdent(t)
+	}
+	_, err := exec.Exec(ctx, "ANALYZE "+strings.Join(idents, ", "))
+	return wrapErr(err)
+}
+
+type TruncateOptions struct {
+	// Cascade also truncates the tables referencing the given ones, otherwise an error
+	// listing them is returned.
+	Cascade bool
+	// RestartIdentity resets the sequences owned by the truncated tables.
+	RestartIdentity bool
+}
+
+// Truncate empties the given tables, see TruncateWithOptions.
+func Truncate(ctx context.Context, db *DB, tables ...string) error {
+	return TruncateWithOptions(ctx, db, TruncateOptions{}, tables...)
+}
+
+// TruncateWithOptions empties the given tables in a single statement, ordered by foreign
+// key dependency (referencing tables first). Foreign keys are introspected beforehand so
+// tables referenced from outside the list are reported with a descriptive error, unless
+// Cascade is set. It's mostly meant for test setup and seeding workflows.
+func TruncateWithOptions(ctx context.Context, db *DB, options TruncateOptions, tables ...string) error {
+	if len(tables) == 0 {
+		return nil
+	}
+
+	// canonical names, as returned by regclass
+	rows, err := db.Pool().Query(ctx, `SELECT t::text FROM unnest($1::regclass[]) AS t`, tables)
+	if err != nil {
+		return wrapErr(err)
+	}
+	names, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return wrapErr(err)
+	}
+
+	if !options.Cascade {
+		rows, err := db.Pool().Query(ctx, `
+			SELECT DISTINCT conrelid::regclass::text FROM pg_constraint
+			WHERE contype = 'f' AND confrelid = ANY($1::regclass[]) AND conrelid <> ALL($1::regclass[])
+			ORDER BY 1`, names)
+		if err != nil {
+			return wrapErr(err)
+		}
+		missing, err := pgx.CollectRows(rows, pgx.RowTo[string])
+		if err != nil {
+			return wrapErr(err)
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf("pgkit: can't truncate %s, referenced by %s", strings.Join(names, ", "), strings.Join(missing, ", "))
+		}
+	}
+
+	rows, err = db.Pool().Query(ctx, `
+		SELECT conrelid::regclass::text, confrelid::regclass::text FROM pg_constraint
+		WHERE contype = 'f' AND conrelid = ANY($1::regclass[]) AND confrelid = ANY($1::regclass[])
+		AND conrelid <> confrelid`, names)
+	if err != nil {
+		return wrapErr(err)
+	}
+	deps := map[string][]string{}
+	var child, parent string
+	_, err = pgx.ForEachRow(rows, []interface{}{&child, &parent}, func() error {
+		deps[parent] = append(deps[parent], child)
+		return nil
+	})
+	if err != nil {
+		return wrapErr(err)
+	}
+
+	query := "TRUNCATE TABLE " + strings.Join(sortByDependency(names, deps), ", ")
+	if options.RestartIdentity {
+		query += " RESTART IDENTITY"
+	}
+	if options.Cascade {
+		query += " CASCADE"
+	}
+	_, err = db.Pool().Exec(ctx, query)
+	return wrapErr(err)
+}
+
+// sortByDependency sorts the tables so the ones referencing others (deps maps a table to
+// the tables referencing it) come first, keeping the original order otherwise.
+func sortByDependency(tables []string, deps map[string][]string) []string {
+	sorted := make([]string, 0, len(tables))
+	visited := map[string]bool{}
+	var visit func(string)
+	visit = func(t string) {
+		if visited[t] {
+			return
+		}
+		visited[t] = true
+		for _, child := range deps[t] {
+			visit(child)
+		}
+		sorted = append(sorted, t)
+	}
+	for _, t := range tables {
+		visit(t)
+	}
+	return sorted
+}
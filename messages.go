@@ -0,0 +1,152 @@
+package pgkit
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ErrorCode identifies a kind of error a client can be told about, see DescribeError.
+type ErrorCode string
+
+const (
+	CodeInvalidSort         ErrorCode = "invalid_sort"
+	CodePageSizeExceeded    ErrorCode = "page_size_exceeded"
+	CodeOffsetTooDeep       ErrorCode = "offset_too_deep"
+	CodePageOutOfRange      ErrorCode = "page_out_of_range"
+	CodeUnindexed           ErrorCode = "unindexed"
+	CodeOverBudget          ErrorCode = "over_budget"
+	CodeSunset              ErrorCode = "sunset"
+	CodeUnavailable         ErrorCode = "unavailable"
+	CodeUniqueViolation     ErrorCode = "unique_violation"
+	CodeForeignKeyViolation ErrorCode = "foreign_key_violation"
+	CodeNotNullViolation    ErrorCode = "not_null_violation"
+	CodeCheckViolation      ErrorCode = "check_violation"
+)
+
+// ErrorDetails is the code of an error and its parameters, ie. the "column" of a unique
+// violation, used in the messages of a Catalog.
+type ErrorDetails struct {
+	Code   ErrorCode         `json:"code"`
+	Params map[string]string `json:"params,omitempty"`
+}
+
+// _MatcherKeyDetail matches the detail of unique and foreign key violations, ie.
+// `Key (email)=(joe@example.com) already exists.`
+var _MatcherKeyDetail = regexp.MustCompile(`^Key \((.+?)\)=`)
+
+// DescribeError returns the details of the errors of pgkit and of the constraint
+// violations reported by postgres, false for the other errors.
+func DescribeError(err error) (ErrorDetails, bool) {
+	var (
+		invalidSort ErrInvalidSort
+		exceeded    ErrPageSizeExceeded
+		outOfRange  ErrPageOutOfRange
+		pgErr       *pgconn.PgError
+	)
+	switch {
+	case errors.As(err, &invalidSort):
+		return ErrorDetails{Code: CodeInvalidSort, Params: map[string]string{"column": invalidSort.Column}}, true
+	case errors.As(err, &exceeded):
+		return ErrorDetails{Code: CodePageSizeExceeded, Params: map[string]string{"max": strconv.FormatUint(uint64(exceeded.Max), 10)}}, true
+	case errors.As(err, &outOfRange):
+		return ErrorDetails{Code: CodePageOutOfRange, Params: map[string]string{
+			"page":  strconv.FormatUint(uint64(outOfRange.Page), 10),
+			"pages": strconv.FormatUint(uint64(outOfRange.TotalPages), 10),
+		}}, true
+	case errors.Is(err, ErrOffsetTooDeep):
+		return ErrorDetails{Code: CodeOffsetTooDeep}, true
+	case errors.Is(err, ErrUnindexed):
+		return ErrorDetails{Code: CodeUnindexed}, true
+	case errors.Is(err, ErrOverBudget):
+		return ErrorDetails{Code: CodeOverBudget}, true
+	case errors.Is(err, ErrSunset):
+		return ErrorDetails{Code: CodeSunset}, true
+	case errors.Is(err, ErrShedding):
+		return ErrorDetails{Code: CodeUnavailable}, true
+	case errors.As(err, &pgErr):
+		codes := map[string]ErrorCode{
+			"23505": CodeUniqueViolation,
+			"23503": CodeForeignKeyViolation,
+			"23502": CodeNotNullViolation,
+			"23514": CodeCheckViolation,
+		}
+		code, ok := codes[pgErr.Code]
+		if !ok {
+			return ErrorDetails{}, false
+		}
+		params := map[string]string{"table": pgErr.TableName, "constraint": pgErr.ConstraintName, "column": pgErr.ColumnName}
+		if m := _MatcherKeyDetail.FindStringSubmatch(pgErr.Detail); m != nil && params["column"] == "" {
+			params["column"] = m[1]
+		}
+		return ErrorDetails{Code: code, Params: params}, true
+	}
+	return ErrorDetails{}, false
+}
+
+// Localizer translates error details to a message in a language, ie. a Catalog or an
+// adapter to a translation library.
+type Localizer interface {
+	Localize(lang string, details ErrorDetails) (string, bool)
+}
+
+// Catalog is a Localizer holding the messages by language and code. A message refers to
+// the parameters of the error by name, ie. "{column} is already taken".
+type Catalog map[string]map[ErrorCode]string
+
+// Messages are the built-in English messages.
+var Messages = Catalog{
+	"en": {
+		CodeInvalidSort:         "Sorting by {column} isn't supported.",
+		CodePageSizeExceeded:    "The page size can't be more than {max}.",
+		CodeOffsetTooDeep:       "The page is too far, narrow down the results.",
+		CodePageOutOfRange:      "The page {page} doesn't exist, there are {pages} pages.",
+		CodeUnindexed:           "This combination of filters isn't supported.",
+		CodeOverBudget:          "The request is too expensive, narrow down the results.",
+		CodeSunset:              "This option is no longer supported.",
+		CodeUnavailable:         "The service is busy, try again later.",
+		CodeUniqueViolation:     "The {column} is already taken.",
+		CodeForeignKeyViolation: "The {column} doesn't exist.",
+		CodeNotNullViolation:    "The {column} is required.",
+		CodeCheckViolation:      "The value is invalid.",
+	},
+}
+
+// Localize implements Localizer, falling back from a regional language to the base one,
+// ie. from "pt-BR" to "pt".
+func (c Catalog) Localize(lang string, details ErrorDetails) (string, bool) {
+	message, ok := c[lang][details.Code]
+	if !ok {
+		if i := strings.IndexAny(lang, "-_"); i > 0 {
+			message, ok = c[lang[:i]][details.Code]
+		}
+	}
+	if !ok {
+		return "", false
+	}
+	pairs := make([]string, 0, 2*len(details.Params))
+	for name, value := range details.Params {
+		pairs = append(pairs, "{"+name+"}", value)
+	}
+	return strings.NewReplacer(pairs...).Replace(message), true
+}
+
+// ErrorMessage returns the message of err in lang, from the first localizer knowing it,
+// falling back to the English Messages. It returns false for the errors DescribeError
+// doesn't know, whose messages shouldn't be shown to clients.
+func ErrorMessage(err error, lang string, localizers ...Localizer) (ErrorDetails, string, bool) {
+	details, ok := DescribeError(err)
+	if !ok {
+		return details, "", false
+	}
+	for _, l := range localizers {
+		if message, ok := l.Localize(lang, details); ok {
+			return details, message, true
+		}
+	}
+	message, _ := Messages.Localize("en", details)
+	return details, message, true
+}
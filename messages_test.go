@@ -0,0 +1,42 @@
+package pgkit_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/goware/pgkit/v2"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorMessage(t *testing.T) {
+	spanish := pgkit.Catalog{"es": {
+		pgkit.CodeInvalidSort:     "No se puede ordenar por {column}.",
+		pgkit.CodeUniqueViolation: "El {column} ya está en uso.",
+	}}
+
+	details, message, ok := pgkit.ErrorMessage(pgkit.ErrInvalidSort{Column: "email"}, "es-AR", spanish)
+	require.True(t, ok)
+	require.Equal(t, pgkit.ErrorDetails{Code: pgkit.CodeInvalidSort, Params: map[string]string{"column": "email"}}, details)
+	require.Equal(t, "No se puede ordenar por email.", message)
+
+	_, message, ok = pgkit.ErrorMessage(fmt.Errorf("query: %w", pgkit.ErrPageSizeExceeded{Max: 50}), "es", spanish)
+	require.True(t, ok)
+	require.Equal(t, "The page size can't be more than 50.", message)
+
+	_, message, ok = pgkit.ErrorMessage(pgkit.ErrPageOutOfRange{Page: 9, TotalPages: 3}, "en")
+	require.True(t, ok)
+	require.Equal(t, "The page 9 doesn't exist, there are 3 pages.", message)
+
+	unique := &pgconn.PgError{Code: "23505", TableName: "accounts", ConstraintName: "accounts_email_key", Detail: "Key (email)=(joe@example.com) already exists."}
+	details, message, ok = pgkit.ErrorMessage(fmt.Errorf("pgkit: %w", unique), "es", spanish)
+	require.True(t, ok)
+	require.Equal(t, pgkit.CodeUniqueViolation, details.Code)
+	require.Equal(t, "accounts_email_key", details.Params["constraint"])
+	require.Equal(t, "El email ya está en uso.", message)
+
+	_, _, ok = pgkit.ErrorMessage(&pgconn.PgError{Code: "42P01"}, "en")
+	require.False(t, ok)
+	_, _, ok = pgkit.ErrorMessage(fmt.Errorf("boom"), "en")
+	require.False(t, ok)
+}
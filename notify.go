@@ -0,0 +1,329 @@
+package pgkit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrInvalidPayload is returned for a notification payload which isn't a JSON object or
+	// doesn't match the schema of its channel, see PayloadError.
+	ErrInvalidPayload = errors.New("pgkit: invalid notification payload")
+	// ErrPayloadVersion is returned for a notification payload older than the min version of
+	// its channel, or newer than its version, see PayloadError.
+	ErrPayloadVersion = errors.New("pgkit: unsupported notification payload version")
+	// ErrUnknownChannel is returned for a channel without a registered schema.
+	ErrUnknownChannel = errors.New("pgkit: no schema registered for the channel")
+)
+
+// PayloadError is returned for a notification payload rejected by its channel schema, it
+// wraps ErrInvalidPayload or ErrPayloadVersion.
+type PayloadError struct {
+	Channel string
+	Version int
+	// Path locates the invalid value in the payload, ie. "$.items[2].id".
+	Path   string
+	Reason string
+	Err    error
+}
+
+func (e *PayloadError) Error() string {
+	msg := fmt.Sprintf("%v on channel %q", e.Err, e.Channel)
+	if e.Path != "" {
+		msg += " at " + e.Path
+	}
+	if e.Reason != "" {
+		msg += ": " + e.Reason
+	}
+	return msg
+}
+
+func (e *PayloadError) Unwrap() error { return e.Err }
+
+// NotificationSchema describes the payloads of a channel, JSON objects carrying their
+// version in a "version" field.
+type NotificationSchema struct {
+	// Version of the payloads sent by Notify.
+	Version int
+	// MinVersion is the oldest version accepted, defaults to Version.
+	MinVersion int
+	// Schema validates the payloads, without their version. It supports a subset of JSON
+	// Schema: type, properties, required, additionalProperties (as a boolean), items and enum.
+	Schema json.RawMessage
+}
+
+// Notifications validates and versions the payloads of the LISTEN/NOTIFY channels, so the
+// malformed or outdated notifications are reported as a PayloadError rather than decoded
+// into invalid values.
+type Notifications struct {
+	mu      sync.Mutex
+	schemas map[string]*channelSchema
+}
+
+type channelSchema struct {
+	NotificationSchema
+	schema *jsonSchema
+}
+
+// Register sets the schema of the channel, replacing the previous one.
+func (n *Notifications) Register(channel string, schema NotificationSchema) error {
+	if schema.MinVersion == 0 {
+		schema.MinVersion = schema.Version
+	}
+	if schema.MinVersion > schema.Version {
+		return fmt.Errorf("pgkit: channel %q min version %d is above version %d", channel, schema.MinVersion, schema.Version)
+	}
+	s := &channelSchema{NotificationSchema: schema}
+	if len(schema.Schema) > 0 {
+		if err := json.Unmarshal(schema.Schema, &s.schema); err != nil {
+			return fmt.Errorf("pgkit: invalid schema of channel %q: %w", channel, err)
+		}
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.schemas == nil {
+		n.schemas = map[string]*channelSchema{}
+	}
+	n.schemas[channel] = s
+	return nil
+}
+
+func (n *Notifications) schema(channel string) (*channelSchema, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	s, ok := n.schemas[channel]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownChannel, channel)
+	}
+	return s, nil
+}
+
+// Encode returns the payload of a notification on the channel, payload encoded as a JSON
+// object with the version of the channel. It fails with a PayloadError when the payload
+// doesn't match the schema.
+func (n *Notifications) Encode(channel string, payload interface{}) (string, error) {
+	s, err := n.schema(channel)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", wrapErr(err)
+	}
+	fields, err := payloadFields(channel, data)
+	if err != nil {
+		return "", err
+	}
+	if err := s.validate(channel, fields, s.Version); err != nil {
+		return "", err
+	}
+	fields["version"] = json.RawMessage(fmt.Sprint(s.Version))
+	data, err = json.Marshal(fields)
+	return string(data), wrapErr(err)
+}
+
+// Notify sends the payload on the channel, encoded as with Encode.
+func (n *Notifications) Notify(ctx context.Context, db *DB, channel string, payload interface{}) error {
+	data, err := n.Encode(channel, payload)
+	if err != nil {
+		return err
+	}
+	_, err = db.Pool().Exec(ctx, `SELECT pg_notify($1, $2)`, channel, data)
+	return wrapErr(err)
+}
+
+// Decode checks the version and the schema of a payload received on the channel, and
+// decodes it into dst. It fails with a PayloadError when the payload is rejected.
+func (n *Notifications) Decode(channel, payload string, dst interface{}) error {
+	s, err := n.schema(channel)
+	if err != nil {
+		return err
+	}
+	fields, err := payloadFields(channel, []byte(payload))
+	if err != nil {
+		return err
+	}
+	var version int
+	if data, ok := fields["version"]; ok {
+		if err := json.Unmarshal(data, &version); err != nil {
+			return &PayloadError{Channel: channel, Path: "$.version", Reason: "expecting an integer", Err: ErrInvalidPayload}
+		}
+	}
+	if version < s.MinVersion || version > s.Version {
+		return &PayloadError{Channel: channel, Version: version, Err: ErrPayloadVersion,
+			Reason: fmt.Sprintf("version %d, expecting %d to %d", version, s.MinVersion, s.Version)}
+	}
+	delete(fields, "version")
+	if err := s.validate(channel, fields, version); err != nil {
+		return err
+	}
+	if err := json.Unmarshal([]byte(payload), dst); err != nil {
+		return &PayloadError{Channel: channel, Version: version, Reason: err.Error(), Err: ErrInvalidPayload}
+	}
+	return nil
+}
+
+// Listen listens to the channel on a connection of db, and calls fn with the payloads
+// decoded into T, until ctx is done or the connection fails. The payloads rejected by Decode
+// are passed to onError, if any, which stops Listen by returning an error, as does fn.
+func Listen[T any](ctx context.Context, db *DB, n *Notifications, channel string, fn func(payload T) error, onError func(payload string, err error) error) error {
+	if _, err := n.schema(channel); err != nil {
+		return err
+	}
+	conn, err := db.Pool().Acquire(ctx)
+	if err != nil {
+		return wrapErr(err)
+	}
+	defer conn.Release()
+	if _, err := conn.Exec(ctx, "LISTEN "+quoteIdent(channel)); err != nil {
+		return wrapErr(err)
+	}
+	defer conn.Exec(context.Background(), "UNLISTEN "+quoteIdent(channel))
+
+	for {
+		notification, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return wrapErr(err)
+		}
+		var payload T
+		if err := n.Decode(channel, notification.Payload, &payload); err != nil {
+			if onError != nil {
+				if err := onError(notification.Payload, err); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if err := fn(payload); err != nil {
+			return err
+		}
+	}
+}
+
+// payloadFields returns the fields of a payload, which must be a JSON object.
+func payloadFields(channel string, data []byte) (map[string]json.RawMessage, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil || fields == nil {
+		return nil, &PayloadError{Channel: channel, Path: "$", Reason: "expecting an object", Err: ErrInvalidPayload}
+	}
+	return fields, nil
+}
+
+// validate checks the fields of a payload, without its version, match the schema.
+func (s *channelSchema) validate(channel string, fields map[string]json.RawMessage, version int) error {
+	if s.schema == nil {
+		return nil
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return wrapErr(err)
+	}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var value interface{}
+	if err := d.Decode(&value); err != nil {
+		return wrapErr(err)
+	}
+	if path, reason := s.schema.validate("$", value); reason != "" {
+		return &PayloadError{Channel: channel, Version: version, Path: path, Reason: reason, Err: ErrInvalidPayload}
+	}
+	return nil
+}
+
+// jsonSchema is the subset of JSON Schema supported by NotificationSchema.
+type jsonSchema struct {
+	Type                 string                 `json:"type"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	Required             []string               `json:"required"`
+	AdditionalProperties *bool                  `json:"additionalProperties"`
+	Items                *jsonSchema            `json:"items"`
+	Enum                 []interface{}          `json:"enum"`
+}
+
+// validate returns the path of the first invalid value and the reason, if any. The numbers
+// of value are json.Number.
+func (s *jsonSchema) validate(path string, value interface{}) (string, string) {
+	if s.Type != "" && jsonType(value, s.Type) != s.Type {
+		return path, fmt.Sprintf("expecting %s, got %s", s.Type, jsonType(value, s.Type))
+	}
+	if len(s.Enum) > 0 {
+		found := false
+		for _, e := range s.Enum {
+			if n, ok := value.(json.Number); ok {
+				if f, ok := e.(float64); ok {
+					v, err := n.Float64()
+					found = found || (err == nil && v == f)
+				}
+			} else if reflect.DeepEqual(e, value) {
+				found = true
+			}
+		}
+		if !found {
+			return path, "not one of the allowed values"
+		}
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				return path + "." + name, "required"
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			prop, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					return path + "." + name, "unexpected property"
+				}
+				continue
+			}
+			if path, reason := prop.validate(path+"."+name, v[name]); reason != "" {
+				return path, reason
+			}
+		}
+	case []interface{}:
+		if s.Items != nil {
+			for i, item := range v {
+				if path, reason := s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item); reason != "" {
+					return path, reason
+				}
+			}
+		}
+	}
+	return "", ""
+}
+
+// jsonType returns the JSON Schema type of value, an integer number is reported as
+// "integer" when expected is "integer", and as "number" otherwise.
+func jsonType(value interface{}, expected string) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if expected == "integer" && !strings.ContainsAny(v.String(), ".eE") {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
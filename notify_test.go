@@ -0,0 +1,86 @@
+package pgkit_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/goware/pgkit/v2"
+	"github.com/stretchr/testify/require"
+)
+
+type orderPlaced struct {
+	ID     int64    `json:"id"`
+	Status string   `json:"status"`
+	Items  []string `json:"items,omitempty"`
+}
+
+func TestNotifications(t *testing.T) {
+	var n pgkit.Notifications
+	require.Error(t, n.Register("orders", pgkit.NotificationSchema{Version: 1, MinVersion: 2}))
+	require.Error(t, n.Register("orders", pgkit.NotificationSchema{Version: 1, Schema: json.RawMessage(`{`)}))
+	require.NoError(t, n.Register("orders", pgkit.NotificationSchema{
+		Version:    3,
+		MinVersion: 2,
+		Schema: json.RawMessage(`{
+			"type": "object",
+			"required": ["id", "status"],
+			"additionalProperties": false,
+			"properties": {
+				"id": {"type": "integer"},
+				"status": {"enum": ["placed", "paid"]},
+				"items": {"type": "array", "items": {"type": "string"}}
+			}
+		}`),
+	}))
+
+	payload, err := n.Encode("orders", orderPlaced{ID: 1, Status: "placed", Items: []string{"a"}})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"id": 1, "status": "placed", "items": ["a"], "version": 3}`, payload)
+
+	var order orderPlaced
+	require.NoError(t, n.Decode("orders", payload, &order))
+	require.Equal(t, orderPlaced{ID: 1, Status: "placed", Items: []string{"a"}}, order)
+	require.NoError(t, n.Decode("orders", `{"id": 2, "status": "paid", "version": 2}`, &order))
+	require.Equal(t, int64(2), order.ID)
+
+	_, err = n.Encode("orders", orderPlaced{ID: 1, Status: "lost"})
+	var payloadErr *pgkit.PayloadError
+	require.ErrorAs(t, err, &payloadErr)
+	require.ErrorIs(t, err, pgkit.ErrInvalidPayload)
+	require.Equal(t, "$.status", payloadErr.Path)
+	require.Equal(t, 3, payloadErr.Version)
+
+	_, err = n.Encode("orders", []int{1})
+	require.ErrorIs(t, err, pgkit.ErrInvalidPayload)
+	_, err = n.Encode("invoices", orderPlaced{})
+	require.ErrorIs(t, err, pgkit.ErrUnknownChannel)
+
+	tt := []struct {
+		payload string
+		err     error
+		path    string
+		version int
+	}{
+		{payload: `{"id": 1, "status": "placed", "version": 1}`, err: pgkit.ErrPayloadVersion, version: 1},
+		{payload: `{"id": 1, "status": "placed", "version": 4}`, err: pgkit.ErrPayloadVersion, version: 4},
+		{payload: `{"id": 1, "status": "placed"}`, err: pgkit.ErrPayloadVersion},
+		{payload: `{"id": 1, "status": "placed", "version": "3"}`, err: pgkit.ErrInvalidPayload, path: "$.version"},
+		{payload: `[1]`, err: pgkit.ErrInvalidPayload, path: "$"},
+		{payload: `not json`, err: pgkit.ErrInvalidPayload, path: "$"},
+		{payload: `{"status": "placed", "version": 3}`, err: pgkit.ErrInvalidPayload, path: "$.id", version: 3},
+		{payload: `{"id": 1.5, "status": "placed", "version": 3}`, err: pgkit.ErrInvalidPayload, path: "$.id", version: 3},
+		{payload: `{"id": 1, "status": "placed", "items": ["a", 2], "version": 3}`, err: pgkit.ErrInvalidPayload, path: "$.items[1]", version: 3},
+		{payload: `{"id": 1, "status": "placed", "extra": true, "version": 3}`, err: pgkit.ErrInvalidPayload, path: "$.extra", version: 3},
+	}
+	for _, tc := range tt {
+		err := n.Decode("orders", tc.payload, &order)
+		require.ErrorIs(t, err, tc.err, tc.payload)
+		require.True(t, errors.As(err, &payloadErr), tc.payload)
+		require.Equal(t, "orders", payloadErr.Channel, tc.payload)
+		require.Equal(t, tc.path, payloadErr.Path, tc.payload)
+		require.Equal(t, tc.version, payloadErr.Version, tc.payload)
+	}
+
+	require.ErrorIs(t, n.Decode("invoices", payload, &order), pgkit.ErrUnknownChannel)
+}
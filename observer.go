@@ -0,0 +1,45 @@
+package pgkit
+
+import (
+	"context"
+	"time"
+)
+
+// QueryStats describes a query run by a paginator, see WithObserver.
+type QueryStats struct {
+	SQL  string
+	Args []interface{}
+	// Count is true for the count query, false for the one of the rows.
+	Count bool
+	// Page is the requested page.
+	Page Page
+
+	Start    time.Time
+	Duration time.Duration
+	Rows     int
+	Err      error
+}
+
+// WithObserver reports the queries run by Paginator.Query and Count, and by ForEachPage,
+// to fn once they're done, ie. to log the slow pages or to record a trace span per query,
+// started at QueryStats.Start. The queries of other paths, ie. PrepareQuery, are reported
+// by the Timing middleware.
+func WithObserver(fn func(ctx context.Context, stats QueryStats)) func(*PaginatorOption) {
+	return func(o *PaginatorOption) { o.observer = fn }
+}
+
+// observe returns the querier reporting its queries to the observer, if any.
+func (o PaginatorOption) observe(querier *Querier, page *Page, count bool) *Querier {
+	if o.observer == nil {
+		return querier
+	}
+	stats := QueryStats{Count: count}
+	if page != nil {
+		stats.Page = *page
+	}
+	return querier.With(Timing(func(ctx context.Context, t QueryTiming) {
+		stats.SQL, stats.Args, stats.Rows, stats.Err = t.SQL, t.Args, t.Rows, t.Err
+		stats.Start, stats.Duration = time.Now().Add(-t.Total), t.Total
+		o.observer(ctx, stats)
+	}))
+}
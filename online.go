@@ -0,0 +1,226 @@
+package pgkit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Helpers for schema changes on live tables, without blocking the application.
+
+// IndexSpec describes an index built by CreateIndexConcurrently.
+type IndexSpec struct {
+	Name   string
+	Table  string
+	Unique bool
+	// Definition follows the table name, ie. "(account_id, created_at) WHERE deleted_at IS NULL"
+	// or "USING GIN (document)".
+	Definition string
+}
+
+// CreateIndexConcurrently builds the index without blocking writes on its table. A failed
+// concurrent build leaves an invalid index behind, which is dropped before trying again, up
+// to attempts times. An existing valid index with the same name is left as is.
+func CreateIndexConcurrently(ctx context.Context, db *DB, spec IndexSpec, attempts int) error {
+	unique := ""
+	if spec.Unique {
+		unique = "UNIQUE "
+	}
+	create := fmt.Sprintf("CREATE %sINDEX CONCURRENTLY IF NOT EXISTS %s ON %s %s",
+		unique, quoteIdent(spec.Name), quoteIdent(spec.Table), spec.Definition)
+
+	var err error
+	for i := 0; i < attempts || i == 0; i++ {
+		if err = dropInvalidIndex(ctx, db, spec); err != nil {
+			return err
+		}
+		if _, err = db.Pool().Exec(ctx, create); err == nil {
+			return dropInvalidIndex(ctx, db, spec)
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if dropErr := dropInvalidIndex(ctx, db, spec); dropErr != nil {
+		return dropErr
+	}
+	return wrapErr(err)
+}
+
+// dropInvalidIndex drops the index if it's left invalid by a failed concurrent build,
+// IF NOT EXISTS would skip it otherwise. The index is looked up on its table, so it's
+// qualified with the schema of the table rather than resolved with the search_path.
+func dropInvalidIndex(ctx context.Context, db *DB, spec IndexSpec) error {
+	var invalid []string
+	rows, err := db.Pool().Query(ctx, `
+		SELECT i.indexrelid::regclass::text FROM pg_index i JOIN pg_class c ON c.oid = i.indexrelid
+		WHERE i.indrelid = to_regclass($1) AND c.relname = $2 AND NOT i.indisvalid`, quoteIdent(spec.Table), spec.Name)
+	if err == nil {
+		invalid, err = pgx.CollectRows(rows, pgx.RowTo[string])
+	}
+	if err != nil {
+		return wrapErr(err)
+	}
+	for _, name := range invalid {
+		if _, err := db.Pool().Exec(ctx, "DROP INDEX CONCURRENTLY IF EXISTS "+name); err != nil {
+			return wrapErr(err)
+		}
+	}
+	return nil
+}
+
+// ColumnBackfill describes the backfill of a new column, see BackfillColumn.
+type ColumnBackfill struct {
+	Table  string
+	Column string
+	// Value is the SQL expression computing the column, evaluated for each row.
+	Value string
+	// KeyColumn is the primary key of Table, defaults to "id".
+	KeyColumn string
+	// BatchSize is the number of rows updated per transaction, defaults to 1000.
+	BatchSize int
+	// Pause between batches, to leave room to replication and autovacuum.
+	Pause time.Duration
+	// NotNull sets the column NOT NULL once it's filled.
+	NotNull bool
+}
+
+// BackfillColumn fills the NULL values of a column in short batches, so rows are never
+// locked for long, returning the number of rows filled. The rows are walked in key order,
+// each one is updated once per pass, and the passes are repeated while they fill rows, as
+// the rows locked by other transactions are skipped. The rows for which Value is NULL are
+// left NULL. With NotNull the constraint is then added through a validated CHECK
+// constraint, so the table is scanned without holding an exclusive lock (PostgreSQL 12+,
+// older versions scan it under the lock), it fails when NULL values are left.
+func BackfillColumn(ctx context.Context, db *DB, spec ColumnBackfill) (int64, error) {
+	if spec.KeyColumn == "" {
+		spec.KeyColumn = "id"
+	}
+	if spec.BatchSize <= 0 {
+		spec.BatchSize = 1000
+	}
+	table, column, key := quoteIdent(spec.Table), quoteIdent(spec.Column), quoteIdent(spec.KeyColumn)
+
+	// the batch returns its size, the rows filled and its last key, the next batch starts after it
+	update := func(after string) string {
+		return fmt.Sprintf(`
+			WITH batch AS (
+				UPDATE %[1]s SET %[2]s = %[4]s
+				WHERE %[3]s IN (SELECT %[3]s FROM %[1]s WHERE %[2]s IS NULL %[6]s ORDER BY %[3]s LIMIT %[5]d FOR UPDATE SKIP LOCKED)
+				RETURNING %[3]s, %[2]s IS NOT NULL AS filled
+			)
+			SELECT count(*), count(*) FILTER (WHERE filled), (SELECT %[3]s FROM batch ORDER BY %[3]s DESC LIMIT 1) FROM batch`,
+			table, column, key, spec.Value, spec.BatchSize, after)
+	}
+	first, next := update(""), update("AND "+key+" > $1")
+	count := fmt.Sprintf("SELECT count(*) FROM %s WHERE %s IS NULL", table, column)
+
+	var total int64
+	for remaining := int64(-1); ; {
+		var last interface{}
+		for {
+			query, args := first, []interface{}(nil)
+			if last != nil {
+				query, args = next, []interface{}{last}
+			}
+			var (
+				n, filled int64
+				lastKey   interface{}
+			)
+			if err := db.Pool().QueryRow(ctx, query, args...).Scan(&n, &filled, &lastKey); err != nil {
+				return total, wrapErr(err)
+			}
+			if n == 0 {
+				break
+			}
+			total, last = total+filled, lastKey
+			if spec.Pause > 0 {
+				select {
+				case <-ctx.Done():
+					return total, wrapErr(ctx.Err())
+				case <-time.After(spec.Pause):
+				}
+			}
+		}
+
+		var left int64
+		if err := db.Pool().QueryRow(ctx, count).Scan(&left); err != nil {
+			return total, wrapErr(err)
+		}
+		if left == 0 {
+			break
+		}
+		// the pass filled nothing, the rows left are NULL or locked
+		if remaining >= 0 && left >= remaining {
+			if spec.NotNull {
+				return total, fmt.Errorf("pgkit: %d rows of %s still have a NULL %s", left, spec.Table, spec.Column)
+			}
+			return total, nil
+		}
+		remaining = left
+	}
+
+	if !spec.NotNull {
+		return total, nil
+	}
+	queries := []string{fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET NOT NULL", table, column)}
+	if ok, err := db.Supports(ctx, FeatureNotNullFromCheck); err != nil {
+		return total, err
+	} else if ok {
+		check := quoteIdent(spec.Column + "_not_null")
+		queries = []string{
+			fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s CHECK (%s IS NOT NULL) NOT VALID", table, check, column),
+			fmt.Sprintf("ALTER TABLE %s VALIDATE CONSTRAINT %s", table, check),
+			queries[0],
+			fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s", table, check),
+		}
+	}
+	for _, query := range queries {
+		if _, err := db.Pool().Exec(ctx, query); err != nil {
+			return total, wrapErr(err)
+		}
+	}
+	return total, nil
+}
+
+// WithLockTimeout runs fn in a transaction which gives up waiting for locks after timeout,
+// retrying up to attempts times with an increasing pause. A DDL statement waiting for a lock
+// blocks every query queued behind it, it's better to fail fast and try again later.
+// The timeout is rounded up to the millisecond, as a zero lock_timeout waits forever.
+func WithLockTimeout(ctx context.Context, db *DB, timeout time.Duration, attempts int, fn func(tx pgx.Tx) error) error {
+	if timeout <= 0 {
+		return fmt.Errorf("pgkit: lock timeout %s must be positive", timeout)
+	}
+	var (
+		err    error
+		pgErr  *pgconn.PgError
+		locked bool
+	)
+	ms := (timeout + time.Millisecond - 1) / time.Millisecond
+	for i := 0; i < attempts || i == 0; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return wrapErr(ctx.Err())
+			case <-time.After(time.Duration(i) * timeout):
+			}
+		}
+		err = pgx.BeginFunc(ctx, db.Pool(), func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, "SELECT set_config('lock_timeout', $1, true)", fmt.Sprintf("%dms", ms)); err != nil {
+				return err
+			}
+			return fn(tx)
+		})
+		if locked = errors.As(err, &pgErr) && pgErr.Code == "55P03"; !locked { // lock_not_available
+			break
+		}
+	}
+	if locked {
+		fireAlert(ctx, db.Alerter, Alert{Kind: AlertLockTimeoutExhausted, Message: "lock timeout retries exhausted", Err: err})
+	}
+	return wrapErr(err)
+}
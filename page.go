@@ -1,11 +1,18 @@
 package pgkit
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"regexp"
+	"sort"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
 )
 
 const (
@@ -15,6 +22,27 @@ const (
 	MaxPageSize = 50
 )
 
+// pageSizes are the page sizes of a DB, which replace DefaultPageSize and MaxPageSize when
+// set, see Config.DefaultPageSize and Config.MaxPageSize.
+type pageSizes struct {
+	def, max atomic.Uint32
+}
+
+// get returns the default and the max page sizes, the ones of the package when not set.
+func (s *pageSizes) get() (uint32, uint32) {
+	def, max := uint32(DefaultPageSize), uint32(MaxPageSize)
+	if s == nil {
+		return def, max
+	}
+	if n := s.def.Load(); n != 0 {
+		def = n
+	}
+	if n := s.max.Load(); n != 0 {
+		max = n
+	}
+	return def, max
+}
+
 type OrderType string
 
 const (
@@ -22,30 +50,132 @@ const (
 	Asc  OrderType = "ASC"
 )
 
+// ParseOrderType parses a sort direction, accepting asc/desc in any case and 1/-1.
+func ParseOrderType(s string) (OrderType, error) {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "ASC", "1", "+1":
+		return Asc, nil
+	case "DESC", "-1":
+		return Desc, nil
+	}
+	return "", fmt.Errorf("pgkit: invalid sort order %q", s)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (o OrderType) MarshalText() ([]byte, error) {
+	if o == "" {
+		return []byte{}, nil
+	}
+	v, err := ParseOrderType(string(o))
+	if err != nil {
+		return nil, err
+	}
+	return []byte(v), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (o *OrderType) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*o = ""
+		return nil
+	}
+	v, err := ParseOrderType(string(text))
+	if err != nil {
+		return err
+	}
+	*o = v
+	return nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler, accepting both strings and 1/-1 numbers.
+func (o *OrderType) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		var n json.Number
+		if err := json.Unmarshal(data, &n); err != nil {
+			return fmt.Errorf("pgkit: invalid sort order %s", data)
+		}
+		s = n.String()
+	}
+	return o.UnmarshalText([]byte(s))
+}
+
+// NullsOrder places NULL values before or after the other ones, by default postgres sorts
+// them as larger than any value: last with ASC and first with DESC.
+type NullsOrder string
+
+const (
+	NullsFirst NullsOrder = "FIRST"
+	NullsLast  NullsOrder = "LAST"
+)
+
 type Sort struct {
-	Column string    `json:"column"`
-	Order  OrderType `json:"order"`
+	Column string     `json:"column"`
+	Order  OrderType  `json:"order"`
+	Nulls  NullsOrder `json:"nulls,omitempty"`
 }
 
 func (s Sort) String() string {
 	if s.Column == "" {
 		return ""
 	}
-	if s.Order == "" {
-		s.Order = Asc
+	s = s.normalize()
+	switch s.Nulls {
+	case NullsFirst, NullsLast:
+		return fmt.Sprintf("%s %s NULLS %s", s.Column, s.Order, s.Nulls)
 	}
 	return fmt.Sprintf("%s %s", s.Column, s.Order)
 }
 
+// normalize returns the sort with its order and nulls in upper case, as compared to Desc and
+// NullsFirst, ie. for a Sort{Order: "desc"} built by the caller. An invalid order is Asc, so
+// it never reaches the query.
+func (s Sort) normalize() Sort {
+	order, err := ParseOrderType(string(s.Order))
+	if err != nil {
+		order = Asc
+	}
+	s.Order = order
+	s.Nulls = NullsOrder(strings.ToUpper(string(s.Nulls)))
+	return s
+}
+
+// normalizeSorts returns a copy of the sorts normalized, see Sort.normalize.
+func normalizeSorts(sorts []Sort) []Sort {
+	list := make([]Sort, len(sorts))
+	for i, s := range sorts {
+		list[i] = s.normalize()
+	}
+	return list
+}
+
 var _MatcherOrderBy = regexp.MustCompile(`-?([a-zA-Z0-9]+)`)
 
+// NewSort parses a sort, ie. "name" or "-created_at" for a descending one. The position of
+// NULL values is set with a ":nullsfirst" or ":nullslast" suffix, ie. "-ended_at:nullslast".
 func NewSort(s string) (Sort, bool) {
+	var nulls NullsOrder
+	if i := strings.LastIndex(s, ":"); i >= 0 {
+		switch strings.ToLower(s[i+1:]) {
+		case "nullsfirst":
+			nulls = NullsFirst
+		case "nullslast":
+			nulls = NullsLast
+		default:
+			return Sort{}, false
+		}
+		s = s[:i]
+	}
 	if s == "" || !_MatcherOrderBy.MatchString(s) {
 		return Sort{}, false
 	}
 	sort := Sort{
 		Column: s,
 		Order:  Asc,
+		Nulls:  nulls,
 	}
 	if strings.HasPrefix(s, "-") {
 		sort.Column = s[1:]
@@ -60,6 +190,22 @@ type Page struct {
 	More   bool   `json:"more"`
 	Column string `json:"column"`
 	Order  []Sort `json:"sort"`
+	// From and To are the 1-based positions of the first and last rows of the page, set by
+	// PrepareResult, ie. "showing 21-30". Both are zero when the page is empty.
+	From uint64 `json:"from,omitempty"`
+	To   uint64 `json:"to,omitempty"`
+	// Cursor is the position after which a CursorPaginator starts the page, and NextCursor
+	// the one of its last row, to be passed as Cursor to get the next page.
+	Cursor     string `json:"cursor,omitempty"`
+	NextCursor string `json:"nextCursor,omitempty"`
+	// Total is the number of rows of all the pages and TotalPages the number of pages, set
+	// by SetTotal.
+	Total      uint64 `json:"total,omitempty"`
+	TotalPages uint32 `json:"totalPages,omitempty"`
+	// Aggregates are the values of the aggregates of the paginator, see WithAggregates.
+	Aggregates map[string]interface{} `json:"aggregates,omitempty"`
+	// NoCache skips the cache of the paginator for this page, see WithCache.
+	NoCache bool `json:"-"`
 }
 
 func NewPage(size, page uint32, sort ...Sort) *Page {
@@ -79,7 +225,7 @@ func NewPage(size, page uint32, sort ...Sort) *Page {
 func (p *Page) GetOrder(defaultSort ...string) []Sort {
 	// if page has sort, use it
 	if p != nil && len(p.Order) != 0 {
-		return p.Order
+		return normalizeSorts(p.Order)
 	}
 	// if page has column, use default sort
 	if p == nil || p.Column == "" {
@@ -102,6 +248,11 @@ func (p *Page) GetOrder(defaultSort ...string) []Sort {
 }
 
 func (p *Page) Offset() uint64 {
+	return p.offset(p.Limit())
+}
+
+// offset returns the offset of the page for pages of limit rows.
+func (p *Page) offset(limit uint64) uint64 {
 	n := uint64(1)
 	if p != nil && p.Page != 0 {
 		n = uint64(p.Page)
@@ -109,18 +260,35 @@ func (p *Page) Offset() uint64 {
 	if n < 1 {
 		n = 1
 	}
-	return (n - 1) * p.Limit()
+	return (n - 1) * limit
 }
 
+// Limit returns the page size, or DefaultPageSize when not set, clamped to MaxPageSize. The
+// paginators size the page with their own sizes instead, see WithMaxSize and WithPageSizes.
 func (p *Page) Limit() uint64 {
-	var n = uint64(DefaultPageSize)
-	if p != nil && p.Size != 0 {
-		n = uint64(p.Size)
+	if n := p.size(); n < MaxPageSize {
+		return n
 	}
-	if n > MaxPageSize {
-		n = MaxPageSize
+	return MaxPageSize
+}
+
+// size returns the page size, or DefaultPageSize when not set, without clamping it.
+func (p *Page) size() uint64 {
+	if p != nil && p.Size != 0 {
+		return uint64(p.Size)
 	}
-	return n
+	return DefaultPageSize
+}
+
+// SetTotal sets the total number of rows, and the resulting number of pages.
+func (p *Page) SetTotal(total uint64) {
+	p.setTotal(total, p.Limit())
+}
+
+// setTotal sets the total number of rows, and the number of pages of limit rows.
+func (p *Page) setTotal(total, limit uint64) {
+	p.Total = total
+	p.TotalPages = uint32((total + limit - 1) / limit)
 }
 
 // WithDefaultSize sets the default page size.
@@ -133,23 +301,108 @@ func WithMaxSize(size uint32) func(*PaginatorOption) {
 	return func(o *PaginatorOption) { o.maxSize = size }
 }
 
+// WithPageSizes uses the page sizes of db, see Config.DefaultPageSize, for the sizes not set
+// with WithDefaultSize and WithMaxSize. It follows the sizes changed by DB.ApplyConfig.
+func WithPageSizes(db *DB) func(*PaginatorOption) {
+	return func(o *PaginatorOption) { o.pageSizes = db.pageSizes }
+}
+
 // WithSort sets the default sort order.
 func WithSort(sort ...string) func(*PaginatorOption) {
+	sort = append([]string(nil), sort...)
 	return func(o *PaginatorOption) { o.defaultSort = sort }
 }
 
-// WithColumnFunc sets a function to transform column names.
+// ErrOffsetTooDeep is returned by the queries of a page beyond the max offset of the paginator.
+var ErrOffsetTooDeep = errors.New("pgkit: page offset too deep, use cursor pagination to go further")
+
+// WithMaxOffset sets the maximum offset of a page, protecting the database from deep
+// paging. The queries prepared for deeper pages fail with ErrOffsetTooDeep.
+func WithMaxOffset(n uint64) func(*PaginatorOption) {
+	return func(o *PaginatorOption) { o.maxOffset = n }
+}
+
+// WithTotalCount makes Paginator.Count run the count query, see PrepareCountQuery.
+func WithTotalCount() func(*PaginatorOption) {
+	return func(o *PaginatorOption) { o.totalCount = true }
+}
+
+// WithInlineCount makes the prepared queries count the total number of rows with a window
+// function, see ScanInlineCount, instead of running a separate count query. It's cheaper
+// when the rows would be scanned anyway, ie. on medium-sized tables.
+func WithInlineCount() func(*PaginatorOption) {
+	return func(o *PaginatorOption) { o.inlineCount = true }
+}
+
+// PaginationQuota limits the pages available to a caller, zero values don't limit.
+type PaginationQuota struct {
+	MaxSize   uint32
+	MaxOffset uint64
+}
+
+// WithQuotaProvider sets the function returning the quota of the caller, ie. read from
+// the API key carried by the context, which further limits the paginator max size and max
+// offset in PrepareQueryContext.
+func WithQuotaProvider(fn func(ctx context.Context) PaginationQuota) func(*PaginatorOption) {
+	return func(o *PaginatorOption) { o.quotaProvider = fn }
+}
+
+// WithAllowedColumns restricts the columns a page can be sorted by, the other ones are
+// dropped. It doesn't apply to the default sort.
+func WithAllowedColumns(columns ...string) func(*PaginatorOption) {
+	m := make(map[string]string, len(columns))
+	for _, c := range columns {
+		m[c] = c
+	}
+	return WithColumnMap(m)
+}
+
+// WithColumnMap is like WithAllowedColumns, mapping the names exposed by the API to the
+// columns, ie. "createdAt" to "created_at".
+func WithColumnMap(columns map[string]string) func(*PaginatorOption) {
+	m := make(map[string]string, len(columns))
+	for k, v := range columns {
+		m[k] = v
+	}
+	return func(o *PaginatorOption) { o.allowedColumns = m }
+}
+
+// WithTiebreaker appends the column, which should be unique, ie. the primary key, to the
+// sort when it's not already part of it, so rows sharing the same sort values keep the same
+// order between pages. It's sorted in the direction of the last sort column.
+func WithTiebreaker(column string) func(*PaginatorOption) {
+	return func(o *PaginatorOption) { o.tiebreaker = column }
+}
+
+// WithFilters adds conditions to the queries, ie. Filters. It's typically passed to
+// PrepareQuery, to filter a single query.
+func WithFilters(filters ...sq.Sqlizer) func(*PaginatorOption) {
+	filters = append([]sq.Sqlizer(nil), filters...)
+	return func(o *PaginatorOption) { o.filters = append(o.filters[:len(o.filters):len(o.filters)], filters...) }
+}
+
+// WithSortExpressions maps sort keys to SQL expressions, ie. "name" to "lower(users.name)"
+// or "author" to "u.name" for a joined table. The expressions are trusted, they're used as is,
+// and their keys are allowed even when the columns are restricted by WithAllowedColumns.
+func WithSortExpressions(expressions map[string]string) func(*PaginatorOption) {
+	m := make(map[string]string, len(expressions))
+	for k, v := range expressions {
+		m[k] = v
+	}
+	return func(o *PaginatorOption) { o.sortExpressions = m }
+}
+
+// WithColumnFunc sets a function to transform column names. It's the transformer named
+// "func" of the chain, see WithColumnTransformers.
 func WithColumnFunc(f func(string) string) func(*PaginatorOption) {
-	return func(o *PaginatorOption) { o.columnFunc = f }
+	return WithColumnTransformers(ColumnTransformer{Name: "func", Func: f})
 }
 
 // NewPaginator creates a new paginator with the given options.
-// Default page size is 10 and max size is 50.
+// Default page size is 10 and max size is 50, unless they're set by the Config of the DB,
+// see Config.DefaultPageSize, or by the options.
 func NewPaginator[T any](options ...func(*PaginatorOption)) Paginator[T] {
-	o := PaginatorOption{
-		defaultSize: DefaultPageSize,
-		maxSize:     MaxPageSize,
-	}
+	var o PaginatorOption
 	for _, fn := range options {
 		fn(&o)
 	}
@@ -159,53 +412,465 @@ func NewPaginator[T any](options ...func(*PaginatorOption)) Paginator[T] {
 type PaginatorOption struct {
 	defaultSize uint32
 	maxSize     uint32
+	pageSizes   *pageSizes
 	defaultSort []string
-	columnFunc  func(string) string
+	maxOffset   uint64
+	totalCount  bool
+	inlineCount bool
+	tiebreaker  string
+	filters     []sq.Sqlizer
+	distinctOn  []string
+	search      *Search
+	observer    func(ctx context.Context, stats QueryStats)
+	indexHints  *IndexHints
+	random      *randomOrder
+	scope       func(ctx context.Context) sq.Sqlizer
+	strict      bool
+	outOfRange  OutOfRangePolicy
+	cache       Cache
+	cacheTTL    time.Duration
+	aggregates  map[string]string
+
+	allowedColumns     map[string]string
+	columnTransformers []ColumnTransformer
+	sortExpressions    map[string]string
+	quotaProvider      func(ctx context.Context) PaginationQuota
+	adaptiveSize       *AdaptiveSize
+	deprecations       *Deprecations
+	codec              PageCodec
 }
 
-// Paginator is a helper to paginate results.
+// Paginator is a helper to paginate results. Its configuration is never modified after
+// creation, so it's safe to share it between goroutines.
 type Paginator[T any] struct {
 	PaginatorOption
 }
 
+// PaginatorConfig is a snapshot of the configuration of a paginator.
+type PaginatorConfig struct {
+	DefaultSize uint32   `json:"defaultSize"`
+	MaxSize     uint32   `json:"maxSize"`
+	DefaultSort []string `json:"defaultSort"`
+	MaxOffset   uint64   `json:"maxOffset,omitempty"`
+	TotalCount  bool     `json:"totalCount,omitempty"`
+	Tiebreaker  string   `json:"tiebreaker,omitempty"`
+	// AllowedColumns lists the column names a page can be sorted by, nil when not restricted.
+	AllowedColumns []string `json:"allowedColumns,omitempty"`
+	// SortExpressions lists the sort keys mapped to expressions, see WithSortExpressions.
+	SortExpressions []string `json:"sortExpressions,omitempty"`
+}
+
+// Config returns a snapshot of the paginator configuration, which can be inspected or
+// exposed without affecting the paginator.
+func (p Paginator[T]) Config() PaginatorConfig {
+	cfg := PaginatorConfig{
+		DefaultSize: p.getDefaultSize(),
+		MaxSize:     p.getMaxSize(),
+		DefaultSort: append([]string(nil), p.defaultSort...),
+		MaxOffset:   p.maxOffset,
+		TotalCount:  p.totalCount,
+		Tiebreaker:  p.tiebreaker,
+	}
+	if p.allowedColumns != nil {
+		cfg.AllowedColumns = make([]string, 0, len(p.allowedColumns))
+		for c := range p.allowedColumns {
+			cfg.AllowedColumns = append(cfg.AllowedColumns, c)
+		}
+		sort.Strings(cfg.AllowedColumns)
+	}
+	for key := range p.sortExpressions {
+		cfg.SortExpressions = append(cfg.SortExpressions, key)
+	}
+	sort.Strings(cfg.SortExpressions)
+	return cfg
+}
+
 func (p Paginator[T]) getOrder(page *Page) []string {
-	sort := page.GetOrder(p.defaultSort...)
-	list := make([]string, len(sort))
+	return sortStrings(p.getSort(page))
+}
+
+// getSort returns the page sort, or the default one. The columns of the page sort are
+// checked against the allowed columns, and the unknown ones are dropped. Then the tiebreaker
+// is added, and finally the sort expressions and the column transformers are applied.
+func (o PaginatorOption) getSort(page *Page) []Sort {
+	custom := page != nil && (len(page.Order) > 0 || page.Column != "")
+	sort := page.GetOrder(o.defaultSort...)
+	if custom && o.allowedColumns != nil {
+		allowed := make([]Sort, 0, len(sort))
+		for _, s := range sort {
+			if _, ok := o.sortExpressions[s.Column]; ok {
+				allowed = append(allowed, s)
+			} else if column, ok := o.allowedColumns[s.Column]; ok {
+				s.Column = column
+				allowed = append(allowed, s)
+			}
+		}
+		sort = allowed
+		if len(sort) == 0 {
+			sort = (*Page)(nil).GetOrder(o.defaultSort...)
+		}
+	}
+	if o.tiebreaker != "" {
+		sort = o.withTiebreaker(sort)
+	}
+	list := make([]Sort, len(sort))
 	for i, s := range sort {
-		if p.columnFunc != nil {
-			s.Column = p.columnFunc(s.Column)
+		if expr, ok := o.sortExpressions[s.Column]; ok {
+			s.Column = expr
+		} else {
+			s.Column = o.transformColumn(s.Column)
 		}
-		list[i] = s.String()
+		list[i] = s
 	}
 	return list
 }
 
+// withTiebreaker appends the tiebreaker to sort, unless it's already there.
+func (o PaginatorOption) withTiebreaker(sort []Sort) []Sort {
+	tiebreaker := Sort{Column: o.tiebreaker, Order: Asc}
+	for _, s := range sort {
+		if strings.EqualFold(strings.Trim(s.Column, `"`), strings.Trim(o.tiebreaker, `"`)) {
+			return sort
+		}
+		tiebreaker.Order = s.Order
+	}
+	return append(sort[:len(sort):len(sort)], tiebreaker)
+}
+
+// setDefaults sets the paginator default size, and clamps it to the max size.
+func (p Paginator[T]) setDefaults(page *Page) {
+	if page.Size == 0 {
+		page.Size = p.getDefaultSize()
+	}
+	if max := p.getMaxSize(); page.Size > max {
+		page.Size = max
+	}
+}
+
+// getDefaultSize returns the default page size of the paginator, the one of its DB or of the
+// package when it's not set, see WithPageSizes.
+func (o PaginatorOption) getDefaultSize() uint32 {
+	if o.defaultSize != 0 {
+		return o.defaultSize
+	}
+	def, _ := o.pageSizes.get()
+	return def
+}
+
+// getMaxSize returns the max page size of the paginator, the one of its DB or of the package
+// when it's not set, see WithPageSizes.
+func (o PaginatorOption) getMaxSize() uint32 {
+	if o.maxSize != 0 {
+		return o.maxSize
+	}
+	_, max := o.pageSizes.get()
+	return max
+}
+
+// limit returns the size of the page, or the default size of the paginator when not set,
+// clamped to its max size, see WithMaxSize.
+func (o PaginatorOption) limit(page *Page) uint64 {
+	n := o.getDefaultSize()
+	if page != nil && page.Size != 0 {
+		n = page.Size
+	}
+	if max := o.getMaxSize(); n > max {
+		n = max
+	}
+	return uint64(n)
+}
+
+// offset returns the offset of the page, sized as with limit.
+func (o PaginatorOption) offset(page *Page) uint64 {
+	return page.offset(o.limit(page))
+}
+
+// setTotal sets the total of the page, sized as with limit.
+func (o PaginatorOption) setTotal(page *Page, total uint64) {
+	page.setTotal(total, o.limit(page))
+}
+
 // PrepareQuery adds pagination to the query. It sets the number of max rows to limit+1.
-func (p Paginator[T]) PrepareQuery(q sq.SelectBuilder, page *Page) ([]T, sq.SelectBuilder) {
-	if page != nil {
-		if page.Size == 0 {
-			page.Size = p.defaultSize
+// A nil page is treated as the first page with the paginator defaults. The options
+// override the ones of the paginator for this query only, ie. WithMaxSize or WithFilters.
+// A query selecting DISTINCT or with a GROUP BY is wrapped in a subselect, see WithDistinctOn.
+func (p Paginator[T]) PrepareQuery(q sq.SelectBuilder, page *Page, options ...func(*PaginatorOption)) ([]T, sq.SelectBuilder) {
+	if page == nil {
+		page = &Page{Page: 1}
+	}
+	p = p.with(options)
+	if p.scope != nil {
+		q = q.Where(errSqlizer{ErrScopeWithoutContext})
+	}
+	var invalid error
+	if p.strict {
+		invalid = p.validate(page)
+	}
+	q = p.wrapGrouped(p.random.sample(p.search.where(where(q, p.filters))))
+	p.setDefaults(page)
+	limit := p.limit(page)
+	if p.random != nil {
+		page.Page = 1
+		q = p.random.order(q).Limit(limit)
+	} else {
+		q = q.Limit(limit + 1).Offset(p.offset(page))
+		if rank := p.search.rank(); rank != nil {
+			q = q.OrderByClause(rank)
 		}
-		if page.Size > p.maxSize {
-			page.Size = p.maxSize
+		q = q.OrderBy(p.getOrder(page)...)
+	}
+	if p.inlineCount {
+		q = q.Column(inlineCountColumn)
+	}
+	if p.deprecations != nil && (len(page.Order) > 0 || page.Column != "") {
+		if err := p.deprecations.checkSort(page.GetOrder()); err != nil {
+			q = q.Where(errSqlizer{err})
 		}
 	}
-	limit := page.Limit()
-	q = q.Limit(page.Limit() + 1).Offset(page.Offset()).OrderBy(p.getOrder(page)...)
+	sort := p.getSort(page)
+	if p.random != nil {
+		sort = nil
+	}
+	if err := p.indexHints.check(p.filters, sort); err != nil {
+		q = q.Where(errSqlizer{err})
+	}
+	if invalid != nil {
+		q = q.Where(errSqlizer{invalid})
+	}
+	if p.maxOffset > 0 && p.offset(page) > p.maxOffset {
+		q = q.Where(errSqlizer{ErrOffsetTooDeep})
+	}
 	return make([]T, 0, limit+1), q
 }
 
+// Query runs the paginated query on exec, ie. a *pgxpool.Pool or a pgx.Tx, returning the
+// rows of the page, and updates the page like PrepareResult. When the paginator is created
+// with WithTotalCount or WithInlineCount, the total is counted too. The options are applied
+// as in PrepareQuery. The page may be served from a cache, see WithCache, and it's set with
+// the aggregates of the rows, see WithAggregates. A page past the last one is empty, unless
+// the paginator is set otherwise, see WithOutOfRange.
+func (p Paginator[T]) Query(ctx context.Context, q sq.SelectBuilder, page *Page, exec Executor, options ...func(*PaginatorOption)) ([]T, error) {
+	if page == nil {
+		page = &Page{Page: 1}
+	}
+	p = p.with(options)
+	if b, ok := exec.(txBeginner); ok && len(p.aggregates) > 0 {
+		tx, err := b.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+		if err != nil {
+			return nil, wrapErr(err)
+		}
+		defer tx.Rollback(ctx)
+		exec = tx
+	}
+	querier := NewQuerier(exec)
+	result, query := p.PrepareQueryContext(ctx, q, page)
+	key := ""
+	if p.cache != nil && !page.NoCache {
+		if key, _ = cacheKey(query); key != "" {
+			if cached, ok := p.getCached(ctx, key, page); ok {
+				return cached, nil
+			}
+		}
+	}
+	rows, err := p.observe(querier, page, false).QueryRows(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if p.inlineCount {
+		if err := ScanInlineCount(rows, &result, page); err != nil {
+			return nil, err
+		}
+	} else {
+		if result, err = Scan[T](rows); err != nil {
+			return nil, err
+		}
+		if err := p.Count(ctx, querier, query, page); err != nil {
+			return nil, err
+		}
+	}
+	if clamp, err := p.checkRange(ctx, querier, query, result, page); err != nil {
+		return nil, err
+	} else if clamp {
+		return p.Query(ctx, q, page, exec)
+	}
+	if err := p.queryAggregates(ctx, querier, query, page); err != nil {
+		return nil, err
+	}
+	result = p.PrepareResult(result, page)
+	if key != "" {
+		p.setCached(ctx, key, result, page)
+	}
+	return result, nil
+}
+
+// with returns a copy of the paginator with the options applied.
+func (p Paginator[T]) with(options []func(*PaginatorOption)) Paginator[T] {
+	for _, fn := range options {
+		fn(&p.PaginatorOption)
+	}
+	return p
+}
+
+// ForEachPage walks all the rows of q page by page, see CursorPaginator.ForEachPage. It uses
+// keyset pagination rather than offsets, so the sort columns must be selected, and the sort
+// should end with a unique column, see WithTiebreaker.
+func (p Paginator[T]) ForEachPage(ctx context.Context, q sq.SelectBuilder, size uint32, querier *Querier, fn func(rows []T, page *Page) error) error {
+	return CursorPaginator[T]{p.PaginatorOption}.ForEachPage(ctx, q, size, querier, fn)
+}
+
+// PrepareQueryContext is like PrepareQuery, applying the quota of the caller, the adaptive
+// max size of the query and the scope, see WithQuotaProvider, WithAdaptiveSize and WithScope.
+func (p Paginator[T]) PrepareQueryContext(ctx context.Context, q sq.SelectBuilder, page *Page, options ...func(*PaginatorOption)) ([]T, sq.SelectBuilder) {
+	return p.with(options).withQuota(ctx).PrepareQuery(q, page)
+}
+
+// where adds the filters to the query, see WithFilters.
+func where(q sq.SelectBuilder, filters []sq.Sqlizer) sq.SelectBuilder {
+	for _, f := range filters {
+		if f, ok := f.(Filters); ok && len(f) == 0 {
+			continue
+		}
+		q = q.Where(f)
+	}
+	return q
+}
+
+// withQuota returns a copy of the paginator limited by the quota of the caller, and scoped.
+func (p Paginator[T]) withQuota(ctx context.Context) Paginator[T] {
+	if p.adaptiveSize != nil {
+		if name := GetQueryConfig(ctx).Name; name != "" {
+			p.maxSize = p.adaptiveSize.MaxSize(name, p.getMaxSize())
+		}
+	}
+	p.PaginatorOption = p.withScope(ctx)
+	if p.quotaProvider == nil {
+		return p
+	}
+	quota := p.quotaProvider(ctx)
+	if quota.MaxSize > 0 && quota.MaxSize < p.getMaxSize() {
+		p.maxSize = quota.MaxSize
+	}
+	if quota.MaxOffset > 0 && (p.maxOffset == 0 || quota.MaxOffset < p.maxOffset) {
+		p.maxOffset = quota.MaxOffset
+	}
+	return p
+}
+
+// PrepareQuery2 is like PrepareQuery, but it also returns the page used to prepare the
+// query. When page is nil, a new one is returned, set to the first page with the paginator
+// defaults, so it can be passed to PrepareResult and returned to the caller.
+func (p Paginator[T]) PrepareQuery2(q sq.SelectBuilder, page *Page) ([]T, sq.SelectBuilder, *Page) {
+	if page == nil {
+		page = &Page{Page: 1}
+	}
+	result, q := p.PrepareQuery(q, page)
+	return result, q, page
+}
+
 // PrepareResult prepares the paginated result. If the number of rows is n+1:
 // - it removes the last element, returning n elements
 // - it sets more to true in the page object
-func (p Paginator[T]) PrepareResult(result []T, page *Page) []T {
-	limit := int(page.Limit())
+// A nil page is treated as the first page with the paginator defaults, use PrepareQuery2
+// to get a page back. The options passed to PrepareQuery which size the page, ie.
+// WithMaxSize, must be passed again.
+func (p Paginator[T]) PrepareResult(result []T, page *Page, options ...func(*PaginatorOption)) []T {
+	p = p.with(options)
+	if page == nil {
+		page = &Page{Page: 1, Size: p.getDefaultSize()}
+	}
+	offset, limit := p.offset(page), int(p.limit(page))
 	page.More = len(result) > limit
 	if page.More {
 		result = result[:limit]
 	}
 
 	page.Size = uint32(limit)
-	page.Page = 1 + uint32(page.Offset())/uint32(limit)
+	page.Page = 1 + uint32(offset)/uint32(limit)
+	page.From, page.To = 0, 0
+	if len(result) > 0 {
+		page.From = offset + 1
+		page.To = offset + uint64(len(result))
+	}
 	return result
 }
+
+// PageResult is the page state computed by PrepareResultValue.
+type PageResult struct {
+	Size uint32 `json:"size"`
+	Page uint32 `json:"page"`
+	More bool   `json:"more"`
+	From uint64 `json:"from,omitempty"`
+	To   uint64 `json:"to,omitempty"`
+}
+
+// PrepareQueryValue is like PrepareQuery, but the page is passed by value so it's never
+// modified, which makes it safe to share a Page across goroutines.
+func (p Paginator[T]) PrepareQueryValue(q sq.SelectBuilder, page Page) ([]T, sq.SelectBuilder) {
+	return p.PrepareQuery(q, &page)
+}
+
+// PrepareResultValue is like PrepareResult, but the page is passed by value and the
+// computed state is returned as a PageResult instead.
+func (p Paginator[T]) PrepareResultValue(result []T, page Page) ([]T, PageResult) {
+	p.setDefaults(&page)
+	result = p.PrepareResult(result, &page)
+	return result, PageResult{Size: page.Size, Page: page.Page, More: page.More, From: page.From, To: page.To}
+}
+
+// PrepareCountQuery returns the query counting all the rows of q, ignoring its limit,
+// offset and order.
+func (p Paginator[T]) PrepareCountQuery(q sq.SelectBuilder) sq.SelectBuilder {
+	inner := removeOrderBy(q.RemoveLimit().RemoveOffset())
+	return sq.Select("count(*)").FromSelect(inner, "pgkit_count").PlaceholderFormat(sq.Dollar)
+}
+
+// Count runs the count query of q and sets the page total, when the paginator is created
+// with WithTotalCount, it does nothing otherwise.
+func (p Paginator[T]) Count(ctx context.Context, querier *Querier, q sq.SelectBuilder, page *Page) error {
+	if !p.totalCount || page == nil {
+		return nil
+	}
+	var total uint64
+	if err := p.observe(querier, page, true).GetOne(ctx, p.PrepareCountQuery(q), &total); err != nil {
+		return err
+	}
+	p.setTotal(page, total)
+	return nil
+}
+
+// PageOf returns the page, under the sort and size of page, containing the first row of q
+// matching item, so a UI can link to "the page with this record". The scope, the filters and
+// the search of the paginator apply, as in Query. It fails with ErrNoRows when no row
+// matches.
+func (p Paginator[T]) PageOf(ctx context.Context, querier *Querier, q sq.SelectBuilder, page *Page, item sq.Sqlizer) (*Page, error) {
+	if page == nil {
+		page = &Page{Page: 1}
+	}
+	result := *page
+	p.PaginatorOption = p.withScope(ctx)
+	q = p.wrapGrouped(p.search.where(where(q, p.filters)))
+	p.setDefaults(&result)
+
+	order, args := p.getOrder(&result), []interface{}(nil)
+	if rank := p.search.rank(); rank != nil {
+		order, args = append([]string{"?"}, order...), []interface{}{rank}
+	}
+	over := ""
+	if len(order) > 0 {
+		over = "ORDER BY " + strings.Join(order, ", ")
+	}
+	inner := removeOrderBy(q.RemoveLimit().RemoveOffset()).
+		Column(sq.Alias(item, "pgkit_match")).
+		Column(sq.Expr(fmt.Sprintf("row_number() OVER (%s) AS pgkit_row", over), args...))
+	query := sq.Select("pgkit_row").FromSelect(inner, "pgkit_page").
+		Where("pgkit_match").OrderBy("pgkit_row").
+		PlaceholderFormat(sq.Dollar)
+
+	var row uint64
+	if err := querier.GetOne(ctx, query, &row); err != nil {
+		return nil, err
+	}
+	result.Page = uint32((row-1)/p.limit(&result)) + 1
+	result.More = false
+	return &result, nil
+}
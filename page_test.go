@@ -1,6 +1,9 @@
 package pgkit_test
 
 import (
+	"context"
+	"encoding/json"
+	"net/url"
 	"strings"
 	"testing"
 
@@ -39,9 +42,313 @@ func TestPagination(t *testing.T) {
 
 	result = paginator.PrepareResult(make([]T, MaxSize), page)
 	require.Len(t, result, MaxSize)
-	require.Equal(t, &pgkit.Page{Page: 1, Size: MaxSize}, page)
+	require.Equal(t, &pgkit.Page{Page: 1, Size: MaxSize, From: 1, To: MaxSize}, page)
 
 	result = paginator.PrepareResult(make([]T, MaxSize+2), page)
 	require.Len(t, result, MaxSize)
-	require.Equal(t, &pgkit.Page{Page: 1, Size: MaxSize, More: true}, page)
+	require.Equal(t, &pgkit.Page{Page: 1, Size: MaxSize, More: true, From: 1, To: MaxSize}, page)
+
+	page.Page = 3
+	result = paginator.PrepareResult(make([]T, 2), page)
+	require.Len(t, result, 2)
+	require.Equal(t, &pgkit.Page{Page: 3, Size: MaxSize, From: 11, To: 12}, page)
+}
+
+func TestPaginationNilPage(t *testing.T) {
+	paginator := pgkit.NewPaginator[T](pgkit.WithDefaultSize(3), pgkit.WithSort("id"))
+
+	result, query := paginator.PrepareQuery(sq.Select("*").From("t"), nil)
+	require.Len(t, result, 0)
+	sql, _, err := query.ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "SELECT * FROM t ORDER BY id ASC LIMIT 4 OFFSET 0", sql)
+	require.Len(t, paginator.PrepareResult(make([]T, 4), nil), 3)
+
+	result, query, page := paginator.PrepareQuery2(sq.Select("*").From("t"), nil)
+	require.Len(t, result, 0)
+	require.Equal(t, &pgkit.Page{Page: 1, Size: 3}, page)
+	sql, _, err = query.ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "SELECT * FROM t ORDER BY id ASC LIMIT 4 OFFSET 0", sql)
+
+	result = paginator.PrepareResult(make([]T, 4), page)
+	require.Len(t, result, 3)
+	require.Equal(t, &pgkit.Page{Page: 1, Size: 3, More: true, From: 1, To: 3}, page)
+}
+
+func TestPaginationValue(t *testing.T) {
+	paginator := pgkit.NewPaginator[T](pgkit.WithDefaultSize(3), pgkit.WithSort("id"))
+
+	page := pgkit.Page{Page: 2}
+	_, query := paginator.PrepareQueryValue(sq.Select("*").From("t"), page)
+	sql, _, err := query.ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "SELECT * FROM t ORDER BY id ASC LIMIT 4 OFFSET 3", sql)
+
+	result, pageResult := paginator.PrepareResultValue(make([]T, 4), page)
+	require.Len(t, result, 3)
+	require.Equal(t, pgkit.PageResult{Page: 2, Size: 3, More: true, From: 4, To: 6}, pageResult)
+	require.Equal(t, pgkit.Page{Page: 2}, page)
+}
+
+func TestPaginatorConfig(t *testing.T) {
+	sort := []string{"-created_at", "id"}
+	paginator := pgkit.NewPaginator[T](pgkit.WithMaxSize(20), pgkit.WithSort(sort...))
+	sort[0] = "name"
+
+	cfg := paginator.Config()
+	require.Equal(t, pgkit.PaginatorConfig{
+		DefaultSize: pgkit.DefaultPageSize,
+		MaxSize:     20,
+		DefaultSort: []string{"-created_at", "id"},
+	}, cfg)
+
+	cfg.DefaultSort[0] = "name"
+	require.Equal(t, []string{"-created_at", "id"}, paginator.Config().DefaultSort)
+}
+
+func TestOrderTypeUnmarshal(t *testing.T) {
+	for input, expected := range map[string]pgkit.OrderType{
+		`{"column":"id","order":"asc"}`:  pgkit.Asc,
+		`{"column":"id","order":"DESC"}`: pgkit.Desc,
+		`{"column":"id","order":1}`:      pgkit.Asc,
+		`{"column":"id","order":-1}`:     pgkit.Desc,
+		`{"column":"id","order":"-1"}`:   pgkit.Desc,
+		`{"column":"id"}`:                "",
+	} {
+		var sort pgkit.Sort
+		require.NoError(t, json.Unmarshal([]byte(input), &sort), input)
+		require.Equal(t, expected, sort.Order, input)
+	}
+
+	var sort pgkit.Sort
+	require.Error(t, json.Unmarshal([]byte(`{"column":"id","order":"id; DROP TABLE t"}`), &sort))
+
+	data, err := json.Marshal(pgkit.Sort{Column: "id", Order: "desc"})
+	require.NoError(t, err)
+	require.Equal(t, `{"column":"id","order":"DESC"}`, string(data))
+
+	require.Equal(t, "id ASC", pgkit.Sort{Column: "id", Order: "; DROP TABLE t"}.String())
+	require.Equal(t, "id DESC NULLS LAST", pgkit.Sort{Column: "id", Order: "desc", Nulls: "last"}.String())
+
+	// a lowercase order built by the caller sorts the same as the constant
+	page := &pgkit.Page{Order: []pgkit.Sort{{Column: "name", Order: "desc"}}}
+	_, query := pgkit.NewPaginator[T](pgkit.WithTiebreaker("id")).PrepareQuery(sq.Select("*").From("t"), page)
+	sql, _, err := query.ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "SELECT * FROM t ORDER BY name DESC, id DESC LIMIT 11 OFFSET 0", sql)
+	require.Equal(t, pgkit.Desc, page.GetOrder()[0].Order)
+}
+
+func TestPaginationMaxOffset(t *testing.T) {
+	paginator := pgkit.NewPaginator[T](pgkit.WithDefaultSize(10), pgkit.WithMaxOffset(20))
+
+	_, query := paginator.PrepareQuery(sq.Select("*").From("t"), pgkit.NewPage(10, 3))
+	_, _, err := query.ToSql()
+	require.NoError(t, err)
+
+	_, query = paginator.PrepareQuery(sq.Select("*").From("t"), pgkit.NewPage(10, 4))
+	_, _, err = query.ToSql()
+	require.ErrorIs(t, err, pgkit.ErrOffsetTooDeep)
+	require.Equal(t, uint64(20), paginator.Config().MaxOffset)
+}
+
+func TestPaginationTotalCount(t *testing.T) {
+	paginator := pgkit.NewPaginator[T](pgkit.WithDefaultSize(10), pgkit.WithSort("id"), pgkit.WithTotalCount())
+	require.True(t, paginator.Config().TotalCount)
+
+	page := pgkit.NewPage(10, 2)
+	_, query := paginator.PrepareQuery(sq.Select("*").From("t").Where(sq.Eq{"a": 1}), page)
+	sql, args, err := paginator.PrepareCountQuery(query).ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "SELECT count(*) FROM (SELECT * FROM t WHERE a = $1) AS pgkit_count", sql)
+	require.Equal(t, []interface{}{1}, args)
+
+	page.SetTotal(134)
+	require.Equal(t, uint64(134), page.Total)
+	require.Equal(t, uint32(14), page.TotalPages)
+
+	page.SetTotal(0)
+	require.Equal(t, uint32(0), page.TotalPages)
+}
+
+func TestPaginationQuota(t *testing.T) {
+	type apiKey struct{}
+	paginator := pgkit.NewPaginator[T](pgkit.WithMaxSize(50), pgkit.WithQuotaProvider(func(ctx context.Context) pgkit.PaginationQuota {
+		if ctx.Value(apiKey{}) == "free" {
+			return pgkit.PaginationQuota{MaxSize: 5, MaxOffset: 10}
+		}
+		return pgkit.PaginationQuota{}
+	}))
+
+	free := context.WithValue(context.Background(), apiKey{}, "free")
+	page := pgkit.NewPage(20, 2)
+	_, query := paginator.PrepareQueryContext(free, sq.Select("*").From("t"), page)
+	sql, _, err := query.ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "SELECT * FROM t LIMIT 6 OFFSET 5", sql)
+
+	_, query = paginator.PrepareQueryContext(free, sq.Select("*").From("t"), pgkit.NewPage(5, 4))
+	_, _, err = query.ToSql()
+	require.ErrorIs(t, err, pgkit.ErrOffsetTooDeep)
+
+	page = pgkit.NewPage(20, 2)
+	_, query = paginator.PrepareQueryContext(context.Background(), sq.Select("*").From("t"), page)
+	sql, _, err = query.ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "SELECT * FROM t LIMIT 21 OFFSET 20", sql)
+}
+
+func TestPaginationAllowedColumns(t *testing.T) {
+	paginator := pgkit.NewPaginator[T](
+		pgkit.WithSort("id"),
+		pgkit.WithColumnMap(map[string]string{"id": "id", "createdAt": "created_at"}),
+	)
+
+	tests := map[string]string{
+		"-createdAt,id":       "ORDER BY created_at DESC, id ASC",
+		"createdAt,password":  "ORDER BY created_at ASC",
+		"id;DROP TABLE users": "ORDER BY id ASC",
+		"created_at":          "ORDER BY id ASC",
+	}
+	for column, expected := range tests {
+		_, query := paginator.PrepareQuery(sq.Select("*").From("t"), &pgkit.Page{Column: column})
+		sql, _, err := query.ToSql()
+		require.NoError(t, err)
+		require.Equal(t, "SELECT * FROM t "+expected+" LIMIT 11 OFFSET 0", sql, column)
+	}
+
+	_, query := paginator.PrepareQuery(sq.Select("*").From("t"), &pgkit.Page{Order: []pgkit.Sort{{Column: "1; DROP TABLE users"}}})
+	sql, _, err := query.ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "SELECT * FROM t ORDER BY id ASC LIMIT 11 OFFSET 0", sql)
+
+	require.Equal(t, []string{"createdAt", "id"}, paginator.Config().AllowedColumns)
+	require.Equal(t, []string{"id"}, pgkit.NewPaginator[T](pgkit.WithAllowedColumns("id")).Config().AllowedColumns)
+}
+
+func TestPaginationNulls(t *testing.T) {
+	sort, ok := pgkit.NewSort("-ended_at:nullslast")
+	require.True(t, ok)
+	require.Equal(t, pgkit.Sort{Column: "ended_at", Order: pgkit.Desc, Nulls: pgkit.NullsLast}, sort)
+	require.Equal(t, "ended_at DESC NULLS LAST", sort.String())
+
+	_, ok = pgkit.NewSort("ended_at:nullsmiddle")
+	require.False(t, ok)
+
+	paginator := pgkit.NewPaginator[T](pgkit.WithColumnFunc(strings.ToUpper))
+	_, query := paginator.PrepareQuery(sq.Select("*").From("t"), &pgkit.Page{Column: "started_at:NullsFirst,id"})
+	sql, _, err := query.ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "SELECT * FROM t ORDER BY STARTED_AT ASC NULLS FIRST, ID ASC LIMIT 11 OFFSET 0", sql)
+}
+
+func TestPaginationTiebreaker(t *testing.T) {
+	paginator := pgkit.NewPaginator[T](pgkit.WithSort("name"), pgkit.WithTiebreaker("id"))
+
+	for column, expected := range map[string]string{
+		"":                "ORDER BY name ASC, id ASC",
+		"-created_at":     "ORDER BY created_at DESC, id DESC",
+		"-id,name":        "ORDER BY id DESC, name ASC",
+		"name,-rank":      "ORDER BY name ASC, rank DESC, id DESC",
+		"name:nullsfirst": "ORDER BY name ASC NULLS FIRST, id ASC",
+	} {
+		_, query := paginator.PrepareQuery(sq.Select("*").From("t"), &pgkit.Page{Column: column})
+		sql, _, err := query.ToSql()
+		require.NoError(t, err)
+		require.Equal(t, "SELECT * FROM t "+expected+" LIMIT 11 OFFSET 0", sql, column)
+	}
+	require.Equal(t, "id", paginator.Config().Tiebreaker)
+}
+
+func TestPaginationCallOptions(t *testing.T) {
+	paginator := pgkit.NewPaginator[T](pgkit.WithSort("id"), pgkit.WithFilters(sq.Eq{"deleted": false}))
+
+	page := pgkit.NewPage(150, 2)
+	result, query := paginator.PrepareQuery(sq.Select("*").From("t"), page, pgkit.WithMaxSize(200), pgkit.WithSort("-updated_at"), pgkit.WithFilters(sq.Eq{"kind": "a"}))
+	sql, args, err := query.ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "SELECT * FROM t WHERE deleted = ? AND kind = ? ORDER BY updated_at DESC LIMIT 151 OFFSET 150", sql)
+	require.Equal(t, []interface{}{false, "a"}, args)
+	require.Equal(t, 151, cap(result))
+
+	result = paginator.PrepareResult(make([]T, 151), page, pgkit.WithMaxSize(200))
+	require.Len(t, result, 150)
+	require.True(t, page.More)
+	require.Equal(t, uint32(2), page.Page)
+	require.Equal(t, uint64(151), page.From)
+
+	// the page alone is clamped to the max page size
+	require.Equal(t, uint64(50), page.Limit())
+	require.Equal(t, uint64(50), page.Offset())
+
+	// the paginator is left untouched
+	page = pgkit.NewPage(150, 2)
+	_, query = paginator.PrepareQuery(sq.Select("*").From("t"), page)
+	sql, _, err = query.ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "SELECT * FROM t WHERE deleted = ? ORDER BY id ASC LIMIT 51 OFFSET 50", sql)
+	require.Equal(t, pgkit.PaginatorConfig{DefaultSize: 10, MaxSize: 50, DefaultSort: []string{"id"}}, paginator.Config())
+}
+
+func TestPaginationSortExpressions(t *testing.T) {
+	paginator := pgkit.NewPaginator[T](
+		pgkit.WithSort("id"),
+		pgkit.WithAllowedColumns("id"),
+		pgkit.WithSortExpressions(map[string]string{"name": "lower(u.name)", "u.created_at": "u.created_at"}),
+		pgkit.WithColumnFunc(func(c string) string { return "t." + c }),
+	)
+
+	for column, expected := range map[string]string{
+		"-name,id":       "ORDER BY lower(u.name) DESC, t.id ASC",
+		"u.created_at":   "ORDER BY u.created_at ASC",
+		"u.deleted_at":   "ORDER BY t.id ASC",
+		"name:nullslast": "ORDER BY lower(u.name) ASC NULLS LAST",
+	} {
+		_, query := paginator.PrepareQuery(sq.Select("*").From("t"), &pgkit.Page{Column: column})
+		sql, _, err := query.ToSql()
+		require.NoError(t, err)
+		require.Equal(t, "SELECT * FROM t "+expected+" LIMIT 11 OFFSET 0", sql, column)
+	}
+
+	page, err := pgkit.PageFromValues(url.Values{"sort": {"-name"}}, pgkit.WithAllowedColumns("id"), pgkit.WithSortExpressions(map[string]string{"name": "lower(u.name)"}))
+	require.NoError(t, err)
+	require.Equal(t, []pgkit.Sort{{Column: "name", Order: pgkit.Desc}}, page.Order)
+}
+
+func TestPaginationInlineCount(t *testing.T) {
+	paginator := pgkit.NewPaginator[T](pgkit.WithInlineCount())
+	_, query := paginator.PrepareQuery(sq.Select("id", "name").From("t"), pgkit.NewPage(10, 2))
+	sql, _, err := query.ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "SELECT id, name, count(*) OVER () AS pgkit_total FROM t LIMIT 11 OFFSET 10", sql)
+}
+
+func TestPaginationDistinctOn(t *testing.T) {
+	paginator := pgkit.NewPaginator[T](pgkit.WithSort("-created_at"))
+	page := pgkit.NewPage(10, 1)
+
+	// the latest post of each author, sorted by date
+	latest := sq.Select("author_id", "created_at").Options("DISTINCT ON (author_id)").From("posts").Where("draft = ?", false)
+	_, query := paginator.PrepareQuery(latest.OrderBy("author_id", "created_at DESC").PlaceholderFormat(sq.Dollar), page)
+	sql, args, err := query.ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "SELECT * FROM (SELECT DISTINCT ON (author_id) author_id, created_at FROM posts WHERE draft = $1 ORDER BY author_id, created_at DESC) AS pgkit_page ORDER BY created_at DESC LIMIT 11 OFFSET 0", sql)
+	require.Equal(t, []interface{}{false}, args)
+
+	_, query = paginator.PrepareQuery(latest, page)
+	sql, _, err = query.ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "SELECT * FROM (SELECT DISTINCT ON (author_id) author_id, created_at FROM posts WHERE draft = ? ORDER BY author_id) AS pgkit_page ORDER BY created_at DESC LIMIT 11 OFFSET 0", sql)
+
+	_, query = paginator.PrepareQuery(sq.Select("DISTINCT ON (a) a, created_at").From("t"), page, pgkit.WithDistinctOn("a"))
+	sql, _, err = query.ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "SELECT * FROM (SELECT DISTINCT ON (a) a, created_at FROM t ORDER BY a) AS pgkit_page ORDER BY created_at DESC LIMIT 11 OFFSET 0", sql)
+
+	counts := sq.Select("author_id", "max(created_at) AS created_at").From("posts").GroupBy("author_id").OrderBy("author_id")
+	_, query = paginator.PrepareQuery(counts, page)
+	sql, _, err = query.ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "SELECT * FROM (SELECT author_id, max(created_at) AS created_at FROM posts GROUP BY author_id) AS pgkit_page ORDER BY created_at DESC LIMIT 11 OFFSET 0", sql)
 }
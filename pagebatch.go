@@ -0,0 +1,79 @@
+package pgkit
+
+import (
+	"context"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
+)
+
+// PageBatch fetches several pages, of possibly different queries and row types, in a single
+// round trip, ie. the lists of a dashboard:
+//
+//	var batch pgkit.PageBatch
+//	var accounts []Account
+//	var reviews []Review
+//	pgkit.AddPage(&batch, accountPaginator, accountsQuery, accountsPage, &accounts)
+//	pgkit.AddPage(&batch, reviewPaginator, reviewsQuery, reviewsPage, &reviews)
+//	err := batch.Send(ctx, db.Query)
+type PageBatch struct {
+	queries Queries
+	scans   []func(rows pgx.Rows) error
+}
+
+// AddPage adds a page of q to the batch, once sent its rows are scanned into dest, and the
+// page is updated, as with Paginator.PrepareQuery and PrepareResult. See AddPageContext for
+// the scoped paginators.
+func AddPage[T any](b *PageBatch, p Paginator[T], q sq.SelectBuilder, page *Page, dest *[]T, options ...func(*PaginatorOption)) {
+	if page == nil {
+		page = &Page{Page: 1}
+	}
+	_, q = p.PrepareQuery(q, page, options...)
+	addPage(b, p.with(options), q, page, dest)
+}
+
+// AddPageContext is like AddPage, preparing the query with Paginator.PrepareQueryContext.
+func AddPageContext[T any](ctx context.Context, b *PageBatch, p Paginator[T], q sq.SelectBuilder, page *Page, dest *[]T, options ...func(*PaginatorOption)) {
+	if page == nil {
+		page = &Page{Page: 1}
+	}
+	_, q = p.PrepareQueryContext(ctx, q, page, options...)
+	addPage(b, p.with(options), q, page, dest)
+}
+
+func addPage[T any](b *PageBatch, p Paginator[T], q sq.SelectBuilder, page *Page, dest *[]T) {
+	b.queries.Add(q)
+	b.scans = append(b.scans, func(rows pgx.Rows) error {
+		result, err := Scan[T](rows)
+		if err != nil {
+			return err
+		}
+		*dest = p.PrepareResult(result, page)
+		return nil
+	})
+}
+
+// Len returns the number of pages in the batch.
+func (b *PageBatch) Len() int {
+	return len(b.scans)
+}
+
+// Send runs the queries of the batch.
+func (b *PageBatch) Send(ctx context.Context, querier *Querier) error {
+	results, n, err := querier.BatchQuery(ctx, b.queries)
+	if err != nil {
+		return err
+	}
+	defer results.Close()
+
+	for i := 0; i < n; i++ {
+		rows, err := results.Query()
+		if err != nil {
+			return wrapErr(err)
+		}
+		if err := b.scans[i](rows); err != nil {
+			return err
+		}
+	}
+	return nil
+}
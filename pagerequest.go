@@ -0,0 +1,135 @@
+package pgkit
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// PageFromRequest parses the page from the query parameters of r, see PageFromValues.
+func PageFromRequest(r *http.Request, options ...func(*PaginatorOption)) (*Page, error) {
+	return PageFromValues(r.URL.Query(), options...)
+}
+
+// PageFromValues parses the page from query parameters such as
+// `?page=2&size=25&sort=-created_at,id`, the size defaulting to the default size of the
+// options and being clamped to their max size. The sort is validated against the allowed
+// columns and sort expressions of the options, if any. A cursor parameter sets Page.Cursor.
+func PageFromValues(values url.Values, options ...func(*PaginatorOption)) (*Page, error) {
+	o := NewPaginator[struct{}](options...).PaginatorOption
+	page := &Page{Page: 1, Size: o.getDefaultSize(), Cursor: values.Get("cursor")}
+
+	for key, dst := range map[string]*uint32{"page": &page.Page, "size": &page.Size} {
+		v := values.Get(key)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.ParseUint(v, 10, 32)
+		if err != nil || n == 0 {
+			return nil, fmt.Errorf("pgkit: invalid %s %q, expecting a positive number", key, v)
+		}
+		*dst = uint32(n)
+	}
+	if max := o.getMaxSize(); page.Size > max {
+		page.Size = max
+	}
+
+	if v := values.Get("sort"); v != "" {
+		for _, part := range strings.Split(v, ",") {
+			s, ok := NewSort(strings.TrimSpace(part))
+			if !ok || s.Column == "" {
+				return nil, fmt.Errorf("pgkit: invalid sort %q", part)
+			}
+			if _, ok := o.sortExpressions[s.Column]; !ok && o.allowedColumns != nil {
+				if _, ok := o.allowedColumns[s.Column]; !ok {
+					return nil, fmt.Errorf("pgkit: invalid sort %q, unknown column", part)
+				}
+			}
+			page.Order = append(page.Order, s)
+		}
+	}
+	return page, nil
+}
+
+// PageLinks are the URLs of the pages around a page, empty when there's no such page, see
+// Page.Links.
+type PageLinks struct {
+	First string `json:"first,omitempty"`
+	Prev  string `json:"prev,omitempty"`
+	Next  string `json:"next,omitempty"`
+	Last  string `json:"last,omitempty"`
+}
+
+// Header returns the value of an RFC 5988 Link header, ie.
+// `<https://api/items?page=3&size=10>; rel="next"`, or an empty string without links.
+func (l PageLinks) Header() string {
+	var parts []string
+	for _, link := range []struct{ rel, url string }{{"first", l.First}, {"prev", l.Prev}, {"next", l.Next}, {"last", l.Last}} {
+		if link.url != "" {
+			parts = append(parts, fmt.Sprintf(`<%s>; rel="%s"`, link.url, link.rel))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Links returns the URLs of the first, previous, next and last pages, once the page is
+// prepared by PrepareResult, in the format parsed by PageFromValues. The other parameters of
+// baseURL, ie. filters, are kept. The last page needs the total, see SetTotal, and a cursor
+// page only links the first and next pages. It's empty when baseURL is invalid.
+func (p *Page) Links(baseURL string) PageLinks {
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return PageLinks{}
+	}
+	var sort []string
+	for _, s := range p.GetOrder() {
+		sort = append(sort, s.param())
+	}
+	link := func(page uint32, cursor string) string {
+		values := base.Query()
+		values.Del("page")
+		values.Del("cursor")
+		if cursor != "" {
+			values.Set("cursor", cursor)
+		} else {
+			values.Set("page", strconv.FormatUint(uint64(page), 10))
+		}
+		values.Set("size", strconv.FormatUint(p.size(), 10))
+		if len(sort) != 0 {
+			values.Set("sort", strings.Join(sort, ","))
+		}
+		u := *base
+		u.RawQuery = values.Encode()
+		return u.String()
+	}
+
+	links := PageLinks{First: link(1, "")}
+	cursor := p.Cursor != "" || p.NextCursor != ""
+	if p.Page > 1 && !cursor {
+		links.Prev = link(p.Page-1, "")
+	}
+	if p.More {
+		links.Next = link(p.Page+1, p.NextCursor)
+	}
+	if p.TotalPages > 0 && !cursor {
+		links.Last = link(p.TotalPages, "")
+	}
+	return links
+}
+
+// param returns the sort in the format parsed by NewSort.
+func (s Sort) param() string {
+	param := s.Column
+	if s.Order == Desc {
+		param = "-" + param
+	}
+	switch s.Nulls {
+	case NullsFirst:
+		param += ":nullsfirst"
+	case NullsLast:
+		param += ":nullslast"
+	}
+	return param
+}
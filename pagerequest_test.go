@@ -0,0 +1,68 @@
+package pgkit_test
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/goware/pgkit/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPageFromRequest(t *testing.T) {
+	r := httptest.NewRequest("GET", "/accounts?page=2&size=25&sort=-created_at,id", nil)
+	page, err := pgkit.PageFromRequest(r)
+	require.NoError(t, err)
+	require.Equal(t, &pgkit.Page{Page: 2, Size: 25, Order: []pgkit.Sort{
+		{Column: "created_at", Order: pgkit.Desc},
+		{Column: "id", Order: pgkit.Asc},
+	}}, page)
+
+	page, err = pgkit.PageFromValues(url.Values{"size": {"100"}}, pgkit.WithDefaultSize(5), pgkit.WithMaxSize(20))
+	require.NoError(t, err)
+	require.Equal(t, &pgkit.Page{Page: 1, Size: 20}, page)
+
+	page, err = pgkit.PageFromValues(url.Values{}, pgkit.WithDefaultSize(5))
+	require.NoError(t, err)
+	require.Equal(t, &pgkit.Page{Page: 1, Size: 5}, page)
+
+	for _, query := range []string{"page=x", "page=0", "size=-1", "sort=-", "sort=id,,name", "sort=password"} {
+		values, err := url.ParseQuery(query)
+		require.NoError(t, err)
+		_, err = pgkit.PageFromValues(values, pgkit.WithAllowedColumns("id", "name"))
+		require.Error(t, err, query)
+	}
+}
+
+func TestPageLinks(t *testing.T) {
+	page := &pgkit.Page{Page: 2, Size: 10, More: true, Order: []pgkit.Sort{
+		{Column: "created_at", Order: pgkit.Desc, Nulls: pgkit.NullsLast},
+		{Column: "id", Order: pgkit.Asc},
+	}}
+	page.SetTotal(45)
+	links := page.Links("https://api.test/items?status=active&page=9")
+	require.Equal(t, pgkit.PageLinks{
+		First: "https://api.test/items?page=1&size=10&sort=-created_at%3Anullslast%2Cid&status=active",
+		Prev:  "https://api.test/items?page=1&size=10&sort=-created_at%3Anullslast%2Cid&status=active",
+		Next:  "https://api.test/items?page=3&size=10&sort=-created_at%3Anullslast%2Cid&status=active",
+		Last:  "https://api.test/items?page=5&size=10&sort=-created_at%3Anullslast%2Cid&status=active",
+	}, links)
+	require.Equal(t, `<`+links.First+`>; rel="first", <`+links.Prev+`>; rel="prev", <`+links.Next+`>; rel="next", <`+links.Last+`>; rel="last"`, links.Header())
+
+	// the links round trip through PageFromValues
+	u, err := url.Parse(links.Next)
+	require.NoError(t, err)
+	next, err := pgkit.PageFromValues(u.Query())
+	require.NoError(t, err)
+	require.Equal(t, &pgkit.Page{Page: 3, Size: 10, Order: page.Order}, next)
+
+	page = &pgkit.Page{Page: 1, Size: 5, More: true, Column: "name", NextCursor: "abc"}
+	require.Equal(t, pgkit.PageLinks{
+		First: "/items?page=1&size=5&sort=name",
+		Next:  "/items?cursor=abc&size=5&sort=name",
+	}, page.Links("/items"))
+
+	require.Empty(t, (&pgkit.Page{Page: 1}).Links("/items").Next)
+	require.Empty(t, pgkit.PageLinks{}.Header())
+	require.Equal(t, pgkit.PageLinks{}, page.Links("%zz"))
+}
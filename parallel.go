@@ -0,0 +1,93 @@
+package pgkit
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// parallelScanChunk is the number of blocks of the table in a chunk of ParallelScan, 8MB
+// with the default block size.
+const parallelScanChunk = 1024
+
+// ParallelScan reads all the rows of the table with a pool of workers, each one running fn
+// on the rows of a chunk of the table at a time, ie. for fast ETL from a large table. The
+// chunks are ranges of the physical location of the rows (ctid), read efficiently as TID
+// range scans on PostgreSQL 14+. All the workers see the same snapshot of the database, see
+// ExportSnapshot, so the rows are the ones of the table when ParallelScan starts, each one
+// read once. Each worker uses a connection of the pool, on top of the one holding the
+// snapshot. The first error stops the scan and is returned, fn must be safe for concurrent
+// use.
+func ParallelScan[T any](ctx context.Context, db *DB, table string, workers int, fn func(ctx context.Context, rows []T) error) error {
+	if workers < 1 {
+		workers = 1
+	}
+	snapshot, err := ExportSnapshot(ctx, db)
+	if err != nil {
+		return err
+	}
+	defer snapshot.Release(context.Background())
+
+	var blocks int64
+	err = snapshot.tx.QueryRow(ctx, `SELECT pg_relation_size($1::regclass) / current_setting('block_size')::int`, table).Scan(&blocks)
+	if err != nil {
+		return wrapErr(err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	chunks := make(chan int64)
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	fail := func(err error) {
+		once.Do(func() { firstErr = err; cancel() })
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for start := range chunks {
+				err := ReadTx(ctx, db, func(q *Querier) error {
+					// the last chunk is open ended, the table may have grown
+					query := q.SQL.Select("*").From(table).Where("ctid >= ?::tid", fmt.Sprintf("(%d,0)", start))
+					if start+parallelScanChunk < blocks {
+						query = query.Where("ctid < ?::tid", fmt.Sprintf("(%d,0)", start+parallelScanChunk))
+					}
+					rows, err := q.QueryRows(ctx, query)
+					if err != nil {
+						return err
+					}
+					result, err := Scan[T](rows)
+					if err != nil {
+						return err
+					}
+					if len(result) == 0 {
+						return nil
+					}
+					return fn(ctx, result)
+				}, WithSnapshot(snapshot.ID))
+				if err != nil {
+					fail(err)
+				}
+			}
+		}()
+	}
+
+send:
+	for start := int64(0); start == 0 || start < blocks; start += parallelScanChunk {
+		select {
+		case chunks <- start:
+		case <-ctx.Done():
+			break send
+		}
+	}
+	close(chunks)
+	wg.Wait()
+	if firstErr == nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return firstErr
+}
@@ -4,6 +4,10 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	sq "github.com/Masterminds/squirrel"
@@ -13,13 +17,46 @@ import (
 )
 
 type DB struct {
+	// Conn is the connection pool, replaced by ApplyConfig: use Pool when ApplyConfig may
+	// run concurrently.
 	Conn  *pgxpool.Pool
 	SQL   *StatementBuilder
 	Query *Querier
+
+	// Alerter receives the alerts of the DB, see Alert.
+	Alerter Alerter
+
+	// appName and cfg are set by Connect, and used by ApplyConfig.
+	mu      sync.Mutex
+	appName string
+	cfg     *Config
+
+	inflight *inflight
+
+	// serverVersion caches ServerVersion.
+	serverVersion atomic.Int64
+	// capabilities caches Capabilities.
+	capabilities atomic.Pointer[Capabilities]
+	// slowQuery is the threshold of SlowQueries, see Config.SlowQueryThreshold.
+	slowQuery atomic.Int64
+	// pageSizes are the page sizes of the DB, see WithPageSizes.
+	pageSizes *pageSizes
+}
+
+// Pool returns the connection pool of the DB, the one replaced by ApplyConfig, which is safe
+// to call while ApplyConfig runs unlike reading Conn.
+func (d *DB) Pool() *pgxpool.Pool {
+	return d.Query.pool.get()
 }
 
 func (d *DB) TxQuery(tx pgx.Tx) *Querier {
-	return &Querier{tx: tx, SQL: d.SQL, pool: d.Conn}
+	return &Querier{tx: tx, SQL: d.SQL, pool: d.Query.pool, middleware: d.Query.middleware, analyzeThreshold: d.Query.analyzeThreshold}
+}
+
+// Use installs middleware on the DB querier, it also applies to queriers returned
+// by TxQuery. It's not safe to call Use while queries are running.
+func (d *DB) Use(middleware ...Middleware) {
+	d.Query = d.Query.With(middleware...)
 }
 
 type Config struct {
@@ -31,11 +68,135 @@ type Config struct {
 	MinConns        int32  `toml:"min_conns"`
 	ConnMaxLifetime string `toml:"conn_max_lifetime"` // ie. "1800s" or "1h"
 
+	// ContextAppName sets the application_name of each acquired connection from the
+	// RequestMeta carried by the query context, see WithRequestMeta.
+	ContextAppName bool `toml:"context_app_name"`
+
+	// SearchPath sets the search_path of the connections, and of DB.SQL, see
+	// StatementBuilder.WithSearchPath. QualifyTables qualifies the table names of DB.SQL with
+	// its first schema, see StatementBuilder.WithQualifiedTables. They can't be changed by
+	// ApplyConfig.
+	SearchPath    []string `toml:"search_path"`
+	QualifyTables bool     `toml:"qualify_tables"`
+
+	// DefaultPageSize and MaxPageSize replace DefaultPageSize and MaxPageSize for the
+	// paginators using the page sizes of the DB, see WithPageSizes, unless they set their
+	// own, see WithDefaultSize and WithMaxSize. Zero keeps the package ones.
+	DefaultPageSize uint32 `toml:"default_page_size"`
+	MaxPageSize     uint32 `toml:"max_page_size"`
+	// SlowQueryThreshold is the duration above which a query is reported by SlowQueries,
+	// ie. "500ms", none are reported when empty.
+	SlowQueryThreshold string `toml:"slow_query_threshold"`
+
 	Override func(cfg *pgx.ConnConfig) `toml:"-"`
 }
 
 func Connect(appName string, cfg Config) (*DB, error) {
-	poolCfg, err := pgxpool.ParseConfig(getConnectURI(appName, cfg))
+	slowQuery, err := cfg.validateSettings()
+	if err != nil {
+		return nil, err
+	}
+	poolCfg, err := poolConfig(appName, &cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := ConnectWithPGX(appName, poolCfg)
+	if err != nil {
+		return nil, err
+	}
+	db.appName, db.cfg = appName, &cfg
+	db.SQL.qualifyTables = cfg.QualifyTables
+	db.applySettings(cfg, slowQuery)
+	return db, nil
+}
+
+// ApplyConfig reconfigures a DB created by Connect at runtime. The pgkit settings, the page
+// sizes and the slow query threshold, are applied in place. When the connection settings
+// changed, ie. the pool size or the connections lifetime, a new pool is connected and
+// replaces DB.Pool, which is used by the DB queriers and helpers from then on: queries
+// already running are not interrupted, the previous pool is closed in the background once
+// all its connections are released.
+func (d *DB) ApplyConfig(ctx context.Context, cfg Config) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.cfg == nil {
+		return wrapErr(fmt.Errorf("db was not created by Connect"))
+	}
+	if searchPathParam(cfg.SearchPath) != searchPathParam(d.cfg.SearchPath) || cfg.QualifyTables != d.cfg.QualifyTables {
+		return wrapErr(fmt.Errorf("search_path and qualify_tables can't be changed at runtime"))
+	}
+	slowQuery, err := cfg.validateSettings()
+	if err != nil {
+		return err
+	}
+
+	poolCfg, err := poolConfig(d.appName, &cfg)
+	if err != nil {
+		return err
+	}
+	// Override can't be compared, when set the pool is always replaced
+	if cfg.Override != nil || d.cfg.Override != nil || !reflect.DeepEqual(d.cfg.connSettings(), cfg.connSettings()) {
+		pool, err := pgxpool.NewWithConfig(ctx, poolCfg)
+		if err != nil {
+			return fmt.Errorf("pgkit: failed to connect to db: %w", err)
+		}
+		if err := pool.Ping(ctx); err != nil {
+			pool.Close()
+			return fmt.Errorf("pgkit: failed to connect to db: %w", err)
+		}
+
+		old := d.Query.pool.Swap(pool)
+		d.Conn = pool
+		d.serverVersion.Store(0)
+		d.capabilities.Store(nil)
+		go old.Close()
+	}
+	d.cfg = &cfg
+	d.applySettings(cfg, slowQuery)
+	return nil
+}
+
+// connSettings returns the settings of cfg which can only be changed with a new pool.
+func (cfg Config) connSettings() Config {
+	cfg.DefaultPageSize, cfg.MaxPageSize, cfg.SlowQueryThreshold = 0, 0, ""
+	cfg.Override = nil
+	return cfg
+}
+
+// validateSettings validates the pgkit settings of cfg, returning the slow query threshold.
+func (cfg Config) validateSettings() (time.Duration, error) {
+	def, max := cfg.DefaultPageSize, cfg.MaxPageSize
+	if def == 0 {
+		def = DefaultPageSize
+	}
+	if max == 0 {
+		max = MaxPageSize
+	}
+	if def > max {
+		return 0, fmt.Errorf("pgkit: config default_page_size %d is above max_page_size %d", def, max)
+	}
+	if cfg.SlowQueryThreshold == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(cfg.SlowQueryThreshold)
+	if err != nil {
+		return 0, fmt.Errorf("pgkit: config invalid slow_query_threshold value: %w", err)
+	}
+	return d, nil
+}
+
+// applySettings applies the pgkit settings of cfg in place.
+func (d *DB) applySettings(cfg Config, slowQuery time.Duration) {
+	d.pageSizes.def.Store(cfg.DefaultPageSize)
+	d.pageSizes.max.Store(cfg.MaxPageSize)
+	d.slowQuery.Store(int64(slowQuery))
+}
+
+// poolConfig returns the pool configuration for cfg, setting its defaults.
+func poolConfig(appName string, cfg *Config) (*pgxpool.Config, error) {
+	poolCfg, err := pgxpool.ParseConfig(getConnectURI(appName, *cfg))
 	if err != nil {
 		return nil, wrapErr(err)
 	}
@@ -59,12 +220,20 @@ func Connect(appName string, cfg Config) (*DB, error) {
 
 	poolCfg.HealthCheckPeriod = time.Minute
 
+	if len(cfg.SearchPath) > 0 {
+		poolCfg.ConnConfig.RuntimeParams["search_path"] = searchPathParam(cfg.SearchPath)
+	}
+
+	if cfg.ContextAppName {
+		poolCfg.BeforeAcquire = contextAppName(appName)
+	}
+
 	// override settings on *pgx.ConnConfig object
 	if cfg.Override != nil {
 		cfg.Override(poolCfg.ConnConfig)
 	}
 
-	return ConnectWithPGX(appName, poolCfg)
+	return poolCfg, nil
 }
 
 func ConnectWithPGX(appName string, pgxConfig *pgxpool.Config) (*DB, error) {
@@ -74,11 +243,15 @@ func ConnectWithPGX(appName string, pgxConfig *pgxpool.Config) (*DB, error) {
 	}
 
 	db := &DB{
-		Conn: pool,
+		Conn:      pool,
+		inflight:  newInflight(),
+		pageSizes: &pageSizes{},
 	}
 
-	db.SQL = &StatementBuilder{StatementBuilderType: sq.StatementBuilder.PlaceholderFormat(sq.Dollar)}
-	db.Query = &Querier{pool: db.Conn, SQL: db.SQL}
+	db.SQL = newStatementBuilder()
+	db.SQL.searchPath = parseSearchPath(pgxConfig.ConnConfig.RuntimeParams["search_path"])
+	db.Query = &Querier{pool: &poolRef{}, SQL: db.SQL, middleware: []Middleware{db.inflight.middleware}}
+	db.Query.pool.Store(pool)
 
 	return db, nil
 }
@@ -125,5 +298,21 @@ func wrapErr(err error) error {
 type Cond map[string]interface{}
 
 func (c Cond) ToSql() (string, []interface{}, error) {
-	return sq.Eq(c).ToSql()
+	eq, and := sq.Eq{}, sq.And{}
+	for k, v := range c {
+		if a, ok := v.(AnyArray); ok {
+			and = append(and, a.Eq(k))
+		} else {
+			eq[k] = v
+		}
+	}
+	if len(and) == 0 {
+		return eq.ToSql()
+	}
+	// sort for deterministic sql, sq.Eq already sorts its own keys
+	sort.Slice(and, func(i, j int) bool { return and[i].(anyPredicate).column < and[j].(anyPredicate).column })
+	if len(eq) > 0 {
+		and = append(sq.And{eq}, and...)
+	}
+	return and.ToSql()
 }
@@ -0,0 +1,73 @@
+package pgkitgen_test
+
+import (
+	"testing"
+
+	"github.com/goware/pgkit/v2"
+	"github.com/goware/pgkit/v2/pgkitgen"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScaffold(t *testing.T) {
+	table := pgkit.TableInfo{Schema: "public", Name: "user_accounts", Columns: []pgkit.ColumnInfo{
+		{Name: "id", Type: "bigint"},
+		{Name: "name", Type: "character varying", Nullable: true},
+		{Name: "created_at", Type: "timestamp with time zone"},
+		{Name: "tags", Type: "ARRAY", SQLType: "character varying(16)[]", Element: "character varying"},
+		{Name: "mood", Type: "USER-DEFINED", SQLType: "mood", Enum: true},
+		{Name: "moods", Type: "ARRAY", SQLType: "mood[]", Element: "mood", Enum: true, Nullable: true},
+		{Name: "location", Type: "point", SQLType: "point"},
+		{Name: "page", Type: "integer"},
+	}}
+	s := pgkitgen.Scaffold{Table: table}.Defaults()
+	require.Equal(t, "useraccounts", s.Package)
+	require.Equal(t, "UserAccount", s.Type)
+	require.Equal(t, "id", s.Key)
+
+	files, err := s.Generate()
+	require.NoError(t, err)
+	require.Len(t, files, 4)
+
+	model := string(files["model.go"])
+	require.Contains(t, model, "package useraccounts\n")
+	require.Contains(t, model, "type UserAccount struct {\n"+
+		"\tID        int64     `db:\"id,omitempty\" json:\"id\"`\n"+
+		"\tName      *string   `db:\"name\" json:\"name\"`\n"+
+		"\tCreatedAt time.Time `db:\"created_at\" json:\"created_at\"`\n"+
+		"\tTags      []string  `db:\"tags\" json:\"tags\"`\n"+
+		"\tMood      string    `db:\"mood\" json:\"mood\"`\n"+
+		"\tMoods     []string  `db:\"moods\" json:\"moods\"`\n"+
+		"\t// TODO: location point isn't mapped, add a field of a type scanning it, ie. of\n"+
+		"\t// pgtype, and add the column to Columns.\n"+
+		"\tPage int32 `db:\"page\" json:\"page\"`\n}")
+	require.Contains(t, model, "return `\"public\".\"user_accounts\"`")
+
+	// the page parameter can't be a filter, the unknown types can't be filtered on
+	repo := string(files["repo.go"])
+	require.Contains(t, repo, "var Filters = pgkit.FilterSchema{\n"+
+		"\t\"id\":         {Type: pgkit.FilterInt},\n"+
+		"\t\"name\":       {Type: pgkit.FilterString},\n"+
+		"\t\"created_at\": {Type: pgkit.FilterTime},\n}")
+	require.Contains(t, repo, "var Columns = []string{`\"id\"`, `\"name\"`, `\"created_at\"`, `\"tags\"`, `\"mood\"`, `\"moods\"`, `\"page\"`}")
+	require.Contains(t, repo, `pgkit.WithAllowedColumns("id", "name", "created_at", "tags", "mood", "moods", "page")`)
+	require.Contains(t, repo, `r.DB.SQL.Select(Columns...)`)
+	require.Contains(t, repo, "func (r Repo) Get(ctx context.Context, key int64) (*UserAccount, error) {")
+
+	require.Contains(t, string(files["handler.go"]), "strconv.ParseInt(key, 10, 64)")
+	// the schema of the test has the declared types
+	test := string(files["handler_test.go"])
+	require.Contains(t, test, "-- TODO: CREATE TYPE mood AS ENUM (...), as in the migrations\n")
+	require.Contains(t, test, `"id" bigint GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,`)
+	require.Contains(t, test, `"tags" character varying(16)[] NOT NULL,`)
+	require.Contains(t, test, `"mood" mood NOT NULL,`)
+	require.Contains(t, test, `"moods" mood[],`)
+	require.Contains(t, test, `"location" point /* TODO: not mapped, so nullable for the record to be inserted */,`)
+	require.Contains(t, test, "record.Tags = []string{}\n")
+	require.Contains(t, test, `record.Mood = "" // TODO: a label of mood`)
+	require.Contains(t, string(files["handler_test.go"]), `"id=like:x"`)
+
+	_, err = pgkitgen.Scaffold{Table: table, Key: "uuid"}.Generate()
+	require.EqualError(t, err, `pgkitgen: table public.user_accounts has no column "uuid"`)
+	_, err = pgkitgen.Scaffold{Table: table, Key: "created_at"}.Generate()
+	require.Error(t, err)
+}
@@ -0,0 +1,269 @@
+package pgkitgen
+
+const _Model = `// Scaffolded by pgkit gen scaffold from {{.TableName}}, adapt it as needed.
+
+package {{.Package}}
+{{if or .StdImports .Imports}}
+import (
+{{range .StdImports}}	"{{.}}"
+{{end}}{{if and .StdImports .Imports}}
+{{end}}{{range .Imports}}	"{{.}}"
+{{end}})
+{{end}}
+// {{.Type}} is a row of {{.TableName}}.
+type {{.Type}} struct {
+{{range .Fields}}{{if .Skip}}	// TODO: {{.Column}} {{.SQLType}} isn't mapped, add a field of a type scanning it, ie. of
+	// pgtype, and add the column to Columns.
+{{else}}	{{.Name}} {{fieldType .}} ` + "`" + `db:"{{dbTag .}}" json:"{{.Column}}"` + "`" + `
+{{end}}{{end}}}
+
+// DBTableName returns the table of the records, see pgkit.StatementBuilder.InsertRecord.
+func ({{.Type}}) DBTableName() string {
+	return {{literal .Quoted}}
+}
+`
+
+const _Repo = `// Scaffolded by pgkit gen scaffold from {{.TableName}}, adapt it as needed.
+
+package {{.Package}}
+
+import (
+	"context"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/goware/pgkit/v2"
+)
+
+// Filters are the fields the {{.Table.Name}} can be filtered on, ie. ` + "`" + `?{{.KeyField.Column}}=eq:1` + "`" + `.
+var Filters = pgkit.FilterSchema{
+{{range .Fields}}{{if .FilterType}}	{{printf "%q" .Column}}: {Type: {{.FilterType}}},
+{{end}}{{end}}}
+
+// Columns are the columns of the {{.Type}} fields, selected by the queries.
+var Columns = []string{ {{- columns .Fields}}}
+
+// PaginatorOptions restrict the sort to the columns of the table, see pgkit.PageFromValues.
+var PaginatorOptions = []func(*pgkit.PaginatorOption){
+	pgkit.WithAllowedColumns({{names .Fields}}),
+	pgkit.WithSort({{printf "%q" .KeyField.Column}}),
+	pgkit.WithTiebreaker({{printf "%q" .KeyField.Column}}),
+	pgkit.WithDefaultSize(20),
+	pgkit.WithMaxSize(100),
+}
+
+// Paginator pages the {{.Table.Name}}.
+var Paginator = pgkit.NewPaginator[{{.Type}}](PaginatorOptions...)
+
+// Repo reads and writes the {{.Table.Name}}.
+type Repo struct {
+	DB *pgkit.DB
+}
+
+// List returns a page of the {{.Table.Name}} matching the filters.
+func (r Repo) List(ctx context.Context, page *pgkit.Page, filters pgkit.Filters) ([]{{.Type}}, error) {
+	q := r.DB.SQL.Select(Columns...).From({{.Type}}{}.DBTableName())
+	if len(filters) > 0 {
+		q = q.Where(filters)
+	}
+	return Paginator.Query(ctx, q, page, r.DB.Pool())
+}
+
+// Get returns the {{lower .Type}} with the {{.KeyField.Column}}, pgkit.ErrNoRows when there's none.
+func (r Repo) Get(ctx context.Context, key {{.KeyField.Type}}) (*{{.Type}}, error) {
+	var record {{.Type}}
+	q := r.DB.SQL.Select(Columns...).From({{.Type}}{}.DBTableName()).Where(sq.Eq{ {{- literal (ident .KeyField.Column)}}: key})
+	if err := r.DB.Query.GetOne(ctx, q, &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// Create inserts the {{lower .Type}}, setting its {{.KeyField.Column}}.
+func (r Repo) Create(ctx context.Context, record *{{.Type}}) error {
+	q := r.DB.SQL.InsertRecord(record).Suffix({{literal (printf "RETURNING %s" (ident .KeyField.Column))}})
+	return r.DB.Query.QueryRow(ctx, q).Scan(&record.{{.KeyField.Name}})
+}
+`
+
+const _Handler = `// Scaffolded by pgkit gen scaffold from {{.TableName}}, adapt it as needed.
+
+package {{.Package}}
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+{{- if ne .KeyField.Type "string"}}
+	"strconv"
+{{- end}}
+	"strings"
+
+	"github.com/goware/pgkit/v2"
+)
+
+// Handler serves the {{.Table.Name}} as JSON:
+//
+//	GET  /       a page of them, ie. ?page=2&size=50&sort=-{{.KeyField.Column}}&{{.KeyField.Column}}=gt:10
+//	GET  /{{"{"}}{{.KeyField.Column}}}  one of them
+//	POST /       creates one
+//
+// It's mounted with http.StripPrefix, ie.
+//
+//	mux.Handle("/{{.Table.Name}}/", http.StripPrefix("/{{.Table.Name}}", {{.Package}}.Handler{Repo: {{.Package}}.Repo{DB: db}}))
+type Handler struct {
+	Repo Repo
+}
+
+// ServeHTTP implements http.Handler.
+func (h Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := strings.Trim(r.URL.Path, "/")
+	switch {
+	case r.Method == http.MethodGet && key == "":
+		h.list(w, r)
+	case r.Method == http.MethodGet:
+		h.get(w, r, key)
+	case r.Method == http.MethodPost && key == "":
+		h.create(w, r)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, errors.New(http.StatusText(http.StatusMethodNotAllowed)))
+	}
+}
+
+func (h Handler) list(w http.ResponseWriter, r *http.Request) {
+	page, err := pgkit.PageFromRequest(r, PaginatorOptions...)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	filters, err := Filters.FiltersFromRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	rows, err := h.Repo.List(r.Context(), page, filters)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if rows == nil {
+		rows = []{{.Type}}{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"page": page, "rows": rows})
+}
+
+func (h Handler) get(w http.ResponseWriter, r *http.Request, key string) {
+{{- if eq .KeyField.Type "string"}}
+	record, err := h.Repo.Get(r.Context(), key)
+{{- else}}
+	n, err := strconv.ParseInt(key, 10, {{bits .KeyField.Type}})
+	if err != nil {
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+		return
+	}
+	record, err := h.Repo.Get(r.Context(), {{.KeyField.Type}}(n))
+{{- end}}
+	if errors.Is(err, pgkit.ErrNoRows) {
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, record)
+}
+
+func (h Handler) create(w http.ResponseWriter, r *http.Request) {
+	var record {{.Type}}
+	if err := json.NewDecoder(r.Body).Decode(&record); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := h.Repo.Create(r.Context(), &record); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, record)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+`
+
+const _HandlerTest = `// Scaffolded by pgkit gen scaffold from {{.TableName}}, adapt it as needed.
+
+package {{.Package}}
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+{{- if .TestTime}}
+	"time"
+{{- end}}
+
+	"github.com/goware/pgkit/v2"
+	"github.com/goware/pgkit/v2/pgkittest"
+	"github.com/stretchr/testify/require"
+)
+
+// _Schema creates the table as introspected, replace it with the migrations of the service.
+const _Schema = ` + "`" + `
+{{- range .Enums}}
+	-- TODO: CREATE TYPE {{.}} AS ENUM (...), as in the migrations
+{{- end}}
+	CREATE SCHEMA IF NOT EXISTS {{ident .Table.Schema}};
+	CREATE TABLE {{.Quoted}} (
+{{range $i, $f := .Fields}}{{if $i}},
+{{end}}		{{sqlDefinition $f}}{{end}}
+	)` + "`" + `
+
+func TestHandlerBadRequest(t *testing.T) {
+	// the requests are rejected before querying the database
+	handler := Handler{}
+	for _, query := range []string{"page=0", "size=none", "sort=none"{{if .KeyField.FilterType}}, {{printf "%q" (printf "%s=%s:x" .KeyField.Column (badOp .KeyField))}}{{end}}} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?"+query, nil))
+		require.Equal(t, http.StatusBadRequest, rec.Code, query)
+	}
+}
+
+func TestRepo(t *testing.T) {
+	ctx := context.Background()
+	db := pgkittest.StartPostgres(t).NewDB(t, "")
+	_, err := db.Pool().Exec(ctx, _Schema)
+	require.NoError(t, err)
+	repo := Repo{DB: db}
+
+	var record {{.Type}}
+{{- range .Fields}}{{with fixture .}}
+	record.{{.}}{{end}}{{end}}
+	require.NoError(t, repo.Create(ctx, &record))
+	got, err := repo.Get(ctx, record.{{.KeyField.Name}})
+	require.NoError(t, err)
+	require.Equal(t, record.{{.KeyField.Name}}, got.{{.KeyField.Name}})
+
+	server := httptest.NewServer(Handler{Repo: repo})
+	defer server.Close()
+	resp, err := http.Get(server.URL + "/?sort=-{{.KeyField.Column}}")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var result struct {
+		Page pgkit.Page   ` + "`" + `json:"page"` + "`" + `
+		Rows []{{.Type}} ` + "`" + `json:"rows"` + "`" + `
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	require.Len(t, result.Rows, 1)
+	require.Equal(t, record.{{.KeyField.Name}}, result.Rows[0].{{.KeyField.Name}})
+}
+`
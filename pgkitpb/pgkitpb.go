@@ -0,0 +1,130 @@
+// Package pgkitpb converts the pagination messages of gRPC services to and from pgkit pages.
+// The canonical messages are defined in pgkit.proto, which services can import or copy. The
+// conversions don't depend on the generated code: requests are read through their getters
+// and responses are set by field name, so they work with any message with the same fields.
+package pgkitpb
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/goware/pgkit/v2"
+)
+
+// PageRequest is implemented by the generated PageRequest message, and by any message
+// with the same fields.
+type PageRequest interface {
+	GetSize() uint32
+	GetPage() uint32
+	GetOrderBy() string
+	GetCursor() string
+}
+
+// FromProto returns the page requested by req, which can be passed to PrepareQuery. A nil
+// request is the first page, as the generated getters are nil safe.
+func FromProto(req PageRequest) (*pgkit.Page, error) {
+	page := &pgkit.Page{Page: 1}
+	if req == nil {
+		return page, nil
+	}
+	sort, err := ParseOrderBy(req.GetOrderBy())
+	if err != nil {
+		return nil, err
+	}
+	page.Size, page.Order, page.Cursor = req.GetSize(), sort, req.GetCursor()
+	if n := req.GetPage(); n > 0 {
+		page.Page = n
+	}
+	return page, nil
+}
+
+// ToProto sets the fields of the PageResponse message msg, a pointer to a generated struct,
+// from page. The fields of pgkit.proto missing from msg are skipped.
+func ToProto(page *pgkit.Page, msg interface{}) error {
+	v := reflect.ValueOf(msg)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("pgkitpb: expecting a pointer to a message, got %T", msg)
+	}
+	v = v.Elem()
+	fields := map[string]interface{}{
+		"Size":       page.Size,
+		"Page":       page.Page,
+		"More":       page.More,
+		"OrderBy":    FormatOrderBy(page.Order),
+		"NextCursor": page.NextCursor,
+		"Total":      page.Total,
+		"TotalPages": page.TotalPages,
+	}
+	for name, value := range fields {
+		field := v.FieldByName(name)
+		if !field.IsValid() || !field.CanSet() {
+			continue
+		}
+		value := reflect.ValueOf(value)
+		if field.Type() != value.Type() {
+			return fmt.Errorf("pgkitpb: field %s of %T is %s, expecting %s", name, msg, field.Type(), value.Type())
+		}
+		field.Set(value)
+	}
+	return nil
+}
+
+// ParseOrderBy parses a comma separated list of columns, each optionally followed by "asc"
+// or "desc" and "nulls first" or "nulls last", ie. "created_at desc, id". The "-created_at"
+// form of pgkit.NewSort is accepted too.
+func ParseOrderBy(orderBy string) ([]pgkit.Sort, error) {
+	if strings.TrimSpace(orderBy) == "" {
+		return nil, nil
+	}
+	parts := strings.Split(orderBy, ",")
+	list := make([]pgkit.Sort, 0, len(parts))
+	for _, part := range parts {
+		words := strings.Fields(part)
+		if len(words) == 0 {
+			return nil, fmt.Errorf("pgkitpb: invalid order by %q", orderBy)
+		}
+		s, ok := pgkit.NewSort(words[0])
+		if !ok {
+			return nil, fmt.Errorf("pgkitpb: invalid order by column %q", words[0])
+		}
+		words = words[1:]
+		if len(words) > 0 && !strings.EqualFold(words[0], "nulls") {
+			order, err := pgkit.ParseOrderType(words[0])
+			if err != nil {
+				return nil, fmt.Errorf("pgkitpb: invalid order by %q", part)
+			}
+			s.Order, words = order, words[1:]
+		}
+		switch {
+		case len(words) == 0:
+		case len(words) == 2 && strings.EqualFold(words[0], "nulls") && strings.EqualFold(words[1], "first"):
+			s.Nulls = pgkit.NullsFirst
+		case len(words) == 2 && strings.EqualFold(words[0], "nulls") && strings.EqualFold(words[1], "last"):
+			s.Nulls = pgkit.NullsLast
+		default:
+			return nil, fmt.Errorf("pgkitpb: invalid order by %q", part)
+		}
+		list = append(list, s)
+	}
+	return list, nil
+}
+
+// FormatOrderBy formats a sort as parsed by ParseOrderBy, ie. "created_at desc, id".
+func FormatOrderBy(sort []pgkit.Sort) string {
+	parts := make([]string, 0, len(sort))
+	for _, s := range sort {
+		part := s.Column
+		if s.Order == pgkit.Desc {
+			part += " desc"
+		}
+		switch s.Nulls {
+		case pgkit.NullsFirst:
+			part += " nulls first"
+		case pgkit.NullsLast:
+			part += " nulls last"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,90 @@
+package pgkitpb_test
+
+import (
+	"testing"
+
+	"github.com/goware/pgkit/v2"
+	"github.com/goware/pgkit/v2/pgkitpb"
+	"github.com/stretchr/testify/require"
+)
+
+// PageRequest and PageResponse mimic the code generated from pgkit.proto.
+type PageRequest struct {
+	Size    uint32
+	Page    uint32
+	OrderBy string
+	Cursor  string
+}
+
+func (x *PageRequest) GetSize() uint32 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *PageRequest) GetPage() uint32 {
+	if x != nil {
+		return x.Page
+	}
+	return 0
+}
+
+func (x *PageRequest) GetOrderBy() string {
+	if x != nil {
+		return x.OrderBy
+	}
+	return ""
+}
+
+func (x *PageRequest) GetCursor() string {
+	if x != nil {
+		return x.Cursor
+	}
+	return ""
+}
+
+type PageResponse struct {
+	Size       uint32
+	Page       uint32
+	More       bool
+	OrderBy    string
+	NextCursor string
+	Total      uint64
+	TotalPages uint32
+}
+
+func TestFromProto(t *testing.T) {
+	page, err := pgkitpb.FromProto(&PageRequest{Size: 20, Page: 3, OrderBy: "created_at DESC NULLS LAST, -score, id asc"})
+	require.NoError(t, err)
+	require.Equal(t, &pgkit.Page{Size: 20, Page: 3, Order: []pgkit.Sort{
+		{Column: "created_at", Order: pgkit.Desc, Nulls: pgkit.NullsLast},
+		{Column: "score", Order: pgkit.Desc},
+		{Column: "id", Order: pgkit.Asc},
+	}}, page)
+
+	page, err = pgkitpb.FromProto((*PageRequest)(nil))
+	require.NoError(t, err)
+	require.Equal(t, &pgkit.Page{Page: 1}, page)
+
+	for _, orderBy := range []string{"id,", "id sideways", "id desc nulls", "id nulls middle"} {
+		_, err = pgkitpb.FromProto(&PageRequest{OrderBy: orderBy})
+		require.Error(t, err, orderBy)
+	}
+}
+
+func TestToProto(t *testing.T) {
+	page := &pgkit.Page{Size: 10, Page: 2, More: true, Order: []pgkit.Sort{{Column: "name", Order: pgkit.Desc, Nulls: pgkit.NullsFirst}, {Column: "id", Order: pgkit.Asc}}}
+	page.SetTotal(25)
+
+	var msg PageResponse
+	require.NoError(t, pgkitpb.ToProto(page, &msg))
+	require.Equal(t, PageResponse{Size: 10, Page: 2, More: true, OrderBy: "name desc nulls first, id", Total: 25, TotalPages: 3}, msg)
+
+	sort, err := pgkitpb.ParseOrderBy(msg.OrderBy)
+	require.NoError(t, err)
+	require.Equal(t, page.Order, sort)
+
+	require.Error(t, pgkitpb.ToProto(page, msg))
+	require.Error(t, pgkitpb.ToProto(page, &struct{ Size int }{}))
+}
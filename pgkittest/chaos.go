@@ -0,0 +1,144 @@
+// Package pgkittest provides helpers to test code built on top of pgkit. It's meant to be
+// imported from tests only.
+package pgkittest
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/goware/pgkit/v2"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ErrConnDropped is returned by queries hit by a Fault with Drop set.
+var ErrConnDropped = errors.New("pgkittest: connection dropped")
+
+// SQLState returns a postgres error with the given SQLSTATE code, ie. "40001" for
+// a serialization failure or "57P01" for admin shutdown.
+func SQLState(code string) error {
+	return &pgconn.PgError{Severity: "ERROR", Code: code, Message: "pgkittest: injected error " + code}
+}
+
+// Fault describes a failure to inject in the queries matching Pattern.
+type Fault struct {
+	// Pattern matches the SQL of the affected queries, nil matches every query.
+	Pattern *regexp.Regexp
+	// Probability of the fault being triggered, from 0 to 1. Zero means always.
+	Probability float64
+	// Latency is added before running the query.
+	Latency time.Duration
+	// Drop fails the query with ErrConnDropped.
+	Drop bool
+	// Err fails the query with the given error, see SQLState.
+	Err error
+}
+
+// Chaos is a pgkit.Middleware injecting faults in the queries. Faults are evaluated in
+// order and the first one triggered wins. Using the same seed produces the same sequence
+// of faults for the same sequence of queries.
+type Chaos struct {
+	faults []Fault
+	mu     sync.Mutex
+	rand   *rand.Rand
+}
+
+// NewChaos creates a Chaos middleware with a deterministic random source.
+func NewChaos(seed int64, faults ...Fault) *Chaos {
+	return &Chaos{faults: faults, rand: rand.New(rand.NewSource(seed))}
+}
+
+// Middleware returns the middleware to install with DB.Use or Querier.With.
+func (c *Chaos) Middleware() pgkit.Middleware {
+	return func(next pgkit.Executor) pgkit.Executor {
+		return chaosExecutor{chaos: c, next: next}
+	}
+}
+
+// inject applies the first triggered fault for the query, returning the error to fail with.
+func (c *Chaos) inject(ctx context.Context, sql string) error {
+	fault, ok := c.pick(sql)
+	if !ok {
+		return nil
+	}
+	if fault.Latency > 0 {
+		select {
+		case <-time.After(fault.Latency):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if fault.Drop {
+		return ErrConnDropped
+	}
+	return fault.Err
+}
+
+func (c *Chaos) pick(sql string) (Fault, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, f := range c.faults {
+		if f.Pattern != nil && !f.Pattern.MatchString(sql) {
+			continue
+		}
+		if f.Probability > 0 && c.rand.Float64() >= f.Probability {
+			continue
+		}
+		return f, true
+	}
+	return Fault{}, false
+}
+
+type chaosExecutor struct {
+	chaos *Chaos
+	next  pgkit.Executor
+}
+
+func (e chaosExecutor) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	if err := e.chaos.inject(ctx, sql); err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	return e.next.Exec(ctx, sql, args...)
+}
+
+func (e chaosExecutor) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	if err := e.chaos.inject(ctx, sql); err != nil {
+		return nil, err
+	}
+	return e.next.Query(ctx, sql, args...)
+}
+
+func (e chaosExecutor) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	if err := e.chaos.inject(ctx, sql); err != nil {
+		return errRow{err}
+	}
+	return e.next.QueryRow(ctx, sql, args...)
+}
+
+func (e chaosExecutor) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	for _, q := range b.QueuedQueries {
+		if err := e.chaos.inject(ctx, q.SQL); err != nil {
+			return errBatchResults{err}
+		}
+	}
+	return e.next.SendBatch(ctx, b)
+}
+
+type errRow struct {
+	err error
+}
+
+func (r errRow) Scan(dest ...interface{}) error { return r.err }
+
+type errBatchResults struct {
+	err error
+}
+
+func (r errBatchResults) Exec() (pgconn.CommandTag, error) { return pgconn.CommandTag{}, r.err }
+func (r errBatchResults) Query() (pgx.Rows, error)         { return nil, r.err }
+func (r errBatchResults) QueryRow() pgx.Row                { return errRow{r.err} }
+func (r errBatchResults) Close() error                     { return r.err }
@@ -0,0 +1,75 @@
+package pgkittest_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/goware/pgkit/v2/pgkittest"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+)
+
+type nopExecutor struct{ calls int }
+
+func (e *nopExecutor) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	e.calls++
+	return pgconn.CommandTag{}, nil
+}
+
+func (e *nopExecutor) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	e.calls++
+	return nil, nil
+}
+
+func (e *nopExecutor) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	e.calls++
+	return nil
+}
+
+func (e *nopExecutor) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	e.calls++
+	return nil
+}
+
+func TestChaos(t *testing.T) {
+	ctx := context.Background()
+	next := &nopExecutor{}
+
+	chaos := pgkittest.NewChaos(1,
+		pgkittest.Fault{Pattern: regexp.MustCompile(`^UPDATE`), Err: pgkittest.SQLState("40001")},
+		pgkittest.Fault{Pattern: regexp.MustCompile(`^DELETE`), Drop: true},
+	)
+	exec := chaos.Middleware()(next)
+
+	_, err := exec.Exec(ctx, "UPDATE accounts SET name = $1")
+	var pgErr *pgconn.PgError
+	require.True(t, errors.As(err, &pgErr))
+	require.Equal(t, "40001", pgErr.Code)
+
+	_, err = exec.Exec(ctx, "DELETE FROM accounts")
+	require.ErrorIs(t, err, pgkittest.ErrConnDropped)
+
+	_, err = exec.Exec(ctx, "SELECT 1")
+	require.NoError(t, err)
+	require.Equal(t, 1, next.calls)
+}
+
+func TestChaosProbability(t *testing.T) {
+	ctx := context.Background()
+
+	run := func() []bool {
+		exec := pgkittest.NewChaos(42, pgkittest.Fault{Probability: 0.5, Drop: true}).Middleware()(&nopExecutor{})
+		failed := make([]bool, 20)
+		for i := range failed {
+			_, err := exec.Exec(ctx, "SELECT 1")
+			failed[i] = err != nil
+		}
+		return failed
+	}
+
+	// same seed, same faults
+	require.Equal(t, run(), run())
+}
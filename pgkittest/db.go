@@ -0,0 +1,47 @@
+package pgkittest
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/goware/pgkit/v2"
+)
+
+var dbSeq uint64
+
+// NewDB provisions a database for the test cloned from template, ie. a database holding
+// the schema and fixtures, and returns a connection to it. The database is dropped when
+// the test ends. The admin connection is used to create and drop it, while cfg is used,
+// with a different Database, to connect to the new database. Tests using their own
+// database can safely run in parallel.
+func NewDB(t testing.TB, admin *pgkit.DB, cfg pgkit.Config, template string) *pgkit.DB {
+	t.Helper()
+	ctx := context.Background()
+
+	prefix := template
+	if prefix == "" {
+		prefix = "pgkittest"
+	}
+	name := fmt.Sprintf("%s_%d_%d", prefix, time.Now().UnixNano(), atomic.AddUint64(&dbSeq, 1))
+	if err := pgkit.CreateDatabase(ctx, admin, name, template); err != nil {
+		t.Fatalf("pgkittest: failed to create database %q: %v", name, err)
+	}
+
+	cfg.Database = name
+	db, err := pgkit.Connect("pgkittest", cfg)
+	if err != nil {
+		pgkit.DropDatabase(ctx, admin, name, true)
+		t.Fatalf("pgkittest: failed to connect to database %q: %v", name, err)
+	}
+
+	t.Cleanup(func() {
+		db.Pool().Close()
+		if err := pgkit.DropDatabase(ctx, admin, name, true); err != nil {
+			t.Errorf("pgkittest: failed to drop database %q: %v", name, err)
+		}
+	})
+	return db
+}
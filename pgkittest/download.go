@@ -0,0 +1,235 @@
+package pgkittest
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// PostgresVersion is the version of the binaries downloaded by StartPostgres, it can be
+// overridden with the PGKITTEST_POSTGRES_VERSION environment variable.
+const PostgresVersion = "16.2.0"
+
+// DefaultMirror is the Maven repository the binaries are downloaded from, it can be
+// overridden with the PGKITTEST_MIRROR environment variable.
+const DefaultMirror = "https://repo1.maven.org/maven2"
+
+// DownloadPostgres downloads the PostgreSQL binaries of version for the current platform,
+// as packaged by the zonky embedded-postgres-binaries project, and returns the directory
+// holding initdb and postgres. The binaries are cached in the PGKITTEST_CACHE directory,
+// defaulting to pgkittest in the user cache directory, and are only downloaded once.
+// The archive is verified against the checksum published by the repository, and extracted
+// with tar, which must support xz.
+func DownloadPostgres(ctx context.Context, version string) (string, error) {
+	platform, err := binariesPlatform()
+	if err != nil {
+		return "", err
+	}
+	root, err := cacheDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(root, version+"-"+platform)
+	if fileExists(filepath.Join(dir, "bin", binName("postgres"))) {
+		return filepath.Join(dir, "bin"), nil
+	}
+
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return "", fmt.Errorf("pgkittest: failed to create the cache: %w", err)
+	}
+	tmp, err := os.MkdirTemp(root, ".download-")
+	if err != nil {
+		return "", fmt.Errorf("pgkittest: failed to create the cache: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	mirror := os.Getenv("PGKITTEST_MIRROR")
+	if mirror == "" {
+		mirror = DefaultMirror
+	}
+	artifact := "embedded-postgres-binaries-" + platform
+	url := fmt.Sprintf("%s/io/zonky/test/postgres/%s/%s/%s-%s.jar", strings.TrimSuffix(mirror, "/"), artifact, version, artifact, version)
+	jar := filepath.Join(tmp, "postgres.jar")
+	if err := download(ctx, url, jar); err != nil {
+		return "", err
+	}
+	if err := verifyChecksum(ctx, url, jar); err != nil {
+		return "", err
+	}
+	txz := filepath.Join(tmp, "postgres.txz")
+	if err := extractTxz(jar, txz); err != nil {
+		return "", err
+	}
+	out := filepath.Join(tmp, "postgres")
+	if err := os.Mkdir(out, 0o755); err != nil {
+		return "", fmt.Errorf("pgkittest: failed to create the cache: %w", err)
+	}
+	if msg, err := exec.CommandContext(ctx, "tar", "-xJf", txz, "-C", out).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("pgkittest: failed to extract %s: %w\n%s", url, err, msg)
+	}
+
+	// a concurrent download may have won the race, its binaries are as good as ours
+	if err := os.Rename(out, dir); err != nil && !fileExists(filepath.Join(dir, "bin", binName("postgres"))) {
+		return "", fmt.Errorf("pgkittest: failed to cache the binaries: %w", err)
+	}
+	return filepath.Join(dir, "bin"), nil
+}
+
+// errNotFound is returned by get for a missing file.
+var errNotFound = errors.New("not found")
+
+// get returns the body of the file at url, which must be closed.
+func get(ctx context.Context, url string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("pgkittest: failed to download %s: %w", url, err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("pgkittest: failed to download %s: %w", url, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("pgkittest: failed to download %s: %s: %w", url, resp.Status, errNotFound)
+		}
+		return nil, fmt.Errorf("pgkittest: failed to download %s: %s", url, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+func download(ctx context.Context, url, path string) error {
+	body, err := get(ctx, url)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("pgkittest: failed to download %s: %w", url, err)
+	}
+	if _, err := io.Copy(f, body); err != nil {
+		f.Close()
+		return fmt.Errorf("pgkittest: failed to download %s: %w", url, err)
+	}
+	return f.Close()
+}
+
+// verifyChecksum checks the file downloaded from url against the checksum published next to
+// it by the repository, the SHA-256 one, or the SHA-1 one which Maven Central publishes for
+// every artifact.
+func verifyChecksum(ctx context.Context, url, path string) error {
+	for _, alg := range []struct {
+		ext  string
+		hash func() hash.Hash
+	}{{".sha256", sha256.New}, {".sha1", sha1.New}} {
+		body, err := get(ctx, url+alg.ext)
+		if errors.Is(err, errNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		data, err := io.ReadAll(io.LimitReader(body, 1024))
+		body.Close()
+		if err != nil {
+			return fmt.Errorf("pgkittest: failed to download %s: %w", url+alg.ext, err)
+		}
+		// the file may hold the name of the artifact after the checksum
+		fields := strings.Fields(string(data))
+		if len(fields) == 0 {
+			return fmt.Errorf("pgkittest: invalid checksum %s", url+alg.ext)
+		}
+		want := strings.ToLower(fields[0])
+
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("pgkittest: failed to verify %s: %w", url, err)
+		}
+		defer f.Close()
+		h := alg.hash()
+		if _, err := io.Copy(h, f); err != nil {
+			return fmt.Errorf("pgkittest: failed to verify %s: %w", url, err)
+		}
+		if got := hex.EncodeToString(h.Sum(nil)); got != want {
+			return fmt.Errorf("pgkittest: checksum mismatch of %s: got %s, expecting %s", url, got, want)
+		}
+		return nil
+	}
+	return fmt.Errorf("pgkittest: no checksum published for %s", url)
+}
+
+// extractTxz extracts the tar.xz archive of the binaries from the jar to path.
+func extractTxz(jar, path string) error {
+	r, err := zip.OpenReader(jar)
+	if err != nil {
+		return fmt.Errorf("pgkittest: invalid binaries archive: %w", err)
+	}
+	defer r.Close()
+
+	for _, file := range r.File {
+		if !strings.HasSuffix(file.Name, ".txz") {
+			continue
+		}
+		src, err := file.Open()
+		if err != nil {
+			return fmt.Errorf("pgkittest: invalid binaries archive: %w", err)
+		}
+		defer src.Close()
+		dst, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("pgkittest: failed to extract the binaries: %w", err)
+		}
+		if _, err := io.Copy(dst, src); err != nil {
+			dst.Close()
+			return fmt.Errorf("pgkittest: failed to extract the binaries: %w", err)
+		}
+		return dst.Close()
+	}
+	return fmt.Errorf("pgkittest: invalid binaries archive: no .txz file")
+}
+
+// binariesPlatform returns the platform name of the embedded-postgres-binaries artifacts.
+func binariesPlatform() (string, error) {
+	arch, ok := map[string]string{
+		"amd64": "amd64",
+		"386":   "i386",
+		"arm64": "arm64v8",
+		"arm":   "arm32v7",
+	}[runtime.GOARCH]
+	if !ok || (runtime.GOOS != "linux" && runtime.GOOS != "darwin" && runtime.GOOS != "windows") {
+		return "", fmt.Errorf("pgkittest: no postgres binaries for %s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	return runtime.GOOS + "-" + arch, nil
+}
+
+func cacheDir() (string, error) {
+	if dir := os.Getenv("PGKITTEST_CACHE"); dir != "" {
+		return dir, nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("pgkittest: no cache directory, set PGKITTEST_CACHE: %w", err)
+	}
+	return filepath.Join(dir, "pgkittest"), nil
+}
+
+func binName(name string) string {
+	if runtime.GOOS == "windows" {
+		return name + ".exe"
+	}
+	return name
+}
@@ -0,0 +1,141 @@
+package pgkittest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/goware/pgkit/v2"
+)
+
+// Postgres is a throwaway PostgreSQL server, for environments where neither a shared
+// server nor Docker are available, see StartPostgres.
+type Postgres struct {
+	// Config connects to the server, with the "postgres" database and superuser.
+	Config pgkit.Config
+	// Admin is connected with Config.
+	Admin *pgkit.DB
+}
+
+// StartPostgres initializes a PostgreSQL cluster in a temporary directory and starts a
+// server on a free local port, tuned for tests (no fsync), which is stopped when the test
+// ends. The initdb and postgres binaries are looked up in the directory set by the
+// PGKITTEST_BIN environment variable, then in the PATH, and are otherwise downloaded with
+// DownloadPostgres, so no Docker nor local install is needed. The test is skipped when
+// they can't be downloaded, ie. without network. Note that postgres refuses to run as root.
+func StartPostgres(t testing.TB) *Postgres {
+	t.Helper()
+
+	initdb, postgres := lookBin("initdb"), lookBin("postgres")
+	if initdb == "" || postgres == "" {
+		version := os.Getenv("PGKITTEST_POSTGRES_VERSION")
+		if version == "" {
+			version = PostgresVersion
+		}
+		bin, err := DownloadPostgres(context.Background(), version)
+		if err != nil {
+			t.Skipf("pgkittest: postgres binaries not found, set PGKITTEST_BIN: %v", err)
+		}
+		initdb, postgres = filepath.Join(bin, binName("initdb")), filepath.Join(bin, binName("postgres"))
+	}
+
+	dir := t.TempDir()
+	data := filepath.Join(dir, "data")
+	if out, err := exec.Command(initdb, "-D", data, "-U", "postgres", "-A", "trust", "-E", "UTF8", "--no-sync").CombinedOutput(); err != nil {
+		t.Fatalf("pgkittest: initdb failed: %v\n%s", err, out)
+	}
+
+	port, err := freePort()
+	if err != nil {
+		t.Fatalf("pgkittest: %v", err)
+	}
+	var logs syncBuffer
+	cmd := exec.Command(postgres, "-D", data, "-p", fmt.Sprint(port), "-k", dir,
+		"-c", "listen_addresses=127.0.0.1", "-c", "fsync=off", "-c", "synchronous_commit=off", "-c", "full_page_writes=off")
+	cmd.Stdout, cmd.Stderr = &logs, &logs
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("pgkittest: failed to start postgres: %v", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Signal(os.Interrupt) // fast shutdown
+		cmd.Wait()
+	})
+
+	p := &Postgres{Config: pgkit.Config{
+		Host:     fmt.Sprintf("127.0.0.1:%d", port),
+		Database: "postgres",
+		Username: "postgres",
+		Password: "postgres",
+	}}
+	ctx := context.Background()
+	for deadline := time.Now().Add(30 * time.Second); ; time.Sleep(50 * time.Millisecond) {
+		if p.Admin == nil {
+			p.Admin, err = pgkit.Connect("pgkittest", p.Config)
+		}
+		if err == nil {
+			if err = p.Admin.Pool().Ping(ctx); err == nil {
+				break
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("pgkittest: postgres didn't start: %v\n%s", err, logs.String())
+		}
+	}
+	t.Cleanup(p.Admin.Pool().Close)
+	return p
+}
+
+// NewDB provisions a database on the server, see NewDB.
+func (p *Postgres) NewDB(t testing.TB, template string) *pgkit.DB {
+	t.Helper()
+	return NewDB(t, p.Admin, p.Config, template)
+}
+
+// syncBuffer is a bytes.Buffer written by the postgres process while it's read.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func lookBin(name string) string {
+	if dir := os.Getenv("PGKITTEST_BIN"); dir != "" {
+		if path := filepath.Join(dir, binName(name)); fileExists(path) {
+			return path
+		}
+	}
+	path, _ := exec.LookPath(name)
+	return path
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, fmt.Errorf("failed to find a free port: %w", err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
@@ -0,0 +1,101 @@
+package pgkittest_test
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/goware/pgkit/v2/pgkittest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartPostgres(t *testing.T) {
+	pg := pgkittest.StartPostgres(t)
+
+	db := pg.NewDB(t, "")
+	var n int
+	require.NoError(t, db.Conn.QueryRow(context.Background(), "SELECT 1").Scan(&n))
+	require.Equal(t, 1, n)
+}
+
+func TestDownloadPostgres(t *testing.T) {
+	if _, err := exec.LookPath("xz"); err != nil || runtime.GOOS == "windows" {
+		t.Skip("xz not found")
+	}
+
+	// a jar with a fake distribution, as packaged by embedded-postgres-binaries
+	src := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(src, "bin"), 0o755))
+	for _, name := range []string{"initdb", "postgres"} {
+		require.NoError(t, os.WriteFile(filepath.Join(src, "bin", name), []byte("#!/bin/sh\n"), 0o755))
+	}
+	txz := filepath.Join(t.TempDir(), "postgres-linux-x86_64.txz")
+	out, err := exec.Command("tar", "-cJf", txz, "-C", src, "bin").CombinedOutput()
+	require.NoError(t, err, string(out))
+	jar := filepath.Join(t.TempDir(), "postgres.jar")
+	f, err := os.Create(jar)
+	require.NoError(t, err)
+	w := zip.NewWriter(f)
+	entry, err := w.Create("postgres-linux-x86_64.txz")
+	require.NoError(t, err)
+	data, err := os.ReadFile(txz)
+	require.NoError(t, err)
+	_, err = entry.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.NoError(t, f.Close())
+
+	data, err = os.ReadFile(jar)
+	require.NoError(t, err)
+	sum := sha256.Sum256(data)
+	checksum := hex.EncodeToString(sum[:])
+
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path)
+		switch {
+		case strings.HasSuffix(r.URL.Path, "-16.2.0.jar"), strings.HasSuffix(r.URL.Path, "-16.3.0.jar"):
+			http.ServeFile(w, r, jar)
+		case strings.HasSuffix(r.URL.Path, "-16.2.0.jar.sha256"):
+			w.Write([]byte(checksum + "  postgres.jar\n"))
+		case strings.HasSuffix(r.URL.Path, "-16.3.0.jar.sha1"):
+			w.Write([]byte("0000000000000000000000000000000000000000"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	t.Setenv("PGKITTEST_MIRROR", srv.URL)
+	t.Setenv("PGKITTEST_CACHE", t.TempDir())
+	ctx := context.Background()
+
+	bin, err := pgkittest.DownloadPostgres(ctx, "16.2.0")
+	require.NoError(t, err)
+	require.FileExists(t, filepath.Join(bin, "initdb"))
+	require.FileExists(t, filepath.Join(bin, "postgres"))
+	require.Len(t, requests, 2)
+	require.Contains(t, requests[0], "/io/zonky/test/postgres/embedded-postgres-binaries-")
+
+	// cached
+	cached, err := pgkittest.DownloadPostgres(ctx, "16.2.0")
+	require.NoError(t, err)
+	require.Equal(t, bin, cached)
+	require.Len(t, requests, 2)
+
+	// the jar must match the published checksum, the SHA-1 one when there's no SHA-256
+	_, err = pgkittest.DownloadPostgres(ctx, "16.3.0")
+	require.ErrorContains(t, err, "checksum mismatch")
+
+	_, err = pgkittest.DownloadPostgres(ctx, "15.0.0")
+	require.ErrorContains(t, err, "404")
+}
@@ -0,0 +1,414 @@
+package pgkittest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/goware/pgkit/v2"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Mode of a Recorder.
+type Mode int
+
+const (
+	// Record runs the queries on the database and records their results.
+	Record Mode = iota
+	// Replay serves the recorded results without touching the database.
+	Replay
+)
+
+// ErrNoFixture is returned in replay mode for queries which were never recorded.
+var ErrNoFixture = errors.New("pgkittest: no recorded fixture for query")
+
+// Recorder is a pgkit.Middleware which records query results to a fixture file and
+// serves them back, so integration tests can run without a live database. Queries are
+// matched by their fingerprint, see pgkit.Fingerprint, and their arguments, when the same
+// query is recorded more than once the results are replayed in the same order, repeating
+// the last one. The postgres errors are replayed as *pgconn.PgError, with their SQLSTATE.
+type Recorder struct {
+	mode     Mode
+	path     string
+	mu       sync.Mutex
+	fixtures map[string][]*fixture
+	cursor   map[string]int
+	typeMap  *pgtype.Map
+}
+
+type fixture struct {
+	SQL        string                    `json:"sql"`
+	CommandTag string                    `json:"command_tag,omitempty"`
+	Fields     []pgconn.FieldDescription `json:"fields,omitempty"`
+	Rows       [][][]byte                `json:"rows,omitempty"`
+	Err        string                    `json:"err,omitempty"`
+	PgErr      *pgconn.PgError           `json:"pg_err,omitempty"`
+
+	// cause is the original error, returned while recording
+	cause error
+}
+
+// NewRecorder creates a recorder backed by the fixture file at path. In replay mode the
+// file is loaded and must exist, in record mode it's written by Save.
+func NewRecorder(path string, mode Mode) (*Recorder, error) {
+	r := &Recorder{
+		mode:     mode,
+		path:     path,
+		fixtures: map[string][]*fixture{},
+		cursor:   map[string]int{},
+		typeMap:  pgtype.NewMap(),
+	}
+	if mode == Replay {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("pgkittest: failed to load fixtures: %w", err)
+		}
+		if err := json.Unmarshal(data, &r.fixtures); err != nil {
+			return nil, fmt.Errorf("pgkittest: invalid fixtures file %q: %w", path, err)
+		}
+	}
+	return r, nil
+}
+
+// Middleware returns the middleware to install with DB.Use or Querier.With.
+func (r *Recorder) Middleware() pgkit.Middleware {
+	return func(next pgkit.Executor) pgkit.Executor {
+		return recorderExecutor{rec: r, next: next}
+	}
+}
+
+// Querier returns a querier serving the recorded fixtures, it doesn't need a database.
+func (r *Recorder) Querier() *pgkit.Querier {
+	return pgkit.NewQuerier(nil).With(r.Middleware())
+}
+
+// Save writes the recorded fixtures to the file, it's a no-op in replay mode.
+func (r *Recorder) Save() error {
+	if r.mode != Record {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	data, err := json.MarshalIndent(r.fixtures, "", "  ")
+	if err != nil {
+		return fmt.Errorf("pgkittest: failed to encode fixtures: %w", err)
+	}
+	return os.WriteFile(r.path, data, 0o644)
+}
+
+func (r *Recorder) add(key string, f *fixture) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fixtures[key] = append(r.fixtures[key], f)
+}
+
+func (r *Recorder) next(key string) (*fixture, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	list := r.fixtures[key]
+	if len(list) == 0 {
+		return nil, ErrNoFixture
+	}
+	i := r.cursor[key]
+	if i < len(list)-1 {
+		r.cursor[key] = i + 1
+	}
+	return list[i], nil
+}
+
+// fixtureKey identifies a query by its fingerprint and its arguments.
+func fixtureKey(sql string, args []interface{}) string {
+	h := sha256.New()
+	if data, err := json.Marshal(args); err == nil {
+		h.Write(data)
+	} else {
+		fmt.Fprintf(h, "%#v", args)
+	}
+	return pgkit.Fingerprint(sql) + "-" + hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// setErr records err, keeping the postgres error details.
+func (f *fixture) setErr(err error) {
+	if err == nil {
+		return
+	}
+	f.Err, f.cause = err.Error(), err
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		f.PgErr = pgErr
+	}
+}
+
+func (f *fixture) err() error {
+	switch {
+	case f.Err == "":
+		return nil
+	case f.cause != nil:
+		return f.cause
+	case f.PgErr != nil && f.PgErr.Error() == f.Err:
+		return f.PgErr
+	case f.PgErr != nil:
+		return fmt.Errorf("%s: %w", strings.TrimSuffix(f.Err, ": "+f.PgErr.Error()), f.PgErr)
+	}
+	return errors.New(f.Err)
+}
+
+// recordRows drains rows into a fixture.
+func recordRows(sql string, rows pgx.Rows, err error) *fixture {
+	f := &fixture{SQL: sql}
+	if err != nil {
+		f.setErr(err)
+		return f
+	}
+	defer rows.Close()
+	f.Fields = rows.FieldDescriptions()
+	for rows.Next() {
+		raw := rows.RawValues()
+		row := make([][]byte, len(raw))
+		for i, v := range raw {
+			if v != nil {
+				row[i] = append([]byte{}, v...)
+			}
+		}
+		f.Rows = append(f.Rows, row)
+	}
+	rows.Close()
+	f.setErr(rows.Err())
+	f.CommandTag = rows.CommandTag().String()
+	return f
+}
+
+type recorderExecutor struct {
+	rec  *Recorder
+	next pgkit.Executor
+}
+
+func (e recorderExecutor) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	key := fixtureKey(sql, args)
+	if e.rec.mode == Replay {
+		f, err := e.rec.next(key)
+		if err != nil {
+			return pgconn.CommandTag{}, err
+		}
+		return pgconn.NewCommandTag(f.CommandTag), f.err()
+	}
+	tag, err := e.next.Exec(ctx, sql, args...)
+	f := &fixture{SQL: sql, CommandTag: tag.String()}
+	f.setErr(err)
+	e.rec.add(key, f)
+	return tag, err
+}
+
+func (e recorderExecutor) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	key := fixtureKey(sql, args)
+	if e.rec.mode == Replay {
+		f, err := e.rec.next(key)
+		if err != nil {
+			return nil, err
+		}
+		return f.rows(e.rec.typeMap)
+	}
+	rows, err := e.next.Query(ctx, sql, args...)
+	f := recordRows(sql, rows, err)
+	e.rec.add(key, f)
+	return f.rows(e.rec.typeMap)
+}
+
+func (e recorderExecutor) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	rows, err := e.Query(ctx, sql, args...)
+	if err != nil {
+		return errRow{err}
+	}
+	return replayRow{rows}
+}
+
+func (e recorderExecutor) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	keys := make([]string, len(b.QueuedQueries))
+	for i, q := range b.QueuedQueries {
+		keys[i] = fixtureKey(q.SQL, q.Arguments)
+	}
+	if e.rec.mode == Replay {
+		return &replayBatch{rec: e.rec, queries: b.QueuedQueries, keys: keys}
+	}
+	return &replayBatch{rec: e.rec, queries: b.QueuedQueries, keys: keys, next: e.next.SendBatch(ctx, b)}
+}
+
+// replayBatch serves batch results from fixtures, recording them first when next is set.
+type replayBatch struct {
+	rec     *Recorder
+	queries []*pgx.QueuedQuery
+	keys    []string
+	next    pgx.BatchResults
+	i       int
+}
+
+func (b *replayBatch) fixture(query bool) (*fixture, error) {
+	if b.i >= len(b.keys) {
+		return nil, errors.New("pgkittest: no more batch results")
+	}
+	sql, key := b.queries[b.i].SQL, b.keys[b.i]
+	b.i++
+	if b.next == nil {
+		return b.rec.next(key)
+	}
+	var f *fixture
+	if query {
+		rows, err := b.next.Query()
+		f = recordRows(sql, rows, err)
+	} else {
+		tag, err := b.next.Exec()
+		f = &fixture{SQL: sql, CommandTag: tag.String()}
+		f.setErr(err)
+	}
+	b.rec.add(key, f)
+	return f, nil
+}
+
+func (b *replayBatch) Exec() (pgconn.CommandTag, error) {
+	f, err := b.fixture(false)
+	if err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	return pgconn.NewCommandTag(f.CommandTag), f.err()
+}
+
+func (b *replayBatch) Query() (pgx.Rows, error) {
+	f, err := b.fixture(true)
+	if err != nil {
+		return nil, err
+	}
+	return f.rows(b.rec.typeMap)
+}
+
+func (b *replayBatch) QueryRow() pgx.Row {
+	rows, err := b.Query()
+	if err != nil {
+		return errRow{err}
+	}
+	return replayRow{rows}
+}
+
+func (b *replayBatch) Close() error {
+	if b.next != nil {
+		return b.next.Close()
+	}
+	return nil
+}
+
+func (f *fixture) rows(typeMap *pgtype.Map) (pgx.Rows, error) {
+	if f.Fields == nil && f.Err != "" {
+		return nil, f.err()
+	}
+	return &replayRows{fixture: f, typeMap: typeMap, i: -1}, nil
+}
+
+// replayRows implements pgx.Rows over recorded raw values, decoding them with the
+// default pgx type map.
+type replayRows struct {
+	fixture *fixture
+	typeMap *pgtype.Map
+	i       int
+	closed  bool
+}
+
+func (r *replayRows) Close() { r.closed = true }
+
+func (r *replayRows) Err() error {
+	if r.closed || r.i >= len(r.fixture.Rows) {
+		return r.fixture.err()
+	}
+	return nil
+}
+
+func (r *replayRows) CommandTag() pgconn.CommandTag {
+	return pgconn.NewCommandTag(r.fixture.CommandTag)
+}
+
+func (r *replayRows) FieldDescriptions() []pgconn.FieldDescription { return r.fixture.Fields }
+
+func (r *replayRows) Next() bool {
+	if r.closed {
+		return false
+	}
+	r.i++
+	if r.i >= len(r.fixture.Rows) {
+		r.closed = true
+		return false
+	}
+	return true
+}
+
+func (r *replayRows) Scan(dest ...interface{}) error {
+	raw := r.RawValues()
+	if len(dest) != len(raw) {
+		return fmt.Errorf("pgkittest: number of field descriptions must equal number of destinations, got %d and %d", len(raw), len(dest))
+	}
+	for i, d := range dest {
+		if d == nil {
+			continue
+		}
+		fd := r.fixture.Fields[i]
+		if err := r.typeMap.Scan(fd.DataTypeOID, fd.Format, raw[i], d); err != nil {
+			return fmt.Errorf("pgkittest: can't scan column %q: %w", fd.Name, err)
+		}
+	}
+	return nil
+}
+
+func (r *replayRows) Values() ([]interface{}, error) {
+	raw := r.RawValues()
+	values := make([]interface{}, len(raw))
+	for i, buf := range raw {
+		if buf == nil {
+			continue
+		}
+		fd := r.fixture.Fields[i]
+		if t, ok := r.typeMap.TypeForOID(fd.DataTypeOID); ok {
+			v, err := t.Codec.DecodeValue(r.typeMap, fd.DataTypeOID, fd.Format, buf)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = v
+		} else if fd.Format == pgx.TextFormatCode {
+			values[i] = string(buf)
+		} else {
+			values[i] = buf
+		}
+	}
+	return values, nil
+}
+
+func (r *replayRows) RawValues() [][]byte {
+	if r.i < 0 || r.i >= len(r.fixture.Rows) {
+		return nil
+	}
+	return r.fixture.Rows[r.i]
+}
+
+func (r *replayRows) Conn() *pgx.Conn { return nil }
+
+type replayRow struct {
+	rows pgx.Rows
+}
+
+func (r replayRow) Scan(dest ...interface{}) error {
+	defer r.rows.Close()
+	if !r.rows.Next() {
+		if err := r.rows.Err(); err != nil {
+			return err
+		}
+		return pgx.ErrNoRows
+	}
+	if err := r.rows.Scan(dest...); err != nil {
+		return err
+	}
+	return r.rows.Err()
+}
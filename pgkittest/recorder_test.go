@@ -0,0 +1,131 @@
+package pgkittest_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/goware/pgkit/v2"
+	"github.com/goware/pgkit/v2/pgkittest"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/require"
+)
+
+// staticRows returns text encoded rows of (id int4, name text).
+type staticRows struct {
+	values [][][]byte
+	i      int
+}
+
+func (r *staticRows) Close()                        {}
+func (r *staticRows) Err() error                    { return nil }
+func (r *staticRows) CommandTag() pgconn.CommandTag { return pgconn.NewCommandTag("SELECT 2") }
+func (r *staticRows) FieldDescriptions() []pgconn.FieldDescription {
+	return []pgconn.FieldDescription{
+		{Name: "id", DataTypeOID: pgtype.Int4OID, Format: pgx.TextFormatCode},
+		{Name: "name", DataTypeOID: pgtype.TextOID, Format: pgx.TextFormatCode},
+	}
+}
+func (r *staticRows) Next() bool                     { r.i++; return r.i <= len(r.values) }
+func (r *staticRows) Scan(dest ...interface{}) error { return nil }
+func (r *staticRows) Values() ([]interface{}, error) { return nil, nil }
+func (r *staticRows) RawValues() [][]byte            { return r.values[r.i-1] }
+func (r *staticRows) Conn() *pgx.Conn                { return nil }
+
+type rowsExecutor struct{ nopExecutor }
+
+func (e *rowsExecutor) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	e.calls++
+	return &staticRows{values: [][][]byte{{[]byte("1"), []byte("peter")}, {[]byte("2"), nil}}}, nil
+}
+
+func TestRecorder(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "fixtures.json")
+
+	type account struct {
+		ID   int32   `db:"id"`
+		Name *string `db:"name"`
+	}
+	query := sq.Select("id", "name").From("accounts").Where(sq.Eq{"disabled": false})
+
+	rec, err := pgkittest.NewRecorder(path, pgkittest.Record)
+	require.NoError(t, err)
+
+	next := &rowsExecutor{}
+	var recorded []account
+	err = pgkit.NewQuerier(next).With(rec.Middleware()).GetAll(ctx, query.PlaceholderFormat(sq.Dollar), &recorded)
+	require.NoError(t, err)
+	require.Len(t, recorded, 2)
+	require.NoError(t, rec.Save())
+
+	replay, err := pgkittest.NewRecorder(path, pgkittest.Replay)
+	require.NoError(t, err)
+
+	var replayed []account
+	err = replay.Querier().GetAll(ctx, query.PlaceholderFormat(sq.Dollar), &replayed)
+	require.NoError(t, err)
+	require.Equal(t, recorded, replayed)
+	require.Equal(t, "peter", *replayed[0].Name)
+	require.Nil(t, replayed[1].Name)
+
+	// matched by fingerprint and args
+	replayed = nil
+	err = replay.Querier().GetAll(ctx, sq.Expr("select id,name from accounts\n where disabled = $1", false), &replayed)
+	require.NoError(t, err)
+	require.Equal(t, recorded, replayed)
+
+	err = replay.Querier().GetAll(ctx, sq.Select("1").PlaceholderFormat(sq.Dollar), &replayed)
+	require.ErrorIs(t, err, pgkittest.ErrNoFixture)
+	err = replay.Querier().GetAll(ctx, query.Where(sq.Eq{"id": 1}).PlaceholderFormat(sq.Dollar), &replayed)
+	require.ErrorIs(t, err, pgkittest.ErrNoFixture)
+}
+
+type errExecutor struct {
+	nopExecutor
+	err error
+}
+
+func (e *errExecutor) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	return pgconn.CommandTag{}, e.err
+}
+
+func (e *errExecutor) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	return nil, e.err
+}
+
+func TestRecorderErrors(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "fixtures.json")
+	insert := sq.Insert("accounts").Columns("name").Values("peter").PlaceholderFormat(sq.Dollar)
+	query := sq.Select("id").From("accounts").PlaceholderFormat(sq.Dollar)
+
+	rec, err := pgkittest.NewRecorder(path, pgkittest.Record)
+	require.NoError(t, err)
+	unique := &pgconn.PgError{Severity: "ERROR", Code: "23505", Message: "duplicate key value violates unique constraint", ConstraintName: "accounts_name_key"}
+	querier := pgkit.NewQuerier(&errExecutor{err: unique}).With(rec.Middleware())
+
+	// the original errors are returned while recording
+	_, err = querier.Exec(ctx, insert)
+	require.ErrorIs(t, err, unique)
+	var ids []int32
+	require.ErrorIs(t, querier.GetAll(ctx, query, &ids), unique)
+	require.NoError(t, rec.Save())
+
+	replay, err := pgkittest.NewRecorder(path, pgkittest.Replay)
+	require.NoError(t, err)
+	for _, err := range []error{
+		func() error { _, err := replay.Querier().Exec(ctx, insert); return err }(),
+		replay.Querier().GetAll(ctx, query, &ids),
+	} {
+		var pgErr *pgconn.PgError
+		require.True(t, errors.As(err, &pgErr), err)
+		require.Equal(t, "23505", pgErr.Code)
+		require.Equal(t, "accounts_name_key", pgErr.ConstraintName)
+		require.Contains(t, err.Error(), unique.Error())
+	}
+}
@@ -0,0 +1,162 @@
+// Package pgkitts generates the TypeScript types of the pagination contracts of a service:
+// the pages and results, as serialized by pgkit, and for each paginated endpoint the columns
+// it can be sorted by and the fields it can be filtered on, so the frontend is checked
+// against the configuration of the paginators. It's meant to be run by go generate, ie. from
+// a small main package writing the file:
+//
+//	pgkitts.Generate(w, pgkitts.Contract{Name: "Users", Paginator: users.Config(), Filters: userFilters})
+package pgkitts
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/goware/pgkit/v2"
+)
+
+// Contract is a paginated endpoint.
+type Contract struct {
+	// Name prefixes the generated types, ie. "Users" for UsersSortColumn and UsersFilters.
+	Name      string
+	Paginator pgkit.PaginatorConfig
+	Filters   pgkit.FilterSchema
+}
+
+var _MatcherName = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// _Header are the types shared by all the contracts.
+const _Header = `// Code generated by pgkitts. DO NOT EDIT.
+
+export type SortOrder = "ASC" | "DESC";
+
+export type NullsOrder = "FIRST" | "LAST";
+
+export interface Sort<C extends string = string> {
+  column: C;
+  order: SortOrder;
+  nulls?: NullsOrder;
+}
+
+// SortParam is a sort of the "sort" query parameter, ie. "-created_at".
+export type SortParam<C extends string = string> = C | ` + "`-${C}`" + `;
+
+// Cursor is an opaque position, the nextCursor of a page to pass as the cursor of the next one.
+export type Cursor = string;
+
+export interface Page<C extends string = string> {
+  size: number;
+  page: number;
+  more: boolean;
+  column: string;
+  sort: Sort<C>[] | null;
+  from?: number;
+  to?: number;
+  cursor?: Cursor;
+  nextCursor?: Cursor;
+  total?: number;
+  totalPages?: number;
+  aggregates?: Record<string, unknown>;
+}
+
+export interface PagedResult<T, C extends string = string> {
+  page: Page<C>;
+  rows: T[];
+}
+
+export type FilterOp = "eq" | "ne" | "lt" | "lte" | "gt" | "gte" | "like" | "in";
+
+// FilterValue is the ` + "`op:value`" + ` of a filter, or a list of them.
+export type FilterValue<Op extends FilterOp = FilterOp> = ` + "`${Op}:${string}`" + ` | ` + "`${Op}:${string}`" + `[];
+
+export interface PaginationConfig {
+  defaultSize: number;
+  maxSize: number;
+  defaultSort: readonly string[];
+}
+`
+
+// Generate writes the TypeScript types of the contracts to w.
+func Generate(w io.Writer, contracts ...Contract) error {
+	var buf bytes.Buffer
+	buf.WriteString(_Header)
+	seen := make(map[string]bool, len(contracts))
+	for _, c := range contracts {
+		if !_MatcherName.MatchString(c.Name) {
+			return fmt.Errorf("pgkitts: invalid contract name %q", c.Name)
+		}
+		if seen[c.Name] {
+			return fmt.Errorf("pgkitts: duplicate contract %q", c.Name)
+		}
+		seen[c.Name] = true
+		writeContract(&buf, c)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func writeContract(buf *bytes.Buffer, c Contract) {
+	cfg := c.Paginator
+	fmt.Fprintf(buf, "\n// %s\n\n", c.Name)
+
+	// the sort is unrestricted without allowed columns
+	columns := "string"
+	if cfg.AllowedColumns != nil {
+		list := append(append([]string(nil), cfg.AllowedColumns...), cfg.SortExpressions...)
+		columns = union(list)
+	}
+	fmt.Fprintf(buf, "export type %sSortColumn = %s;\n\n", c.Name, columns)
+	fmt.Fprintf(buf, "export type %sPage = Page<%sSortColumn>;\n\n", c.Name, c.Name)
+	fmt.Fprintf(buf, "export type %sResult<T> = PagedResult<T, %sSortColumn>;\n\n", c.Name, c.Name)
+
+	fmt.Fprintf(buf, "export interface %sFilters {\n", c.Name)
+	names := make([]string, 0, len(c.Filters))
+	for name := range c.Filters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		field := c.Filters[name]
+		ops := make([]string, 0, len(field.Ops))
+		for _, op := range field.AllowedOps() {
+			ops = append(ops, string(op))
+		}
+		fmt.Fprintf(buf, "  // %s\n  %s?: FilterValue<%s>;\n", field.Type, quote(name), union(ops))
+	}
+	buf.WriteString("}\n\n")
+
+	defaultSort := make([]string, len(cfg.DefaultSort))
+	for i, s := range cfg.DefaultSort {
+		defaultSort[i] = quote(s)
+	}
+	fmt.Fprintf(buf, "export const %sPagination: PaginationConfig = {\n", c.Name)
+	fmt.Fprintf(buf, "  defaultSize: %d,\n  maxSize: %d,\n  defaultSort: [%s],\n};\n",
+		cfg.DefaultSize, cfg.MaxSize, strings.Join(defaultSort, ", "))
+}
+
+// union returns the union of the string literals, never when empty.
+func union(list []string) string {
+	if len(list) == 0 {
+		return "never"
+	}
+	list = append([]string(nil), list...)
+	sort.Strings(list)
+	parts := make([]string, 0, len(list))
+	for i, s := range list {
+		if i > 0 && s == list[i-1] {
+			continue
+		}
+		parts = append(parts, quote(s))
+	}
+	return strings.Join(parts, " | ")
+}
+
+// quote returns the string literal of s, JSON strings are valid TypeScript ones.
+func quote(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
@@ -0,0 +1,59 @@
+package pgkitts_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/goware/pgkit/v2"
+	"github.com/goware/pgkit/v2/pgkitts"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate(t *testing.T) {
+	users := pgkit.NewPaginator[struct{}](
+		pgkit.WithSort("-created_at", "id"),
+		pgkit.WithAllowedColumns("name", "created_at"),
+		pgkit.WithSortExpressions(map[string]string{"email": "lower(email)"}),
+	)
+	filters := pgkit.FilterSchema{
+		"status":     {Type: pgkit.FilterString, Ops: []pgkit.FilterOp{pgkit.OpEq, pgkit.OpIn}},
+		"created_at": {Type: pgkit.FilterTime},
+	}
+
+	var b strings.Builder
+	err := pgkitts.Generate(&b,
+		pgkitts.Contract{Name: "Users", Paginator: users.Config(), Filters: filters},
+		pgkitts.Contract{Name: "Logs", Paginator: pgkit.NewPaginator[struct{}]().Config()},
+	)
+	require.NoError(t, err)
+	out := b.String()
+	require.True(t, strings.HasPrefix(out, "// Code generated by pgkitts. DO NOT EDIT.\n"))
+	require.Contains(t, out, "export interface PagedResult<T, C extends string = string> {\n  page: Page<C>;\n  rows: T[];\n}\n")
+	require.Contains(t, out, `
+// Users
+
+export type UsersSortColumn = "created_at" | "email" | "name";
+
+export type UsersPage = Page<UsersSortColumn>;
+
+export type UsersResult<T> = PagedResult<T, UsersSortColumn>;
+
+export interface UsersFilters {
+  // time
+  "created_at"?: FilterValue<"eq" | "gt" | "gte" | "lt" | "lte" | "ne">;
+  // string
+  "status"?: FilterValue<"eq" | "in">;
+}
+
+export const UsersPagination: PaginationConfig = {
+  defaultSize: 10,
+  maxSize: 50,
+  defaultSort: ["-created_at", "id"],
+};
+`)
+	require.Contains(t, out, "export type LogsSortColumn = string;\n")
+	require.Contains(t, out, "export interface LogsFilters {\n}\n")
+
+	require.Error(t, pgkitts.Generate(&b, pgkitts.Contract{Name: "my-users"}))
+	require.Error(t, pgkitts.Generate(&b, pgkitts.Contract{Name: "Users"}, pgkitts.Contract{Name: "Users"}))
+}
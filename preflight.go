@@ -0,0 +1,226 @@
+package pgkit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Check is a boot time validation of the database, see Preflight.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context, db *DB) error
+}
+
+// CheckResult is the outcome of a Check, Err is nil when it passed.
+type CheckResult struct {
+	Name     string
+	Err      error
+	Duration time.Duration
+}
+
+// PreflightReport lists the outcome of each check, in order.
+type PreflightReport struct {
+	Results []CheckResult
+}
+
+// OK returns true when all the checks passed.
+func (r PreflightReport) OK() bool {
+	return r.Err() == nil
+}
+
+// Err returns an error describing the failed checks, or nil.
+func (r PreflightReport) Err() error {
+	var failed []string
+	for _, res := range r.Results {
+		if res.Err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", res.Name, res.Err))
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return fmt.Errorf("pgkit: preflight failed: %s", strings.Join(failed, "; "))
+}
+
+// Preflight runs all the checks, even after a failure, so a service can validate its
+// database at startup and report every problem at once:
+//
+//	report := pgkit.Preflight(ctx, db,
+//		pgkit.CheckServerVersion(140000),
+//		pgkit.CheckExtensions("pg_trgm"),
+//		pgkit.CheckSortIndex("accounts", paginator.Config()),
+//	)
+//	if err := report.Err(); err != nil {
+//		log.Fatal(err)
+//	}
+func Preflight(ctx context.Context, db *DB, checks ...Check) PreflightReport {
+	report := PreflightReport{Results: make([]CheckResult, 0, len(checks))}
+	for _, c := range checks {
+		start := time.Now()
+		err := c.Run(ctx, db)
+		report.Results = append(report.Results, CheckResult{Name: c.Name, Err: err, Duration: time.Since(start)})
+	}
+	return report
+}
+
+// CheckServerVersion requires a server version of at least min, in the server_version_num
+// format, ie. 140000 for PostgreSQL 14.
+func CheckServerVersion(min int) Check {
+	return Check{
+		Name: fmt.Sprintf("server version >= %d", min),
+		Run: func(ctx context.Context, db *DB) error {
+			version, err := db.ServerVersion(ctx)
+			if err != nil {
+				return err
+			}
+			if version < min {
+				return fmt.Errorf("server version is %d", version)
+			}
+			return nil
+		},
+	}
+}
+
+// CheckExtensions requires the given extensions to be installed in the database.
+func CheckExtensions(names ...string) Check {
+	return Check{
+		Name: "extensions " + strings.Join(names, ", "),
+		Run: func(ctx context.Context, db *DB) error {
+			return missing(ctx, db, "extensions", names,
+				`SELECT n FROM unnest($1::text[]) AS n WHERE NOT EXISTS (SELECT 1 FROM pg_extension WHERE extname = n)`)
+		},
+	}
+}
+
+// CheckTables requires the given, possibly schema qualified, tables or views to exist.
+func CheckTables(tables ...string) Check {
+	return Check{
+		Name: "tables " + strings.Join(tables, ", "),
+		Run: func(ctx context.Context, db *DB) error {
+			return missing(ctx, db, "tables", tables,
+				`SELECT n FROM unnest($1::text[]) AS n WHERE to_regclass(n) IS NULL`)
+		},
+	}
+}
+
+// CheckRowSecurity requires row level security to be enabled on the given tables.
+func CheckRowSecurity(tables ...string) Check {
+	return Check{
+		Name: "row level security on " + strings.Join(tables, ", "),
+		Run: func(ctx context.Context, db *DB) error {
+			return missing(ctx, db, "row level security on", tables, `
+				SELECT n FROM unnest($1::text[]) AS n
+				WHERE NOT EXISTS (SELECT 1 FROM pg_class WHERE oid = to_regclass(n) AND relrowsecurity)`)
+		},
+	}
+}
+
+// CheckIndex requires an index on table whose leading columns are the given ones, in order,
+// or any index on table when none is given.
+func CheckIndex(table string, columns ...string) Check {
+	return checkIndex(table, columns, nil)
+}
+
+// CheckSortIndex requires an index on table supporting the default sort of a paginator,
+// parsed as the paginator does, see NewSort: its leading columns are the sort columns, and
+// their order and nulls order are the ones of the sort, or all the opposite ones, as an index
+// can be scanned backward. It passes when the paginator has no default sort.
+func CheckSortIndex(table string, cfg PaginatorConfig) Check {
+	columns := make([]string, 0, len(cfg.DefaultSort))
+	sorts := make([]Sort, 0, len(cfg.DefaultSort))
+	for _, s := range cfg.DefaultSort {
+		if sort, ok := NewSort(s); ok {
+			columns = append(columns, strings.Trim(sort.Column, `"`))
+			sorts = append(sorts, sort.normalize())
+		}
+	}
+	check := checkIndex(table, columns, sorts)
+	if len(sorts) == 0 {
+		check.Run = func(ctx context.Context, db *DB) error { return nil }
+	}
+	return check
+}
+
+// checkIndex requires an index on table whose leading columns are the given ones, sorted as
+// sorts when not nil.
+func checkIndex(table string, columns []string, sorts []Sort) Check {
+	return Check{
+		Name: fmt.Sprintf("index on %s (%s)", table, strings.Join(columns, ", ")),
+		Run: func(ctx context.Context, db *DB) error {
+			// the options of the leading keys, whose bits are DESC and NULLS FIRST
+			rows, err := db.Pool().Query(ctx, `
+				SELECT i.indoption[0:greatest(cardinality($2::text[]), 1) - 1]::int2[] FROM pg_index i
+				WHERE i.indrelid = to_regclass($1)
+				AND (
+					cardinality($2::text[]) = 0 OR (
+						SELECT array_agg(a.attname::text ORDER BY k.ord)
+						FROM unnest(i.indkey[0:cardinality($2::text[]) - 1]) WITH ORDINALITY AS k(attnum, ord)
+						JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = k.attnum
+					) = $2::text[]
+				)`, table, columns)
+			if err != nil {
+				return wrapErr(err)
+			}
+			defer rows.Close()
+			found := false
+			for rows.Next() {
+				var options []int16
+				if err := rows.Scan(&options); err != nil {
+					return wrapErr(err)
+				}
+				found = found || sorts == nil || indexSorts(options, sorts)
+			}
+			if err := rows.Err(); err != nil {
+				return wrapErr(err)
+			}
+			if !found {
+				return fmt.Errorf("no index found")
+			}
+			return nil
+		},
+	}
+}
+
+// indexSorts reports whether an index whose leading keys have the given options returns the
+// rows in the order of sorts, scanned forward or backward.
+func indexSorts(options []int16, sorts []Sort) bool {
+	if len(options) < len(sorts) {
+		return false
+	}
+	forward, backward := true, true
+	for i, s := range sorts {
+		desc := s.Order == Desc
+		// NULL values are larger than any other by default
+		nullsFirst := s.Nulls == NullsFirst || (s.Nulls != NullsLast && desc)
+		indexDesc, indexNullsFirst := options[i]&1 != 0, options[i]&2 != 0
+		forward = forward && desc == indexDesc && nullsFirst == indexNullsFirst
+		backward = backward && desc != indexDesc && nullsFirst != indexNullsFirst
+	}
+	return forward || backward
+}
+
+// missing runs query, which returns the names missing among names, failing when any.
+func missing(ctx context.Context, db *DB, what string, names []string, query string) error {
+	rows, err := db.Pool().Query(ctx, query, names)
+	if err != nil {
+		return wrapErr(err)
+	}
+	defer rows.Close()
+	var list []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return wrapErr(err)
+		}
+		list = append(list, name)
+	}
+	if err := rows.Err(); err != nil {
+		return wrapErr(err)
+	}
+	if len(list) > 0 {
+		return fmt.Errorf("missing %s %s", what, strings.Join(list, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+package pgkit_test
+
+import (
+	"testing"
+
+	"github.com/goware/pgkit/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckSortIndex(t *testing.T) {
+	cfg := pgkit.NewPaginator[T](pgkit.WithSort("-created_at:nullslast", "id")).Config()
+	require.Equal(t, "index on events (created_at, id)", pgkit.CheckSortIndex("events", cfg).Name)
+
+	cfg = pgkit.NewPaginator[T](pgkit.WithSort(`-"rank"`, "name:nullsfirst")).Config()
+	require.Equal(t, "index on events (rank, name)", pgkit.CheckSortIndex("events", cfg).Name)
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync/atomic"
 
 	sq "github.com/Masterminds/squirrel"
 	"github.com/georgysavva/scany/v2/pgxscan"
@@ -14,9 +15,80 @@ import (
 )
 
 type Querier struct {
-	pool *pgxpool.Pool
-	tx   pgx.Tx
-	SQL  *StatementBuilder
+	pool       *poolRef
+	tx         pgx.Tx
+	exec       Executor
+	SQL        *StatementBuilder
+	middleware []Middleware
+	// analyzeThreshold replaces AnalyzeThreshold when set, negative disables it, see
+	// WithAnalyzeThreshold.
+	analyzeThreshold int64
+}
+
+// Executor is the subset of the pgx api used by Querier to run queries. It's satisfied
+// by *pgxpool.Pool, *pgx.Conn and pgx.Tx.
+type Executor interface {
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+}
+
+// poolRef is shared by a DB and its queriers, so they all follow the pool replaced by
+// DB.ApplyConfig.
+type poolRef struct {
+	atomic.Pointer[pgxpool.Pool]
+}
+
+func (r *poolRef) get() *pgxpool.Pool {
+	if r == nil {
+		return nil
+	}
+	return r.Load()
+}
+
+// Middleware wraps the Executor used by a Querier, allowing to intercept every
+// query sent to the database.
+type Middleware func(next Executor) Executor
+
+// NewQuerier returns a Querier running its queries on exec, ie. a *pgx.Conn.
+func NewQuerier(exec Executor) *Querier {
+	return &Querier{exec: exec, SQL: newStatementBuilder()}
+}
+
+// With returns a copy of the querier which runs its queries through the given
+// middleware. The first middleware is the outermost one.
+func (q *Querier) With(middleware ...Middleware) *Querier {
+	qq := *q
+	qq.middleware = append(append([]Middleware{}, q.middleware...), middleware...)
+	return &qq
+}
+
+// WithAnalyzeThreshold returns a copy of the querier whose CopyFrom analyzes the table when
+// more than n rows were copied, instead of AnalyzeThreshold. Zero disables it.
+func (q *Querier) WithAnalyzeThreshold(n int64) *Querier {
+	qq := *q
+	if qq.analyzeThreshold = n; n <= 0 {
+		qq.analyzeThreshold = -1
+	}
+	return &qq
+}
+
+// executor returns the transaction, the executor or the pool, wrapped by the querier middleware.
+func (q *Querier) executor() Executor {
+	var e Executor
+	switch {
+	case q.tx != nil:
+		e = q.tx
+	case q.exec != nil:
+		e = q.exec
+	default:
+		e = q.pool.get()
+	}
+	for i := len(q.middleware) - 1; i >= 0; i-- {
+		e = q.middleware[i](e)
+	}
+	return e
 }
 
 func (q *Querier) Exec(ctx context.Context, query Sqlizer) (pgconn.CommandTag, error) {
@@ -30,13 +102,7 @@ func (q *Querier) Exec(ctx context.Context, query Sqlizer) (pgconn.CommandTag, e
 		return pgconn.CommandTag{}, wrapErr(err)
 	}
 
-	var tag pgconn.CommandTag
-	if q.tx != nil {
-		tag, err = q.tx.Exec(ctx, sql, args...)
-	} else {
-		tag, err = q.pool.Exec(ctx, sql, args...)
-	}
-
+	tag, err := q.executor().Exec(ctx, sql, args...)
 	if err != nil {
 		return pgconn.CommandTag{}, wrapErr(err)
 	}
@@ -54,13 +120,7 @@ func (q *Querier) QueryRows(ctx context.Context, query Sqlizer) (pgx.Rows, error
 		return nil, wrapErr(err)
 	}
 
-	var rows pgx.Rows
-	if q.tx != nil {
-		rows, err = q.tx.Query(ctx, sql, args...)
-	} else {
-		rows, err = q.pool.Query(ctx, sql, args...)
-	}
-
+	rows, err := q.executor().Query(ctx, sql, args...)
 	if err != nil {
 		return nil, wrapErr(err)
 	}
@@ -78,11 +138,7 @@ func (q *Querier) QueryRow(ctx context.Context, query Sqlizer) pgx.Row {
 		return errRow{wrapErr(err)}
 	}
 
-	if q.tx != nil {
-		return q.tx.QueryRow(ctx, sql, args...)
-	} else {
-		return q.pool.QueryRow(ctx, sql, args...)
-	}
+	return q.executor().QueryRow(ctx, sql, args...)
 }
 
 func (q *Querier) GetAll(ctx context.Context, query Sqlizer, dest interface{}) error {
@@ -108,6 +164,44 @@ func (q *Querier) GetOne(ctx context.Context, query Sqlizer, dest interface{}) e
 	return wrapErr(pgxscan.ScanOne(dest, rows))
 }
 
+// CopyFrom bulk loads rows into table using the COPY protocol, analyzing the table afterwards
+// when more than AnalyzeThreshold rows were copied, see WithAnalyzeThreshold. It bypasses the
+// querier middleware.
+func (q *Querier) CopyFrom(ctx context.Context, table string, columns []string, src pgx.CopyFromSource) (int64, error) {
+	type copier interface {
+		CopyFrom(context.Context, pgx.Identifier, []string, pgx.CopyFromSource) (int64, error)
+		Exec(context.Context, string, ...interface{}) (pgconn.CommandTag, error)
+	}
+	var conn copier
+	switch {
+	case q.tx != nil:
+		conn = q.tx
+	case q.exec != nil:
+		c, ok := q.exec.(copier)
+		if !ok {
+			return 0, wrapErr(fmt.Errorf("executor %T doesn't support copy", q.exec))
+		}
+		conn = c
+	default:
+		conn = q.pool.get()
+	}
+
+	n, err := conn.CopyFrom(ctx, pgx.Identifier(strings.Split(table, ".")), columns, src)
+	if err != nil {
+		return n, wrapErr(err)
+	}
+	threshold := q.analyzeThreshold
+	if threshold == 0 {
+		threshold = AnalyzeThreshold
+	}
+	if threshold > 0 && n > threshold {
+		if err := analyze(ctx, conn, table); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
 func (q *Querier) BatchExec(ctx context.Context, queries Queries) ([]pgconn.CommandTag, error) {
 	if len(queries) == 0 {
 		return nil, wrapErr(fmt.Errorf("empty query"))
@@ -131,12 +225,7 @@ func (q *Querier) BatchExec(ctx context.Context, queries Queries) ([]pgconn.Comm
 	}
 
 	// Send batch
-	var results pgx.BatchResults
-	if q.tx != nil {
-		results = q.tx.SendBatch(ctx, batch)
-	} else {
-		results = q.pool.SendBatch(ctx, batch)
-	}
+	results := q.executor().SendBatch(ctx, batch)
 	defer results.Close()
 
 	// Exec the number of times as we have queries in the batch so we may get the exec
@@ -176,12 +265,7 @@ func (q *Querier) BatchQuery(ctx context.Context, queries Queries) (pgx.BatchRes
 	}
 
 	// Send batch
-	var batchResults pgx.BatchResults
-	if q.tx != nil {
-		batchResults = q.tx.SendBatch(ctx, batch)
-	} else {
-		batchResults = q.pool.SendBatch(ctx, batch)
-	}
+	batchResults := q.executor().SendBatch(ctx, batch)
 	// defer results.Close()
 
 	// NOTE: the caller of BatchQuery must close the `batchResults` themselves.
@@ -0,0 +1,36 @@
+package pgkit
+
+import "context"
+
+// Priority of a query, used by policies such as LoadShedding to decide which queries
+// can be rejected when the database is under pressure.
+type Priority int
+
+const (
+	PriorityLow Priority = iota - 1
+	PriorityNormal
+	PriorityCritical
+)
+
+// QueryConfig tags the queries run with a context, see WithQueryConfig.
+type QueryConfig struct {
+	// Name identifies the query class, ie. "list_accounts".
+	Name string
+	// Priority of the query, defaults to PriorityNormal.
+	Priority Priority
+	// Expensive marks the query to be checked by a CostBudget before it runs.
+	Expensive bool
+}
+
+type queryConfigKey struct{}
+
+// WithQueryConfig returns a copy of ctx carrying the query config.
+func WithQueryConfig(ctx context.Context, cfg QueryConfig) context.Context {
+	return context.WithValue(ctx, queryConfigKey{}, cfg)
+}
+
+// GetQueryConfig returns the query config carried by ctx, or the zero value.
+func GetQueryConfig(ctx context.Context) QueryConfig {
+	cfg, _ := ctx.Value(queryConfigKey{}).(QueryConfig)
+	return cfg
+}
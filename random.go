@@ -0,0 +1,82 @@
+package pgkit
+
+import (
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/lann/builder"
+)
+
+// WithRandomOrder returns the rows of the query in random order instead of the page sort,
+// ie. to sample rows. An optional seed, in [-1, 1], makes the order reproducible for the
+// same rows read in the same order, without one the order changes each time. The page size
+// is honored, but pages aren't: the query has no offset and Page.More is never set, as each
+// query is a new sample. See WithTableSample for big tables.
+func WithRandomOrder(seed ...float64) func(*PaginatorOption) {
+	return func(o *PaginatorOption) {
+		r := &randomOrder{}
+		if o.random != nil {
+			*r = *o.random
+		}
+		r.seed = nil
+		if len(seed) > 0 {
+			r.seed = &seed[0]
+		}
+		o.random = r
+	}
+}
+
+// WithTableSample reads only about the given percentage of the table with TABLESAMPLE
+// SYSTEM, which picks random pages of the table rather than scanning it whole, so the rows
+// of a page are clustered. It's applied to the FROM clause of the query, which must be a
+// table, and implies WithRandomOrder, whose seed makes the sample REPEATABLE.
+func WithTableSample(percent float64) func(*PaginatorOption) {
+	return func(o *PaginatorOption) {
+		r := &randomOrder{}
+		if o.random != nil {
+			*r = *o.random
+		}
+		r.samplePercent = percent
+		o.random = r
+	}
+}
+
+type randomOrder struct {
+	seed          *float64
+	samplePercent float64
+}
+
+// sample adds the TABLESAMPLE clause to the FROM of the query.
+func (r *randomOrder) sample(q sq.SelectBuilder) sq.SelectBuilder {
+	if r == nil || r.samplePercent <= 0 {
+		return q
+	}
+	from, ok := get(q, "From").(sq.Sqlizer)
+	if !ok {
+		return q.Where(errSqlizer{fmt.Errorf("pgkit: table sample of a query without FROM")})
+	}
+	clause := sq.Expr("? TABLESAMPLE SYSTEM (?)", from, r.samplePercent)
+	if r.seed != nil {
+		clause = sq.Expr("? TABLESAMPLE SYSTEM (?) REPEATABLE (?)", from, r.samplePercent, *r.seed)
+	}
+	return builder.Set(q, "From", clause).(sq.SelectBuilder)
+}
+
+// order replaces the ORDER BY of the query with a random one. A seeded order wraps the
+// query in a lateral join after setseed, so the seed is set before the rows are read.
+func (r *randomOrder) order(q sq.SelectBuilder) sq.SelectBuilder {
+	q = removeOrderBy(q)
+	if r.seed == nil {
+		return q.OrderBy("random()")
+	}
+	format, _ := get(q, "PlaceholderFormat").(sq.PlaceholderFormat)
+	if format == nil {
+		format = sq.Question
+	}
+	inner := q.PlaceholderFormat(sq.Question)
+	return sq.Select("pgkit_sample.*").
+		FromSelect(sq.Select().Column("setseed(?)", *r.seed), "pgkit_seed").
+		JoinClause(sq.Expr("CROSS JOIN LATERAL (?) AS pgkit_sample", inner)).
+		OrderBy("random()").
+		PlaceholderFormat(format)
+}
@@ -0,0 +1,43 @@
+package pgkit_test
+
+import (
+	"testing"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/goware/pgkit/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRandomOrder(t *testing.T) {
+	paginator := pgkit.NewPaginator[T](pgkit.WithSort("-id"))
+	q := sq.Select("*").From("posts").Where(sq.Eq{"status": "draft"}).PlaceholderFormat(sq.Dollar)
+
+	page := &pgkit.Page{Page: 3, Size: 5}
+	_, query := paginator.PrepareQuery(q, page, pgkit.WithRandomOrder())
+	sql, args, err := query.ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "SELECT * FROM posts WHERE status = $1 ORDER BY random() LIMIT 5", sql)
+	require.Equal(t, []interface{}{"draft"}, args)
+	require.Equal(t, uint32(1), page.Page)
+
+	rows := make([]T, 5)
+	require.Len(t, paginator.PrepareResult(rows, page), 5)
+	require.False(t, page.More)
+	require.Equal(t, uint64(1), page.From)
+
+	_, query = paginator.PrepareQuery(q, &pgkit.Page{Size: 5}, pgkit.WithRandomOrder(0.5))
+	sql, args, err = query.ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "SELECT pgkit_sample.* FROM (SELECT setseed($1)) AS pgkit_seed CROSS JOIN LATERAL (SELECT * FROM posts WHERE status = $2) AS pgkit_sample ORDER BY random() LIMIT 5", sql)
+	require.Equal(t, []interface{}{0.5, "draft"}, args)
+
+	_, query = paginator.PrepareQuery(q, &pgkit.Page{Size: 5}, pgkit.WithTableSample(1), pgkit.WithRandomOrder(0.5))
+	sql, args, err = query.ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "SELECT pgkit_sample.* FROM (SELECT setseed($1)) AS pgkit_seed CROSS JOIN LATERAL (SELECT * FROM posts TABLESAMPLE SYSTEM ($2) REPEATABLE ($3) WHERE status = $4) AS pgkit_sample ORDER BY random() LIMIT 5", sql)
+	require.Equal(t, []interface{}{0.5, float64(1), 0.5, "draft"}, args)
+
+	_, query = paginator.PrepareQuery(sq.Select("1"), nil, pgkit.WithTableSample(1))
+	_, _, err = query.ToSql()
+	require.Error(t, err)
+}
@@ -0,0 +1,77 @@
+package pgkit
+
+import (
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Multirange is a multirange value (PostgreSQL 14+), ie. a tstzmultirange scanned into a
+// Multirange[time.Time], which can be used as a query argument too. It's nil when NULL.
+type Multirange[T any] []pgtype.Range[T]
+
+// IsNull implements pgtype.MultirangeGetter.
+func (m Multirange[T]) IsNull() bool {
+	return m == nil
+}
+
+// Len implements pgtype.MultirangeGetter.
+func (m Multirange[T]) Len() int {
+	return len(m)
+}
+
+// Index implements pgtype.MultirangeGetter.
+func (m Multirange[T]) Index(i int) any {
+	return m[i]
+}
+
+// IndexType implements pgtype.MultirangeGetter.
+func (m Multirange[T]) IndexType() any {
+	return pgtype.Range[T]{}
+}
+
+// ScanNull implements pgtype.MultirangeSetter.
+func (m *Multirange[T]) ScanNull() error {
+	*m = nil
+	return nil
+}
+
+// SetLen implements pgtype.MultirangeSetter.
+func (m *Multirange[T]) SetLen(n int) error {
+	*m = make(Multirange[T], n)
+	return nil
+}
+
+// ScanIndex implements pgtype.MultirangeSetter.
+func (m Multirange[T]) ScanIndex(i int) any {
+	return &m[i]
+}
+
+// ScanIndexType implements pgtype.MultirangeSetter.
+func (m Multirange[T]) ScanIndexType() any {
+	return new(pgtype.Range[T])
+}
+
+// RangeAgg returns the query selecting the union of the ranges of column over the rows of q,
+// as a multirange in a "ranges" column, grouped by the groupBy columns which are selected
+// first, ie. the busy periods of each room from their bookings.
+func RangeAgg(q sq.SelectBuilder, column string, groupBy ...string) sq.SelectBuilder {
+	columns := append(append([]string(nil), groupBy...), "range_agg("+column+") AS ranges")
+	return sq.Select(columns...).FromSelect(removeOrderBy(q), "pgkit_ranges").GroupBy(groupBy...).PlaceholderFormat(sq.Dollar)
+}
+
+// RangeGaps returns the query selecting the parts of the range within which are not covered
+// by the ranges of column over the rows of q, one range per row in a "gap" column, in order,
+// ie. the free slots of a calendar. within is a range expression, ie.
+// sq.Expr("tstzrange(?, ?)", from, to).
+func RangeGaps(q sq.SelectBuilder, column string, within sq.Sqlizer) sq.SelectBuilder {
+	gaps := sq.Select().Column(sq.ConcatExpr("unnest(multirange(", within, ") - coalesce(range_agg("+column+"), '{}')) AS gap"))
+	return gaps.FromSelect(removeOrderBy(q), "pgkit_ranges").PlaceholderFormat(sq.Dollar)
+}
+
+// UnnestMultirange returns the lateral join expanding the multirange expression into one
+// row per range, in the column of the given name of the alias table, ie.
+//
+//	q.JoinClause(UnnestMultirange("r.ranges", "u", "slot"))
+func UnnestMultirange(expr, alias, column string) sq.Sqlizer {
+	return sq.Expr("CROSS JOIN LATERAL unnest(" + expr + ") AS " + quoteIdent(alias) + "(" + quoteIdent(column) + ")")
+}
@@ -0,0 +1,30 @@
+package pgkit_test
+
+import (
+	"testing"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/goware/pgkit/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRangeQueries(t *testing.T) {
+	bookings := sq.Select("room_id", "period").From("bookings").Where(sq.Eq{"hotel_id": 1}).OrderBy("period")
+
+	sql, args, err := pgkit.RangeAgg(bookings, "period", "room_id").ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "SELECT room_id, range_agg(period) AS ranges FROM (SELECT room_id, period FROM bookings WHERE hotel_id = $1) AS pgkit_ranges GROUP BY room_id", sql)
+	require.Equal(t, []interface{}{1}, args)
+
+	from, to := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	sql, args, err = pgkit.RangeGaps(bookings, "period", sq.Expr("tstzrange(?, ?)", from, to)).ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "SELECT unnest(multirange(tstzrange($1, $2)) - coalesce(range_agg(period), '{}')) AS gap FROM (SELECT room_id, period FROM bookings WHERE hotel_id = $3) AS pgkit_ranges", sql)
+	require.Equal(t, []interface{}{from, to, 1}, args)
+
+	q := sq.Select("r.room_id", "u.slot").From("rooms r").JoinClause(pgkit.UnnestMultirange("r.ranges", "u", "slot"))
+	sql, _, err = q.ToSql()
+	require.NoError(t, err)
+	require.Equal(t, `SELECT r.room_id, u.slot FROM rooms r CROSS JOIN LATERAL unnest(r.ranges) AS "u"("slot")`, sql)
+}
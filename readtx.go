@@ -0,0 +1,54 @@
+package pgkit
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ReadTx runs fn in a READ ONLY REPEATABLE READ transaction, so all the queries of fn, ie.
+// a page of results, its facet counts and its total, see the same snapshot of the database.
+// The transaction is always rolled back, as there's nothing to commit.
+func ReadTx(ctx context.Context, db *DB, fn func(q *Querier) error, options ...func(*pgx.TxOptions)) error {
+	opts := pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly}
+	for _, o := range options {
+		o(&opts)
+	}
+	tx, err := db.Pool().BeginTx(ctx, opts)
+	if err != nil {
+		return wrapErr(err)
+	}
+	defer tx.Rollback(ctx)
+	return fn(db.TxQuery(tx))
+}
+
+// Deferrable makes ReadTx use a SERIALIZABLE READ ONLY DEFERRABLE transaction, which may wait
+// for a safe snapshot when it starts, but then runs without any serialization overhead nor
+// risk of serialization failures. Suited for long running reports.
+func Deferrable(opts *pgx.TxOptions) {
+	opts.IsoLevel, opts.DeferrableMode = pgx.Serializable, pgx.Deferrable
+}
+
+// SerializableTx runs fn in a SERIALIZABLE transaction, retrying it up to attempts times
+// when it fails with a serialization failure or a deadlock, so fn must be safe to run
+// again. The DB alerter receives AlertSerializationRetriesExhausted when it gives up.
+func SerializableTx(ctx context.Context, db *DB, attempts int, fn func(tx pgx.Tx) error) error {
+	var (
+		err   error
+		pgErr *pgconn.PgError
+		retry bool
+	)
+	for i := 0; i < attempts || i == 0; i++ {
+		err = pgx.BeginTxFunc(ctx, db.Pool(), pgx.TxOptions{IsoLevel: pgx.Serializable}, fn)
+		// serialization_failure or deadlock_detected
+		if retry = errors.As(err, &pgErr) && (pgErr.Code == "40001" || pgErr.Code == "40P01"); !retry || ctx.Err() != nil {
+			break
+		}
+	}
+	if retry {
+		fireAlert(ctx, db.Alerter, Alert{Kind: AlertSerializationRetriesExhausted, Message: "serialization retries exhausted", Err: err})
+	}
+	return wrapErr(err)
+}
@@ -0,0 +1,79 @@
+package pgkit
+
+import (
+	"context"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/lann/builder"
+)
+
+// Relation is what a query reads from when it's not a plain table: a view, or a call of a
+// function returning setof, whose arguments are bound parameters, ie. for
+// `CREATE FUNCTION accounts_of(org int) RETURNS SETOF accounts`:
+//
+//	rel := pgkit.Relation{Name: "accounts_of", Function: true, Args: []interface{}{orgID}}
+//	rows, err := paginator.Query(ctx, rel.From(db.SQL.Select("*")), page, db.Pool())
+//
+// The paginator sorts and limits the rows of the call, so the function should be inlinable,
+// ie. a STABLE SQL function with a single SELECT, for postgres to push the sort into it.
+type Relation struct {
+	// Name is the possibly schema qualified name of the view or the function, used as is.
+	Name string
+	// Function calls Name with Args.
+	Function bool
+	Args     []interface{}
+	// Alias names the relation in the query, optional.
+	Alias string
+}
+
+// ToSql implements sq.Sqlizer, returning the FROM item of the relation.
+func (r Relation) ToSql() (string, []interface{}, error) {
+	sql := r.Name
+	if r.Function {
+		sql += "(" + strings.TrimSuffix(strings.Repeat("?, ", len(r.Args)), ", ") + ")"
+	}
+	if r.Alias != "" {
+		sql += " AS " + quoteIdent(r.Alias)
+	}
+	return sql, r.Args, nil
+}
+
+// From sets the FROM clause of the query to the relation.
+func (r Relation) From(q sq.SelectBuilder) sq.SelectBuilder {
+	return builder.Set(q, "From", r).(sq.SelectBuilder)
+}
+
+// Columns returns the columns of the relation, in order. The query is prepared to describe
+// its rows, not run, so the function isn't called.
+func (r Relation) Columns(ctx context.Context, db *DB) ([]string, error) {
+	sql, _, err := r.From(sq.Select("*").PlaceholderFormat(sq.Dollar)).ToSql()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := db.Pool().Acquire(ctx)
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	defer conn.Release()
+	desc, err := conn.Conn().PgConn().Prepare(ctx, "", sql, nil)
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	columns := make([]string, len(desc.Fields))
+	for i, f := range desc.Fields {
+		columns[i] = f.Name
+	}
+	return columns, nil
+}
+
+// AllowedColumns returns the option restricting the sort to the columns of the relation,
+// see WithAllowedColumns, so the sort parameters are checked against the actual columns of
+// a view or a function rather than a list kept by hand.
+func (r Relation) AllowedColumns(ctx context.Context, db *DB) (func(*PaginatorOption), error) {
+	columns, err := r.Columns(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	return WithAllowedColumns(columns...), nil
+}
@@ -0,0 +1,30 @@
+package pgkit_test
+
+import (
+	"testing"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/goware/pgkit/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelation(t *testing.T) {
+	paginator := pgkit.NewPaginator[T](pgkit.WithSort("id"), pgkit.WithAllowedColumns("id", "name"))
+	page := pgkit.NewPage(5, 2, pgkit.Sort{Column: "name", Order: pgkit.Desc})
+
+	rel := pgkit.Relation{Name: "accounts_of", Function: true, Args: []interface{}{7, true}, Alias: "a"}
+	_, q := paginator.PrepareQuery(rel.From(sq.Select("*").PlaceholderFormat(sq.Dollar)).Where("a.name <> ?", ""), page)
+	sql, args, err := q.ToSql()
+	require.NoError(t, err)
+	require.Equal(t, `SELECT * FROM accounts_of($1, $2) AS "a" WHERE a.name <> $3 ORDER BY name DESC LIMIT 6 OFFSET 5`, sql)
+	require.Equal(t, []interface{}{7, true, ""}, args)
+
+	sql, args, err = pgkit.Relation{Name: "now_playing", Function: true}.From(sq.Select("*")).ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "SELECT * FROM now_playing()", sql)
+	require.Empty(t, args)
+
+	sql, _, err = pgkit.Relation{Name: "public.active_accounts"}.From(sq.Select("*")).ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "SELECT * FROM public.active_accounts", sql)
+}
@@ -0,0 +1,227 @@
+package pgkit
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// _RollupUnits are the date_trunc units a rollup level can use, with their approximate
+// width, used to route reads.
+var _RollupUnits = map[string]time.Duration{
+	"minute":  time.Minute,
+	"hour":    time.Hour,
+	"day":     24 * time.Hour,
+	"week":    7 * 24 * time.Hour,
+	"month":   30 * 24 * time.Hour,
+	"quarter": 91 * 24 * time.Hour,
+	"year":    365 * 24 * time.Hour,
+}
+
+// RollupLevel is a rollup table, holding the aggregates of the source rows by the time
+// column truncated to Unit, ie. "hour" or "day", in a "bucket" column.
+type RollupLevel struct {
+	Unit  string
+	Table string
+}
+
+// Rollup maintains rollup tables, the aggregates of a source table by time bucket, ie.
+// hourly and daily, so dashboards read a few buckets rather than scanning the source.
+//
+// Refresh is incremental: it recomputes only the buckets from the one of the last watermark,
+// the greatest time column refreshed, so the time column should grow with the inserts, ie.
+// "created_at". Rows inserted later with an older time, in a bucket already refreshed,
+// aren't counted. As whole buckets are recomputed, any aggregate can be used.
+type Rollup struct {
+	Name   string
+	Source string
+	// TimeColumn is the column of Source the buckets truncate, and the watermark.
+	TimeColumn string
+	// Dimensions are the columns of Source grouped with the bucket, ie. "account_id". They
+	// should be NOT NULL, as they are part of the unique key of the rollup tables.
+	Dimensions []string
+	// Measures maps the columns of the rollup tables to their aggregates over the source
+	// rows, ie. "total" to "sum(amount)".
+	Measures map[string]string
+	Levels   []RollupLevel
+	// Table stores the watermarks, it defaults to "pgkit_rollups", see Install.
+	Table string
+}
+
+func (r Rollup) table() string {
+	if r.Table == "" {
+		return "pgkit_rollups"
+	}
+	return r.Table
+}
+
+// selectBuckets returns the query aggregating the source rows of the level, from the bucket
+// of the watermark $1, all of them when it's NULL, to $2.
+func (r Rollup) selectBuckets(level RollupLevel) (string, error) {
+	if _, ok := _RollupUnits[level.Unit]; !ok {
+		return "", fmt.Errorf("pgkit: invalid rollup unit %q", level.Unit)
+	}
+	if len(r.Measures) == 0 {
+		return "", fmt.Errorf("pgkit: rollup %q has no measures", r.Name)
+	}
+	column := quoteIdent(r.TimeColumn)
+	columns := []string{"date_trunc(" + quoteLiteral(level.Unit) + ", " + column + ") AS bucket"}
+	groupBy := []string{"1"}
+	for i, d := range r.Dimensions {
+		columns = append(columns, quoteIdent(d))
+		groupBy = append(groupBy, fmt.Sprint(i+2))
+	}
+	for _, name := range r.measures() {
+		columns = append(columns, r.Measures[name]+" AS "+quoteIdent(name))
+	}
+	return fmt.Sprintf("SELECT %s FROM %s WHERE ($1::timestamptz IS NULL OR %s >= date_trunc(%s, $1::timestamptz)) AND %s <= $2 GROUP BY %s",
+		strings.Join(columns, ", "), quoteIdent(r.Source), column, quoteLiteral(level.Unit), column, strings.Join(groupBy, ", ")), nil
+}
+
+func (r Rollup) measures() []string {
+	names := make([]string, 0, len(r.Measures))
+	for name := range r.Measures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// key returns the unique key of the rollup tables.
+func (r Rollup) key() string {
+	key := []string{"bucket"}
+	for _, d := range r.Dimensions {
+		key = append(key, quoteIdent(d))
+	}
+	return strings.Join(key, ", ")
+}
+
+// Install creates the watermarks table, which can be shared by several rollups, and the
+// rollup tables, with the column types of the aggregates, if they don't exist.
+func (r Rollup) Install(ctx context.Context, db *DB) error {
+	_, err := db.Pool().Exec(ctx, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			name TEXT PRIMARY KEY,
+			watermark TIMESTAMPTZ,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		)`, quoteIdent(r.table())))
+	if err != nil {
+		return wrapErr(err)
+	}
+	for _, level := range r.Levels {
+		query, err := r.selectBuckets(level)
+		if err != nil {
+			return err
+		}
+		// the parameters can't be used in CREATE TABLE AS, the query only gives the types
+		query = strings.NewReplacer("$1::timestamptz", "NULL::timestamptz", "$2", "NULL").Replace(query)
+		table := quoteIdent(level.Table)
+		_, err = db.Pool().Exec(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s AS %s WITH NO DATA`, table, query))
+		if err != nil {
+			return wrapErr(err)
+		}
+		index := quoteIdent(strings.ReplaceAll(level.Table, ".", "_") + "_key")
+		_, err = db.Pool().Exec(ctx, fmt.Sprintf(`CREATE UNIQUE INDEX IF NOT EXISTS %s ON %s (%s)`, index, table, r.key()))
+		if err != nil {
+			return wrapErr(err)
+		}
+	}
+	return nil
+}
+
+// Refresh updates the buckets of all the levels from the last watermark to the latest source
+// row, and moves the watermark, in a transaction. Concurrent refreshes of the same rollup
+// wait for each other. It's meant to be called periodically, ie. every minute.
+func (r Rollup) Refresh(ctx context.Context, db *DB) error {
+	table := quoteIdent(r.table())
+	_, err := db.Pool().Exec(ctx, fmt.Sprintf(`INSERT INTO %s (name) VALUES ($1) ON CONFLICT (name) DO NOTHING`, table), r.Name)
+	if err != nil {
+		return wrapErr(err)
+	}
+
+	tx, err := db.Pool().Begin(ctx)
+	if err != nil {
+		return wrapErr(err)
+	}
+	defer tx.Rollback(ctx)
+
+	var watermark, latest *time.Time
+	err = tx.QueryRow(ctx, fmt.Sprintf(`SELECT watermark FROM %s WHERE name = $1 FOR UPDATE`, table), r.Name).Scan(&watermark)
+	if err != nil {
+		return wrapErr(err)
+	}
+	err = tx.QueryRow(ctx, fmt.Sprintf(`SELECT max(%s)::timestamptz FROM %s`, quoteIdent(r.TimeColumn), quoteIdent(r.Source))).Scan(&latest)
+	if err != nil {
+		return wrapErr(err)
+	}
+	if latest == nil {
+		return nil
+	}
+
+	set := make([]string, 0, len(r.Measures))
+	for _, name := range r.measures() {
+		set = append(set, quoteIdent(name)+" = EXCLUDED."+quoteIdent(name))
+	}
+	for _, level := range r.Levels {
+		query, err := r.selectBuckets(level)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, fmt.Sprintf(`INSERT INTO %s %s ON CONFLICT (%s) DO UPDATE SET %s`,
+			quoteIdent(level.Table), query, r.key(), strings.Join(set, ", ")), watermark, *latest)
+		if err != nil {
+			return wrapErr(err)
+		}
+	}
+
+	_, err = tx.Exec(ctx, fmt.Sprintf(`UPDATE %s SET watermark = $2, updated_at = now() WHERE name = $1`, table), r.Name, *latest)
+	if err != nil {
+		return wrapErr(err)
+	}
+	return wrapErr(tx.Commit(ctx))
+}
+
+// Level returns the level to read the range [from, to) from: the finest one with at most
+// maxBuckets buckets in the range, or the coarsest one.
+func (r Rollup) Level(from, to time.Time, maxBuckets int) (RollupLevel, bool) {
+	var finest, coarsest *RollupLevel
+	span := to.Sub(from)
+	for i, level := range r.Levels {
+		width, ok := _RollupUnits[level.Unit]
+		if !ok {
+			continue
+		}
+		if coarsest == nil || width > _RollupUnits[coarsest.Unit] {
+			coarsest = &r.Levels[i]
+		}
+		if (span+width-1)/width <= time.Duration(maxBuckets) && (finest == nil || width < _RollupUnits[finest.Unit]) {
+			finest = &r.Levels[i]
+		}
+	}
+	switch {
+	case finest != nil:
+		return *finest, true
+	case coarsest != nil:
+		return *coarsest, true
+	}
+	return RollupLevel{}, false
+}
+
+// Select returns the query reading the buckets starting in [from, to), sorted by bucket, from
+// the level picked by Level, ie. with the dimensions to filter by:
+//
+//	q := rollup.Select(from, to, 500).Where(sq.Eq{"account_id": id})
+func (r Rollup) Select(from, to time.Time, maxBuckets int) sq.SelectBuilder {
+	q := sq.Select("*").PlaceholderFormat(sq.Dollar)
+	level, ok := r.Level(from, to, maxBuckets)
+	if !ok {
+		return q.Where(errSqlizer{fmt.Errorf("pgkit: rollup %q has no valid level", r.Name)})
+	}
+	return q.From(quoteIdent(level.Table)).
+		Where(sq.GtOrEq{"bucket": from}).Where(sq.Lt{"bucket": to}).
+		OrderBy("bucket")
+}
@@ -0,0 +1,44 @@
+package pgkit_test
+
+import (
+	"testing"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/goware/pgkit/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRollupSelect(t *testing.T) {
+	rollup := pgkit.Rollup{
+		Name:   "orders",
+		Source: "orders",
+		Levels: []pgkit.RollupLevel{
+			{Unit: "day", Table: "orders_daily"},
+			{Unit: "hour", Table: "orders_hourly"},
+		},
+	}
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	level, ok := rollup.Level(from, from.Add(24*time.Hour), 100)
+	require.True(t, ok)
+	require.Equal(t, "orders_hourly", level.Table)
+
+	// too many hours
+	level, ok = rollup.Level(from, from.Add(30*24*time.Hour), 100)
+	require.True(t, ok)
+	require.Equal(t, "orders_daily", level.Table)
+
+	// too many days, the coarsest level
+	level, ok = rollup.Level(from, from.Add(365*24*time.Hour), 100)
+	require.True(t, ok)
+	require.Equal(t, "orders_daily", level.Table)
+
+	sql, args, err := rollup.Select(from, from.Add(24*time.Hour), 100).Where(sq.Eq{"account_id": 1}).ToSql()
+	require.NoError(t, err)
+	require.Equal(t, `SELECT * FROM "orders_hourly" WHERE bucket >= $1 AND bucket < $2 AND account_id = $3 ORDER BY bucket`, sql)
+	require.Equal(t, []interface{}{from, from.Add(24 * time.Hour), 1}, args)
+
+	_, _, err = pgkit.Rollup{Levels: []pgkit.RollupLevel{{Unit: "fortnight", Table: "x"}}}.Select(from, from, 100).ToSql()
+	require.Error(t, err)
+}
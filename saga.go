@@ -0,0 +1,192 @@
+package pgkit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrSagaFailed is returned by a saga run which failed and was compensated.
+var ErrSagaFailed = errors.New("pgkit: saga failed")
+
+// Saga runs a multi-step business flow, persisting the progress of its runs in a table, so
+// a run interrupted by a crash is resumed where it stopped, see ResumeAll. Each step runs in
+// a transaction which also records its completion, so the changes of a step are committed
+// exactly once. Side effects outside of the database, ie. calls to other services, may be
+// repeated and should be idempotent.
+//
+// When a step fails, the completed steps are compensated in reverse order, each in its own
+// transaction too.
+type Saga struct {
+	Name  string
+	Steps []SagaStep
+	// Table stores the runs, it defaults to "pgkit_sagas", see Install.
+	Table string
+}
+
+// SagaStep is a step of a Saga. The data is the one the run was started with.
+type SagaStep struct {
+	Name string
+	Run  func(ctx context.Context, q *Querier, data json.RawMessage) error
+	// Compensate undoes the step, it's optional.
+	Compensate func(ctx context.Context, q *Querier, data json.RawMessage) error
+}
+
+// SagaStatus is the status of a saga run.
+type SagaStatus string
+
+const (
+	SagaRunning      SagaStatus = "running"
+	SagaCompensating SagaStatus = "compensating"
+	SagaDone         SagaStatus = "done"
+	SagaFailed       SagaStatus = "failed"
+)
+
+func (s Saga) table() string {
+	if s.Table == "" {
+		return "pgkit_sagas"
+	}
+	return s.Table
+}
+
+// Install creates the runs table, if it doesn't exist. It can be shared by several sagas.
+func (s Saga) Install(ctx context.Context, db *DB) error {
+	_, err := db.Pool().Exec(ctx, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			id TEXT PRIMARY KEY,
+			saga TEXT NOT NULL,
+			data JSONB,
+			step INT NOT NULL DEFAULT 0,
+			status TEXT NOT NULL,
+			error TEXT,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		)`, quoteIdent(s.table())))
+	return wrapErr(err)
+}
+
+// Start starts a run with the given id and data, encoded in JSON, and runs it, see Resume.
+// Starting an existing run resumes it instead, so a start can be retried safely.
+func (s Saga) Start(ctx context.Context, db *DB, id string, data interface{}) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return wrapErr(err)
+	}
+	_, err = db.Pool().Exec(ctx, fmt.Sprintf(`INSERT INTO %s (id, saga, data, status) VALUES ($1, $2, $3, $4) ON CONFLICT (id) DO NOTHING`,
+		quoteIdent(s.table())), id, s.Name, encoded, SagaRunning)
+	if err != nil {
+		return wrapErr(err)
+	}
+	return s.Resume(ctx, db, id)
+}
+
+// Resume runs the remaining steps of a run, or its remaining compensations, until it's done
+// or failed. A failed run returns ErrSagaFailed, with the error of the failed step. The run
+// is locked by each step, so concurrent calls run it once.
+func (s Saga) Resume(ctx context.Context, db *DB, id string) error {
+	for {
+		status, failure, err := s.advance(ctx, db, id)
+		if err != nil {
+			return err
+		}
+		switch status {
+		case SagaDone:
+			return nil
+		case SagaFailed:
+			return fmt.Errorf("%w: %s", ErrSagaFailed, failure)
+		}
+	}
+}
+
+// ResumeAll resumes the unfinished runs of the saga, ie. after a crash. It stops at the
+// first error.
+func (s Saga) ResumeAll(ctx context.Context, db *DB) error {
+	rows, err := db.Pool().Query(ctx, fmt.Sprintf(`SELECT id FROM %s WHERE saga = $1 AND status IN ($2, $3) ORDER BY updated_at`,
+		quoteIdent(s.table())), s.Name, SagaRunning, SagaCompensating)
+	if err != nil {
+		return wrapErr(err)
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return wrapErr(err)
+	}
+	for _, id := range ids {
+		if err := s.Resume(ctx, db, id); err != nil && !errors.Is(err, ErrSagaFailed) {
+			return err
+		}
+	}
+	return nil
+}
+
+// advance runs the next step or compensation of the run, returning its new status.
+func (s Saga) advance(ctx context.Context, db *DB, id string) (SagaStatus, string, error) {
+	tx, err := db.Pool().Begin(ctx)
+	if err != nil {
+		return "", "", wrapErr(err)
+	}
+	defer tx.Rollback(ctx)
+
+	var (
+		data    json.RawMessage
+		step    int
+		status  SagaStatus
+		failure *string
+	)
+	table := quoteIdent(s.table())
+	err = tx.QueryRow(ctx, fmt.Sprintf(`SELECT data, step, status, error FROM %s WHERE id = $1 AND saga = $2 FOR UPDATE`, table),
+		id, s.Name).Scan(&data, &step, &status, &failure)
+	if err != nil {
+		return "", "", wrapErr(err)
+	}
+	if failure == nil {
+		failure = new(string)
+	}
+	update := fmt.Sprintf(`UPDATE %s SET step = $2, status = $3, error = $4, updated_at = now() WHERE id = $1`, table)
+
+	switch {
+	case status == SagaRunning && step >= len(s.Steps):
+		status = SagaDone
+	case status == SagaRunning:
+		// the step runs in a savepoint, so its changes are rolled back when it fails
+		sp, err := tx.Begin(ctx)
+		if err != nil {
+			return "", "", wrapErr(err)
+		}
+		if err := s.Steps[step].Run(ctx, db.TxQuery(sp), data); err != nil {
+			if ctx.Err() != nil {
+				return "", "", ctx.Err()
+			}
+			if err := sp.Rollback(ctx); err != nil {
+				return "", "", wrapErr(err)
+			}
+			status, *failure = SagaCompensating, fmt.Sprintf("step %s: %v", s.Steps[step].Name, err)
+			break
+		}
+		if err := sp.Commit(ctx); err != nil {
+			return "", "", wrapErr(err)
+		}
+		step++
+	case status == SagaCompensating && step == 0:
+		status = SagaFailed
+	case status == SagaCompensating:
+		if fn := s.Steps[step-1].Compensate; fn != nil {
+			if err := fn(ctx, db.TxQuery(tx), data); err != nil {
+				return "", "", fmt.Errorf("pgkit: compensating step %s: %w", s.Steps[step-1].Name, err)
+			}
+		}
+		step--
+	default:
+		return status, *failure, nil
+	}
+
+	var msg interface{}
+	if *failure != "" {
+		msg = *failure
+	}
+	if _, err := tx.Exec(ctx, update, id, step, status, msg); err != nil {
+		return "", "", wrapErr(err)
+	}
+	return status, *failure, wrapErr(tx.Commit(ctx))
+}
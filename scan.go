@@ -0,0 +1,30 @@
+package pgkit
+
+import (
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+)
+
+// Scan scans all the rows into a slice of T, and closes them. When T is a struct, or a
+// pointer to one, the columns are mapped to its fields by their db tag, or their name in
+// snake_case, ie. CreatedAt to created_at. The fields of embedded structs are mapped as if
+// they were part of T, and the ones of nested structs are prefixed by the struct field name
+// and a dot, ie. "author.name". Pointer fields are nil for NULL columns, and any type pgx can
+// scan is supported, ie. the pgtype ones. Otherwise, T receives the only column.
+func Scan[T any](rows pgx.Rows) ([]T, error) {
+	var result []T
+	if err := pgxscan.ScanAll(&result, rows); err != nil {
+		return nil, wrapErr(err)
+	}
+	return result, nil
+}
+
+// ScanOne scans the first row into a T, like Scan, and closes the rows. It fails with
+// ErrNoRows when there's no row.
+func ScanOne[T any](rows pgx.Rows) (T, error) {
+	var result T
+	if err := pgxscan.ScanOne(&result, rows); err != nil {
+		return result, wrapErr(err)
+	}
+	return result, nil
+}
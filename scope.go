@@ -0,0 +1,35 @@
+package pgkit
+
+import (
+	"context"
+	"errors"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// ErrScopeWithoutContext is returned by the queries of a scoped paginator prepared without
+// a context, see WithScope.
+var ErrScopeWithoutContext = errors.New("pgkit: scoped paginator used without a context, use the Context variant")
+
+// WithScope sets a predicate added to every query of the paginator, derived from the
+// context, ie. the tenant of the caller or `deleted_at IS NULL`, so it can't be forgotten.
+// The context is the one passed to Query, PageOf, ForEachPage or the Context variants of
+// the prepare functions, ie. PrepareQueryContext. The ones without a context fail with
+// ErrScopeWithoutContext rather than dropping the predicate. A nil predicate adds no
+// condition, while a scope which can't be derived can be rejected with a predicate
+// returning an error, failing the query.
+func WithScope(scope func(ctx context.Context) sq.Sqlizer) func(*PaginatorOption) {
+	return func(o *PaginatorOption) { o.scope = scope }
+}
+
+// withScope returns a copy of the options with the scope of ctx added to the filters.
+func (o PaginatorOption) withScope(ctx context.Context) PaginatorOption {
+	if o.scope == nil {
+		return o
+	}
+	if pred := o.scope(ctx); pred != nil {
+		o.filters = append(o.filters[:len(o.filters):len(o.filters)], pred)
+	}
+	o.scope = nil
+	return o
+}
@@ -0,0 +1,68 @@
+package pgkit_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/goware/pgkit/v2"
+	"github.com/stretchr/testify/require"
+)
+
+type tenantKey struct{}
+
+func TestWithScope(t *testing.T) {
+	scope := func(ctx context.Context) sq.Sqlizer {
+		tenant, ok := ctx.Value(tenantKey{}).(int)
+		if !ok {
+			return sq.Expr("false")
+		}
+		return sq.Eq{"tenant_id": tenant}
+	}
+	paginator := pgkit.NewPaginator[T](pgkit.WithSort("id"), pgkit.WithScope(scope))
+	q := sq.Select("*").From("posts").Where(sq.Eq{"status": "draft"}).PlaceholderFormat(sq.Dollar)
+
+	ctx := context.WithValue(context.Background(), tenantKey{}, 7)
+	_, query := paginator.PrepareQueryContext(ctx, q, nil, pgkit.WithFilters(pgkit.Filter{Column: "kind", Op: pgkit.OpEq, Value: "a"}))
+	sql, args, err := query.ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "SELECT * FROM posts WHERE status = $1 AND kind = $2 AND tenant_id = $3 ORDER BY id ASC LIMIT 11 OFFSET 0", sql)
+	require.Equal(t, []interface{}{"draft", "a", 7}, args)
+
+	_, query = paginator.PrepareQueryContext(context.Background(), q, nil)
+	sql, _, err = query.ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "SELECT * FROM posts WHERE status = $1 AND false ORDER BY id ASC LIMIT 11 OFFSET 0", sql)
+
+	// without a context, the scope would be dropped
+	_, query = paginator.PrepareQuery(q, nil)
+	_, _, err = query.ToSql()
+	require.ErrorIs(t, err, pgkit.ErrScopeWithoutContext)
+	_, query = paginator.PrepareTimeQuery(q, pgkit.TimePage{Column: "created_at"})
+	_, _, err = query.ToSql()
+	require.ErrorIs(t, err, pgkit.ErrScopeWithoutContext)
+	_, query = paginator.PrepareTimeQueryContext(ctx, q, pgkit.TimePage{Column: "created_at"})
+	sql, _, err = query.ToSql()
+	require.NoError(t, err)
+	require.Contains(t, sql, "tenant_id = $2")
+
+	cursors := pgkit.NewCursorPaginator[T](pgkit.WithSort("id"), pgkit.WithScope(func(ctx context.Context) sq.Sqlizer {
+		return nil
+	}))
+	_, _, err = cursors.PrepareQuery(q, nil)
+	require.ErrorIs(t, err, pgkit.ErrScopeWithoutContext)
+	_, query, err = cursors.PrepareQueryContext(ctx, q, nil)
+	require.NoError(t, err)
+	sql, _, err = query.ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "SELECT * FROM posts WHERE status = $1 ORDER BY id ASC LIMIT 11", sql)
+
+	override := pgkit.WithScope(func(ctx context.Context) sq.Sqlizer {
+		return sq.Expr("tenant_id = ?", fmt.Sprint(ctx.Value(tenantKey{})))
+	})
+	_, query = paginator.PrepareQueryContext(ctx, q, nil, override)
+	_, args, err = query.ToSql()
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{"draft", "7"}, args)
+}
@@ -0,0 +1,138 @@
+package pgkit
+
+import (
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// SearchMode is the way a Search matches the rows.
+type SearchMode int
+
+const (
+	// SearchILike matches the rows containing the text in any of the columns, case
+	// insensitively.
+	SearchILike SearchMode = iota
+	// SearchFullText matches the rows whose columns match the text in the web search syntax,
+	// see websearch_to_tsquery.
+	SearchFullText
+	// SearchTrigram matches the rows with a column similar to the text, tolerating typos. It
+	// requires the pg_trgm extension, see word_similarity and Search.Resolve.
+	SearchTrigram
+)
+
+// Extension returns the extension the mode requires, if any.
+func (m SearchMode) Extension() Extension {
+	if m == SearchTrigram {
+		return ExtensionTrigram
+	}
+	return ""
+}
+
+// Search is the text of a search box, ie. the `q` parameter of a request, matched against
+// some columns of the rows. It can be passed to Paginator.PrepareQuery with WithSearch. An
+// empty text matches all the rows.
+type Search struct {
+	Text    string
+	Mode    SearchMode
+	Columns []string
+	// Config is the text search configuration of SearchFullText, defaults to "simple".
+	Config string
+	// Ranked sorts the rows by relevance before the page sort, with SearchFullText and
+	// SearchTrigram. The Paginator applies it, not the CursorPaginator.
+	Ranked bool
+	// Fallback is the mode used by Resolve when the extension of Mode isn't installed,
+	// SearchILike by default. Setting it to Mode requires the extension.
+	Fallback SearchMode
+}
+
+// Resolve returns the search with the Fallback mode when the extension of its mode isn't
+// installed, so the same code runs on the databases without it, ie. on managed offerings:
+//
+//	caps, err := db.Capabilities(ctx)
+//	...
+//	search, err := pgkit.Search{Text: q, Mode: pgkit.SearchTrigram, Columns: cols}.Resolve(caps)
+//
+// A ranked ILIKE search isn't sorted by relevance.
+func (s Search) Resolve(c Capabilities) (Search, error) {
+	ext := s.Mode.Extension()
+	if ext == "" || c.Has(ext) {
+		return s, nil
+	}
+	if s.Fallback == s.Mode {
+		return s, ErrMissingExtension{Extension: ext, Feature: "search"}
+	}
+	s.Mode = s.Fallback
+	return s.Resolve(c)
+}
+
+// ToSql implements sq.Sqlizer, returning the predicate matching the rows.
+func (s Search) ToSql() (string, []interface{}, error) {
+	text := strings.TrimSpace(s.Text)
+	switch s.Mode {
+	case SearchFullText:
+		return sq.Expr(s.document()+" @@ websearch_to_tsquery("+s.config()+", ?)", text).ToSql()
+	case SearchTrigram:
+		or := make(sq.Or, len(s.Columns))
+		for i, c := range s.Columns {
+			or[i] = sq.Expr("? <% "+c, text)
+		}
+		return or.ToSql()
+	}
+	pattern := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(text) + "%"
+	or := make(sq.Or, len(s.Columns))
+	for i, c := range s.Columns {
+		or[i] = sq.ILike{c: pattern}
+	}
+	return or.ToSql()
+}
+
+func (s Search) config() string {
+	if s.Config == "" {
+		return quoteLiteral("simple")
+	}
+	return quoteLiteral(s.Config)
+}
+
+func (s Search) document() string {
+	return "to_tsvector(" + s.config() + ", concat_ws(' ', " + strings.Join(s.Columns, ", ") + "))"
+}
+
+// empty reports whether the search matches all the rows.
+func (s *Search) empty() bool {
+	return s == nil || strings.TrimSpace(s.Text) == "" || len(s.Columns) == 0
+}
+
+// where adds the search predicate to the query.
+func (s *Search) where(q sq.SelectBuilder) sq.SelectBuilder {
+	if s.empty() {
+		return q
+	}
+	return q.Where(*s)
+}
+
+// rank returns the ORDER BY clause sorting the rows by relevance, or nil.
+func (s *Search) rank() sq.Sqlizer {
+	if s.empty() || !s.Ranked {
+		return nil
+	}
+	text := strings.TrimSpace(s.Text)
+	switch s.Mode {
+	case SearchFullText:
+		return sq.Expr("ts_rank("+s.document()+", websearch_to_tsquery("+s.config()+", ?)) DESC", text)
+	case SearchTrigram:
+		parts := make([]string, len(s.Columns))
+		args := make([]interface{}, len(s.Columns))
+		for i, c := range s.Columns {
+			parts[i], args[i] = "word_similarity(?, "+c+")", text
+		}
+		return sq.Expr("greatest("+strings.Join(parts, ", ")+") DESC", args...)
+	}
+	return nil
+}
+
+// WithSearch filters the rows with the search, and sorts them by relevance when it's ranked.
+// It's typically passed to PrepareQuery, ie. with the `q` parameter of the request.
+func WithSearch(s Search) func(*PaginatorOption) {
+	return func(o *PaginatorOption) { o.search = &s }
+}
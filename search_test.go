@@ -0,0 +1,77 @@
+package pgkit_test
+
+import (
+	"testing"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/goware/pgkit/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearch(t *testing.T) {
+	paginator := pgkit.NewPaginator[T](pgkit.WithSort("id"))
+	users := sq.Select("*").From("users")
+
+	_, q := paginator.PrepareQuery(users, nil, pgkit.WithSearch(pgkit.Search{Text: " 50%_off ", Columns: []string{"name", "email"}}))
+	sql, args, err := q.ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "SELECT * FROM users WHERE (name ILIKE ? OR email ILIKE ?) ORDER BY id ASC LIMIT 11 OFFSET 0", sql)
+	require.Equal(t, []interface{}{`%50\%\_off%`, `%50\%\_off%`}, args)
+
+	search := pgkit.Search{Text: "jane doe", Mode: pgkit.SearchFullText, Columns: []string{"name", "bio"}, Config: "english", Ranked: true}
+	_, q = paginator.PrepareQuery(users, nil, pgkit.WithSearch(search))
+	sql, args, err = q.ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "SELECT * FROM users WHERE to_tsvector('english', concat_ws(' ', name, bio)) @@ websearch_to_tsquery('english', ?) "+
+		"ORDER BY ts_rank(to_tsvector('english', concat_ws(' ', name, bio)), websearch_to_tsquery('english', ?)) DESC, id ASC LIMIT 11 OFFSET 0", sql)
+	require.Equal(t, []interface{}{"jane doe", "jane doe"}, args)
+
+	search = pgkit.Search{Text: "jnae", Mode: pgkit.SearchTrigram, Columns: []string{"name", "email"}, Ranked: true}
+	_, q = paginator.PrepareQuery(users, nil, pgkit.WithSearch(search))
+	sql, args, err = q.ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "SELECT * FROM users WHERE (? <% name OR ? <% email) ORDER BY greatest(word_similarity(?, name), word_similarity(?, email)) DESC, id ASC LIMIT 11 OFFSET 0", sql)
+	require.Len(t, args, 4)
+
+	// an empty search matches all the rows
+	_, q = paginator.PrepareQuery(users, nil, pgkit.WithSearch(pgkit.Search{Text: "  ", Columns: []string{"name"}, Ranked: true}))
+	sql, _, err = q.ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "SELECT * FROM users ORDER BY id ASC LIMIT 11 OFFSET 0", sql)
+
+	// the cursor paginator doesn't rank
+	_, q, err = pgkit.NewCursorPaginator[T](pgkit.WithSort("id"), pgkit.WithSearch(search)).PrepareQuery(users, nil)
+	require.NoError(t, err)
+	sql, _, err = q.ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "SELECT * FROM users WHERE (? <% name OR ? <% email) ORDER BY id ASC LIMIT 11", sql)
+}
+
+func TestSearchResolve(t *testing.T) {
+	without := pgkit.Capabilities{Installed: map[pgkit.Extension]string{}}
+	with := pgkit.Capabilities{Installed: map[pgkit.Extension]string{pgkit.ExtensionTrigram: "1.6"}}
+
+	search := pgkit.Search{Text: "jnae", Mode: pgkit.SearchTrigram, Columns: []string{"name"}}
+	resolved, err := search.Resolve(with)
+	require.NoError(t, err)
+	require.Equal(t, pgkit.SearchTrigram, resolved.Mode)
+
+	// ILIKE by default
+	resolved, err = search.Resolve(without)
+	require.NoError(t, err)
+	require.Equal(t, pgkit.SearchILike, resolved.Mode)
+
+	search.Fallback = pgkit.SearchFullText
+	resolved, err = search.Resolve(without)
+	require.NoError(t, err)
+	require.Equal(t, pgkit.SearchFullText, resolved.Mode)
+
+	search.Fallback = pgkit.SearchTrigram
+	_, err = search.Resolve(without)
+	require.Equal(t, pgkit.ErrMissingExtension{Extension: pgkit.ExtensionTrigram, Feature: "search"}, err)
+
+	require.NoError(t, with.Require("search", pgkit.ExtensionTrigram))
+	require.Error(t, with.Require("nearby", pgkit.ExtensionTrigram, pgkit.ExtensionPostGIS))
+	require.Equal(t, "trigram", pgkit.Fallback(with, pgkit.ExtensionTrigram, "trigram", "ilike"))
+	require.Equal(t, "ilike", pgkit.Fallback(without, pgkit.ExtensionTrigram, "trigram", "ilike"))
+}
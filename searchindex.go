@@ -0,0 +1,180 @@
+package pgkit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// SearchIndex is a site-wide full text search index stored in a single table, kept up to
+// date from the source tables by triggers, so documents are indexed in the same
+// transaction that changes them.
+type SearchIndex struct {
+	// Table holding the documents, defaults to "search_documents".
+	Table string
+	// Config is the text search configuration, defaults to "simple".
+	Config string
+}
+
+// SearchSource describes how the rows of a table are indexed.
+type SearchSource struct {
+	// Kind identifies the source in the index, defaults to Table.
+	Kind  string
+	Table string
+	// IDColumn is the primary key of Table, defaults to "id".
+	IDColumn string
+	// TitleColumn is stored with the document and returned with the results.
+	TitleColumn string
+	// Columns are indexed with decreasing weights: A, B, C, then D for the rest.
+	Columns []string
+}
+
+// SearchDocument is a search result.
+type SearchDocument struct {
+	Kind  string  `db:"kind"`
+	Ref   string  `db:"ref"`
+	Title string  `db:"title"`
+	Rank  float32 `db:"rank"`
+}
+
+func (s SearchIndex) table() string {
+	if s.Table == "" {
+		return "search_documents"
+	}
+	return s.Table
+}
+
+func (s SearchIndex) config() string {
+	if s.Config == "" {
+		return "simple"
+	}
+	return s.Config
+}
+
+// Install creates the documents table and its index, if they don't exist.
+func (s SearchIndex) Install(ctx context.Context, db *DB) error {
+	table := quoteIdent(s.table())
+	_, err := db.Pool().Exec(ctx, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			kind TEXT NOT NULL,
+			ref TEXT NOT NULL,
+			title TEXT,
+			document TSVECTOR NOT NULL,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+			PRIMARY KEY (kind, ref)
+		);
+		CREATE INDEX IF NOT EXISTS %s ON %s USING GIN (document);`,
+		table, quoteIdent(s.table()+"_document_idx"), table))
+	return wrapErr(err)
+}
+
+// InstallSource creates, or replaces, the trigger indexing the rows of the source table.
+// Existing rows are only indexed by Reindex.
+func (s SearchIndex) InstallSource(ctx context.Context, db *DB, src SearchSource) error {
+	src = src.withDefaults()
+	fn := quoteIdent("pgkit_search_" + strings.ReplaceAll(src.Table, ".", "_"))
+
+	_, err := db.Pool().Exec(ctx, fmt.Sprintf(`
+		CREATE OR REPLACE FUNCTION %[1]s() RETURNS trigger AS $$
+		BEGIN
+			IF TG_OP = 'DELETE' THEN
+				DELETE FROM %[2]s WHERE kind = %[3]s AND ref = OLD.%[4]s::text;
+				RETURN OLD;
+			END IF;
+			INSERT INTO %[2]s (kind, ref, title, document, updated_at)
+			VALUES (%[3]s, NEW.%[4]s::text, %[5]s, %[6]s, now())
+			ON CONFLICT (kind, ref) DO UPDATE
+			SET title = EXCLUDED.title, document = EXCLUDED.document, updated_at = EXCLUDED.updated_at;
+			RETURN NEW;
+		END
+		$$ LANGUAGE plpgsql;
+		DROP TRIGGER IF EXISTS pgkit_search ON %[7]s;
+		CREATE TRIGGER pgkit_search AFTER INSERT OR UPDATE OR DELETE ON %[7]s
+		FOR EACH ROW EXECUTE FUNCTION %[1]s();`,
+		fn, quoteIdent(s.table()), quoteLiteral(src.Kind), quoteIdent(src.IDColumn),
+		src.title("NEW."), s.document(src, "NEW."), quoteIdent(src.Table)))
+	return wrapErr(err)
+}
+
+// Reindex indexes all the rows of the source table.
+func (s SearchIndex) Reindex(ctx context.Context, db *DB, src SearchSource) error {
+	src = src.withDefaults()
+	_, err := db.Pool().Exec(ctx, fmt.Sprintf(`
+		INSERT INTO %s (kind, ref, title, document, updated_at)
+		SELECT %s, t.%s::text, %s, %s, now() FROM %s t
+		ON CONFLICT (kind, ref) DO UPDATE
+		SET title = EXCLUDED.title, document = EXCLUDED.document, updated_at = EXCLUDED.updated_at`,
+		quoteIdent(s.table()), quoteLiteral(src.Kind), quoteIdent(src.IDColumn),
+		src.title("t."), s.document(src, "t."), quoteIdent(src.Table)))
+	return wrapErr(err)
+}
+
+func (s SearchIndex) document(src SearchSource, prefix string) string {
+	if len(src.Columns) == 0 {
+		return "''::tsvector"
+	}
+	parts := make([]string, len(src.Columns))
+	for i, c := range src.Columns {
+		weight := "D"
+		if i < 3 {
+			weight = string(rune('A' + i))
+		}
+		parts[i] = fmt.Sprintf("setweight(to_tsvector(%s, coalesce(%s%s::text, '')), '%s')", quoteLiteral(s.config()), prefix, quoteIdent(c), weight)
+	}
+	return strings.Join(parts, " || ")
+}
+
+func (src SearchSource) withDefaults() SearchSource {
+	if src.Kind == "" {
+		src.Kind = src.Table
+	}
+	if src.IDColumn == "" {
+		src.IDColumn = "id"
+	}
+	return src
+}
+
+func (src SearchSource) title(prefix string) string {
+	if src.TitleColumn == "" {
+		return "NULL"
+	}
+	return prefix + quoteIdent(src.TitleColumn) + "::text"
+}
+
+// SearchPaginator queries a SearchIndex, ranking the results by relevance.
+type SearchPaginator struct {
+	Index     SearchIndex
+	Paginator Paginator[SearchDocument]
+}
+
+// NewSearchPaginator creates a SearchPaginator, the paginator options apply to the results.
+func NewSearchPaginator(index SearchIndex, options ...func(*PaginatorOption)) SearchPaginator {
+	return SearchPaginator{Index: index, Paginator: NewPaginator[SearchDocument](options...)}
+}
+
+// Search returns a page of the documents matching the web search syntax text (see
+// websearch_to_tsquery), optionally restricted to the given kinds.
+func (p SearchPaginator) Search(ctx context.Context, querier *Querier, text string, page *Page, kinds ...string) ([]SearchDocument, error) {
+	q := querier.SQL.Select("kind", "ref", "coalesce(title, '') AS title", "ts_rank(document, query) AS rank").
+		From(quoteIdent(p.Index.table())).
+		JoinClause("CROSS JOIN websearch_to_tsquery(?::regconfig, ?) AS query", p.Index.config(), text).
+		Where("document @@ query")
+	if len(kinds) > 0 {
+		q = q.Where(Any(kinds).Eq("kind"))
+	}
+	if page == nil {
+		page = NewPage(0, 0)
+	}
+	page.Column, page.Order = "", []Sort{{Column: "rank", Order: Desc}, {Column: "kind"}, {Column: "ref"}}
+
+	result, q := p.Paginator.PrepareQueryContext(ctx, q, page)
+	if err := querier.GetAll(ctx, q, &result); err != nil {
+		return nil, err
+	}
+	return p.Paginator.PrepareResult(result, page), nil
+}
+
+// quoteLiteral quotes a string literal.
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
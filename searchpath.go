@@ -0,0 +1,112 @@
+package pgkit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
+)
+
+// WithPlaceholderFormat returns a copy of the builder using the placeholder format, ie.
+// sq.Question for the queries run through a database/sql driver expecting "?".
+func (s StatementBuilder) WithPlaceholderFormat(format sq.PlaceholderFormat) *StatementBuilder {
+	s.StatementBuilderType = s.StatementBuilderType.PlaceholderFormat(format)
+	return &s
+}
+
+// WithSearchPath returns a copy of the builder resolving the unqualified table names against
+// the schemas, in order, see Table and ResolveTable. It doesn't change the search_path of
+// the connections, which Config.SearchPath does.
+func (s StatementBuilder) WithSearchPath(schemas ...string) *StatementBuilder {
+	s.searchPath = append([]string(nil), schemas...)
+	return &s
+}
+
+// WithQualifiedTables returns a copy of the builder qualifying the unqualified table names of
+// the records, and the ones passed to Table, with the first schema of its search path, so
+// the queries don't depend on the search_path of the connections, ie. behind a pooler which
+// resets it. See Config.QualifyTables.
+func (s StatementBuilder) WithQualifiedTables(qualify bool) *StatementBuilder {
+	s.qualifyTables = qualify
+	return &s
+}
+
+// SearchPath returns the schemas of the builder, see WithSearchPath.
+func (s StatementBuilder) SearchPath() []string {
+	return append([]string(nil), s.searchPath...)
+}
+
+// Table returns the name of the table as used by the builder: qualified with the first
+// schema of the search path when the builder qualifies the tables, as is otherwise, ie.
+//
+//	db.SQL.Select("*").From(db.SQL.Table("accounts"))
+func (s StatementBuilder) Table(name string) string {
+	if !s.qualifyTables || len(s.searchPath) == 0 || name == "" || isQualified(name) {
+		return name
+	}
+	return quoteIdent(s.searchPath[0]) + "." + name
+}
+
+// ResolveTable returns the name of the table qualified with the first schema of the search
+// path of the builder which has it, as postgres resolves it, ie. to check at startup that the
+// tables of a multi-schema deployment are the expected ones. A qualified name is returned as
+// is.
+func (s StatementBuilder) ResolveTable(ctx context.Context, db *DB, name string) (string, error) {
+	if isQualified(name) {
+		return name, nil
+	}
+	var schema string
+	err := db.Pool().QueryRow(ctx, `
+		SELECT s.name FROM unnest($1::text[]) WITH ORDINALITY AS s(name, i)
+		WHERE to_regclass(quote_ident(s.name) || '.' || $2) IS NOT NULL
+		ORDER BY s.i LIMIT 1`, s.searchPath, name).Scan(&schema)
+	if err == pgx.ErrNoRows {
+		return "", fmt.Errorf("pgkit: table %s not found in the search path %s", name, strings.Join(s.searchPath, ", "))
+	}
+	if err != nil {
+		return "", wrapErr(err)
+	}
+	return quoteIdent(schema) + "." + name, nil
+}
+
+// isQualified returns true when the name has a dot outside of the quoted identifiers.
+func isQualified(name string) bool {
+	quoted := false
+	for _, r := range name {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == '.' && !quoted:
+			return true
+		}
+	}
+	return false
+}
+
+// searchPathParam returns the value of the search_path parameter for the schemas.
+func searchPathParam(schemas []string) string {
+	quoted := make([]string, len(schemas))
+	for i, s := range schemas {
+		quoted[i] = pgx.Identifier{s}.Sanitize()
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// parseSearchPath returns the schemas of a search_path parameter, without the "$user" one.
+func parseSearchPath(param string) []string {
+	var schemas []string
+	for _, s := range strings.Split(param, ",") {
+		s = strings.TrimSpace(s)
+		if strings.HasPrefix(s, `"`) && strings.HasSuffix(s, `"`) && len(s) > 1 {
+			s = strings.ReplaceAll(s[1:len(s)-1], `""`, `"`)
+		} else {
+			s = strings.ToLower(s)
+		}
+		if s != "" && s != "$user" {
+			schemas = append(schemas, s)
+		}
+	}
+	return schemas
+}
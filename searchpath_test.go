@@ -0,0 +1,46 @@
+package pgkit_test
+
+import (
+	"testing"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/goware/pgkit/v2"
+	"github.com/stretchr/testify/require"
+)
+
+type tenantAccount struct {
+	ID   int64  `db:"id,omitempty"`
+	Name string `db:"name"`
+}
+
+func (tenantAccount) DBTableName() string { return "accounts" }
+
+func TestSearchPath(t *testing.T) {
+	builder := pgkit.NewQuerier(nil).SQL.WithSearchPath("Tenant", "public")
+	require.Equal(t, []string{"Tenant", "public"}, builder.SearchPath())
+
+	// not qualified by default
+	require.Equal(t, "accounts", builder.Table("accounts"))
+	sql, _, err := builder.InsertRecord(tenantAccount{Name: "a"}).ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "INSERT INTO accounts (name) VALUES ($1)", sql)
+
+	builder = builder.WithQualifiedTables(true)
+	require.Equal(t, `"Tenant".accounts`, builder.Table("accounts"))
+	require.Equal(t, "public.accounts", builder.Table("public.accounts"))
+	require.Equal(t, `"Tenant"."a.b"`, builder.Table(`"a.b"`))
+
+	sql, _, err = builder.InsertRecord(tenantAccount{Name: "a"}).ToSql()
+	require.NoError(t, err)
+	require.Equal(t, `INSERT INTO "Tenant".accounts (name) VALUES ($1)`, sql)
+	sql, _, err = builder.InsertRecords([]tenantAccount{{Name: "a"}, {Name: "b"}}).ToSql()
+	require.NoError(t, err)
+	require.Equal(t, `INSERT INTO "Tenant".accounts (name) VALUES ($1),($2)`, sql)
+	sql, _, err = builder.UpdateRecord(tenantAccount{ID: 1, Name: "a"}, sq.Eq{"id": 1}).ToSql()
+	require.NoError(t, err)
+	require.Equal(t, `UPDATE "Tenant".accounts SET id = $1, name = $2 WHERE id = $3`, sql)
+
+	sql, _, err = builder.WithPlaceholderFormat(sq.Question).Select("*").From(builder.Table("accounts")).Where("id = ?", 1).ToSql()
+	require.NoError(t, err)
+	require.Equal(t, `SELECT * FROM "Tenant".accounts WHERE id = ?`, sql)
+}
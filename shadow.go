@@ -0,0 +1,179 @@
+package pgkit
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ShadowPolicy configures Shadow.
+type ShadowPolicy struct {
+	// SampleRate is the ratio of the reads mirrored, between 0 and 1.
+	SampleRate float64
+	// Rewrite returns the query run on the shadow executor, ie. to try an alternate query,
+	// defaults to the same query.
+	Rewrite func(sql string, args []interface{}) (string, []interface{})
+	// Timeout of the shadow queries, defaults to 5s.
+	Timeout time.Duration
+	// Report receives the comparison of each mirrored read.
+	Report func(ctx context.Context, r ShadowReport)
+}
+
+// ShadowReport compares a read with its shadow.
+type ShadowReport struct {
+	SQL    string
+	Config QueryConfig
+
+	Rows, ShadowRows       int
+	Latency, ShadowLatency time.Duration
+	Err, ShadowErr         error
+}
+
+// Differs reports whether the shadow returned a different number of rows, or failed
+// differently.
+func (r ShadowReport) Differs() bool {
+	return r.Rows != r.ShadowRows || (r.Err == nil) != (r.ShadowErr == nil)
+}
+
+// Shadow returns a middleware mirroring a sample of the reads, the Query calls of read-only
+// statements (see IsReadOnly), to the shadow executor, ie. a replica candidate or a database
+// with a new index or schema, and reporting the row counts and latencies of both. The writes,
+// ie. an INSERT ... RETURNING, are never mirrored, and the shadow queries run in a read-only
+// transaction when the shadow executor can begin one, ie. a *pgxpool.Pool, so a function
+// with side effects fails rather than writing. The shadow queries run in the background,
+// they never affect the results nor the errors returned to the caller. Exec, QueryRow and
+// batches aren't mirrored.
+func Shadow(shadow Executor, policy ShadowPolicy) Middleware {
+	if policy.Timeout == 0 {
+		policy.Timeout = 5 * time.Second
+	}
+	return func(next Executor) Executor {
+		return shadowExecutor{next: next, shadow: shadow, policy: policy}
+	}
+}
+
+type shadowExecutor struct {
+	next   Executor
+	shadow Executor
+	policy ShadowPolicy
+}
+
+func (e shadowExecutor) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	return e.next.Exec(ctx, sql, args...)
+}
+
+func (e shadowExecutor) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	if e.policy.Report == nil || !IsReadOnly(sql) || rand.Float64() >= e.policy.SampleRate {
+		return e.next.Query(ctx, sql, args...)
+	}
+	cfg := GetQueryConfig(ctx)
+	shadowed := make(chan ShadowReport, 1)
+	go func() {
+		shadowed <- e.runShadow(cfg, sql, args)
+	}()
+
+	r := &shadowRows{ctx: ctx, report: e.policy.Report, shadowed: shadowed, start: time.Now()}
+	r.result = ShadowReport{SQL: sql, Config: cfg}
+	rows, err := e.next.Query(ctx, sql, args...)
+	if err != nil {
+		r.result.Err = err
+		r.done()
+		return nil, err
+	}
+	r.Rows = rows
+	return r, nil
+}
+
+// runShadow runs the shadow query, detached from the caller context so it's not canceled
+// with the request.
+func (e shadowExecutor) runShadow(cfg QueryConfig, sql string, args []interface{}) ShadowReport {
+	ctx, cancel := context.WithTimeout(WithQueryConfig(context.Background(), cfg), e.policy.Timeout)
+	defer cancel()
+	if e.policy.Rewrite != nil {
+		sql, args = e.policy.Rewrite(sql, args)
+	}
+	var r ShadowReport
+	if !IsReadOnly(sql) {
+		r.ShadowErr = errShadowWrite
+		return r
+	}
+	start := time.Now()
+	exec := e.shadow
+	if b, ok := exec.(txBeginner); ok {
+		tx, err := b.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+		if err != nil {
+			r.ShadowLatency, r.ShadowErr = time.Since(start), wrapErr(err)
+			return r
+		}
+		defer tx.Rollback(ctx)
+		exec = tx
+	}
+	rows, err := exec.Query(ctx, sql, args...)
+	if err == nil {
+		for rows.Next() {
+			r.ShadowRows++
+		}
+		rows.Close()
+		err = rows.Err()
+	}
+	r.ShadowLatency, r.ShadowErr = time.Since(start), err
+	return r
+}
+
+var errShadowWrite = errors.New("pgkit: the rewritten shadow query isn't read-only")
+
+func (e shadowExecutor) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	return e.next.QueryRow(ctx, sql, args...)
+}
+
+func (e shadowExecutor) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	return e.next.SendBatch(ctx, b)
+}
+
+type shadowRows struct {
+	pgx.Rows
+	ctx      context.Context
+	report   func(context.Context, ShadowReport)
+	shadowed chan ShadowReport
+	start    time.Time
+	result   ShadowReport
+	reported bool
+}
+
+func (r *shadowRows) Next() bool {
+	ok := r.Rows.Next()
+	if ok {
+		r.result.Rows++
+	} else {
+		r.result.Err = r.Rows.Err()
+		r.done()
+	}
+	return ok
+}
+
+func (r *shadowRows) Close() {
+	r.Rows.Close()
+	if r.result.Err == nil {
+		r.result.Err = r.Rows.Err()
+	}
+	r.done()
+}
+
+// done reports once the shadow query is done too, without making the caller wait.
+func (r *shadowRows) done() {
+	if r.reported {
+		return
+	}
+	r.reported = true
+	result := r.result
+	result.Latency = time.Since(r.start)
+	go func() {
+		shadow := <-r.shadowed
+		result.ShadowRows, result.ShadowLatency, result.ShadowErr = shadow.ShadowRows, shadow.ShadowLatency, shadow.ShadowErr
+		r.report(r.ctx, result)
+	}()
+}
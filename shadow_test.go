@@ -0,0 +1,48 @@
+package pgkit_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/goware/pgkit/v2"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+)
+
+// recordExecutor records the queries it's sent, and fails them.
+type recordExecutor struct {
+	sleepExecutor
+	queries chan string
+}
+
+func (e recordExecutor) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	e.queries <- sql
+	return nil, errors.New("no database")
+}
+
+func TestShadowWrites(t *testing.T) {
+	ctx := context.Background()
+	shadow := recordExecutor{queries: make(chan string, 2)}
+	reports := make(chan pgkit.ShadowReport, 2)
+	policy := pgkit.ShadowPolicy{SampleRate: 1, Report: func(ctx context.Context, r pgkit.ShadowReport) { reports <- r }}
+	querier := pgkit.NewQuerier(failExecutor{}).With(pgkit.Shadow(shadow, policy))
+
+	// the write is run once, on the primary executor only
+	querier.QueryRows(ctx, querier.SQL.Insert("accounts").Columns("name").Values("a").Suffix("RETURNING id"))
+	querier.QueryRows(ctx, querier.SQL.Select("id").From("accounts"))
+	r := <-reports
+	require.Equal(t, "SELECT id FROM accounts", r.SQL)
+	require.Equal(t, "SELECT id FROM accounts", <-shadow.queries)
+	require.Len(t, shadow.queries, 0)
+
+	// so is a read rewritten into a write
+	policy.Rewrite = func(sql string, args []interface{}) (string, []interface{}) {
+		return "DELETE FROM accounts RETURNING id", nil
+	}
+	querier = pgkit.NewQuerier(failExecutor{}).With(pgkit.Shadow(shadow, policy))
+	querier.QueryRows(ctx, querier.SQL.Select("id").From("accounts"))
+	r = <-reports
+	require.Error(t, r.ShadowErr)
+	require.Len(t, shadow.queries, 0)
+}
@@ -0,0 +1,218 @@
+package pgkit
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// Shard is a source of a ShardedPaginator: a query, ie. on one of the tables the rows are
+// split into, and the executor it runs on.
+type Shard struct {
+	Query sq.SelectBuilder
+	Exec  Executor
+}
+
+// ShardedPaginator paginates the rows of several shards as a single, globally sorted, list.
+// Each shard is queried for the rows up to the end of the page, which are then merged by
+// the page sort, so the sort columns must be selected, as with a CursorPaginator, and the
+// pages should stay shallow, see WithMaxOffset. Strings are compared byte-wise, which may
+// differ from the collation of the database.
+type ShardedPaginator[T any] struct {
+	PaginatorOption
+	workers int
+}
+
+// NewShardedPaginator creates a paginator querying up to workers shards at a time, it takes
+// the same options as NewPaginator, but the total count isn't supported.
+func NewShardedPaginator[T any](workers int, options ...func(*PaginatorOption)) ShardedPaginator[T] {
+	if workers < 1 {
+		workers = 1
+	}
+	return ShardedPaginator[T]{PaginatorOption: NewPaginator[T](options...).PaginatorOption, workers: workers}
+}
+
+// Query runs the page on all the shards and returns the rows of the merged page, updating
+// the page like Paginator.PrepareResult. The first error cancels the other queries and is
+// returned.
+func (p ShardedPaginator[T]) Query(ctx context.Context, shards []Shard, page *Page) ([]T, error) {
+	if page == nil {
+		page = &Page{Page: 1}
+	}
+	paginator := Paginator[T]{p.PaginatorOption}
+	queries := make([]sq.SelectBuilder, len(shards))
+	for i, shard := range shards {
+		_, queries[i] = paginator.PrepareQueryContext(ctx, shard.Query, page)
+	}
+	offset, limit := p.offset(page), p.limit(page)
+	sortBy := p.getSort(page)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make([][]T, len(shards))
+	sem := make(chan struct{}, p.workers)
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	for i := range shards {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				return
+			}
+			// the shard rows up to the end of the page, and one more for Page.More
+			query := queries[i].Limit(offset + limit + 1).RemoveOffset()
+			rows, err := NewQuerier(shards[i].Exec).QueryRows(ctx, query)
+			if err == nil {
+				results[i], err = Scan[T](rows)
+			}
+			if err != nil {
+				once.Do(func() { firstErr = err; cancel() })
+			}
+		}(i)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	merged, err := mergeSorted(results, sortBy)
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(merged)) <= offset {
+		merged = merged[:0]
+	} else {
+		merged = merged[offset:]
+	}
+	if uint64(len(merged)) > limit+1 {
+		merged = merged[:limit+1]
+	}
+	return paginator.PrepareResult(merged, page), nil
+}
+
+// mergeSorted merges the rows of the shards, each one already sorted, by the sort.
+func mergeSorted[T any](results [][]T, sortBy []Sort) ([]T, error) {
+	type row struct {
+		item   T
+		values []interface{}
+	}
+	var all []row
+	for _, result := range results {
+		for _, item := range result {
+			values, err := sortValues(item, sortBy)
+			if err != nil {
+				return nil, err
+			}
+			all = append(all, row{item: item, values: values})
+		}
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		for k, s := range sortBy {
+			c := compareSortValues(all[i].values[k], all[j].values[k], s)
+			if c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+	list := make([]T, len(all))
+	for i := range all {
+		list[i] = all[i].item
+	}
+	return list, nil
+}
+
+// compareSortValues compares two values of a sort column as postgres does, NULL values,
+// nil pointers, being last in ascending order unless set otherwise.
+func compareSortValues(a, b interface{}, s Sort) int {
+	va, vb := derefValue(a), derefValue(b)
+	if !va.IsValid() || !vb.IsValid() {
+		if va.IsValid() == vb.IsValid() {
+			return 0
+		}
+		nullsFirst := s.Nulls == NullsFirst || (s.Nulls == "" && s.Order == Desc)
+		if !va.IsValid() == nullsFirst {
+			return -1
+		}
+		return 1
+	}
+	c := compareValues(va, vb)
+	if s.Order == Desc {
+		return -c
+	}
+	return c
+}
+
+// derefValue returns the value pointed by v, invalid for nil.
+func derefValue(v interface{}) reflect.Value {
+	rv := reflect.ValueOf(v)
+	for rv.IsValid() && (rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface) {
+		if rv.IsNil() {
+			return reflect.Value{}
+		}
+		rv = rv.Elem()
+	}
+	return rv
+}
+
+func compareValues(a, b reflect.Value) int {
+	switch x := a.Interface().(type) {
+	case time.Time:
+		if y, ok := b.Interface().(time.Time); ok {
+			switch {
+			case x.Before(y):
+				return -1
+			case x.After(y):
+				return 1
+			}
+			return 0
+		}
+	case Decimal:
+		if y, ok := b.Interface().(Decimal); ok {
+			return x.Cmp(y)
+		}
+	}
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return compareOrdered(a.Int(), b.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return compareOrdered(a.Uint(), b.Uint())
+	case reflect.Float32, reflect.Float64:
+		return compareOrdered(a.Float(), b.Float())
+	case reflect.String:
+		return strings.Compare(a.String(), b.String())
+	case reflect.Bool:
+		return compareOrdered(boolInt(a.Bool()), boolInt(b.Bool()))
+	}
+	return strings.Compare(fmt.Sprint(a.Interface()), fmt.Sprint(b.Interface()))
+}
+
+func compareOrdered[V int64 | uint64 | float64 | int](a, b V) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,147 @@
+package pgkit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrShedding is returned for queries rejected by LoadShedding.
+var ErrShedding = errors.New("pgkit: query shed due to pool pressure")
+
+// SheddingPolicy configures LoadShedding. A zero threshold disables the check.
+type SheddingPolicy struct {
+	// MaxSaturation is the ratio of acquired to max connections above which queries are shed.
+	MaxSaturation float64
+	// MaxAcquireWait is the average time to acquire a connection above which queries are shed.
+	MaxAcquireWait time.Duration
+	// ShedBelow is the priority under which queries are shed, defaults to PriorityNormal,
+	// meaning only PriorityLow queries are rejected.
+	ShedBelow Priority
+	// Interval between pool stat samples, defaults to 1s.
+	Interval time.Duration
+	// Alerter is notified when shedding starts and stops, when it receives those kinds of
+	// alerts, see AlertKinds.
+	Alerter Alerter
+}
+
+// LoadShedding returns a middleware rejecting low priority queries with ErrShedding
+// while the pool returned by pool is under pressure. Query priority is set with
+// WithQueryConfig. The pool is read by each sample, see DB.LoadShedding to follow the
+// pool replaced by ApplyConfig.
+func LoadShedding(pool func() *pgxpool.Pool, policy SheddingPolicy) Middleware {
+	if policy.Interval == 0 {
+		policy.Interval = time.Second
+	}
+	s := &shedder{pool: pool, policy: policy}
+	return func(next Executor) Executor {
+		return sheddingExecutor{shedder: s, next: next}
+	}
+}
+
+// LoadShedding returns a LoadShedding middleware sampling the pool of the DB, following
+// the pool replaced by ApplyConfig.
+func (d *DB) LoadShedding(policy SheddingPolicy) Middleware {
+	return LoadShedding(d.Query.pool.get, policy)
+}
+
+type shedder struct {
+	pool   func() *pgxpool.Pool
+	policy SheddingPolicy
+
+	mu           sync.Mutex
+	sampled      *pgxpool.Pool
+	sampledAt    time.Time
+	acquireCount int64
+	acquireTime  time.Duration
+	overloaded   bool
+}
+
+func (s *shedder) shed(ctx context.Context) bool {
+	if GetQueryConfig(ctx).Priority >= s.policy.ShedBelow {
+		return false
+	}
+
+	s.mu.Lock()
+	if time.Since(s.sampledAt) < s.policy.Interval {
+		defer s.mu.Unlock()
+		return s.overloaded
+	}
+	overloaded := s.sample()
+	changed := overloaded != s.overloaded
+	s.overloaded = overloaded
+	s.mu.Unlock()
+
+	if changed {
+		alert := Alert{Kind: AlertSheddingStopped, Message: "pool pressure is back to normal"}
+		if overloaded {
+			alert = Alert{Kind: AlertSheddingStarted, Message: "shedding queries due to pool pressure"}
+		}
+		fireAlert(ctx, s.policy.Alerter, alert)
+	}
+	return overloaded
+}
+
+// sample reads the pool stats, returning whether the pool is overloaded.
+func (s *shedder) sample() bool {
+	pool := s.pool()
+	if pool == nil {
+		return false
+	}
+	if pool != s.sampled {
+		// the pool was replaced, its stats don't follow the previous sample
+		s.sampled, s.sampledAt, s.acquireCount, s.acquireTime = pool, time.Time{}, 0, 0
+	}
+	stat := pool.Stat()
+	overloaded := false
+	if s.policy.MaxSaturation > 0 && stat.MaxConns() > 0 {
+		overloaded = float64(stat.AcquiredConns())/float64(stat.MaxConns()) > s.policy.MaxSaturation
+	}
+	// average acquire duration since the previous sample
+	if count := stat.AcquireCount() - s.acquireCount; s.policy.MaxAcquireWait > 0 && count > 0 && !s.sampledAt.IsZero() {
+		wait := (stat.AcquireDuration() - s.acquireTime) / time.Duration(count)
+		overloaded = overloaded || wait > s.policy.MaxAcquireWait
+	}
+	s.sampledAt = time.Now()
+	s.acquireCount = stat.AcquireCount()
+	s.acquireTime = stat.AcquireDuration()
+	return overloaded
+}
+
+type sheddingExecutor struct {
+	shedder *shedder
+	next    Executor
+}
+
+func (e sheddingExecutor) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	if e.shedder.shed(ctx) {
+		return pgconn.CommandTag{}, ErrShedding
+	}
+	return e.next.Exec(ctx, sql, args...)
+}
+
+func (e sheddingExecutor) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	if e.shedder.shed(ctx) {
+		return nil, ErrShedding
+	}
+	return e.next.Query(ctx, sql, args...)
+}
+
+func (e sheddingExecutor) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	if e.shedder.shed(ctx) {
+		return errRow{ErrShedding}
+	}
+	return e.next.QueryRow(ctx, sql, args...)
+}
+
+func (e sheddingExecutor) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	if e.shedder.shed(ctx) {
+		return errBatchResults{ErrShedding}
+	}
+	return e.next.SendBatch(ctx, b)
+}
@@ -0,0 +1,45 @@
+package pgkit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/goware/pgkit/v2"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadShedding(t *testing.T) {
+	ctx := pgkit.WithQueryConfig(context.Background(), pgkit.QueryConfig{Priority: pgkit.PriorityLow})
+
+	// the pool is connected lazily, its stats are read without a server
+	pool, err := pgxpool.New(context.Background(), "postgres://postgres@localhost:1/pgkit_test")
+	require.NoError(t, err)
+	defer pool.Close()
+
+	var current *pgxpool.Pool
+	samples := 0
+	querier := pgkit.NewQuerier(sleepExecutor{}).With(pgkit.LoadShedding(func() *pgxpool.Pool {
+		samples++
+		return current
+	}, pgkit.SheddingPolicy{MaxSaturation: 0.5, Interval: time.Nanosecond}))
+
+	// no pool, nothing to sample
+	_, err = querier.Exec(ctx, querier.SQL.Delete("accounts"))
+	require.NoError(t, err)
+
+	// the pool is read by each sample, following the pool replaced
+	current = pool
+	for i := 0; i < 3; i++ {
+		time.Sleep(time.Millisecond)
+		_, err = querier.Exec(ctx, querier.SQL.Delete("accounts"))
+		require.NoError(t, err)
+	}
+	require.Equal(t, 4, samples)
+
+	// queries above the priority are not sampled
+	_, err = querier.Exec(context.Background(), querier.SQL.Delete("accounts"))
+	require.NoError(t, err)
+	require.Equal(t, 4, samples)
+}
@@ -0,0 +1,291 @@
+package pgkit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// QueryLogEntry is a line of the structured query log written by QueryLog.
+type QueryLogEntry struct {
+	Time        time.Time     `json:"time"`
+	Name        string        `json:"name,omitempty"`
+	Fingerprint string        `json:"fingerprint"`
+	SQL         string        `json:"sql"`
+	Args        []interface{} `json:"args,omitempty"`
+	Duration    time.Duration `json:"duration"`
+	Rows        int           `json:"rows"`
+	Err         string        `json:"err,omitempty"`
+}
+
+// QueryLog returns the Timing reporter writing a QueryLogEntry per query to w, as NDJSON, ie.
+// to capture the traffic replayed by a Simulation:
+//
+//	querier.With(pgkit.Timing(pgkit.QueryLog(file)))
+//
+// The arguments are logged, so the log may hold personal data. The errors of w are ignored.
+func QueryLog(w io.Writer) func(ctx context.Context, t QueryTiming) {
+	var mu sync.Mutex
+	return func(ctx context.Context, t QueryTiming) {
+		entry := QueryLogEntry{
+			Time:        time.Now().Add(-t.Total),
+			Name:        t.Config.Name,
+			Fingerprint: Fingerprint(t.SQL),
+			SQL:         t.SQL,
+			Args:        t.Args,
+			Duration:    t.Total,
+			Rows:        t.Rows,
+		}
+		if t.Err != nil {
+			entry.Err = t.Err.Error()
+		}
+		line, err := json.Marshal(entry)
+		if err != nil {
+			// an argument which can't be encoded
+			entry.Args = nil
+			if line, err = json.Marshal(entry); err != nil {
+				return
+			}
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		w.Write(append(line, '\n'))
+	}
+}
+
+// ReadQueryLog reads the entries of a log written by QueryLog.
+func ReadQueryLog(r io.Reader) ([]QueryLogEntry, error) {
+	var entries []QueryLogEntry
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var entry QueryLogEntry
+		dec := json.NewDecoder(strings.NewReader(line))
+		dec.UseNumber()
+		if err := dec.Decode(&entry); err != nil {
+			return nil, fmt.Errorf("pgkit: invalid query log line %d: %w", n, err)
+		}
+		for i, arg := range entry.Args {
+			entry.Args[i] = logArg(arg)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("pgkit: reading the query log: %w", err)
+	}
+	return entries, nil
+}
+
+// logArg converts the numbers of a decoded argument to int64 when possible, float64
+// otherwise, so they can be encoded by pgx.
+func logArg(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	case []interface{}:
+		for i := range v {
+			v[i] = logArg(v[i])
+		}
+	case map[string]interface{}:
+		for k := range v {
+			v[k] = logArg(v[k])
+		}
+	}
+	return v
+}
+
+// Simulation replays a query log against a database, ie. a staging one, at the pace the
+// queries were captured, or faster, and compares their latencies to the captured ones, to
+// size the database before traffic grows. The arguments are replayed as decoded from JSON:
+// strings, numbers, booleans, lists and objects, which postgres converts to the types of
+// the parameters, binary arguments aren't supported.
+type Simulation struct {
+	// Speed multiplies the pace of the log, ie. 2 replays an hour of traffic in 30 minutes,
+	// it defaults to 1.
+	Speed float64
+	// Concurrency limits the queries running at once, it defaults to 32. The queries wait
+	// for a slot when it's reached, which adds to their latency.
+	Concurrency int
+	// Filter selects the entries to replay, it defaults to the ones without error of the
+	// statements which don't write, see IsReadOnly.
+	Filter func(entry QueryLogEntry) bool
+}
+
+// SimulationReport is the result of a Simulation.
+type SimulationReport struct {
+	Queries  int
+	Errors   int
+	Duration time.Duration
+	// Stats are the latencies by fingerprint, the most degraded first.
+	Stats []SimulationStats
+}
+
+// SimulationStats are the captured and replayed latencies of a fingerprint.
+type SimulationStats struct {
+	Fingerprint string
+	SQL         string
+	Queries     int
+	Errors      int
+	CapturedP50 time.Duration
+	CapturedP95 time.Duration
+	ReplayedP50 time.Duration
+	ReplayedP95 time.Duration
+}
+
+// Degradation is the ratio of the replayed p95 latency to the captured one, ie. 1.5 when
+// the queries are 50% slower.
+func (s SimulationStats) Degradation() float64 {
+	if s.CapturedP95 <= 0 {
+		return 0
+	}
+	return float64(s.ReplayedP95) / float64(s.CapturedP95)
+}
+
+// IsReadOnly returns true for the statements starting with SELECT, WITH (without any data
+// modifying statement), SHOW, VALUES, TABLE or EXPLAIN (without ANALYZE).
+func IsReadOnly(sql string) bool {
+	tokens := tokenizeSQL(sql)
+	if len(tokens) == 0 {
+		return false
+	}
+	switch tokens[0] {
+	case "select", "show", "values", "table", "with", "explain":
+	default:
+		return false
+	}
+	for i, tok := range tokens {
+		switch tok {
+		case "insert", "update", "delete", "merge", "analyze", "into":
+			// "FOR UPDATE" locks are reads
+			if tok == "update" && i > 0 && tokens[i-1] == "for" {
+				continue
+			}
+			return false
+		}
+	}
+	return true
+}
+
+// Run replays the entries on exec, ie. a *pgxpool.Pool, and returns the report. It stops
+// when ctx is done, reporting the queries replayed so far.
+func (s Simulation) Run(ctx context.Context, exec Executor, entries []QueryLogEntry) (SimulationReport, error) {
+	speed := s.Speed
+	if speed <= 0 {
+		speed = 1
+	}
+	concurrency := s.Concurrency
+	if concurrency <= 0 {
+		concurrency = 32
+	}
+	filter := s.Filter
+	if filter == nil {
+		filter = func(entry QueryLogEntry) bool { return entry.Err == "" && IsReadOnly(entry.SQL) }
+	}
+	replay := make([]QueryLogEntry, 0, len(entries))
+	for _, entry := range entries {
+		if filter(entry) {
+			replay = append(replay, entry)
+		}
+	}
+	sort.SliceStable(replay, func(i, j int) bool { return replay[i].Time.Before(replay[j].Time) })
+
+	var (
+		wg        sync.WaitGroup
+		slots     = make(chan struct{}, concurrency)
+		latencies = make([]time.Duration, len(replay))
+		failed    = make([]bool, len(replay))
+		start     = time.Now()
+		done      = len(replay)
+	)
+loop:
+	for i, entry := range replay {
+		at := time.Duration(float64(entry.Time.Sub(replay[0].Time)) / speed)
+		if wait := at - time.Since(start); wait > 0 {
+			select {
+			case <-ctx.Done():
+				done = i
+				break loop
+			case <-time.After(wait):
+			}
+		}
+		// the latency counts from the scheduled time, including the wait for a slot
+		scheduled := time.Now()
+		select {
+		case <-ctx.Done():
+			done = i
+			break loop
+		case slots <- struct{}{}:
+		}
+		wg.Add(1)
+		go func(i int, entry QueryLogEntry) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			_, err := exec.Exec(ctx, entry.SQL, entry.Args...)
+			latencies[i], failed[i] = time.Since(scheduled), err != nil
+		}(i, entry)
+	}
+	wg.Wait()
+
+	report := SimulationReport{Duration: time.Since(start)}
+	type samples struct {
+		stats              SimulationStats
+		captured, replayed []time.Duration
+	}
+	byFingerprint := map[string]*samples{}
+	for i, entry := range replay[:done] {
+		fingerprint := entry.Fingerprint
+		if fingerprint == "" {
+			fingerprint = Fingerprint(entry.SQL)
+		}
+		f, ok := byFingerprint[fingerprint]
+		if !ok {
+			f = &samples{stats: SimulationStats{Fingerprint: fingerprint, SQL: entry.SQL}}
+			byFingerprint[fingerprint] = f
+		}
+		f.stats.Queries++
+		report.Queries++
+		if failed[i] {
+			f.stats.Errors++
+			report.Errors++
+			continue
+		}
+		f.captured = append(f.captured, entry.Duration)
+		f.replayed = append(f.replayed, latencies[i])
+	}
+	for _, f := range byFingerprint {
+		f.stats.CapturedP50, f.stats.CapturedP95 = percentile(f.captured, 0.50), percentile(f.captured, 0.95)
+		f.stats.ReplayedP50, f.stats.ReplayedP95 = percentile(f.replayed, 0.50), percentile(f.replayed, 0.95)
+		report.Stats = append(report.Stats, f.stats)
+	}
+	sort.Slice(report.Stats, func(i, j int) bool {
+		a, b := report.Stats[i], report.Stats[j]
+		if a.Degradation() != b.Degradation() {
+			return a.Degradation() > b.Degradation()
+		}
+		return a.Fingerprint < b.Fingerprint
+	})
+	return report, ctx.Err()
+}
+
+// percentile returns the p percentile of the samples, which are sorted.
+func percentile(samples []time.Duration, p float64) time.Duration {
+	if len(samples) == 0 {
+		return 0
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	return samples[int(p*float64(len(samples)-1))]
+}
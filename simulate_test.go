@@ -0,0 +1,73 @@
+package pgkit_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/goware/pgkit/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimulation(t *testing.T) {
+	var log bytes.Buffer
+	querier := pgkit.NewQuerier(sleepExecutor{d: time.Millisecond}).With(pgkit.Timing(pgkit.QueryLog(&log)))
+	ctx := pgkit.WithQueryConfig(context.Background(), pgkit.QueryConfig{Name: "accounts"})
+	for i := 0; i < 5; i++ {
+		_, err := querier.Exec(ctx, querier.SQL.Select("*").From("accounts").Where("id = ? AND name = ?", i, "a"))
+		require.NoError(t, err)
+	}
+	_, err := querier.Exec(ctx, querier.SQL.Update("accounts").Set("disabled", true))
+	require.NoError(t, err)
+
+	entries, err := pgkit.ReadQueryLog(&log)
+	require.NoError(t, err)
+	require.Len(t, entries, 6)
+	require.Equal(t, "accounts", entries[0].Name)
+	require.Equal(t, "SELECT * FROM accounts WHERE id = $1 AND name = $2", entries[0].SQL)
+	require.Equal(t, []interface{}{int64(0), "a"}, entries[0].Args)
+	require.Equal(t, pgkit.Fingerprint(entries[0].SQL), entries[0].Fingerprint)
+	require.GreaterOrEqual(t, entries[0].Duration, time.Millisecond)
+
+	// the replay is 5 times slower, the update isn't replayed
+	report, err := pgkit.Simulation{Speed: 10}.Run(context.Background(), sleepExecutor{d: 5 * time.Millisecond}, entries)
+	require.NoError(t, err)
+	require.Equal(t, 5, report.Queries)
+	require.Len(t, report.Stats, 1)
+	stats := report.Stats[0]
+	require.Equal(t, 5, stats.Queries)
+	require.GreaterOrEqual(t, stats.ReplayedP50, 5*time.Millisecond)
+	require.Greater(t, stats.Degradation(), 2.0)
+
+	// a single slot queues the queries
+	for i := range entries {
+		entries[i].Time = entries[0].Time
+	}
+	report, err = pgkit.Simulation{Concurrency: 1, Filter: func(pgkit.QueryLogEntry) bool { return true }}.Run(context.Background(), sleepExecutor{d: 5 * time.Millisecond}, entries)
+	require.NoError(t, err)
+	require.Equal(t, 6, report.Queries)
+	require.Len(t, report.Stats, 2)
+	require.GreaterOrEqual(t, report.Duration, 30*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = pgkit.Simulation{}.Run(ctx, sleepExecutor{}, entries)
+	require.True(t, errors.Is(err, context.Canceled))
+}
+
+func TestIsReadOnly(t *testing.T) {
+	for sql, expected := range map[string]bool{
+		"SELECT * FROM t":                                       true,
+		"select * from t for update":                            true,
+		"WITH x AS (SELECT 1) SELECT * FROM x":                  true,
+		"WITH x AS (DELETE FROM t RETURNING *) SELECT * FROM x": false,
+		"EXPLAIN ANALYZE DELETE FROM t":                         false,
+		"UPDATE t SET a = 1":                                    false,
+		"SELECT * INTO t2 FROM t":                               false,
+		"":                                                      false,
+	} {
+		require.Equal(t, expected, pgkit.IsReadOnly(sql), sql)
+	}
+}
@@ -0,0 +1,57 @@
+package pgkit
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Snapshot is a transaction snapshot exported by ExportSnapshot, which transactions on other
+// connections can import, ie. the workers of a parallel export, so they all see the same
+// state of the database, see WithSnapshot.
+type Snapshot struct {
+	// ID identifies the snapshot, ie. "00000003-0000001B-1".
+	ID string
+	tx pgx.Tx
+}
+
+// ExportSnapshot starts a READ ONLY REPEATABLE READ transaction and exports its snapshot.
+// The snapshot can be imported until it's released, the transaction holds a connection of
+// the pool meanwhile.
+func ExportSnapshot(ctx context.Context, db *DB) (*Snapshot, error) {
+	tx, err := db.Pool().BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	s := &Snapshot{tx: tx}
+	if err := tx.QueryRow(ctx, `SELECT pg_export_snapshot()`).Scan(&s.ID); err != nil {
+		tx.Rollback(ctx)
+		return nil, wrapErr(err)
+	}
+	return s, nil
+}
+
+// Release ends the transaction exporting the snapshot, which can't be imported anymore. The
+// transactions which already imported it aren't affected.
+func (s *Snapshot) Release(ctx context.Context) error {
+	return wrapErr(s.tx.Rollback(ctx))
+}
+
+// WithSnapshot makes ReadTx import the snapshot of the given ID, see ExportSnapshot. It
+// replaces the other options, the transaction is READ ONLY REPEATABLE READ, ie.
+//
+//	snapshot, err := pgkit.ExportSnapshot(ctx, db)
+//	...
+//	defer snapshot.Release(ctx)
+//	for _, chunk := range chunks {
+//		go pgkit.ReadTx(ctx, db, exportChunk(chunk), pgkit.WithSnapshot(snapshot.ID))
+//	}
+func WithSnapshot(id string) func(*pgx.TxOptions) {
+	return func(opts *pgx.TxOptions) {
+		*opts = pgx.TxOptions{
+			IsoLevel:   pgx.RepeatableRead,
+			AccessMode: pgx.ReadOnly,
+			BeginQuery: "BEGIN ISOLATION LEVEL REPEATABLE READ READ ONLY; SET TRANSACTION SNAPSHOT " + quoteLiteral(id),
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package pgkit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// QuerySpecVersion is the version of the QuerySpec format written by this package.
+const QuerySpecVersion = 1
+
+// QuerySpec describes a paginated query by name, without any SQL, so it can be validated
+// by a service, ie. a gateway, and forwarded to the one compiling and running it against
+// its own QuerySchema. In JSON:
+//
+//	{"version": 1, "columns": ["id", "name"], "filters": {"status": ["eq:active"]},
+//	 "sort": ["-created_at"], "page": {"size": 20, "page": 2}}
+//
+// The filters use the `op:value` format of FilterSchema, and the sort the one of NewSort.
+type QuerySpec struct {
+	Version int                 `json:"version"`
+	Columns []string            `json:"columns,omitempty"`
+	Filters map[string][]string `json:"filters,omitempty"`
+	Sort    []string            `json:"sort,omitempty"`
+	Page    QuerySpecPage       `json:"page"`
+}
+
+// QuerySpecPage is the page of a QuerySpec.
+type QuerySpecPage struct {
+	Size   uint32 `json:"size,omitempty"`
+	Page   uint32 `json:"page,omitempty"`
+	Cursor string `json:"cursor,omitempty"`
+}
+
+// QuerySchema is what a QuerySpec may refer to: the filterable fields, and the columns which
+// can be selected and sorted by, by name.
+type QuerySchema struct {
+	Filters FilterSchema
+	// Columns maps the names of the columns to their SQL expressions, ie. "name" to "a.name".
+	Columns map[string]string
+}
+
+// MarshalJSON implements json.Marshaler, setting the version.
+func (s QuerySpec) MarshalJSON() ([]byte, error) {
+	type spec QuerySpec
+	s.Version = QuerySpecVersion
+	return json.Marshal(spec(s))
+}
+
+// ParseQuerySpec decodes a QuerySpec, rejecting the versions newer than QuerySpecVersion
+// and the unknown fields.
+func ParseQuerySpec(data []byte) (QuerySpec, error) {
+	type spec QuerySpec
+	var s spec
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&s); err != nil {
+		return QuerySpec{}, fmt.Errorf("pgkit: invalid query spec: %w", err)
+	}
+	if s.Version < 1 || s.Version > QuerySpecVersion {
+		return QuerySpec{}, fmt.Errorf("pgkit: unsupported query spec version %d", s.Version)
+	}
+	return QuerySpec(s), nil
+}
+
+// Validate checks the spec refers only to what the schema allows.
+func (s QuerySpec) Validate(schema QuerySchema) error {
+	_, _, err := s.Compile(schema, sq.Select())
+	return err
+}
+
+// Compile adds the selected columns, all the columns of the schema by default, and the
+// filters of the spec to q, and returns the page to pass to the paginator, with the sort.
+// Anything the schema doesn't allow is rejected, and the sort columns are the ones of the
+// schema, so the paginator doesn't need WithAllowedColumns.
+func (s QuerySpec) Compile(schema QuerySchema, q sq.SelectBuilder) (sq.SelectBuilder, *Page, error) {
+	names := s.Columns
+	if len(names) == 0 {
+		for name := range schema.Columns {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	for _, name := range names {
+		column, ok := schema.Columns[name]
+		if !ok {
+			return q, nil, fmt.Errorf("pgkit: unknown column %q", name)
+		}
+		if column != name {
+			column += " AS " + quoteIdent(name)
+		}
+		q = q.Column(column)
+	}
+
+	values := make(url.Values, len(s.Filters))
+	for name, list := range s.Filters {
+		if _, ok := schema.Filters[name]; !ok {
+			return q, nil, fmt.Errorf("pgkit: unknown filter field %q", name)
+		}
+		values[name] = list
+	}
+	filters, err := schema.Filters.FiltersFromValues(values)
+	if err != nil {
+		return q, nil, err
+	}
+	if len(filters) > 0 {
+		q = q.Where(filters)
+	}
+
+	page := &Page{Size: s.Page.Size, Page: s.Page.Page, Cursor: s.Page.Cursor}
+	for _, v := range s.Sort {
+		sort, ok := NewSort(v)
+		if !ok {
+			return q, nil, ErrInvalidSort{Column: v}
+		}
+		column, ok := schema.Columns[sort.Column]
+		if !ok {
+			return q, nil, ErrInvalidSort{Column: sort.Column}
+		}
+		sort.Column = column
+		page.Order = append(page.Order, sort)
+	}
+	return q, page, nil
+}
@@ -0,0 +1,58 @@
+package pgkit_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/goware/pgkit/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuerySpec(t *testing.T) {
+	spec := pgkit.QuerySpec{
+		Columns: []string{"id", "name"},
+		Filters: map[string][]string{"status": {"eq:active"}, "created_at": {"gte:2024-01-01"}},
+		Sort:    []string{"-name", "id"},
+		Page:    pgkit.QuerySpecPage{Size: 20, Page: 2},
+	}
+	data, err := json.Marshal(spec)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"version": 1, "columns": ["id", "name"], "filters": {"created_at": ["gte:2024-01-01"], "status": ["eq:active"]},
+		"sort": ["-name", "id"], "page": {"size": 20, "page": 2}}`, string(data))
+
+	parsed, err := pgkit.ParseQuerySpec(data)
+	require.NoError(t, err)
+
+	schema := pgkit.QuerySchema{
+		Filters: pgkit.FilterSchema{
+			"status":     {Column: "a.status"},
+			"created_at": {Column: "a.created_at", Type: pgkit.FilterTime},
+		},
+		Columns: map[string]string{"id": "a.id", "name": "a.name", "email": "a.email"},
+	}
+	require.NoError(t, parsed.Validate(schema))
+
+	q, page, err := parsed.Compile(schema, sq.Select().From("accounts a"))
+	require.NoError(t, err)
+	_, query := pgkit.NewPaginator[T]().PrepareQuery(q, page)
+	sql, args, err := query.ToSql()
+	require.NoError(t, err)
+	require.Equal(t, `SELECT a.id AS "id", a.name AS "name" FROM accounts a WHERE (a.created_at >= ? AND a.status = ?) ORDER BY a.name DESC, a.id ASC LIMIT 21 OFFSET 20`, sql)
+	require.Len(t, args, 2)
+
+	for _, invalid := range []pgkit.QuerySpec{
+		{Columns: []string{"password"}},
+		{Filters: map[string][]string{"password": {"eq:x"}}},
+		{Filters: map[string][]string{"created_at": {"like:x"}}},
+		{Sort: []string{"email:nullsmiddle"}},
+		{Sort: []string{"password"}},
+	} {
+		require.Error(t, invalid.Validate(schema))
+	}
+
+	_, err = pgkit.ParseQuerySpec([]byte(`{"version": 2}`))
+	require.Error(t, err)
+	_, err = pgkit.ParseQuerySpec([]byte(`{"version": 1, "sql": "DROP TABLE accounts"}`))
+	require.Error(t, err)
+}
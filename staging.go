@@ -0,0 +1,76 @@
+package pgkit
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
+)
+
+// StageThreshold is the number of keys above which InKeys stages them in a temporary
+// table instead of binding them as an array, see InKeysWithThreshold.
+const StageThreshold = 10000
+
+var stageSeq uint64
+
+// InKeys returns a predicate matching column against keys, see InKeysWithThreshold, staging
+// more than StageThreshold keys.
+func InKeys[K any](ctx context.Context, tx pgx.Tx, column string, keys []K) (sq.Sqlizer, error) {
+	return InKeysWithThreshold(ctx, tx, column, keys, StageThreshold)
+}
+
+// InKeysWithThreshold returns a predicate matching column against keys. Up to threshold keys
+// are bound as a single array (see Any), larger lists are copied into a temporary table with
+// StageKeys and matched with `column IN (SELECT key FROM ...)`. The temporary table is
+// dropped on commit, so the predicate must be used within tx.
+func InKeysWithThreshold[K any](ctx context.Context, tx pgx.Tx, column string, keys []K, threshold int) (sq.Sqlizer, error) {
+	if len(keys) <= threshold {
+		return Any(keys).Eq(column), nil
+	}
+	keyType, err := pgTypeOf(reflect.TypeOf(keys).Elem())
+	if err != nil {
+		return nil, err
+	}
+	table, err := StageKeys(ctx, tx, keyType, keys)
+	if err != nil {
+		return nil, err
+	}
+	return sq.Expr(fmt.Sprintf("%s IN (SELECT key FROM %s)", column, table)), nil
+}
+
+// StageKeys creates a temporary table with a single `key` column of the given postgres
+// type, dropped on commit, and copies the keys into it. It returns the table name.
+func StageKeys[K any](ctx context.Context, tx pgx.Tx, keyType string, keys []K) (string, error) {
+	table := fmt.Sprintf("pgkit_keys_%d", atomic.AddUint64(&stageSeq, 1))
+
+	_, err := tx.Exec(ctx, fmt.Sprintf(`CREATE TEMPORARY TABLE %s (key %s NOT NULL) ON COMMIT DROP`, table, keyType))
+	if err != nil {
+		return "", wrapErr(err)
+	}
+	_, err = tx.CopyFrom(ctx, pgx.Identifier{table}, []string{"key"}, pgx.CopyFromSlice(len(keys), func(i int) ([]interface{}, error) {
+		return []interface{}{keys[i]}, nil
+	}))
+	if err != nil {
+		return "", wrapErr(err)
+	}
+	// give the planner row estimates for the join
+	if _, err := tx.Exec(ctx, `ANALYZE `+table); err != nil {
+		return "", wrapErr(err)
+	}
+	return table, nil
+}
+
+func pgTypeOf(t reflect.Type) (string, error) {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int64, reflect.Uint32:
+		return "bigint", nil
+	case reflect.Int32, reflect.Int16, reflect.Int8, reflect.Uint16, reflect.Uint8:
+		return "integer", nil
+	case reflect.String:
+		return "text", nil
+	}
+	return "", fmt.Errorf("pgkit: can't infer postgres type of %s keys, use StageKeys", t)
+}
@@ -0,0 +1,152 @@
+package pgkit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// ErrInvalidSort is returned in strict mode for a sort which can't be parsed, or whose
+// column isn't allowed, see WithAllowedColumns.
+type ErrInvalidSort struct {
+	Column string
+}
+
+func (e ErrInvalidSort) Error() string {
+	return fmt.Sprintf("pgkit: invalid sort %q", e.Column)
+}
+
+// ErrPageSizeExceeded is returned in strict mode for a page size above the max size.
+type ErrPageSizeExceeded struct {
+	Max uint32
+}
+
+func (e ErrPageSizeExceeded) Error() string {
+	return fmt.Sprintf("pgkit: page size exceeds the max of %d", e.Max)
+}
+
+// ErrPageOutOfRange is returned by Query for a page past the last one, see WithOutOfRange.
+type ErrPageOutOfRange struct {
+	Page, TotalPages uint32
+}
+
+func (e ErrPageOutOfRange) Error() string {
+	return fmt.Sprintf("pgkit: page %d is out of range, there are %d pages", e.Page, e.TotalPages)
+}
+
+// OutOfRangePolicy is what Query does with a page past the last one, see WithOutOfRange.
+type OutOfRangePolicy int
+
+const (
+	// OutOfRangeEmpty returns an empty page, unless the paginator is strict, see WithStrict.
+	OutOfRangeEmpty OutOfRangePolicy = iota
+	// OutOfRangeError fails with ErrPageOutOfRange.
+	OutOfRangeError
+	// OutOfRangeClamp returns the last page instead, the page number is updated.
+	OutOfRangeClamp
+)
+
+// WithOutOfRange sets what Query does when a page other than the first one has no rows
+// because it's past the last one, rather than empty because of the filters. The pages
+// without rows are counted to tell them apart, unless the total is already counted, see
+// WithTotalCount.
+func WithOutOfRange(policy OutOfRangePolicy) func(*PaginatorOption) {
+	return func(o *PaginatorOption) { o.outOfRange = policy }
+}
+
+// checkRange checks the rows of the page aren't missing because it's out of range. It
+// returns true when the page was clamped to the last one, and must be queried again.
+func (p Paginator[T]) checkRange(ctx context.Context, querier *Querier, query sq.SelectBuilder, result []T, page *Page) (bool, error) {
+	policy := p.outOfRange
+	if policy == OutOfRangeEmpty && p.strict {
+		policy = OutOfRangeError
+	}
+	if policy == OutOfRangeEmpty || len(result) > 0 || p.offset(page) == 0 {
+		return false, nil
+	}
+	// the inline count is missing without rows
+	if !p.totalCount || p.inlineCount {
+		var total uint64
+		if err := p.observe(querier, page, true).GetOne(ctx, p.PrepareCountQuery(query), &total); err != nil {
+			return false, err
+		}
+		p.setTotal(page, total)
+	}
+	if page.Total > p.offset(page) {
+		return false, nil
+	}
+	if policy == OutOfRangeError {
+		return false, ErrPageOutOfRange{Page: page.Page, TotalPages: page.TotalPages}
+	}
+	page.Page = page.TotalPages
+	if page.Page == 0 {
+		page.Page = 1
+	}
+	return true, nil
+}
+
+// WithStrict rejects the pages the paginator would otherwise fix silently: a size above the
+// max one, which is clamped, and a sort which can't be parsed or isn't allowed, which is
+// dropped. The queries prepared by PrepareQuery fail with ErrPageSizeExceeded or
+// ErrInvalidSort, which PrepareQueryE returns directly. Query also fails with
+// ErrPageOutOfRange for a page past the last one, unless it's clamped, see WithOutOfRange.
+func WithStrict() func(*PaginatorOption) {
+	return func(o *PaginatorOption) { o.strict = true }
+}
+
+// PrepareQueryE is like PrepareQuery, but it checks the page first, as with WithStrict, and
+// returns the error instead of a failing query, so it can be reported to the client, ie.
+// with a 400 status. The max offset is checked too, see WithMaxOffset.
+func (p Paginator[T]) PrepareQueryE(q sq.SelectBuilder, page *Page, options ...func(*PaginatorOption)) ([]T, sq.SelectBuilder, error) {
+	p = p.with(options)
+	if err := p.validate(page); err != nil {
+		return nil, q, err
+	}
+	if page != nil && p.maxOffset > 0 {
+		checked := *page
+		p.setDefaults(&checked)
+		if p.offset(&checked) > p.maxOffset {
+			return nil, q, ErrOffsetTooDeep
+		}
+	}
+	result, q := p.PrepareQuery(q, page)
+	return result, q, nil
+}
+
+// validate checks the size and the sort of the page, before they're fixed by setDefaults
+// and getSort.
+func (o PaginatorOption) validate(page *Page) error {
+	if page == nil {
+		return nil
+	}
+	if max := o.getMaxSize(); page.Size > max {
+		return ErrPageSizeExceeded{Max: max}
+	}
+	sort := page.Order
+	if len(sort) == 0 && page.Column != "" {
+		for _, part := range strings.Split(page.Column, ",") {
+			s, ok := NewSort(part)
+			if !ok {
+				return ErrInvalidSort{Column: part}
+			}
+			sort = append(sort, s)
+		}
+	}
+	for _, s := range sort {
+		if s.Column == "" || !_MatcherOrderBy.MatchString(s.Column) {
+			return ErrInvalidSort{Column: s.Column}
+		}
+		if o.allowedColumns == nil {
+			continue
+		}
+		if _, ok := o.sortExpressions[s.Column]; ok {
+			continue
+		}
+		if _, ok := o.allowedColumns[s.Column]; !ok {
+			return ErrInvalidSort{Column: s.Column}
+		}
+	}
+	return nil
+}
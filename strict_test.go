@@ -0,0 +1,46 @@
+package pgkit_test
+
+import (
+	"errors"
+	"testing"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/goware/pgkit/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStrict(t *testing.T) {
+	paginator := pgkit.NewPaginator[T](pgkit.WithSort("id"), pgkit.WithMaxSize(20), pgkit.WithAllowedColumns("id", "name"))
+	q := sq.Select("*").From("t")
+
+	_, query, err := paginator.PrepareQueryE(q, &pgkit.Page{Size: 20, Column: "-name,id"})
+	require.NoError(t, err)
+	sql, _, err := query.ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "SELECT * FROM t ORDER BY name DESC, id ASC LIMIT 21 OFFSET 0", sql)
+
+	_, _, err = paginator.PrepareQueryE(q, &pgkit.Page{Size: 21})
+	require.Equal(t, pgkit.ErrPageSizeExceeded{Max: 20}, err)
+
+	_, _, err = paginator.PrepareQueryE(q, &pgkit.Page{Column: "email"})
+	require.Equal(t, pgkit.ErrInvalidSort{Column: "email"}, err)
+	require.EqualError(t, err, `pgkit: invalid sort "email"`)
+
+	_, _, err = paginator.PrepareQueryE(q, &pgkit.Page{Column: "name:nullsmiddle"})
+	require.Equal(t, pgkit.ErrInvalidSort{Column: "name:nullsmiddle"}, err)
+
+	_, _, err = paginator.PrepareQueryE(q, &pgkit.Page{Page: 100}, pgkit.WithMaxOffset(100))
+	require.ErrorIs(t, err, pgkit.ErrOffsetTooDeep)
+
+	// not strict, fixed silently
+	_, query = paginator.PrepareQuery(q, &pgkit.Page{Size: 21, Column: "email"})
+	sql, _, err = query.ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "SELECT * FROM t ORDER BY id ASC LIMIT 21 OFFSET 0", sql)
+
+	_, query = paginator.PrepareQuery(q, &pgkit.Page{Size: 21, Column: "email"}, pgkit.WithStrict())
+	_, _, err = query.ToSql()
+	var exceeded pgkit.ErrPageSizeExceeded
+	require.True(t, errors.As(err, &exceeded))
+	require.Equal(t, uint32(20), exceeded.Max)
+}
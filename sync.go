@@ -0,0 +1,147 @@
+package pgkit
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// SyncPaginator pages the rows changed since a sync token, ordered by (updated_at, id),
+// together with the tombstones of the rows deleted since then, for offline-first clients
+// which keep a local copy of a table. T is the row type and K the type of its id.
+type SyncPaginator[T any, K any] struct {
+	// UpdatedAtColumn and IDColumn define the sync order, defaults to "updated_at" and "id".
+	UpdatedAtColumn string
+	IDColumn        string
+	// DeletionsTable holds the tombstones, with the `id` of the deleted row and its `deleted_at` time.
+	DeletionsTable string
+	// Size is the maximum number of rows and of tombstones returned per call, defaults to DefaultPageSize.
+	Size uint32
+	// Lag excludes the rows changed in the last Lag, as a row's updated_at is usually set when
+	// its transaction starts, a long transaction could commit a change behind the token.
+	Lag time.Duration
+	// Key returns the sync position of a row, it's required.
+	Key func(row T) (updatedAt time.Time, id K)
+}
+
+// SyncResult is a page of changes returned by SyncPaginator.
+type SyncResult[T any, K any] struct {
+	Items   []T
+	Deleted []K
+	// Token is passed to the next call to continue from this point.
+	Token string
+	// More is true when there are more changes to fetch right away.
+	More bool
+}
+
+type syncPosition[K any] struct {
+	UpdatedAt time.Time `json:"u"`
+	ID        K         `json:"i"`
+}
+
+type syncToken[K any] struct {
+	Items   *syncPosition[K] `json:"it,omitempty"`
+	Deleted *syncPosition[K] `json:"de,omitempty"`
+}
+
+// Sync returns the changes of the rows selected by q since token, an empty token returns
+// all rows. q must not have an ORDER BY, LIMIT or OFFSET clause.
+func (p SyncPaginator[T, K]) Sync(ctx context.Context, querier *Querier, q sq.SelectBuilder, token string) (SyncResult[T, K], error) {
+	var result SyncResult[T, K]
+	if p.Key == nil {
+		return result, fmt.Errorf("pgkit: sync paginator without a Key func")
+	}
+
+	pos, err := decodeSyncToken[K](token)
+	if err != nil {
+		return result, err
+	}
+
+	updatedAt, id := p.UpdatedAtColumn, p.IDColumn
+	if updatedAt == "" {
+		updatedAt = "updated_at"
+	}
+	if id == "" {
+		id = "id"
+	}
+	size := uint64(p.Size)
+	if size == 0 {
+		size = DefaultPageSize
+	}
+
+	// changed rows
+	q = q.OrderBy(updatedAt, id).Limit(size + 1)
+	if pos.Items != nil {
+		q = q.Where(sq.Expr(fmt.Sprintf("(%s, %s) > (?, ?)", updatedAt, id), pos.Items.UpdatedAt, pos.Items.ID))
+	}
+	if p.Lag > 0 {
+		q = q.Where(sq.Expr(fmt.Sprintf("%s < now() - make_interval(secs => ?)", updatedAt), p.Lag.Seconds()))
+	}
+	if err := querier.GetAll(ctx, q, &result.Items); err != nil {
+		return result, err
+	}
+	if len(result.Items) > int(size) {
+		result.Items, result.More = result.Items[:size], true
+	}
+	if n := len(result.Items); n > 0 {
+		t, k := p.Key(result.Items[n-1])
+		pos.Items = &syncPosition[K]{UpdatedAt: t, ID: k}
+	}
+
+	// tombstones
+	if p.DeletionsTable != "" {
+		dq := querier.SQL.Select("id", "deleted_at").From(p.DeletionsTable).OrderBy("deleted_at", "id").Limit(size + 1)
+		if pos.Deleted != nil {
+			dq = dq.Where(sq.Expr("(deleted_at, id) > (?, ?)", pos.Deleted.UpdatedAt, pos.Deleted.ID))
+		}
+		if p.Lag > 0 {
+			dq = dq.Where(sq.Expr("deleted_at < now() - make_interval(secs => ?)", p.Lag.Seconds()))
+		}
+		var deleted []struct {
+			ID        K         `db:"id"`
+			DeletedAt time.Time `db:"deleted_at"`
+		}
+		if err := querier.GetAll(ctx, dq, &deleted); err != nil {
+			return result, err
+		}
+		if len(deleted) > int(size) {
+			deleted, result.More = deleted[:size], true
+		}
+		for _, d := range deleted {
+			result.Deleted = append(result.Deleted, d.ID)
+		}
+		if n := len(deleted); n > 0 {
+			pos.Deleted = &syncPosition[K]{UpdatedAt: deleted[n-1].DeletedAt, ID: deleted[n-1].ID}
+		}
+	}
+
+	result.Token, err = encodeSyncToken(pos)
+	return result, err
+}
+
+func encodeSyncToken[K any](t syncToken[K]) (string, error) {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return "", wrapErr(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeSyncToken[K any](token string) (syncToken[K], error) {
+	var t syncToken[K]
+	if token == "" {
+		return t, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return t, fmt.Errorf("pgkit: invalid sync token: %w", err)
+	}
+	if err := json.Unmarshal(data, &t); err != nil {
+		return t, fmt.Errorf("pgkit: invalid sync token: %w", err)
+	}
+	return t, nil
+}
@@ -0,0 +1,46 @@
+package pgkit_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/goware/pgkit/v2"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+)
+
+// argsExecutor records the query it's sent and its args, and fails it.
+type argsExecutor struct {
+	sleepExecutor
+	sql  *string
+	args *[]interface{}
+}
+
+func (e argsExecutor) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	*e.sql, *e.args = sql, args
+	return nil, errors.New("no database")
+}
+
+func TestSyncPaginator(t *testing.T) {
+	ctx := context.Background()
+	var (
+		sql  string
+		args []interface{}
+	)
+	querier := pgkit.NewQuerier(argsExecutor{sql: &sql, args: &args})
+	q := querier.SQL.Select("*").From("accounts")
+
+	paginator := pgkit.SyncPaginator[T, int64]{Lag: 1500 * time.Millisecond}
+	_, err := paginator.Sync(ctx, querier, q, "")
+	require.ErrorContains(t, err, "Key")
+	require.Empty(t, sql)
+
+	// the lag is sent in seconds
+	paginator.Key = func(T) (time.Time, int64) { return time.Time{}, 0 }
+	_, err = paginator.Sync(ctx, querier, q, "")
+	require.Error(t, err)
+	require.Equal(t, "SELECT * FROM accounts WHERE updated_at < now() - make_interval(secs => $1) ORDER BY updated_at, id LIMIT 11", sql)
+	require.Equal(t, []interface{}{1.5}, args)
+}
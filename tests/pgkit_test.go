@@ -3,11 +3,18 @@ package pgkit_test
 import (
 	"context"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	mrand "math/rand"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"sort"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -15,7 +22,11 @@ import (
 	"github.com/georgysavva/scany/v2/pgxscan"
 	"github.com/goware/pgkit/v2"
 	"github.com/goware/pgkit/v2/dbtype"
+	"github.com/goware/pgkit/v2/pgkittest"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -793,3 +804,2174 @@ func hexEncode(b []byte) string {
 	hex.Encode(enc[0:], b)
 	return string(enc)
 }
+
+func TestInKeysStaging(t *testing.T) {
+	ctx := context.Background()
+	truncateTable(t, "accounts")
+
+	queries := pgkit.Queries{}
+	for i := 0; i < 10; i++ {
+		queries.Add(DB.SQL.InsertRecord(&Account{Name: fmt.Sprintf("user-%d", i)}))
+	}
+	_, err := DB.Query.BatchExec(ctx, queries)
+	require.NoError(t, err)
+
+	names := []string{"user-1", "user-3", "user-5", "missing"}
+
+	err = pgx.BeginFunc(ctx, DB.Conn, func(tx pgx.Tx) error {
+		cond, err := pgkit.InKeysWithThreshold(ctx, tx, "name", names, 2)
+		if err != nil {
+			return err
+		}
+		var accounts []*Account
+		err = DB.TxQuery(tx).GetAll(ctx, DB.SQL.Select("*").From("accounts").Where(cond).OrderBy("name"), &accounts)
+		if err != nil {
+			return err
+		}
+		require.Len(t, accounts, 3)
+		assert.Equal(t, "user-1", accounts[0].Name)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestAnalyzeAfter(t *testing.T) {
+	ctx := context.Background()
+	_, err := DB.Conn.Exec(ctx, `
+		DROP TABLE IF EXISTS analyze_items;
+		CREATE TABLE analyze_items (id int PRIMARY KEY);
+		INSERT INTO analyze_items SELECT generate_series(1, 100);`)
+	require.NoError(t, err)
+	t.Cleanup(func() { DB.Conn.Exec(ctx, `DROP TABLE analyze_items`) })
+
+	reltuples := func() float64 {
+		var n float64
+		require.NoError(t, DB.Conn.QueryRow(ctx, `SELECT reltuples FROM pg_class WHERE oid = 'analyze_items'::regclass`).Scan(&n))
+		return n
+	}
+	assert.LessOrEqual(t, reltuples(), float64(0))
+	require.NoError(t, pgkit.AnalyzeAfter(ctx, DB, "analyze_items"))
+	assert.Equal(t, float64(100), reltuples())
+
+	// copies above the threshold are analyzed
+	rows := make([][]interface{}, 20)
+	for i := range rows {
+		rows[i] = []interface{}{1000 + i}
+	}
+	n, err := DB.Query.WithAnalyzeThreshold(10).CopyFrom(ctx, "analyze_items", []string{"id"}, pgx.CopyFromRows(rows))
+	require.NoError(t, err)
+	assert.Equal(t, int64(20), n)
+	assert.Equal(t, float64(120), reltuples())
+
+	// and the others aren't
+	for i := range rows {
+		rows[i] = []interface{}{2000 + i}
+	}
+	_, err = DB.Query.CopyFrom(ctx, "analyze_items", []string{"id"}, pgx.CopyFromRows(rows))
+	require.NoError(t, err)
+	assert.Equal(t, float64(120), reltuples())
+}
+
+func TestTruncate(t *testing.T) {
+	ctx := context.Background()
+	_, err := DB.Conn.Exec(ctx, `
+		DROP TABLE IF EXISTS truncate_children, truncate_parents;
+		CREATE TABLE truncate_parents (id SERIAL PRIMARY KEY);
+		CREATE TABLE truncate_children (id int PRIMARY KEY, parent_id int REFERENCES truncate_parents (id));
+		INSERT INTO truncate_parents DEFAULT VALUES;
+		INSERT INTO truncate_children VALUES (1, 1);`)
+	require.NoError(t, err)
+	t.Cleanup(func() { DB.Conn.Exec(ctx, `DROP TABLE truncate_children, truncate_parents`) })
+
+	count := func(table string) int {
+		var n int
+		require.NoError(t, DB.Conn.QueryRow(ctx, `SELECT count(*) FROM `+table).Scan(&n))
+		return n
+	}
+
+	err = pgkit.Truncate(ctx, DB, "truncate_parents")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "truncate_children")
+	assert.Equal(t, 1, count("truncate_parents"))
+
+	// the referencing table is listed last, and truncated first
+	require.NoError(t, pgkit.TruncateWithOptions(ctx, DB, pgkit.TruncateOptions{RestartIdentity: true}, "truncate_parents", "truncate_children"))
+	assert.Equal(t, 0, count("truncate_parents"))
+	assert.Equal(t, 0, count("truncate_children"))
+
+	var id int
+	require.NoError(t, DB.Conn.QueryRow(ctx, `INSERT INTO truncate_parents DEFAULT VALUES RETURNING id`).Scan(&id))
+	assert.Equal(t, 1, id)
+	_, err = DB.Conn.Exec(ctx, `INSERT INTO truncate_children VALUES (1, 1)`)
+	require.NoError(t, err)
+
+	require.NoError(t, pgkit.TruncateWithOptions(ctx, DB, pgkit.TruncateOptions{Cascade: true}, "truncate_parents"))
+	assert.Equal(t, 0, count("truncate_children"))
+}
+
+func TestDatabases(t *testing.T) {
+	ctx := context.Background()
+	name := fmt.Sprintf("pgkit_test_db_%d", time.Now().UnixNano())
+	clone := name + "_clone"
+	t.Cleanup(func() {
+		pgkit.DropDatabase(ctx, DB, clone, true)
+		pgkit.DropDatabase(ctx, DB, name, true)
+	})
+
+	require.NoError(t, pgkit.CreateDatabase(ctx, DB, name, ""))
+	exists, err := pgkit.DatabaseExists(ctx, DB, name)
+	require.NoError(t, err)
+	assert.True(t, exists)
+	require.Error(t, pgkit.CreateDatabase(ctx, DB, name, ""))
+
+	require.NoError(t, pgkit.CreateDatabase(ctx, DB, clone, name))
+	exists, err = pgkit.DatabaseExists(ctx, DB, clone)
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	require.NoError(t, pgkit.DropDatabase(ctx, DB, clone, true))
+	require.NoError(t, pgkit.DropDatabase(ctx, DB, clone, true))
+	exists, err = pgkit.DatabaseExists(ctx, DB, clone)
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	// a database of its own, dropped when the test ends
+	var dbName string
+	t.Run("NewDB", func(t *testing.T) {
+		db := pgkittest.NewDB(t, DB, pgkit.Config{
+			Host:     "localhost",
+			Username: "postgres",
+			Password: "postgres",
+		}, name)
+		require.NoError(t, db.Conn.QueryRow(ctx, `SELECT current_database()`).Scan(&dbName))
+	})
+	assert.True(t, strings.HasPrefix(dbName, name+"_"))
+	exists, err = pgkit.DatabaseExists(ctx, DB, dbName)
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestMissingKeys(t *testing.T) {
+	ctx := context.Background()
+	truncateTable(t, "accounts")
+
+	_, err := DB.Query.Exec(ctx, DB.SQL.InsertRecords([]*Account{{Name: "peter"}, {Name: "mario"}}))
+	require.NoError(t, err)
+
+	missing, err := pgkit.MissingKeys(ctx, DB.Query, "accounts", "name", []string{"zelda", "peter", "link", "mario"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"zelda", "link"}, missing)
+
+	// a mixed case name addresses the same table in both queries
+	_, err = DB.Conn.Exec(ctx, `
+		DROP TABLE IF EXISTS "KeyItems";
+		CREATE TABLE "KeyItems" (id int PRIMARY KEY);
+		INSERT INTO "KeyItems" VALUES (1), (3);`)
+	require.NoError(t, err)
+	t.Cleanup(func() { DB.Conn.Exec(ctx, `DROP TABLE "KeyItems"`) })
+	ids, err := pgkit.MissingKeys(ctx, DB.Query, "KeyItems", "id", []int{1, 2, 3, 4})
+	require.NoError(t, err)
+	assert.Equal(t, []int{2, 4}, ids)
+}
+
+func TestImportCSV(t *testing.T) {
+	ctx := context.Background()
+	truncateTable(t, "accounts")
+
+	importer := pgkit.Importer[Account]{
+		Table: "accounts",
+		Validate: func(a Account) error {
+			if a.Name == "" {
+				return fmt.Errorf("name is required")
+			}
+			return nil
+		},
+	}
+
+	csv := "name,disabled\npeter,false\n,true\nmario,notabool\nzelda,true\n"
+	report, err := importer.ImportCSV(ctx, DB, strings.NewReader(csv))
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Imported)
+	require.Len(t, report.Errors, 2)
+	assert.Equal(t, 3, report.Errors[0].Line)
+	assert.Equal(t, 4, report.Errors[1].Line)
+
+	var accounts []*Account
+	err = DB.Query.GetAll(ctx, DB.SQL.Select("*").From("accounts").OrderBy("name"), &accounts)
+	require.NoError(t, err)
+	require.Len(t, accounts, 2)
+	assert.True(t, accounts[1].Disabled)
+
+	// the constraint violations reject their record, and the upsert updates the others
+	_, err = DB.Conn.Exec(ctx, `
+		DROP TABLE IF EXISTS import_items;
+		CREATE TABLE import_items (id int PRIMARY KEY, name text NOT NULL CHECK (length(name) < 5));
+		INSERT INTO import_items VALUES (1, 'joe');`)
+	require.NoError(t, err)
+	t.Cleanup(func() { DB.Conn.Exec(ctx, `DROP TABLE import_items`) })
+	type item struct {
+		ID   int    `db:"id"`
+		Name string `db:"name"`
+	}
+	items := pgkit.Importer[item]{Table: "import_items", Conflict: []string{"id"}}
+	report, err = items.ImportCSV(ctx, DB, strings.NewReader("id,name\n1,ann\n2,toolong\n3,bob\n"))
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Imported)
+	require.Len(t, report.Errors, 1)
+	assert.Equal(t, 3, report.Errors[0].Line)
+	var names []string
+	require.NoError(t, pgxscan.Select(ctx, DB.Conn, &names, `SELECT name FROM import_items ORDER BY id`))
+	assert.Equal(t, []string{"ann", "bob"}, names)
+
+	// the other errors abort the import
+	items.Table = "import_missing"
+	report, err = items.ImportCSV(ctx, DB, strings.NewReader("id,name\n4,dan\n"))
+	require.Error(t, err)
+	assert.Empty(t, report.Errors)
+}
+
+func TestSearchIndex(t *testing.T) {
+	ctx := context.Background()
+	_, err := DB.Conn.Exec(ctx, `
+		DROP TABLE IF EXISTS search_docs, search_posts;
+		CREATE TABLE search_posts (id int PRIMARY KEY, title text NOT NULL, body text);
+		INSERT INTO search_posts VALUES (1, 'hello world', 'first post');`)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		DB.Conn.Exec(ctx, `DROP TABLE search_docs, search_posts; DROP FUNCTION IF EXISTS pgkit_search_search_posts()`)
+	})
+
+	index := pgkit.SearchIndex{Table: "search_docs"}
+	source := pgkit.SearchSource{Kind: "post", Table: "search_posts", TitleColumn: "title", Columns: []string{"title", "body"}}
+	require.NoError(t, index.Install(ctx, DB))
+	require.NoError(t, index.Install(ctx, DB))
+	require.NoError(t, index.InstallSource(ctx, DB, source))
+
+	search := func(text string, kinds ...string) []string {
+		docs, err := pgkit.NewSearchPaginator(index).Search(ctx, DB.Query, text, nil, kinds...)
+		require.NoError(t, err)
+		refs := []string{}
+		for _, d := range docs {
+			refs = append(refs, d.Ref)
+		}
+		return refs
+	}
+
+	// existing rows are only indexed by Reindex
+	assert.Empty(t, search("hello"))
+	require.NoError(t, index.Reindex(ctx, DB, source))
+	assert.Equal(t, []string{"1"}, search("hello"))
+
+	// the changes are indexed by the trigger, the title match ranks first
+	_, err = DB.Conn.Exec(ctx, `INSERT INTO search_posts VALUES (2, 'second post', 'hello again')`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1", "2"}, search("hello"))
+	assert.Equal(t, []string{"1", "2"}, search("hello", "post"))
+	assert.Empty(t, search("hello", "comment"))
+
+	_, err = DB.Conn.Exec(ctx, `UPDATE search_posts SET title = 'goodbye' WHERE id = 1`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2"}, search("hello"))
+	_, err = DB.Conn.Exec(ctx, `DELETE FROM search_posts WHERE id = 2`)
+	require.NoError(t, err)
+	assert.Empty(t, search("hello"))
+	assert.Equal(t, []string{"1"}, search("goodbye"))
+}
+
+func TestCounters(t *testing.T) {
+	ctx := context.Background()
+	_, err := DB.Conn.Exec(ctx, `
+		DROP TABLE IF EXISTS counter_comments, counter_posts;
+		CREATE TABLE counter_posts (id int PRIMARY KEY, comment_count int NOT NULL DEFAULT 0);
+		CREATE TABLE counter_comments (id int PRIMARY KEY, post int REFERENCES counter_posts (id));
+		INSERT INTO counter_posts (id) VALUES (1), (2);
+		INSERT INTO counter_comments VALUES (1, 1);`)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		DB.Conn.Exec(ctx, `DROP TABLE counter_comments, counter_posts; DROP FUNCTION IF EXISTS pgkit_counter_counter_comments_comment_count()`)
+	})
+
+	spec := pgkit.CounterSpec{Parent: "counter_posts", Child: "counter_comments", Column: "comment_count"}
+	counts := func() []int {
+		var n []int
+		require.NoError(t, pgxscan.Select(ctx, DB.Conn, &n, `SELECT comment_count FROM counter_posts ORDER BY id`))
+		return n
+	}
+
+	// the foreign key is looked up, or must be set
+	require.Error(t, pgkit.InstallCounter(ctx, DB, pgkit.CounterSpec{Parent: "counter_comments", Child: "counter_posts", Column: "id"}))
+	require.NoError(t, pgkit.InstallCounter(ctx, DB, spec))
+	require.NoError(t, pgkit.InstallCounter(ctx, DB, spec))
+	fixed, err := pgkit.Recount(ctx, DB, spec)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), fixed)
+	assert.Equal(t, []int{1, 0}, counts())
+
+	_, err = DB.Conn.Exec(ctx, `INSERT INTO counter_comments VALUES (2, 1), (3, 2), (4, NULL)`)
+	require.NoError(t, err)
+	assert.Equal(t, []int{2, 1}, counts())
+
+	// re-parented
+	_, err = DB.Conn.Exec(ctx, `UPDATE counter_comments SET post = 2 WHERE id = 1`)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, counts())
+	_, err = DB.Conn.Exec(ctx, `UPDATE counter_comments SET post = 1 WHERE id = 4`)
+	require.NoError(t, err)
+	assert.Equal(t, []int{2, 2}, counts())
+
+	_, err = DB.Conn.Exec(ctx, `DELETE FROM counter_comments WHERE id IN (1, 2)`)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 1}, counts())
+
+	// a drift is fixed
+	_, err = DB.Conn.Exec(ctx, `UPDATE counter_posts SET comment_count = 5 WHERE id = 2`)
+	require.NoError(t, err)
+	fixed, err = pgkit.Recount(ctx, DB, spec)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), fixed)
+	assert.Equal(t, []int{1, 1}, counts())
+}
+
+func TestReadTx(t *testing.T) {
+	ctx := context.Background()
+	truncateTable(t, "accounts")
+
+	_, err := DB.Query.Exec(ctx, DB.SQL.InsertRecords([]*Account{{Name: "peter"}, {Name: "mario"}}))
+	require.NoError(t, err)
+
+	for _, options := range [][]func(*pgx.TxOptions){nil, {pgkit.Deferrable}} {
+		err = pgkit.ReadTx(ctx, DB, func(q *pgkit.Querier) error {
+			var before []*Account
+			require.NoError(t, q.GetAll(ctx, DB.SQL.Select("*").From("accounts"), &before))
+
+			// changes committed meanwhile are not visible
+			_, err := DB.Query.Exec(ctx, DB.SQL.InsertRecords([]*Account{{Name: "zelda"}}))
+			require.NoError(t, err)
+
+			var count int
+			require.NoError(t, q.GetOne(ctx, DB.SQL.Select("count(*)").From("accounts"), &count))
+			assert.Equal(t, len(before), count)
+
+			// writes are rejected
+			_, err = q.Exec(ctx, DB.SQL.InsertRecords([]*Account{{Name: "link"}}))
+			assert.Error(t, err)
+			return nil
+		}, options...)
+		require.NoError(t, err)
+	}
+}
+
+func TestExportSnapshot(t *testing.T) {
+	ctx := context.Background()
+	truncateTable(t, "accounts")
+
+	_, err := DB.Query.Exec(ctx, DB.SQL.InsertRecord(&Account{Name: "before"}))
+	require.NoError(t, err)
+
+	snapshot, err := pgkit.ExportSnapshot(ctx, DB)
+	require.NoError(t, err)
+	assert.NotEmpty(t, snapshot.ID)
+
+	_, err = DB.Query.Exec(ctx, DB.SQL.InsertRecord(&Account{Name: "after"}))
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	counts := make([]int, 3)
+	errs := make([]error, 3)
+	for i := range counts {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = pgkit.ReadTx(ctx, DB, func(q *pgkit.Querier) error {
+				return q.GetOne(ctx, DB.SQL.Select("count(*)").From("accounts"), &counts[i])
+			}, pgkit.WithSnapshot(snapshot.ID))
+		}(i)
+	}
+	wg.Wait()
+	for i := range counts {
+		require.NoError(t, errs[i])
+		assert.Equal(t, 1, counts[i])
+	}
+
+	require.NoError(t, snapshot.Release(ctx))
+	err = pgkit.ReadTx(ctx, DB, func(q *pgkit.Querier) error { return nil }, pgkit.WithSnapshot(snapshot.ID))
+	assert.Error(t, err)
+}
+
+func TestParallelScan(t *testing.T) {
+	ctx := context.Background()
+	_, err := DB.Conn.Exec(ctx, `
+		DROP TABLE IF EXISTS scan_items;
+		CREATE TABLE scan_items (id int PRIMARY KEY, payload text NOT NULL);
+		INSERT INTO scan_items SELECT i, repeat('x', 100) FROM generate_series(1, 100000) i;`)
+	require.NoError(t, err)
+	t.Cleanup(func() { DB.Conn.Exec(ctx, `DROP TABLE scan_items`) })
+
+	type Item struct {
+		ID      int    `db:"id"`
+		Payload string `db:"payload"`
+	}
+	var (
+		mu     sync.Mutex
+		seen   = make(map[int]bool)
+		chunks int
+	)
+	err = pgkit.ParallelScan(ctx, DB, "scan_items", 4, func(ctx context.Context, rows []Item) error {
+		mu.Lock()
+		defer mu.Unlock()
+		chunks++
+		for _, r := range rows {
+			seen[r.ID] = true
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Len(t, seen, 100000)
+	assert.Greater(t, chunks, 1)
+
+	errStop := errors.New("stop")
+	err = pgkit.ParallelScan(ctx, DB, "scan_items", 2, func(ctx context.Context, rows []Item) error {
+		return errStop
+	})
+	assert.ErrorIs(t, err, errStop)
+}
+
+func TestApplyConfig(t *testing.T) {
+	ctx := context.Background()
+	cfg := pgkit.Config{
+		Database: "pgkit_test",
+		Host:     "localhost",
+		Username: "postgres",
+		Password: "postgres",
+		MaxConns: 2,
+	}
+	db, err := pgkit.Connect("pgkit_test", cfg)
+	require.NoError(t, err)
+	defer func() { db.Pool().Close() }()
+
+	querier := db.Query
+	conn := db.Pool()
+
+	// same configuration, the pool is kept
+	require.NoError(t, db.ApplyConfig(ctx, cfg))
+	assert.Same(t, conn, db.Pool())
+
+	cfg.MaxConns = 8
+	require.NoError(t, db.ApplyConfig(ctx, cfg))
+	assert.NotSame(t, conn, db.Pool())
+	assert.Equal(t, int32(8), db.Pool().Config().MaxConns)
+
+	// existing queriers use the new pool
+	var n int
+	require.NoError(t, querier.GetOne(ctx, pgkit.RawQuery("SELECT 1").Build(), &n))
+	assert.Equal(t, 1, n)
+
+	cfg.ConnMaxLifetime = "invalid"
+	require.Error(t, db.ApplyConfig(ctx, cfg))
+}
+
+func TestLoadSheddingApplyConfig(t *testing.T) {
+	ctx := context.Background()
+	cfg := pgkit.Config{
+		Database: "pgkit_test",
+		Host:     "localhost",
+		Username: "postgres",
+		Password: "postgres",
+		MaxConns: 4,
+	}
+	db, err := pgkit.Connect("pgkit_test", cfg)
+	require.NoError(t, err)
+	defer func() { db.Conn.Close() }()
+
+	querier := db.Query.With(db.LoadShedding(pgkit.SheddingPolicy{MaxSaturation: 0.5, Interval: time.Nanosecond}))
+	low := pgkit.WithQueryConfig(ctx, pgkit.QueryConfig{Priority: pgkit.PriorityLow})
+
+	// a single connection, held, saturates the new pool
+	cfg.MaxConns = 1
+	require.NoError(t, db.ApplyConfig(ctx, cfg))
+	conn, err := db.Conn.Acquire(ctx)
+	require.NoError(t, err)
+
+	var n int
+	err = querier.GetOne(low, pgkit.RawQuery("SELECT 1").Build(), &n)
+	assert.ErrorIs(t, err, pgkit.ErrShedding)
+
+	conn.Release()
+	time.Sleep(time.Millisecond)
+	require.NoError(t, querier.GetOne(low, pgkit.RawQuery("SELECT 1").Build(), &n))
+	assert.Equal(t, 1, n)
+}
+
+func TestPreflight(t *testing.T) {
+	ctx := context.Background()
+
+	report := pgkit.Preflight(ctx, DB,
+		pgkit.CheckServerVersion(100000),
+		pgkit.CheckTables("accounts", "public.reviews"),
+		pgkit.CheckSortIndex("accounts", pgkit.NewPaginator[Account](pgkit.WithSort("-id")).Config()),
+		pgkit.CheckSortIndex("accounts", pgkit.NewPaginator[Account]().Config()),
+		pgkit.CheckIndex("accounts"),
+	)
+	require.NoError(t, report.Err())
+	assert.True(t, report.OK())
+	assert.Len(t, report.Results, 5)
+
+	// the index is scanned forward or backward, its directions must match the sort ones
+	_, err := DB.Conn.Exec(ctx, `
+		DROP TABLE IF EXISTS preflight_items;
+		CREATE TABLE preflight_items (a int, b int);
+		CREATE INDEX ON preflight_items (a, b DESC);`)
+	require.NoError(t, err)
+	t.Cleanup(func() { DB.Conn.Exec(ctx, `DROP TABLE preflight_items`) })
+	for sort, ok := range map[string]bool{
+		"a,-b":           true,
+		"-a,b":           true,
+		"a":              true,
+		"-a:nullsfirst":  true,
+		"a:nullsfirst":   false,
+		"a,b":            false,
+		"-a,-b":          false,
+		"a,-b:nullslast": false,
+	} {
+		check := pgkit.CheckSortIndex("preflight_items", pgkit.NewPaginator[Account](pgkit.WithSort(strings.Split(sort, ",")...)).Config())
+		assert.Equal(t, ok, pgkit.Preflight(ctx, DB, check).OK(), sort)
+	}
+
+	report = pgkit.Preflight(ctx, DB,
+		pgkit.CheckServerVersion(990000),
+		pgkit.CheckExtensions("pgkit_nonexistent"),
+		pgkit.CheckTables("accounts", "nonexistent"),
+		pgkit.CheckIndex("accounts", "name"),
+		pgkit.CheckRowSecurity("accounts"),
+		pgkit.CheckIndex("preflight_items", "b"),
+	)
+	assert.False(t, report.OK())
+	for _, res := range report.Results {
+		assert.Error(t, res.Err, res.Name)
+	}
+	assert.ErrorContains(t, report.Err(), "missing tables nonexistent")
+}
+
+func TestSupports(t *testing.T) {
+	ctx := context.Background()
+
+	version, err := DB.ServerVersion(ctx)
+	require.NoError(t, err)
+	assert.Greater(t, version, 100000)
+
+	ok, err := DB.Supports(ctx, pgkit.FeatureMultirange)
+	require.NoError(t, err)
+	assert.Equal(t, version >= 140000, ok)
+
+	_, err = DB.Supports(ctx, pgkit.Feature("time travel"))
+	assert.Error(t, err)
+}
+
+func TestCapabilities(t *testing.T) {
+	ctx := context.Background()
+
+	caps, err := DB.Capabilities(ctx)
+	require.NoError(t, err)
+	assert.True(t, caps.Has("plpgsql"))
+	assert.False(t, caps.Has("time_travel"))
+
+	search, err := pgkit.Search{Text: "jnae", Mode: pgkit.SearchTrigram, Columns: []string{"name"}}.Resolve(caps)
+	require.NoError(t, err)
+	if !caps.Has(pgkit.ExtensionTrigram) {
+		assert.Equal(t, pgkit.SearchILike, search.Mode)
+	}
+	_, err = DB.Query.Exec(ctx, DB.SQL.Select("id").From("accounts").Where(search))
+	require.NoError(t, err)
+}
+
+func TestJSONRows(t *testing.T) {
+	ctx := context.Background()
+	truncateTable(t, "logs")
+
+	items := []map[string]interface{}{{"sku": "a", "qty": 1}, {"sku": "b", "qty": 2}, {"sku": "c", "qty": 3}}
+	_, err := DB.Query.Exec(ctx, DB.SQL.Insert("logs").Columns("message", "etc").Values("order", map[string]interface{}{"items": items}))
+	require.NoError(t, err)
+
+	type item struct {
+		SKU string `db:"sku"`
+		Qty int    `db:"qty"`
+	}
+	q := DB.SQL.Select("i.*").From("logs l").JoinClause(pgkit.JSONRecordset("l.etc->'items'", "i", pgkit.JSONColumnsOf[item]()...).Join())
+
+	paginator := pgkit.NewPaginator[item](pgkit.WithSort("-qty"))
+	page := pgkit.NewPage(2, 1)
+	rows, err := paginator.Query(ctx, q, page, DB.Conn)
+	require.NoError(t, err)
+	assert.Equal(t, []item{{"c", 3}, {"b", 2}}, rows)
+	assert.True(t, page.More)
+
+	if ok, _ := DB.Supports(ctx, pgkit.FeatureJSONTable); ok {
+		var skus []string
+		q := DB.SQL.Select("i.sku").From("logs l").JoinClause(pgkit.JSONTable("l.etc", "$.items[*]", "i", pgkit.JSONColumn{Name: "sku", Type: "text"}).Join()).OrderBy("i.sku")
+		require.NoError(t, DB.Query.GetAll(ctx, q, &skus))
+		assert.Equal(t, []string{"a", "b", "c"}, skus)
+	}
+}
+
+func TestMultirange(t *testing.T) {
+	ctx := context.Background()
+	if ok, err := DB.Supports(ctx, pgkit.FeatureMultirange); err != nil || !ok {
+		t.Skip("multiranges require PostgreSQL 14")
+	}
+
+	_, err := DB.Conn.Exec(ctx, `
+		DROP TABLE IF EXISTS bookings;
+		CREATE TABLE bookings (room_id int NOT NULL, period int8range NOT NULL);
+		INSERT INTO bookings VALUES (1, '[1,3)'), (1, '[2,5)'), (1, '[7,8)'), (2, '[4,6)');`)
+	require.NoError(t, err)
+	t.Cleanup(func() { DB.Conn.Exec(ctx, `DROP TABLE bookings`) })
+
+	bookings := DB.SQL.Select("room_id", "period").From("bookings")
+
+	var busy struct {
+		RoomID int                     `db:"room_id"`
+		Ranges pgkit.Multirange[int64] `db:"ranges"`
+	}
+	require.NoError(t, DB.Query.GetOne(ctx, pgkit.RangeAgg(bookings.Where(sq.Eq{"room_id": 1}), "period", "room_id"), &busy))
+	require.Len(t, busy.Ranges, 2)
+	assert.Equal(t, int64(1), busy.Ranges[0].Lower)
+	assert.Equal(t, int64(5), busy.Ranges[0].Upper)
+
+	var gaps []pgtype.Range[int64]
+	require.NoError(t, DB.Query.GetAll(ctx, pgkit.RangeGaps(bookings.Where(sq.Eq{"room_id": 1}), "period", sq.Expr("int8range(?, ?)", 0, 10)), &gaps))
+	require.Len(t, gaps, 3)
+	assert.Equal(t, int64(5), gaps[1].Lower)
+	assert.Equal(t, int64(7), gaps[1].Upper)
+
+	var n int
+	require.NoError(t, DB.Conn.QueryRow(ctx, `SELECT cardinality($1::int8multirange)`, busy.Ranges).Scan(&n))
+	assert.Equal(t, 2, n)
+}
+
+func TestDisableTriggers(t *testing.T) {
+	ctx := context.Background()
+	_, err := DB.Conn.Exec(ctx, `
+		DROP TABLE IF EXISTS trigger_items;
+		CREATE TABLE trigger_items (id int PRIMARY KEY, touched bool NOT NULL DEFAULT false);
+		CREATE OR REPLACE FUNCTION trigger_items_touch() RETURNS trigger AS $$ BEGIN NEW.touched = true; RETURN NEW; END $$ LANGUAGE plpgsql;
+		CREATE TRIGGER touch BEFORE INSERT ON trigger_items FOR EACH ROW EXECUTE FUNCTION trigger_items_touch();
+		DROP TABLE IF EXISTS "TriggerItems";
+		CREATE TABLE "TriggerItems" (id int PRIMARY KEY, touched bool NOT NULL DEFAULT false);
+		CREATE TRIGGER touch BEFORE INSERT ON "TriggerItems" FOR EACH ROW EXECUTE FUNCTION trigger_items_touch();`)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		DB.Conn.Exec(ctx, `DROP TABLE trigger_items, "TriggerItems"; DROP FUNCTION trigger_items_touch()`)
+	})
+
+	enabled := func(tx pgx.Tx) bool {
+		var state string
+		require.NoError(t, tx.QueryRow(ctx, `SELECT tgenabled FROM pg_trigger WHERE tgrelid = 'trigger_items'::regclass AND tgname = 'touch'`).Scan(&state))
+		return state != "D"
+	}
+
+	tx, err := DB.Conn.Begin(ctx)
+	require.NoError(t, err)
+	defer tx.Rollback(ctx)
+
+	err = pgkit.DisableTriggers(ctx, tx, "trigger_items", func() error {
+		assert.False(t, enabled(tx))
+		_, err := tx.Exec(ctx, `INSERT INTO trigger_items (id) VALUES (1)`)
+		return err
+	})
+	require.NoError(t, err)
+	assert.True(t, enabled(tx))
+
+	// a failing fn is rolled back to its savepoint and the triggers are re-enabled
+	failure := errors.New("failed")
+	err = pgkit.DisableTriggers(ctx, tx, "trigger_items", func() error {
+		if _, err := tx.Exec(ctx, `INSERT INTO trigger_items (id) VALUES (2)`); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, `INSERT INTO trigger_items (id) VALUES (1)`)
+		require.Error(t, err)
+		return failure
+	})
+	assert.ErrorIs(t, err, failure)
+	assert.True(t, enabled(tx))
+
+	// so is a panicking one
+	assert.Panics(t, func() {
+		pgkit.DisableTriggers(ctx, tx, "trigger_items", func() error { panic("boom") })
+	})
+	assert.True(t, enabled(tx))
+
+	var touched []bool
+	require.NoError(t, pgxscan.Select(ctx, tx, &touched, `SELECT touched FROM trigger_items ORDER BY id`))
+	assert.Equal(t, []bool{false}, touched)
+	_, err = tx.Exec(ctx, `INSERT INTO trigger_items (id) VALUES (3)`)
+	require.NoError(t, err)
+	require.NoError(t, tx.QueryRow(ctx, `SELECT touched FROM trigger_items WHERE id = 3`).Scan(&touched[0]))
+	assert.True(t, touched[0])
+
+	// a mixed case name addresses the same table in both statements
+	err = pgkit.DisableTriggers(ctx, tx, "TriggerItems", func() error {
+		_, err := tx.Exec(ctx, `INSERT INTO "TriggerItems" (id) VALUES (1)`)
+		return err
+	})
+	require.NoError(t, err)
+	require.NoError(t, tx.QueryRow(ctx, `SELECT touched FROM "TriggerItems" WHERE id = 1`).Scan(&touched[0]))
+	assert.False(t, touched[0])
+}
+
+func TestSyncPaginator(t *testing.T) {
+	ctx := context.Background()
+	_, err := DB.Conn.Exec(ctx, `
+		DROP TABLE IF EXISTS sync_items, sync_deletions;
+		CREATE TABLE sync_items (id int PRIMARY KEY, updated_at timestamptz NOT NULL);
+		CREATE TABLE sync_deletions (id int PRIMARY KEY, deleted_at timestamptz NOT NULL);
+		INSERT INTO sync_items SELECT i, now() - interval '1 hour' + i * interval '1 second' FROM generate_series(1, 3) i;
+		INSERT INTO sync_items VALUES (4, now());
+		INSERT INTO sync_deletions VALUES (10, now() - interval '1 hour'), (11, now());`)
+	require.NoError(t, err)
+	t.Cleanup(func() { DB.Conn.Exec(ctx, `DROP TABLE sync_items, sync_deletions`) })
+
+	type item struct {
+		ID        int       `db:"id"`
+		UpdatedAt time.Time `db:"updated_at"`
+	}
+	paginator := pgkit.SyncPaginator[item, int]{
+		DeletionsTable: "sync_deletions",
+		Size:           2,
+		Lag:            90 * time.Second,
+		Key:            func(row item) (time.Time, int) { return row.UpdatedAt, row.ID },
+	}
+	q := DB.SQL.Select("id", "updated_at").From("sync_items")
+
+	// the changes of the last 90s are held back
+	result, err := paginator.Sync(ctx, DB.Query, q, "")
+	require.NoError(t, err)
+	assert.Len(t, result.Items, 2)
+	assert.Equal(t, []int{10}, result.Deleted)
+	assert.True(t, result.More)
+
+	result, err = paginator.Sync(ctx, DB.Query, q, result.Token)
+	require.NoError(t, err)
+	require.Len(t, result.Items, 1)
+	assert.Equal(t, 3, result.Items[0].ID)
+	assert.Empty(t, result.Deleted)
+	assert.False(t, result.More)
+
+	paginator.Lag = 0
+	result, err = paginator.Sync(ctx, DB.Query, q, result.Token)
+	require.NoError(t, err)
+	require.Len(t, result.Items, 1)
+	assert.Equal(t, 4, result.Items[0].ID)
+	assert.Equal(t, []int{11}, result.Deleted)
+}
+
+func TestDecimal(t *testing.T) {
+	ctx := context.Background()
+	_, err := DB.Conn.Exec(ctx, `
+		DROP TABLE IF EXISTS payments;
+		CREATE TABLE payments (id int NOT NULL, amount numeric(20, 4));
+		INSERT INTO payments VALUES (1, 0.1), (2, 0.2), (3, 12345678901234567.8901), (4, NULL);`)
+	require.NoError(t, err)
+	t.Cleanup(func() { DB.Conn.Exec(ctx, `DROP TABLE payments`) })
+
+	var payments []struct {
+		ID     int            `db:"id"`
+		Amount *pgkit.Decimal `db:"amount"`
+	}
+	threshold, err := pgkit.ParseDecimal("0.1")
+	require.NoError(t, err)
+	q := DB.SQL.Select("id", "amount").From("payments").Where(sq.Gt{"amount": threshold}).OrderBy("amount")
+	require.NoError(t, DB.Query.GetAll(ctx, q, &payments))
+	require.Len(t, payments, 2)
+	assert.Equal(t, "0.2000", payments[0].Amount.String())
+	assert.Equal(t, "12345678901234567.8901", payments[1].Amount.String())
+
+	var sum pgkit.Decimal
+	require.NoError(t, DB.Conn.QueryRow(ctx, `SELECT $1::numeric + 0.2`, pgkit.NewDecimal(1, -1)).Scan(&sum))
+	assert.Equal(t, "0.3", sum.String())
+}
+
+func TestShadow(t *testing.T) {
+	ctx := context.Background()
+	truncateTable(t, "accounts")
+
+	for i := 1; i <= 3; i++ {
+		_, err := DB.Query.Exec(ctx, DB.SQL.InsertRecord(&Account{Name: fmt.Sprintf("user%d", i)}))
+		require.NoError(t, err)
+	}
+
+	reports := make(chan pgkit.ShadowReport, 1)
+	querier := DB.Query.With(pgkit.Shadow(DB.Conn, pgkit.ShadowPolicy{
+		SampleRate: 1,
+		Rewrite: func(sql string, args []interface{}) (string, []interface{}) {
+			return sql + " LIMIT 2", args
+		},
+		Report: func(ctx context.Context, r pgkit.ShadowReport) { reports <- r },
+	}))
+
+	var accounts []*Account
+	require.NoError(t, querier.GetAll(ctx, DB.SQL.Select("*").From("accounts"), &accounts))
+	assert.Len(t, accounts, 3)
+
+	select {
+	case r := <-reports:
+		assert.Equal(t, 3, r.Rows)
+		assert.Equal(t, 2, r.ShadowRows)
+		assert.True(t, r.Differs())
+		assert.NoError(t, r.ShadowErr)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no shadow report")
+	}
+}
+
+func TestOnlineSchemaChanges(t *testing.T) {
+	ctx := context.Background()
+
+	_, err := DB.Conn.Exec(ctx, `
+		DROP TABLE IF EXISTS online_items;
+		CREATE TABLE online_items (id SERIAL PRIMARY KEY, name TEXT NOT NULL);
+		INSERT INTO online_items (name) SELECT 'item ' || i FROM generate_series(1, 250) AS i;
+		INSERT INTO online_items (name) VALUES ('item 1');
+		ALTER TABLE online_items ADD COLUMN slug TEXT;`)
+	require.NoError(t, err)
+	defer DB.Conn.Exec(ctx, `DROP TABLE IF EXISTS online_items`)
+
+	// the duplicated name makes the build fail, leaving no invalid index behind
+	spec := pgkit.IndexSpec{Name: "online_items_name_idx", Table: "online_items", Unique: true, Definition: "(name)"}
+	require.Error(t, pgkit.CreateIndexConcurrently(ctx, DB, spec, 2))
+	require.NoError(t, pgkit.Preflight(ctx, DB, pgkit.CheckTables("online_items")).Err())
+	require.Error(t, pgkit.Preflight(ctx, DB, pgkit.CheckIndex("online_items", "name")).Err())
+
+	spec.Unique = false
+	require.NoError(t, pgkit.CreateIndexConcurrently(ctx, DB, spec, 2))
+	require.NoError(t, pgkit.Preflight(ctx, DB, pgkit.CheckIndex("online_items", "name")).Err())
+
+	n, err := pgkit.BackfillColumn(ctx, DB, pgkit.ColumnBackfill{
+		Table:     "online_items",
+		Column:    "slug",
+		Value:     "replace(name, ' ', '-')",
+		BatchSize: 100,
+		NotNull:   true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(251), n)
+
+	_, err = DB.Conn.Exec(ctx, `INSERT INTO online_items (name) VALUES ('no slug')`)
+	require.Error(t, err)
+
+	// the rows for which the value is NULL are updated once
+	_, err = DB.Conn.Exec(ctx, `ALTER TABLE online_items ADD COLUMN code TEXT`)
+	require.NoError(t, err)
+	backfill := pgkit.ColumnBackfill{
+		Table:     "online_items",
+		Column:    "code",
+		Value:     "CASE WHEN id % 2 = 0 THEN id::text END",
+		BatchSize: 100,
+	}
+	n, err = pgkit.BackfillColumn(ctx, DB, backfill)
+	require.NoError(t, err)
+	assert.Equal(t, int64(125), n)
+	backfill.NotNull = true
+	_, err = pgkit.BackfillColumn(ctx, DB, backfill)
+	require.ErrorContains(t, err, "126 rows")
+
+	err = pgkit.WithLockTimeout(ctx, DB, 100*time.Millisecond, 3, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `ALTER TABLE online_items ADD COLUMN extra TEXT`)
+		return err
+	})
+	require.NoError(t, err)
+
+	// a timeout under 1ms doesn't disable it
+	err = pgkit.WithLockTimeout(ctx, DB, time.Microsecond, 1, func(tx pgx.Tx) error {
+		var timeout string
+		require.NoError(t, tx.QueryRow(ctx, `SHOW lock_timeout`).Scan(&timeout))
+		assert.Equal(t, "1ms", timeout)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Error(t, pgkit.WithLockTimeout(ctx, DB, 0, 1, func(tx pgx.Tx) error { return nil }))
+
+	// the invalid index is found in the schema of its table, out of the search_path
+	_, err = DB.Conn.Exec(ctx, `
+		DROP SCHEMA IF EXISTS online CASCADE;
+		CREATE SCHEMA online;
+		CREATE TABLE online.items (id int PRIMARY KEY, name TEXT NOT NULL);
+		INSERT INTO online.items VALUES (1, 'a'), (2, 'a');`)
+	require.NoError(t, err)
+	defer DB.Conn.Exec(ctx, `DROP SCHEMA IF EXISTS online CASCADE`)
+
+	spec = pgkit.IndexSpec{Name: "items_name_idx", Table: "online.items", Unique: true, Definition: "(name)"}
+	require.Error(t, pgkit.CreateIndexConcurrently(ctx, DB, spec, 2))
+	var indexes int
+	require.NoError(t, DB.Conn.QueryRow(ctx, `SELECT count(*) FROM pg_index WHERE indrelid = 'online.items'::regclass AND NOT indisprimary`).Scan(&indexes))
+	assert.Zero(t, indexes)
+}
+
+func TestDualWrite(t *testing.T) {
+	ctx := context.Background()
+
+	_, err := DB.Conn.Exec(ctx, `
+		DROP TABLE IF EXISTS dualwrite_items;
+		CREATE TABLE dualwrite_items (id SERIAL PRIMARY KEY, name TEXT);
+		INSERT INTO dualwrite_items (name) VALUES ('a'), ('b');
+		ALTER TABLE dualwrite_items ADD COLUMN title TEXT;`)
+	require.NoError(t, err)
+	defer DB.Conn.Exec(ctx, `DROP TABLE IF EXISTS dualwrite_items`)
+
+	dw := pgkit.DualWrite{Table: "dualwrite_items", Columns: map[string]string{"name": "title"}}
+	require.NoError(t, dw.Install(ctx, DB))
+
+	n, err := dw.Verify(ctx, DB)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+
+	n, err = dw.Copy(ctx, DB)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+
+	// old and new writers
+	_, err = DB.Conn.Exec(ctx, `
+		INSERT INTO dualwrite_items (name) VALUES ('c');
+		INSERT INTO dualwrite_items (title) VALUES ('d');
+		UPDATE dualwrite_items SET name = 'aa' WHERE name = 'a';
+		UPDATE dualwrite_items SET title = 'bb' WHERE title = 'b';`)
+	require.NoError(t, err)
+
+	n, err = dw.Verify(ctx, DB)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+
+	var titles []string
+	require.NoError(t, DB.Conn.QueryRow(ctx, `SELECT array_agg(title ORDER BY id) FROM dualwrite_items`).Scan(&titles))
+	assert.Equal(t, []string{"aa", "bb", "c", "d"}, titles)
+
+	require.NoError(t, dw.Uninstall(ctx, DB))
+}
+
+func TestCancelInFlight(t *testing.T) {
+	ctx := context.Background()
+	critical := pgkit.WithQueryConfig(ctx, pgkit.QueryConfig{Priority: pgkit.PriorityCritical})
+
+	errs := make(chan error, 2)
+	for _, ctx := range []context.Context{ctx, critical} {
+		go func(ctx context.Context) {
+			_, err := DB.Query.Exec(ctx, pgkit.RawQuery("SELECT pg_sleep(0.5)").Build())
+			errs <- err
+		}(ctx)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	assert.Equal(t, 1, DB.CancelInFlight("shutdown"))
+	err := <-errs
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "shutdown")
+	assert.NoError(t, <-errs)
+
+	assert.Equal(t, 0, DB.CancelInFlight("shutdown"))
+}
+
+func TestPageOf(t *testing.T) {
+	ctx := context.Background()
+	truncateTable(t, "accounts")
+
+	for i := 1; i <= 25; i++ {
+		_, err := DB.Query.Exec(ctx, DB.SQL.InsertRecord(&Account{Name: fmt.Sprintf("user%02d", i)}))
+		require.NoError(t, err)
+	}
+
+	paginator := pgkit.NewPaginator[Account](pgkit.WithSort("-name"))
+	q := DB.SQL.Select("*").From("accounts")
+
+	page, err := paginator.PageOf(ctx, DB.Query, q, pgkit.NewPage(10, 1), sq.Eq{"name": "user20"})
+	require.NoError(t, err)
+	assert.Equal(t, uint32(1), page.Page)
+
+	page, err = paginator.PageOf(ctx, DB.Query, q, pgkit.NewPage(10, 1), sq.Eq{"name": "user03"})
+	require.NoError(t, err)
+	assert.Equal(t, uint32(3), page.Page)
+
+	result, query := paginator.PrepareQuery(q, page)
+	require.NoError(t, DB.Query.GetAll(ctx, query, &result))
+	assert.Equal(t, "user03", result[2].Name)
+
+	_, err = paginator.PageOf(ctx, DB.Query, q, nil, sq.Eq{"name": "nobody"})
+	assert.ErrorIs(t, err, pgkit.ErrNoRows)
+}
+
+type disabledKey struct{}
+
+func TestPageOfScope(t *testing.T) {
+	ctx := context.Background()
+	truncateTable(t, "accounts")
+
+	for i := 1; i <= 25; i++ {
+		_, err := DB.Query.Exec(ctx, DB.SQL.InsertRecord(&Account{Name: fmt.Sprintf("user%02d", i), Disabled: i%2 == 0}))
+		require.NoError(t, err)
+	}
+
+	paginator := pgkit.NewPaginator[Account](pgkit.WithSort("-name"), pgkit.WithScope(func(ctx context.Context) sq.Sqlizer {
+		return sq.Eq{"disabled": ctx.Value(disabledKey{}) == true}
+	}))
+	q := DB.SQL.Select("*").From("accounts")
+	enabled := context.WithValue(ctx, disabledKey{}, false)
+	disabled := context.WithValue(ctx, disabledKey{}, true)
+
+	// user25, user23 ... user07, then user05, user03, user01
+	page, err := paginator.PageOf(enabled, DB.Query, q, pgkit.NewPage(10, 1), sq.Eq{"name": "user03"})
+	require.NoError(t, err)
+	assert.Equal(t, uint32(2), page.Page)
+
+	// user24, user22 ... user16, then user14 ... user06, then user04, user02
+	page, err = paginator.PageOf(disabled, DB.Query, q, pgkit.NewPage(5, 1), sq.Eq{"name": "user04"})
+	require.NoError(t, err)
+	assert.Equal(t, uint32(3), page.Page)
+
+	result, query := paginator.PrepareQueryContext(disabled, q, page)
+	require.NoError(t, DB.Query.GetAll(ctx, query, &result))
+	assert.Equal(t, "user04", result[0].Name)
+
+	_, err = paginator.PageOf(enabled, DB.Query, q, nil, sq.Eq{"name": "user04"})
+	assert.ErrorIs(t, err, pgkit.ErrNoRows)
+}
+
+func TestCursorPaginator(t *testing.T) {
+	ctx := context.Background()
+	truncateTable(t, "accounts")
+
+	for i := 1; i <= 7; i++ {
+		_, err := DB.Query.Exec(ctx, DB.SQL.InsertRecord(&Account{Name: fmt.Sprintf("user%d", i%3)}))
+		require.NoError(t, err)
+	}
+
+	paginator := pgkit.NewCursorPaginator[Account](pgkit.WithDefaultSize(3), pgkit.WithSort("name", "-id"))
+	page := &pgkit.Page{}
+	var names []string
+	for {
+		result, q, err := paginator.PrepareQuery(DB.SQL.Select("*").From("accounts"), page)
+		require.NoError(t, err)
+		require.NoError(t, DB.Query.GetAll(ctx, q, &result))
+		result, err = paginator.PrepareResult(result, page)
+		require.NoError(t, err)
+		for _, a := range result {
+			names = append(names, a.Name)
+		}
+		if !page.More {
+			break
+		}
+		page = &pgkit.Page{Cursor: page.NextCursor}
+	}
+	assert.Equal(t, []string{"user0", "user0", "user1", "user1", "user1", "user2", "user2"}, names)
+}
+
+func TestPaginatorForEachPage(t *testing.T) {
+	ctx := context.Background()
+	truncateTable(t, "accounts")
+
+	for i := 1; i <= 7; i++ {
+		_, err := DB.Query.Exec(ctx, DB.SQL.InsertRecord(&Account{Name: fmt.Sprintf("user%d", i%3)}))
+		require.NoError(t, err)
+	}
+
+	paginator := pgkit.NewPaginator[Account](pgkit.WithSort("name"), pgkit.WithTiebreaker("id"))
+	var names []string
+	var pages []uint32
+	err := paginator.ForEachPage(ctx, DB.SQL.Select("*").From("accounts"), 3, DB.Query, func(rows []Account, page *pgkit.Page) error {
+		for _, a := range rows {
+			names = append(names, a.Name)
+		}
+		pages = append(pages, page.Page)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user0", "user0", "user1", "user1", "user1", "user2", "user2"}, names)
+	assert.Equal(t, []uint32{1, 2, 3}, pages)
+
+	stop := errors.New("stop")
+	err = paginator.ForEachPage(ctx, DB.SQL.Select("*").From("accounts"), 3, DB.Query, func(rows []Account, page *pgkit.Page) error {
+		return stop
+	})
+	assert.ErrorIs(t, err, stop)
+}
+
+func TestDiffQueries(t *testing.T) {
+	ctx := context.Background()
+	truncateTable(t, "accounts")
+
+	for i := 1; i <= 5; i++ {
+		_, err := DB.Query.Exec(ctx, DB.SQL.InsertRecord(&Account{Name: fmt.Sprintf("user%d", i)}))
+		require.NoError(t, err)
+	}
+
+	old := DB.SQL.Select("*").From("accounts").Where("name <> 'user5'").OrderBy("id").Limit(3).Offset(1)
+	rewrite := DB.SQL.Select("*").From("accounts").Where(pgkit.Keyset([]pgkit.Sort{{Column: "id"}}, firstID(t))).OrderBy("id").Limit(4)
+	diff, err := pgkit.DiffQueries(ctx, DB.Query, old, rewrite, func(a Account) int64 { return a.ID })
+	require.NoError(t, err)
+	assert.Len(t, diff.Missing, 0)
+	assert.Len(t, diff.Extra, 1)
+	assert.Equal(t, "user5", diff.Extra[0].Name)
+}
+
+func firstID(t *testing.T) int64 {
+	var id int64
+	require.NoError(t, DB.Conn.QueryRow(context.Background(), `SELECT min(id) FROM accounts`).Scan(&id))
+	return id
+}
+
+func TestPageBatch(t *testing.T) {
+	ctx := context.Background()
+	truncateTable(t, "accounts")
+	truncateTable(t, "logs")
+
+	for i := 1; i <= 5; i++ {
+		_, err := DB.Query.Exec(ctx, DB.SQL.InsertRecord(&Account{Name: fmt.Sprintf("user%d", i)}))
+		require.NoError(t, err)
+		_, err = DB.Query.Exec(ctx, DB.SQL.Insert("logs").Columns("message", "etc").Values(fmt.Sprintf("log%d", i), map[string]interface{}{}))
+		require.NoError(t, err)
+	}
+
+	var (
+		batch    pgkit.PageBatch
+		accounts []Account
+		logs     []Log
+	)
+	accountsPage, logsPage := pgkit.NewPage(2, 1), pgkit.NewPage(3, 2)
+	pgkit.AddPage(&batch, pgkit.NewPaginator[Account](pgkit.WithSort("name")), DB.SQL.Select("*").From("accounts"), accountsPage, &accounts)
+	pgkit.AddPage(&batch, pgkit.NewPaginator[Log](pgkit.WithSort("message")), DB.SQL.Select("*").From("logs"), logsPage, &logs)
+	require.Equal(t, 2, batch.Len())
+	require.NoError(t, batch.Send(ctx, DB.Query))
+
+	require.Len(t, accounts, 2)
+	assert.Equal(t, "user1", accounts[0].Name)
+	assert.True(t, accountsPage.More)
+	require.Len(t, logs, 2)
+	assert.Equal(t, "log4", logs[0].Message)
+	assert.False(t, logsPage.More)
+}
+
+func TestPaginatorInlineCount(t *testing.T) {
+	ctx := context.Background()
+	truncateTable(t, "accounts")
+
+	for i := 1; i <= 7; i++ {
+		_, err := DB.Query.Exec(ctx, DB.SQL.InsertRecord(&Account{Name: fmt.Sprintf("user%d", i)}))
+		require.NoError(t, err)
+	}
+
+	paginator := pgkit.NewPaginator[Account](pgkit.WithSort("id"), pgkit.WithInlineCount())
+	page := pgkit.NewPage(3, 2)
+	result, err := paginator.Query(ctx, DB.SQL.Select("*").From("accounts"), page, DB.Conn)
+	require.NoError(t, err)
+	require.Len(t, result, 3)
+	assert.Equal(t, "user4", result[0].Name)
+	assert.Equal(t, uint64(7), page.Total)
+	assert.Equal(t, uint32(3), page.TotalPages)
+	assert.True(t, page.More)
+}
+
+func TestPaginatorDistinctOn(t *testing.T) {
+	ctx := context.Background()
+	truncateTable(t, "accounts")
+
+	for _, name := range []string{"a", "b", "a", "c", "b", "a"} {
+		_, err := DB.Query.Exec(ctx, DB.SQL.InsertRecord(&Account{Name: name}))
+		require.NoError(t, err)
+	}
+
+	// the last account of each name, sorted by id
+	paginator := pgkit.NewPaginator[Account](pgkit.WithSort("-id"))
+	q := DB.SQL.Select("*").Options("DISTINCT ON (name)").From("accounts").OrderBy("name", "id DESC")
+	result, err := paginator.Query(ctx, q, pgkit.NewPage(2, 1), DB.Conn)
+	require.NoError(t, err)
+	require.Len(t, result, 2)
+	assert.Equal(t, "a", result[0].Name)
+	assert.Equal(t, "b", result[1].Name)
+
+	var counts []struct {
+		Name  string `db:"name"`
+		Count int    `db:"count"`
+	}
+	page := pgkit.NewPage(10, 1, pgkit.Sort{Column: "count", Order: pgkit.Desc})
+	_, query := pgkit.NewPaginator[Account]().PrepareQuery(DB.SQL.Select("name", "count(*) AS count").From("accounts").GroupBy("name"), page)
+	require.NoError(t, DB.Query.GetAll(ctx, query, &counts))
+	require.Len(t, counts, 3)
+	assert.Equal(t, "a", counts[0].Name)
+	assert.Equal(t, 3, counts[0].Count)
+}
+
+func TestTimeBuckets(t *testing.T) {
+	ctx := context.Background()
+	truncateTable(t, "accounts")
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, minutes := range []int{0, 5, 20, 50, 55, 70} {
+		_, err := DB.Query.Exec(ctx, DB.SQL.InsertRecord(&Account{Name: "user", CreatedAt: from.Add(time.Duration(minutes) * time.Minute)}))
+		require.NoError(t, err)
+	}
+
+	var buckets []struct {
+		Bucket time.Time `db:"bucket"`
+		N      int       `db:"n"`
+	}
+	accounts := DB.SQL.Select("created_at").From("accounts")
+	require.NoError(t, DB.Query.GetAll(ctx, pgkit.WidthBuckets(accounts, "created_at", from, from.Add(time.Hour), 4, pgkit.Count("n")), &buckets))
+	require.Len(t, buckets, 3)
+	assert.True(t, from.Equal(buckets[0].Bucket))
+	assert.Equal(t, 2, buckets[0].N)
+	assert.True(t, from.Add(15*time.Minute).Equal(buckets[1].Bucket))
+	assert.Equal(t, 2, buckets[2].N)
+
+	buckets = nil
+	require.NoError(t, DB.Query.GetAll(ctx, pgkit.TruncBuckets(accounts, "created_at", "hour", pgkit.Count("n")), &buckets))
+	require.Len(t, buckets, 2)
+	assert.Equal(t, 5, buckets[0].N)
+}
+
+func TestSummarize(t *testing.T) {
+	ctx := context.Background()
+	truncateTable(t, "accounts")
+
+	for i := 1; i <= 10; i++ {
+		_, err := DB.Query.Exec(ctx, DB.SQL.InsertRecord(&Account{Name: "user", Disabled: i%4 == 0}))
+		require.NoError(t, err)
+	}
+
+	accounts := DB.SQL.Select("*").From("accounts")
+	var s pgkit.Summary
+	require.NoError(t, DB.Query.GetOne(ctx, pgkit.Summarize(accounts, "id"), &s))
+	assert.Equal(t, int64(10), s.Count)
+	require.NotNil(t, s.P50)
+	assert.Equal(t, *s.Min+4.5, *s.P50)
+
+	var stats struct {
+		Disabled int       `db:"disabled"`
+		Ps       []float64 `db:"ps"`
+		Name     string    `db:"name"`
+	}
+	q := pgkit.Aggregates(accounts,
+		pgkit.Count("disabled").Filter(sq.Eq{"disabled": true}),
+		pgkit.Percentiles([]float64{0, 1}, "id", "ps"),
+		pgkit.Mode("name", "name"),
+	)
+	require.NoError(t, DB.Query.GetOne(ctx, q, &stats))
+	assert.Equal(t, 2, stats.Disabled)
+	assert.Len(t, stats.Ps, 2)
+	assert.Equal(t, "user", stats.Name)
+}
+
+func TestPaginatorSearch(t *testing.T) {
+	ctx := context.Background()
+	truncateTable(t, "accounts")
+
+	for _, name := range []string{"red apple", "green apple pie", "banana", "Apple"} {
+		_, err := DB.Query.Exec(ctx, DB.SQL.InsertRecord(&Account{Name: name}))
+		require.NoError(t, err)
+	}
+
+	paginator := pgkit.NewPaginator[Account](pgkit.WithSort("id"))
+	accounts := DB.SQL.Select("*").From("accounts")
+
+	result, err := paginator.Query(ctx, accounts, nil, DB.Conn, pgkit.WithSearch(pgkit.Search{Text: "APPLE", Columns: []string{"name"}}))
+	require.NoError(t, err)
+	assert.Len(t, result, 3)
+
+	search := pgkit.Search{Text: "apple -pie", Mode: pgkit.SearchFullText, Columns: []string{"name"}, Ranked: true}
+	result, err = paginator.Query(ctx, accounts, nil, DB.Conn, pgkit.WithSearch(search))
+	require.NoError(t, err)
+	require.Len(t, result, 2)
+	assert.Equal(t, "red apple", result[0].Name)
+}
+
+func TestPaginatorRandomOrder(t *testing.T) {
+	ctx := context.Background()
+	truncateTable(t, "accounts")
+
+	for i := 0; i < 20; i++ {
+		_, err := DB.Query.Exec(ctx, DB.SQL.InsertRecord(&Account{Name: fmt.Sprintf("account-%02d", i)}))
+		require.NoError(t, err)
+	}
+
+	paginator := pgkit.NewPaginator[Account](pgkit.WithSort("id"))
+	accounts := DB.SQL.Select("*").From("accounts")
+
+	page := &pgkit.Page{Page: 2, Size: 5}
+	first, err := paginator.Query(ctx, accounts, page, DB.Conn, pgkit.WithRandomOrder(0.25))
+	require.NoError(t, err)
+	require.Len(t, first, 5)
+	assert.False(t, page.More)
+
+	second, err := paginator.Query(ctx, accounts, &pgkit.Page{Size: 5}, DB.Conn, pgkit.WithRandomOrder(0.25))
+	require.NoError(t, err)
+	assert.Equal(t, first, second)
+
+	_, err = paginator.Query(ctx, accounts, nil, DB.Conn, pgkit.WithTableSample(50))
+	require.NoError(t, err)
+}
+
+func TestPaginatorCache(t *testing.T) {
+	ctx := context.Background()
+	truncateTable(t, "accounts")
+
+	for _, name := range []string{"a", "b", "c"} {
+		_, err := DB.Query.Exec(ctx, DB.SQL.InsertRecord(&Account{Name: name}))
+		require.NoError(t, err)
+	}
+
+	cache := pgkit.NewMemoryCache(10)
+	paginator := pgkit.NewPaginator[Account](pgkit.WithSort("name"), pgkit.WithTotalCount(), pgkit.WithCache(cache, time.Minute))
+	accounts := DB.SQL.Select("*").From("accounts")
+
+	page := &pgkit.Page{Size: 2}
+	result, err := paginator.Query(ctx, accounts, page, DB.Conn)
+	require.NoError(t, err)
+	require.Len(t, result, 2)
+	assert.Equal(t, 1, cache.Len())
+
+	_, err = DB.Query.Exec(ctx, DB.SQL.InsertRecord(&Account{Name: "0"}))
+	require.NoError(t, err)
+
+	cached := &pgkit.Page{Size: 2}
+	result, err = paginator.Query(ctx, accounts, cached, DB.Conn)
+	require.NoError(t, err)
+	assert.Equal(t, "a", result[0].Name)
+	assert.Equal(t, uint64(3), cached.Total)
+	assert.True(t, cached.More)
+
+	fresh := &pgkit.Page{Size: 2, NoCache: true}
+	result, err = paginator.Query(ctx, accounts, fresh, DB.Conn)
+	require.NoError(t, err)
+	assert.Equal(t, "0", result[0].Name)
+	assert.Equal(t, uint64(4), fresh.Total)
+}
+
+func TestSaga(t *testing.T) {
+	ctx := context.Background()
+	truncateTable(t, "accounts")
+
+	type Data struct {
+		Name string `json:"name"`
+	}
+	insert := func(suffix string) pgkit.SagaStep {
+		return pgkit.SagaStep{
+			Name: "insert" + suffix,
+			Run: func(ctx context.Context, q *pgkit.Querier, data json.RawMessage) error {
+				var d Data
+				if err := json.Unmarshal(data, &d); err != nil {
+					return err
+				}
+				_, err := q.Exec(ctx, q.SQL.InsertRecord(&Account{Name: d.Name + suffix}))
+				return err
+			},
+			Compensate: func(ctx context.Context, q *pgkit.Querier, data json.RawMessage) error {
+				var d Data
+				if err := json.Unmarshal(data, &d); err != nil {
+					return err
+				}
+				_, err := q.Exec(ctx, q.SQL.Delete("accounts").Where(sq.Eq{"name": d.Name + suffix}))
+				return err
+			},
+		}
+	}
+	saga := pgkit.Saga{Name: "signup", Table: "test_sagas", Steps: []pgkit.SagaStep{insert("-1"), insert("-2")}}
+	_, err := DB.Conn.Exec(ctx, "DROP TABLE IF EXISTS test_sagas")
+	require.NoError(t, err)
+	require.NoError(t, saga.Install(ctx, DB))
+
+	require.NoError(t, saga.Start(ctx, DB, "run-1", Data{Name: "ok"}))
+	require.NoError(t, saga.Start(ctx, DB, "run-1", Data{Name: "ok"}))
+
+	var names []string
+	require.NoError(t, DB.Query.GetAll(ctx, DB.SQL.Select("name").From("accounts").OrderBy("name"), &names))
+	assert.Equal(t, []string{"ok-1", "ok-2"}, names)
+
+	saga.Steps = append(saga.Steps, pgkit.SagaStep{
+		Name: "fail",
+		Run: func(ctx context.Context, q *pgkit.Querier, data json.RawMessage) error {
+			_, err := q.Exec(ctx, q.SQL.InsertRecord(&Account{Name: "never"}))
+			require.NoError(t, err)
+			return errors.New("boom")
+		},
+	})
+	err = saga.Start(ctx, DB, "run-2", Data{Name: "ko"})
+	require.ErrorIs(t, err, pgkit.ErrSagaFailed)
+	assert.EqualError(t, err, "pgkit: saga failed: step fail: boom")
+
+	names = nil
+	require.NoError(t, DB.Query.GetAll(ctx, DB.SQL.Select("name").From("accounts").OrderBy("name"), &names))
+	assert.Equal(t, []string{"ok-1", "ok-2"}, names)
+	require.NoError(t, saga.ResumeAll(ctx, DB))
+}
+
+func TestPaginatorAggregates(t *testing.T) {
+	ctx := context.Background()
+	truncateTable(t, "accounts")
+
+	for i := 0; i < 5; i++ {
+		_, err := DB.Query.Exec(ctx, DB.SQL.InsertRecord(&Account{Name: fmt.Sprint(i), Disabled: i%2 == 0}))
+		require.NoError(t, err)
+	}
+
+	paginator := pgkit.NewPaginator[Account](pgkit.WithSort("id"), pgkit.WithAggregates(map[string]string{
+		"count":    "count(*)",
+		"disabled": "count(*) FILTER (WHERE disabled)",
+	}))
+	page := &pgkit.Page{Size: 2}
+	result, err := paginator.Query(ctx, DB.SQL.Select("*").From("accounts"), page, DB.Conn)
+	require.NoError(t, err)
+	require.Len(t, result, 2)
+	assert.Equal(t, map[string]interface{}{"count": int64(5), "disabled": int64(3)}, page.Aggregates)
+}
+
+func TestBackfill(t *testing.T) {
+	ctx := context.Background()
+	truncateTable(t, "accounts")
+
+	for i := 0; i < 25; i++ {
+		_, err := DB.Query.Exec(ctx, DB.SQL.InsertRecord(&Account{Name: fmt.Sprint(i)}))
+		require.NoError(t, err)
+	}
+	_, err := DB.Conn.Exec(ctx, "DROP TABLE IF EXISTS test_backfills")
+	require.NoError(t, err)
+
+	var reports []pgkit.BackfillProgress
+	backfill := pgkit.Backfill[Account]{
+		Name:      "disable",
+		Query:     DB.SQL.Select("*").From("accounts"),
+		BatchSize: 10,
+		Table:     "test_backfills",
+		Process: func(ctx context.Context, q *pgkit.Querier, rows []Account) error {
+			ids := make([]int64, len(rows))
+			for i := range rows {
+				ids[i] = rows[i].ID
+			}
+			_, err := q.Exec(ctx, q.SQL.Update("accounts").Set("disabled", true).Where(sq.Eq{"id": ids}))
+			return err
+		},
+		Progress: func(progress pgkit.BackfillProgress) {
+			reports = append(reports, progress)
+		},
+	}
+	require.NoError(t, backfill.Install(ctx, DB))
+
+	require.NoError(t, backfill.Pause(ctx, DB))
+	require.ErrorIs(t, backfill.Run(ctx, DB), pgkit.ErrBackfillPaused)
+	require.NoError(t, backfill.Resume(ctx, DB))
+	require.NoError(t, backfill.Run(ctx, DB))
+
+	require.Len(t, reports, 3)
+	assert.Equal(t, int64(25), reports[2].Processed)
+	assert.True(t, reports[2].Done)
+
+	var enabled int
+	require.NoError(t, DB.Query.GetOne(ctx, DB.SQL.Select("count(*)").From("accounts").Where("NOT disabled"), &enabled))
+	assert.Equal(t, 0, enabled)
+
+	status, err := backfill.Status(ctx, DB)
+	require.NoError(t, err)
+	assert.Equal(t, int64(25), status.Processed)
+	require.NoError(t, backfill.Run(ctx, DB))
+}
+
+func TestCostBudget(t *testing.T) {
+	ctx := context.Background()
+	querier := DB.Query.With(pgkit.CostBudget{MaxRows: 1000}.Middleware())
+	huge := DB.SQL.Select("*").From("generate_series(1, 1000000)")
+
+	estimate, err := DB.Query.Estimate(ctx, huge)
+	require.NoError(t, err)
+	assert.Greater(t, estimate.Rows, float64(1000))
+
+	// not tagged, not checked
+	_, err = querier.Exec(ctx, huge.Limit(1))
+	require.NoError(t, err)
+
+	expensive := pgkit.WithQueryConfig(ctx, pgkit.QueryConfig{Name: "report", Expensive: true})
+	_, err = querier.Exec(expensive, huge)
+	require.ErrorIs(t, err, pgkit.ErrOverBudget)
+
+	var n int
+	require.NoError(t, querier.QueryRow(expensive, DB.SQL.Select("count(*)").From("generate_series(1, 10)")).Scan(&n))
+	assert.Equal(t, 10, n)
+}
+
+func TestShardedPaginator(t *testing.T) {
+	ctx := context.Background()
+	truncateTable(t, "accounts")
+
+	for i := 0; i < 9; i++ {
+		_, err := DB.Query.Exec(ctx, DB.SQL.InsertRecord(&Account{Name: fmt.Sprintf("account-%d", i), Disabled: i%3 == 0}))
+		require.NoError(t, err)
+	}
+
+	// the enabled and disabled accounts as two shards
+	shards := []pgkit.Shard{
+		{Query: DB.SQL.Select("*").From("accounts").Where("disabled"), Exec: DB.Conn},
+		{Query: DB.SQL.Select("*").From("accounts").Where("NOT disabled"), Exec: DB.Conn},
+	}
+	paginator := pgkit.NewShardedPaginator[Account](2, pgkit.WithSort("-name"))
+
+	var names []string
+	for n := uint32(1); ; n++ {
+		page := &pgkit.Page{Page: n, Size: 4}
+		result, err := paginator.Query(ctx, shards, page)
+		require.NoError(t, err)
+		for _, account := range result {
+			names = append(names, account.Name)
+		}
+		if !page.More {
+			assert.Equal(t, uint32(3), page.Page)
+			break
+		}
+	}
+	assert.Equal(t, []string{"account-8", "account-7", "account-6", "account-5", "account-4", "account-3", "account-2", "account-1", "account-0"}, names)
+
+	_, err := paginator.Query(ctx, append(shards, pgkit.Shard{Query: DB.SQL.Select("*").From("missing"), Exec: DB.Conn}), nil)
+	require.Error(t, err)
+}
+
+func TestRollup(t *testing.T) {
+	ctx := context.Background()
+	truncateTable(t, "accounts")
+	_, err := DB.Conn.Exec(ctx, "DROP TABLE IF EXISTS test_rollups, accounts_hourly, accounts_daily")
+	require.NoError(t, err)
+
+	rollup := pgkit.Rollup{
+		Name:       "accounts",
+		Source:     "accounts",
+		TimeColumn: "created_at",
+		Dimensions: []string{"disabled"},
+		Measures:   map[string]string{"n": "count(*)"},
+		Levels: []pgkit.RollupLevel{
+			{Unit: "hour", Table: "accounts_hourly"},
+			{Unit: "day", Table: "accounts_daily"},
+		},
+		Table: "test_rollups",
+	}
+	require.NoError(t, rollup.Install(ctx, DB))
+	require.NoError(t, rollup.Install(ctx, DB))
+
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	insert := func(at time.Time) {
+		_, err := DB.Query.Exec(ctx, DB.SQL.InsertRecord(&Account{Name: "a", CreatedAt: at}))
+		require.NoError(t, err)
+	}
+	type bucket struct {
+		Bucket   time.Time `db:"bucket"`
+		Disabled bool      `db:"disabled"`
+		N        int64     `db:"n"`
+	}
+	read := func(from, to time.Time, max int) []bucket {
+		var buckets []bucket
+		require.NoError(t, DB.Query.GetAll(ctx, rollup.Select(from, to, max), &buckets))
+		for i := range buckets {
+			buckets[i].Bucket = buckets[i].Bucket.UTC()
+		}
+		return buckets
+	}
+
+	// nothing to refresh
+	require.NoError(t, rollup.Refresh(ctx, DB))
+
+	insert(day.Add(time.Hour))
+	insert(day.Add(time.Hour + time.Minute))
+	insert(day.Add(2 * time.Hour))
+	require.NoError(t, rollup.Refresh(ctx, DB))
+	require.Equal(t, []bucket{{day.Add(time.Hour), false, 2}, {day.Add(2 * time.Hour), false, 1}}, read(day, day.Add(24*time.Hour), 24))
+	require.Equal(t, []bucket{{day, false, 3}}, read(day, day.Add(30*24*time.Hour), 24))
+
+	// the bucket of the watermark is recomputed, the earlier ones are kept
+	insert(day.Add(2*time.Hour + time.Minute))
+	insert(day.Add(3 * time.Hour))
+	require.NoError(t, rollup.Refresh(ctx, DB))
+	require.Equal(t, []bucket{{day.Add(time.Hour), false, 2}, {day.Add(2 * time.Hour), false, 2}, {day.Add(3 * time.Hour), false, 1}}, read(day, day.Add(24*time.Hour), 24))
+	require.Equal(t, []bucket{{day, false, 5}}, read(day, day.Add(30*24*time.Hour), 24))
+}
+
+func TestAdminBrowser(t *testing.T) {
+	ctx := context.Background()
+	truncateTable(t, "accounts")
+	for _, name := range []string{"alice", "bob", "carol"} {
+		_, err := DB.Query.Exec(ctx, DB.SQL.InsertRecord(&Account{Name: name}))
+		require.NoError(t, err)
+	}
+
+	tables, err := pgkit.Tables(ctx, DB, "public")
+	require.NoError(t, err)
+	var accounts *pgkit.TableInfo
+	for i := range tables {
+		if tables[i].Name == "accounts" {
+			accounts = &tables[i]
+		}
+	}
+	require.NotNil(t, accounts)
+	require.Equal(t, `"public"."accounts"`, accounts.String())
+	require.Equal(t, []pgkit.ColumnInfo{
+		{Name: "id", Type: "integer", SQLType: "integer"},
+		{Name: "name", Type: "character varying", Nullable: true, SQLType: "character varying(255)"},
+		{Name: "disabled", Type: "boolean", Nullable: true, SQLType: "boolean"},
+		{Name: "created_at", Type: "timestamp with time zone", SQLType: "timestamp with time zone"},
+	}, accounts.Columns)
+
+	server := httptest.NewServer(pgkit.AdminBrowser{DB: DB, Schemas: []string{"public"}, PageSize: 2})
+	defer server.Close()
+	get := func(query string) (int, string) {
+		resp, err := http.Get(server.URL + "/?" + query)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var body strings.Builder
+		_, err = io.Copy(&body, resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, body.String()
+	}
+
+	status, body := get("")
+	require.Equal(t, http.StatusOK, status)
+	require.Contains(t, body, "public.accounts")
+
+	status, body = get("table=public.accounts&sort=-name")
+	require.Equal(t, http.StatusOK, status)
+	require.Contains(t, body, "<td>carol</td>")
+	require.Contains(t, body, "<td>bob</td>")
+	require.NotContains(t, body, "<td>alice</td>")
+	require.Contains(t, body, ">next</a>")
+
+	status, body = get("table=public.accounts&name=like:a%25&disabled=")
+	require.Equal(t, http.StatusOK, status)
+	require.Contains(t, body, "<td>alice</td>")
+	require.Contains(t, body, "<td>carol</td>")
+	require.NotContains(t, body, "<td>bob</td>")
+
+	status, _ = get("table=public.accounts&id=gt:x")
+	require.Equal(t, http.StatusBadRequest, status)
+	status, _ = get("table=public.missing")
+	require.Equal(t, http.StatusNotFound, status)
+}
+
+func TestPaginatorOutOfRange(t *testing.T) {
+	ctx := context.Background()
+	truncateTable(t, "accounts")
+	for i := 0; i < 5; i++ {
+		_, err := DB.Query.Exec(ctx, DB.SQL.InsertRecord(&Account{Name: fmt.Sprint(i)}))
+		require.NoError(t, err)
+	}
+	q := DB.SQL.Select("*").From("accounts")
+
+	// empty by default
+	paginator := pgkit.NewPaginator[Account](pgkit.WithSort("id"))
+	page := &pgkit.Page{Size: 2, Page: 4}
+	rows, err := paginator.Query(ctx, q, page, DB.Conn)
+	require.NoError(t, err)
+	require.Empty(t, rows)
+
+	for _, options := range [][]func(*pgkit.PaginatorOption){
+		{pgkit.WithStrict()},
+		{pgkit.WithOutOfRange(pgkit.OutOfRangeError), pgkit.WithTotalCount()},
+		{pgkit.WithOutOfRange(pgkit.OutOfRangeError), pgkit.WithInlineCount()},
+	} {
+		_, err = paginator.Query(ctx, q, &pgkit.Page{Size: 2, Page: 4}, DB.Conn, options...)
+		require.Equal(t, pgkit.ErrPageOutOfRange{Page: 4, TotalPages: 3}, err)
+
+		// the last page and an empty result aren't out of range
+		rows, err = paginator.Query(ctx, q, &pgkit.Page{Size: 2, Page: 3}, DB.Conn, options...)
+		require.NoError(t, err)
+		require.Len(t, rows, 1)
+		rows, err = paginator.Query(ctx, q.Where(sq.Eq{"name": "none"}), &pgkit.Page{Size: 2, Page: 1}, DB.Conn, options...)
+		require.NoError(t, err)
+		require.Empty(t, rows)
+	}
+
+	page = &pgkit.Page{Size: 2, Page: 4}
+	rows, err = paginator.Query(ctx, q, page, DB.Conn, pgkit.WithOutOfRange(pgkit.OutOfRangeClamp))
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	require.Equal(t, uint32(3), page.Page)
+
+	// nothing to clamp to
+	page = &pgkit.Page{Size: 2, Page: 4}
+	rows, err = paginator.Query(ctx, q.Where(sq.Eq{"name": "none"}), page, DB.Conn, pgkit.WithOutOfRange(pgkit.OutOfRangeClamp))
+	require.NoError(t, err)
+	require.Empty(t, rows)
+	require.Equal(t, uint32(1), page.Page)
+}
+
+func TestChangeFeed(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	truncateTable(t, "accounts")
+
+	feed := &pgkit.ChangeFeed{Channel: "test_changes"}
+	require.NoError(t, feed.Install(ctx, DB, "accounts"))
+	defer feed.Uninstall(context.Background(), DB, "accounts")
+
+	type change struct{ old, new *Account }
+	changes := make(chan change, 10)
+	pgkit.OnChange(feed, "accounts", func(c pgkit.Change[Account]) {
+		require.False(t, c.Truncated)
+		changes <- change{c.Old, c.New}
+	})
+	listening := make(chan error, 1)
+	go func() { listening <- feed.Listen(ctx, DB, nil) }()
+	time.Sleep(200 * time.Millisecond)
+
+	account := &Account{Name: "joe"}
+	require.NoError(t, DB.Query.QueryRow(ctx, DB.SQL.InsertRecord(account).Suffix("RETURNING id, created_at")).Scan(&account.ID, &account.CreatedAt))
+	c := <-changes
+	require.Nil(t, c.old)
+	require.Equal(t, account.ID, c.new.ID)
+	require.Equal(t, "joe", c.new.Name)
+	require.True(t, account.CreatedAt.Equal(c.new.CreatedAt))
+
+	// only the changed columns, and the key
+	_, err := DB.Query.Exec(ctx, DB.SQL.Update("accounts").Set("disabled", true).Where(sq.Eq{"id": account.ID}))
+	require.NoError(t, err)
+	c = <-changes
+	require.Equal(t, &Account{ID: account.ID}, c.old)
+	require.Equal(t, &Account{ID: account.ID, Disabled: true}, c.new)
+
+	// no change, no notification
+	_, err = DB.Query.Exec(ctx, DB.SQL.Update("accounts").Set("disabled", true).Where(sq.Eq{"id": account.ID}))
+	require.NoError(t, err)
+
+	_, err = DB.Query.Exec(ctx, DB.SQL.Delete("accounts").Where(sq.Eq{"id": account.ID}))
+	require.NoError(t, err)
+	c = <-changes
+	require.Nil(t, c.new)
+	require.Equal(t, account.ID, c.old.ID)
+	require.Equal(t, "joe", c.old.Name)
+	require.True(t, c.old.Disabled)
+
+	// a mixed case name addresses the same table in all the statements
+	_, err = DB.Conn.Exec(ctx, `DROP TABLE IF EXISTS "FeedItems"; CREATE TABLE "FeedItems" (id int PRIMARY KEY, name text)`)
+	require.NoError(t, err)
+	t.Cleanup(func() { DB.Conn.Exec(context.Background(), `DROP TABLE "FeedItems"`) })
+	require.NoError(t, feed.Install(ctx, DB, "FeedItems"))
+	t.Cleanup(func() { feed.Uninstall(context.Background(), DB, "FeedItems") })
+	type feedItem struct {
+		ID   int    `db:"id"`
+		Name string `db:"name"`
+	}
+	items := make(chan pgkit.Change[feedItem], 1)
+	pgkit.OnChange(feed, "FeedItems", func(c pgkit.Change[feedItem]) { items <- c })
+	_, err = DB.Conn.Exec(ctx, `INSERT INTO "FeedItems" VALUES (1, 'joe')`)
+	require.NoError(t, err)
+	item := <-items
+	assert.Equal(t, &feedItem{ID: 1, Name: "joe"}, item.New)
+	assert.False(t, item.Truncated)
+
+	// a diff too large for a notification only holds the key
+	_, err = DB.Conn.Exec(ctx, `UPDATE "FeedItems" SET name = repeat('x', 8000) WHERE id = 1`)
+	require.NoError(t, err)
+	item = <-items
+	assert.True(t, item.Truncated)
+	assert.Equal(t, "UPDATE", item.Op)
+	assert.Equal(t, &feedItem{ID: 1}, item.Old)
+	assert.Equal(t, &feedItem{ID: 1}, item.New)
+
+	cancel()
+	require.ErrorIs(t, <-listening, context.Canceled)
+}
+
+func TestRelation(t *testing.T) {
+	ctx := context.Background()
+	truncateTable(t, "accounts")
+	for _, name := range []string{"alice", "bob", "carol", "dave"} {
+		_, err := DB.Query.Exec(ctx, DB.SQL.InsertRecord(&Account{Name: name, Disabled: name == "bob"}))
+		require.NoError(t, err)
+	}
+	_, err := DB.Conn.Exec(ctx, `
+		CREATE OR REPLACE VIEW active_accounts AS SELECT id, name FROM accounts WHERE NOT disabled;
+		CREATE OR REPLACE FUNCTION accounts_named(prefix text) RETURNS SETOF accounts
+		LANGUAGE sql STABLE AS $$ SELECT * FROM accounts WHERE name >= prefix $$;`)
+	require.NoError(t, err)
+	defer DB.Conn.Exec(ctx, `DROP VIEW active_accounts; DROP FUNCTION accounts_named`)
+
+	view := pgkit.Relation{Name: "active_accounts"}
+	columns, err := view.Columns(ctx, DB)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"id", "name"}, columns)
+	allowed, err := view.AllowedColumns(ctx, DB)
+	require.NoError(t, err)
+
+	paginator := pgkit.NewPaginator[Account](pgkit.WithSort("id"), allowed)
+	// disabled isn't a column of the view, it's dropped from the sort
+	page := pgkit.NewPage(2, 1, pgkit.Sort{Column: "name", Order: pgkit.Desc}, pgkit.Sort{Column: "disabled"})
+	rows, err := paginator.Query(ctx, view.From(DB.SQL.Select("*")), page, DB.Conn)
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, "dave", rows[0].Name)
+	assert.Equal(t, "carol", rows[1].Name)
+
+	// the function isn't called to read its columns
+	fn := pgkit.Relation{Name: "accounts_named", Function: true, Args: []interface{}{"bob"}, Alias: "a"}
+	columns, err = fn.Columns(ctx, DB)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"id", "name", "disabled", "created_at"}, columns)
+
+	page = pgkit.NewPage(2, 2, pgkit.Sort{Column: "name"})
+	rows, err = pgkit.NewPaginator[Account](pgkit.WithSort("id")).Query(ctx, fn.From(DB.SQL.Select("a.*")), page, DB.Conn)
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, "dave", rows[0].Name)
+}
+
+func TestResolveTable(t *testing.T) {
+	ctx := context.Background()
+	_, err := DB.Conn.Exec(ctx, `CREATE SCHEMA IF NOT EXISTS tenant; CREATE TABLE IF NOT EXISTS tenant.settings (id int)`)
+	require.NoError(t, err)
+	defer DB.Conn.Exec(ctx, `DROP SCHEMA tenant CASCADE`)
+
+	builder := DB.SQL.WithSearchPath("tenant", "public")
+	table, err := builder.ResolveTable(ctx, DB, "accounts")
+	require.NoError(t, err)
+	assert.Equal(t, `"public".accounts`, table)
+	table, err = builder.ResolveTable(ctx, DB, "settings")
+	require.NoError(t, err)
+	assert.Equal(t, `"tenant".settings`, table)
+	_, err = builder.ResolveTable(ctx, DB, "nothing")
+	assert.Error(t, err)
+
+	qualified := builder.WithQualifiedTables(true)
+	var count int
+	err = DB.Query.GetOne(ctx, qualified.Select("count(*)").From(qualified.Table("settings")), &count)
+	require.NoError(t, err)
+}
+
+func TestWarmup(t *testing.T) {
+	ctx := context.Background()
+
+	n := int(DB.Conn.Config().MaxConns)
+	require.NoError(t, DB.Warmup(ctx, n+10, "SELECT id FROM accounts WHERE id = $1"))
+	assert.Equal(t, int32(n), DB.Conn.Stat().TotalConns())
+
+	var ids []int64
+	require.NoError(t, pgxscan.Select(ctx, DB.Conn, &ids, "SELECT id FROM accounts WHERE id = $1", 1))
+
+	assert.Error(t, DB.Warmup(ctx, 1, "SELECT nothing FROM accounts"))
+}
+
+func TestScan(t *testing.T) {
+	ctx := context.Background()
+
+	type Audit struct {
+		CreatedBy string
+	}
+	type Row struct {
+		Audit
+		ID       int64
+		Name     *string
+		Period   pgtype.Range[int64]
+		Author   struct{ Name string }
+		Disabled bool `db:"is_disabled"`
+	}
+	rows, err := DB.Conn.Query(ctx, `SELECT 1 AS id, 'a' AS name, 'joe' AS created_by, int8range(1, 5) AS period, 'jane' AS "author.name", true AS is_disabled
+		UNION ALL SELECT 2, NULL, 'joe', NULL, 'jim', false ORDER BY id`)
+	require.NoError(t, err)
+	result, err := pgkit.Scan[Row](rows)
+	require.NoError(t, err)
+	require.Len(t, result, 2)
+	assert.Equal(t, "a", *result[0].Name)
+	assert.Equal(t, "joe", result[0].CreatedBy)
+	assert.Equal(t, int64(5), result[0].Period.Upper)
+	assert.Equal(t, "jane", result[0].Author.Name)
+	assert.True(t, result[0].Disabled)
+	assert.Nil(t, result[1].Name)
+	assert.False(t, result[1].Period.Valid)
+
+	rows, err = DB.Conn.Query(ctx, `SELECT count(*) FROM generate_series(1, 3)`)
+	require.NoError(t, err)
+	n, err := pgkit.ScanOne[int](rows)
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+
+	rows, err = DB.Conn.Query(ctx, `SELECT 1 WHERE false`)
+	require.NoError(t, err)
+	_, err = pgkit.ScanOne[int](rows)
+	assert.ErrorIs(t, err, pgkit.ErrNoRows)
+}
+
+func TestUpsert(t *testing.T) {
+	ctx := context.Background()
+	_, err := DB.Conn.Exec(ctx, `
+		DROP TABLE IF EXISTS upsert_items;
+		CREATE TABLE upsert_items (sku text PRIMARY KEY, name text NOT NULL, price int NOT NULL);
+		INSERT INTO upsert_items VALUES ('a', 'Apple', 10), ('b', 'Banana', 5);`)
+	require.NoError(t, err)
+	t.Cleanup(func() { DB.Conn.Exec(ctx, `DROP TABLE upsert_items`) })
+
+	type Item struct {
+		SKU   string `db:"sku"`
+		Name  string `db:"name"`
+		Price int    `db:"price"`
+	}
+	items := []Item{{"c", "Cherry", 3}, {"a", "Apple", 10}, {"b", "Banana", 6}}
+	results, err := pgkit.Upsert(ctx, DB.Query, "upsert_items", items, []string{"sku"}, func(i Item) string { return i.SKU })
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	assert.Equal(t, pgkit.UpsertInserted, results[0].Outcome)
+	assert.Equal(t, pgkit.UpsertUnchanged, results[1].Outcome)
+	assert.Equal(t, pgkit.UpsertUpdated, results[2].Outcome)
+	assert.Equal(t, 6, results[2].Record.Price)
+}
+
+func TestPaginatorObserver(t *testing.T) {
+	ctx := context.Background()
+	truncateTable(t, "accounts")
+
+	for i := 1; i <= 5; i++ {
+		_, err := DB.Query.Exec(ctx, DB.SQL.InsertRecord(&Account{Name: fmt.Sprintf("user%d", i)}))
+		require.NoError(t, err)
+	}
+
+	var stats []pgkit.QueryStats
+	paginator := pgkit.NewPaginator[Account](pgkit.WithSort("id"), pgkit.WithTotalCount(), pgkit.WithObserver(func(ctx context.Context, s pgkit.QueryStats) {
+		stats = append(stats, s)
+	}))
+	_, err := paginator.Query(ctx, DB.SQL.Select("*").From("accounts"), pgkit.NewPage(2, 2), DB.Conn)
+	require.NoError(t, err)
+	require.Len(t, stats, 2)
+	assert.False(t, stats[0].Count)
+	assert.Equal(t, 3, stats[0].Rows)
+	assert.Equal(t, uint32(2), stats[0].Page.Page)
+	assert.Contains(t, stats[0].SQL, "LIMIT 3 OFFSET 2")
+	assert.True(t, stats[1].Count)
+	assert.NoError(t, stats[1].Err)
+	assert.Positive(t, stats[1].Duration)
+}
+
+func TestPaginatorCount(t *testing.T) {
+	ctx := context.Background()
+	truncateTable(t, "accounts")
+
+	for i := 1; i <= 7; i++ {
+		_, err := DB.Query.Exec(ctx, DB.SQL.InsertRecord(&Account{Name: fmt.Sprintf("user%d", i)}))
+		require.NoError(t, err)
+	}
+
+	paginator := pgkit.NewPaginator[Account](pgkit.WithSort("id"), pgkit.WithTotalCount())
+	page := pgkit.NewPage(3, 3)
+	result, q := paginator.PrepareQuery(DB.SQL.Select("*").From("accounts"), page)
+	require.NoError(t, DB.Query.GetAll(ctx, q, &result))
+	require.NoError(t, paginator.Count(ctx, DB.Query, q, page))
+	result = paginator.PrepareResult(result, page)
+
+	assert.Len(t, result, 1)
+	assert.Equal(t, uint64(7), page.Total)
+	assert.Equal(t, uint32(3), page.TotalPages)
+	assert.Equal(t, uint64(7), page.From)
+	assert.Equal(t, uint64(7), page.To)
+}
+
+func TestGrid(t *testing.T) {
+	ctx := context.Background()
+	truncateTable(t, "accounts")
+
+	for i := 1; i <= 5; i++ {
+		_, err := DB.Query.Exec(ctx, DB.SQL.InsertRecord(&Account{Name: fmt.Sprintf("user%d", i), Disabled: i%2 == 0}))
+		require.NoError(t, err)
+	}
+
+	grid := pgkit.Grid[*Account]{
+		Querier:   DB.Query,
+		Query:     DB.SQL.Select("*").From("accounts"),
+		Columns:   []string{"id", "name", "disabled"},
+		Paginator: pgkit.NewPaginator[*Account](pgkit.WithSort("id")),
+	}
+
+	w := httptest.NewRecorder()
+	grid.Handle(w, httptest.NewRequest("GET", "/?fields=name&disabled=false&sort=-name&size=2", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp pgkit.GridPage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, []map[string]interface{}{{"name": "user5"}, {"name": "user3"}}, resp.Rows)
+	assert.True(t, resp.Page.More)
+
+	w = httptest.NewRecorder()
+	grid.Handle(w, httptest.NewRequest("GET", "/?fields=id,name&name=in:user1,user2&format=csv&sort=name", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Regexp(t, `^id,name\n\d+,user1\n\d+,user2\n$`, w.Body.String())
+
+	grid.Filters = pgkit.FilterSchema{"id": {Type: pgkit.FilterInt}, "name": {}}
+	for _, query := range []string{"fields=password", "password=x", "name.in=x", "page=x", "id=x", "name=gt:x", "disabled=true"} {
+		w = httptest.NewRecorder()
+		grid.Handle(w, httptest.NewRequest("GET", "/?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+
+	// a failed export isn't served as an empty file
+	broken := pgkit.Grid[*struct {
+		Name int `db:"name"`
+	}]{Querier: DB.Query, Query: DB.SQL.Select("*").From("accounts"), Columns: []string{"name"}}
+	w = httptest.NewRecorder()
+	broken.Handle(w, httptest.NewRequest("GET", "/?format=csv", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.NotContains(t, w.Body.String(), "name\n")
+}
+
+func TestAlerter(t *testing.T) {
+	ctx := context.Background()
+
+	alerts := make(chan pgkit.Alert, 1)
+	alerter := pgkit.AlertFunc(func(ctx context.Context, alert pgkit.Alert) { alerts <- alert })
+	DB.Alerter = alerter
+	defer func() { DB.Alerter = nil }()
+
+	// the queries canceled aren't part of the default kinds
+	DB.Alert(ctx, pgkit.Alert{Kind: pgkit.AlertQueriesCanceled})
+	assert.Len(t, alerts, 0)
+	DB.Alerter = pgkit.AlertKinds(alerter, append(pgkit.DefaultAlertKinds, pgkit.AlertQueriesCanceled)...)
+
+	done := make(chan error)
+	go func() {
+		_, err := DB.Query.Exec(ctx, pgkit.RawQuery("SELECT pg_sleep(0.5)").Build())
+		done <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+	require.Equal(t, 1, DB.CancelInFlight("deploy"))
+	require.Error(t, <-done)
+
+	alert := <-alerts
+	assert.Equal(t, pgkit.AlertQueriesCanceled, alert.Kind)
+	assert.Contains(t, alert.Message, "deploy")
+	assert.False(t, alert.Time.IsZero())
+
+	// the serialization failures are retried, then alerted
+	attempts := 0
+	serialization := &pgconn.PgError{Code: "40001"}
+	err := pgkit.SerializableTx(ctx, DB, 3, func(tx pgx.Tx) error {
+		attempts++
+		return serialization
+	})
+	assert.ErrorIs(t, err, serialization)
+	assert.Equal(t, 3, attempts)
+	alert = <-alerts
+	assert.Equal(t, pgkit.AlertSerializationRetriesExhausted, alert.Kind)
+	assert.ErrorIs(t, alert.Err, serialization)
+
+	attempts = 0
+	err = pgkit.SerializableTx(ctx, DB, 3, func(tx pgx.Tx) error {
+		if attempts++; attempts < 2 {
+			return serialization
+		}
+		var level string
+		return tx.QueryRow(ctx, `SHOW transaction_isolation`).Scan(&level)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, attempts)
+	assert.Len(t, alerts, 0)
+}
+
+func TestPaginatorQuery(t *testing.T) {
+	ctx := context.Background()
+	truncateTable(t, "accounts")
+
+	for i := 1; i <= 5; i++ {
+		_, err := DB.Query.Exec(ctx, DB.SQL.InsertRecord(&Account{Name: fmt.Sprintf("user%d", i)}))
+		require.NoError(t, err)
+	}
+
+	paginator := pgkit.NewPaginator[*Account](pgkit.WithSort("-name"), pgkit.WithTotalCount())
+	page := pgkit.NewPage(2, 1)
+	accounts, err := paginator.Query(ctx, DB.SQL.Select("*").From("accounts"), page, DB.Conn)
+	require.NoError(t, err)
+	require.Len(t, accounts, 2)
+	assert.Equal(t, "user5", accounts[0].Name)
+	assert.True(t, page.More)
+	assert.Equal(t, uint64(5), page.Total)
+
+	err = pgx.BeginFunc(ctx, DB.Conn, func(tx pgx.Tx) error {
+		accounts, err = paginator.Query(ctx, DB.SQL.Select("*").From("accounts"), pgkit.NewPage(2, 3), tx)
+		return err
+	})
+	require.NoError(t, err)
+	require.Len(t, accounts, 1)
+	assert.Equal(t, "user1", accounts[0].Name)
+
+	schema := pgkit.FilterSchema{"name": {}}
+	filters, err := schema.FiltersFromValues(url.Values{"name": {"in:user1,user2,user3"}})
+	require.NoError(t, err)
+	page = pgkit.NewPage(2, 1)
+	accounts, err = paginator.Query(ctx, DB.SQL.Select("*").From("accounts"), page, DB.Conn, pgkit.WithFilters(filters))
+	require.NoError(t, err)
+	require.Len(t, accounts, 2)
+	assert.Equal(t, "user3", accounts[0].Name)
+	assert.Equal(t, uint64(3), page.Total)
+}
+
+func TestNotifications(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	type event struct {
+		Name string `json:"name"`
+	}
+	var n pgkit.Notifications
+	require.NoError(t, n.Register("test_events", pgkit.NotificationSchema{
+		Version: 2,
+		Schema:  json.RawMessage(`{"type": "object", "required": ["name"], "properties": {"name": {"type": "string"}}}`),
+	}))
+
+	events := make(chan event, 10)
+	rejected := make(chan error, 10)
+	listening := make(chan error, 1)
+	go func() {
+		listening <- pgkit.Listen(ctx, DB, &n, "test_events", func(e event) error {
+			events <- e
+			return nil
+		}, func(payload string, err error) error {
+			rejected <- err
+			return nil
+		})
+	}()
+	time.Sleep(200 * time.Millisecond)
+
+	require.NoError(t, n.Notify(ctx, DB, "test_events", event{Name: "joe"}))
+	require.Equal(t, event{Name: "joe"}, <-events)
+
+	_, err := DB.Conn.Exec(ctx, `SELECT pg_notify('test_events', '{"name": "joe", "version": 1}')`)
+	require.NoError(t, err)
+	require.ErrorIs(t, <-rejected, pgkit.ErrPayloadVersion)
+
+	_, err = DB.Conn.Exec(ctx, `SELECT pg_notify('test_events', '{"name": 1, "version": 2}')`)
+	require.NoError(t, err)
+	require.ErrorIs(t, <-rejected, pgkit.ErrInvalidPayload)
+
+	cancel()
+	require.ErrorIs(t, <-listening, context.Canceled)
+}
+
+func TestRegisterTypes(t *testing.T) {
+	ctx := context.Background()
+	_, err := DB.Conn.Exec(ctx, `
+		DROP TYPE IF EXISTS "Mood", types_point, types_positive;
+		CREATE TYPE "Mood" AS ENUM ('happy', 'sad');
+		CREATE TYPE types_point AS (x int, y int);
+		CREATE DOMAIN types_positive AS int CHECK (VALUE > 0);`)
+	require.NoError(t, err)
+	t.Cleanup(func() { DB.Conn.Exec(ctx, `DROP TYPE "Mood", types_point, types_positive`) })
+
+	cfg := DB.Conn.Config()
+	pgkit.RegisterEnum(cfg, "public.Mood")
+	pgkit.RegisterCompositeType(cfg, "types_point")
+	pgkit.RegisterDomain(cfg, "types_positive")
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	var moods []string
+	require.NoError(t, pool.QueryRow(ctx, `SELECT '{happy,sad}'::"Mood"[]`).Scan(&moods))
+	assert.Equal(t, []string{"happy", "sad"}, moods)
+
+	var x, y int
+	require.NoError(t, pool.QueryRow(ctx, `SELECT (1, 2)::types_point`).Scan(pgtype.CompositeFields{&x, &y}))
+	assert.Equal(t, []int{1, 2}, []int{x, y})
+
+	var n int
+	require.NoError(t, pool.QueryRow(ctx, `SELECT 3::types_positive`).Scan(&n))
+	assert.Equal(t, 3, n)
+
+	// the name is case sensitive
+	cfg = DB.Conn.Config()
+	pgkit.RegisterEnum(cfg, "mood")
+	pool, err = pgxpool.NewWithConfig(ctx, cfg)
+	require.NoError(t, err)
+	defer pool.Close()
+	assert.Error(t, pool.Ping(ctx))
+}
@@ -0,0 +1,124 @@
+package pgkit
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// TimePage is a window of time-series rows, ie. events or logs, paginated by periods rather
+// than by number of rows: it holds the rows whose time column is in [From, To), sorted in
+// the Order direction, which is also the one of NextWindow.
+type TimePage struct {
+	Column string    `json:"column"`
+	From   time.Time `json:"from"`
+	To     time.Time `json:"to"`
+	Order  OrderType `json:"order"`
+}
+
+// Window returns the duration of the page.
+func (t TimePage) Window() time.Duration {
+	return t.To.Sub(t.From)
+}
+
+// NextWindow returns the following window of the same duration, the later one when sorted
+// ascending, the earlier one when descending.
+func (t TimePage) NextWindow() TimePage {
+	d := t.Window()
+	if (Sort{Order: t.Order}).normalize().Order == Desc {
+		d = -d
+	}
+	t.From, t.To = t.From.Add(d), t.To.Add(d)
+	return t
+}
+
+// ToSql implements sq.Sqlizer, returning the predicate of the window.
+func (t TimePage) ToSql() (string, []interface{}, error) {
+	return sq.And{sq.GtOrEq{t.Column: t.From}, sq.Lt{t.Column: t.To}}.ToSql()
+}
+
+// PrepareTimeQuery restricts the query to the rows of the time window, sorted by the time
+// column, then by the tiebreaker if any. The rows aren't limited, the window is the page.
+// The filters and the search of the options apply, as in PrepareQuery.
+func (p Paginator[T]) PrepareTimeQuery(q sq.SelectBuilder, page TimePage, options ...func(*PaginatorOption)) ([]T, sq.SelectBuilder) {
+	p = p.with(options)
+	if p.scope != nil {
+		q = q.Where(errSqlizer{ErrScopeWithoutContext})
+	}
+	q = p.wrapGrouped(p.search.where(where(q, p.filters))).Where(page)
+	sort := []Sort{{Column: page.Column, Order: page.Order}}
+	if p.tiebreaker != "" {
+		sort = p.withTiebreaker(sort)
+	}
+	return []T{}, q.OrderBy(sortStrings(sort)...)
+}
+
+// PrepareTimeQueryContext is like PrepareTimeQuery, applying the scope of ctx, see WithScope.
+func (p Paginator[T]) PrepareTimeQueryContext(ctx context.Context, q sq.SelectBuilder, page TimePage, options ...func(*PaginatorOption)) ([]T, sq.SelectBuilder) {
+	p = p.with(options)
+	p.PaginatorOption = p.withScope(ctx)
+	return p.PrepareTimeQuery(q, page)
+}
+
+// TimePageFromValues parses the time window of the column from query parameters such as
+// `?from=2024-01-01&window=7d&order=desc`. The from and to times are RFC 3339 or dates, the
+// window is a duration, ie. "90m", "24h", or a number of days or weeks, ie. "7d" or "2w", and
+// is used when to is missing, defaulting to defaultWindow. The order defaults to ascending.
+func TimePageFromValues(values url.Values, column string, defaultWindow time.Duration) (TimePage, error) {
+	t := TimePage{Column: column, Order: Asc}
+	if v := values.Get("order"); v != "" {
+		order, err := ParseOrderType(v)
+		if err != nil {
+			return TimePage{}, err
+		}
+		t.Order = order
+	}
+	var err error
+	if t.From, err = parseTime(values.Get("from")); err != nil {
+		return TimePage{}, fmt.Errorf("pgkit: invalid from %q", values.Get("from"))
+	}
+	if v := values.Get("to"); v != "" {
+		if t.To, err = parseTime(v); err != nil || !t.To.After(t.From) {
+			return TimePage{}, fmt.Errorf("pgkit: invalid to %q", v)
+		}
+		return t, nil
+	}
+	window := defaultWindow
+	if v := values.Get("window"); v != "" {
+		if window, err = parseWindow(v); err != nil {
+			return TimePage{}, fmt.Errorf("pgkit: invalid window %q", v)
+		}
+	}
+	if window <= 0 {
+		return TimePage{}, fmt.Errorf("pgkit: missing time window")
+	}
+	t.To = t.From.Add(window)
+	return t, nil
+}
+
+func parseTime(s string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", s)
+}
+
+func parseWindow(s string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if strings.HasSuffix(s, suffix) {
+			days, err := strconv.ParseUint(strings.TrimSuffix(s, suffix), 10, 16)
+			if err != nil {
+				return 0, err
+			}
+			return time.Duration(days) * unit, nil
+		}
+	}
+	return time.ParseDuration(s)
+}
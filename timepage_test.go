@@ -0,0 +1,50 @@
+package pgkit_test
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/goware/pgkit/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimePage(t *testing.T) {
+	values, err := url.ParseQuery("from=2024-01-01&window=7d&order=desc")
+	require.NoError(t, err)
+	page, err := pgkit.TimePageFromValues(values, "created_at", time.Hour)
+	require.NoError(t, err)
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.Equal(t, pgkit.TimePage{Column: "created_at", From: from, To: from.AddDate(0, 0, 7), Order: pgkit.Desc}, page)
+
+	paginator := pgkit.NewPaginator[T](pgkit.WithTiebreaker("id"))
+	_, q := paginator.PrepareTimeQuery(sq.Select("*").From("events").Where(sq.Eq{"kind": "login"}), page)
+	sql, args, err := q.ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "SELECT * FROM events WHERE kind = ? AND (created_at >= ? AND created_at < ?) ORDER BY created_at DESC, id DESC", sql)
+	require.Equal(t, []interface{}{"login", from, from.AddDate(0, 0, 7)}, args)
+
+	next := page.NextWindow()
+	require.Equal(t, from.AddDate(0, 0, -7), next.From)
+	require.Equal(t, from, next.To)
+	page.Order = pgkit.Asc
+	require.Equal(t, from.AddDate(0, 0, 14), page.NextWindow().To)
+
+	page, err = pgkit.TimePageFromValues(url.Values{"from": {"2024-01-01T10:00:00Z"}, "to": {"2024-01-01T12:00:00Z"}}, "at", 0)
+	require.NoError(t, err)
+	require.Equal(t, 2*time.Hour, page.Window())
+	page, err = pgkit.TimePageFromValues(url.Values{"from": {"2024-01-01"}}, "at", 24*time.Hour)
+	require.NoError(t, err)
+	require.Equal(t, 24*time.Hour, page.Window())
+	page, err = pgkit.TimePageFromValues(url.Values{"from": {"2024-01-01"}, "window": {"90m"}}, "at", 0)
+	require.NoError(t, err)
+	require.Equal(t, 90*time.Minute, page.Window())
+
+	for _, query := range []string{"", "from=yesterday&window=1d", "from=2024-01-01", "from=2024-01-01&window=xd", "from=2024-01-02&to=2024-01-01", "from=2024-01-01&window=1d&order=up"} {
+		values, err := url.ParseQuery(query)
+		require.NoError(t, err)
+		_, err = pgkit.TimePageFromValues(values, "at", 0)
+		require.Error(t, err, query)
+	}
+}
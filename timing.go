@@ -0,0 +1,197 @@
+package pgkit
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// QueryTiming is the timing breakdown of a query reported by the Timing middleware.
+type QueryTiming struct {
+	SQL    string
+	Args   []interface{}
+	Config QueryConfig
+
+	// FirstRow is the time until the first row (or the command tag) was received, it
+	// accounts for planning, execution and network latency.
+	FirstRow time.Duration
+	// Fetch is the time spent waiting for rows, including FirstRow.
+	Fetch time.Duration
+	// Scan is the time spent decoding rows into the destinations.
+	Scan time.Duration
+	// Total is the time until the rows were closed, it includes the time the caller
+	// spent between rows, ie. mapping them into structs.
+	Total time.Duration
+
+	Rows int
+	Err  error
+}
+
+// Timing returns a middleware reporting the timing breakdown of every query to fn,
+// once the query is done. Batches are reported as a single query.
+func Timing(fn func(ctx context.Context, t QueryTiming)) Middleware {
+	return func(next Executor) Executor {
+		return timingExecutor{report: fn, next: next}
+	}
+}
+
+// SlowQueries returns a middleware reporting the queries slower than the threshold of the
+// configuration to fn, ie. to log them, see Config.SlowQueryThreshold and Timing. The
+// threshold is read by each query, so it follows ApplyConfig.
+func (d *DB) SlowQueries(fn func(ctx context.Context, t QueryTiming)) Middleware {
+	return Timing(func(ctx context.Context, t QueryTiming) {
+		if threshold := time.Duration(d.slowQuery.Load()); threshold > 0 && t.Total >= threshold {
+			fn(ctx, t)
+		}
+	})
+}
+
+type timingExecutor struct {
+	report func(context.Context, QueryTiming)
+	next   Executor
+}
+
+func (e timingExecutor) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	t0 := time.Now()
+	tag, err := e.next.Exec(ctx, sql, args...)
+	d := time.Since(t0)
+	e.report(ctx, QueryTiming{
+		SQL: sql, Args: args, Config: GetQueryConfig(ctx),
+		FirstRow: d, Fetch: d, Total: d, Rows: int(tag.RowsAffected()), Err: err,
+	})
+	return tag, err
+}
+
+func (e timingExecutor) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	t := &timingRows{ctx: ctx, report: e.report, start: time.Now()}
+	t.timing = QueryTiming{SQL: sql, Args: args, Config: GetQueryConfig(ctx)}
+	rows, err := e.next.Query(ctx, sql, args...)
+	if err != nil {
+		t.timing.Err = err
+		t.done()
+		return nil, err
+	}
+	t.Rows = rows
+	return t, nil
+}
+
+func (e timingExecutor) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	t0 := time.Now()
+	row := e.next.QueryRow(ctx, sql, args...)
+	return timingRow{ctx: ctx, report: e.report, row: row, start: t0, timing: QueryTiming{SQL: sql, Args: args, Config: GetQueryConfig(ctx)}}
+}
+
+func (e timingExecutor) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	sql := make([]string, len(b.QueuedQueries))
+	for i, q := range b.QueuedQueries {
+		sql[i] = q.SQL
+	}
+	return &timingBatch{
+		BatchResults: e.next.SendBatch(ctx, b),
+		ctx:          ctx,
+		report:       e.report,
+		start:        time.Now(),
+		timing:       QueryTiming{SQL: strings.Join(sql, ";\n"), Config: GetQueryConfig(ctx)},
+	}
+}
+
+type timingRows struct {
+	pgx.Rows
+	ctx      context.Context
+	report   func(context.Context, QueryTiming)
+	start    time.Time
+	timing   QueryTiming
+	reported bool
+}
+
+func (r *timingRows) Next() bool {
+	t0 := time.Now()
+	ok := r.Rows.Next()
+	r.timing.Fetch += time.Since(t0)
+	if r.timing.FirstRow == 0 {
+		r.timing.FirstRow = time.Since(r.start)
+	}
+	if ok {
+		r.timing.Rows++
+	} else {
+		r.timing.Err = r.Rows.Err()
+		r.done()
+	}
+	return ok
+}
+
+func (r *timingRows) Scan(dest ...interface{}) error {
+	t0 := time.Now()
+	err := r.Rows.Scan(dest...)
+	r.timing.Scan += time.Since(t0)
+	return err
+}
+
+func (r *timingRows) Values() ([]interface{}, error) {
+	t0 := time.Now()
+	values, err := r.Rows.Values()
+	r.timing.Scan += time.Since(t0)
+	return values, err
+}
+
+func (r *timingRows) Close() {
+	r.Rows.Close()
+	if r.timing.Err == nil {
+		r.timing.Err = r.Rows.Err()
+	}
+	r.done()
+}
+
+func (r *timingRows) done() {
+	if r.reported {
+		return
+	}
+	r.reported = true
+	r.timing.Total = time.Since(r.start)
+	r.report(r.ctx, r.timing)
+}
+
+type timingRow struct {
+	ctx    context.Context
+	report func(context.Context, QueryTiming)
+	row    pgx.Row
+	start  time.Time
+	timing QueryTiming
+}
+
+func (r timingRow) Scan(dest ...interface{}) error {
+	err := r.row.Scan(dest...)
+	d := time.Since(r.start)
+	r.timing.FirstRow, r.timing.Fetch, r.timing.Total = d, d, d
+	if err == nil {
+		r.timing.Rows = 1
+	} else if err != pgx.ErrNoRows {
+		r.timing.Err = err
+	}
+	r.report(r.ctx, r.timing)
+	return err
+}
+
+type timingBatch struct {
+	pgx.BatchResults
+	ctx      context.Context
+	report   func(context.Context, QueryTiming)
+	start    time.Time
+	timing   QueryTiming
+	reported bool
+}
+
+func (b *timingBatch) Close() error {
+	err := b.BatchResults.Close()
+	if b.reported {
+		return err
+	}
+	b.reported = true
+	d := time.Since(b.start)
+	b.timing.FirstRow, b.timing.Fetch, b.timing.Total, b.timing.Err = d, d, d, err
+	b.report(b.ctx, b.timing)
+	return err
+}
@@ -0,0 +1,92 @@
+package pgkit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/goware/pgkit/v2"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+)
+
+type sleepExecutor struct {
+	d time.Duration
+}
+
+func (e sleepExecutor) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	time.Sleep(e.d)
+	return pgconn.NewCommandTag("UPDATE 3"), nil
+}
+
+func (e sleepExecutor) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	panic("not implemented")
+}
+
+func (e sleepExecutor) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	panic("not implemented")
+}
+
+func (e sleepExecutor) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	panic("not implemented")
+}
+
+func TestTiming(t *testing.T) {
+	var timings []pgkit.QueryTiming
+	querier := pgkit.NewQuerier(sleepExecutor{d: 10 * time.Millisecond}).With(pgkit.Timing(func(ctx context.Context, t pgkit.QueryTiming) {
+		timings = append(timings, t)
+	}))
+
+	ctx := pgkit.WithQueryConfig(context.Background(), pgkit.QueryConfig{Name: "disable_accounts"})
+	_, err := querier.Exec(ctx, querier.SQL.Update("accounts").Set("disabled", true).Where(sq.Eq{"id": []int{1, 2, 3}}))
+	require.NoError(t, err)
+
+	require.Len(t, timings, 1)
+	require.Equal(t, "UPDATE accounts SET disabled = $1 WHERE id IN ($2,$3,$4)", timings[0].SQL)
+	require.Equal(t, "disable_accounts", timings[0].Config.Name)
+	require.Equal(t, 3, timings[0].Rows)
+	require.GreaterOrEqual(t, timings[0].Total, 10*time.Millisecond)
+}
+
+func TestSlowQueries(t *testing.T) {
+	ctx := context.Background()
+	cfg := pgkit.Config{Host: "localhost", Database: "pgkit_none", SlowQueryThreshold: "1h"}
+	db, err := pgkit.Connect("pgkit_test", cfg)
+	require.NoError(t, err)
+	defer db.Pool().Close()
+	conn, paginator := db.Pool(), pgkit.NewPaginator[T](pgkit.WithPageSizes(db))
+	t.Cleanup(func() { db.ApplyConfig(ctx, pgkit.Config{Host: "localhost", Database: "pgkit_none"}) })
+
+	var slow []pgkit.QueryTiming
+	querier := pgkit.NewQuerier(sleepExecutor{d: 10 * time.Millisecond}).With(db.SlowQueries(func(ctx context.Context, t pgkit.QueryTiming) {
+		slow = append(slow, t)
+	}))
+	update := querier.SQL.Update("accounts").Set("disabled", true)
+	_, err = querier.Exec(ctx, update)
+	require.NoError(t, err)
+	require.Empty(t, slow)
+
+	// the settings are applied in place, the pool is kept
+	cfg.SlowQueryThreshold, cfg.DefaultPageSize, cfg.MaxPageSize = "5ms", 20, 200
+	require.NoError(t, db.ApplyConfig(ctx, cfg))
+	require.Same(t, conn, db.Pool())
+	_, err = querier.Exec(ctx, update)
+	require.NoError(t, err)
+	require.Len(t, slow, 1)
+	require.Equal(t, uint32(20), paginator.Config().DefaultSize)
+	require.Equal(t, uint32(200), paginator.Config().MaxSize)
+	require.Equal(t, uint32(30), pgkit.NewPaginator[T](pgkit.WithPageSizes(db), pgkit.WithMaxSize(30)).Config().MaxSize)
+	// the sizes of the DB don't leak to the other paginators
+	require.Equal(t, uint32(pgkit.MaxPageSize), pgkit.NewPaginator[T]().Config().MaxSize)
+	require.Equal(t, uint64(pgkit.DefaultPageSize), (&pgkit.Page{}).Limit())
+	require.Equal(t, uint64(pgkit.MaxPageSize), (&pgkit.Page{Size: 100}).Limit())
+
+	// an invalid configuration isn't applied
+	cfg.DefaultPageSize = 300
+	require.Error(t, db.ApplyConfig(ctx, cfg))
+	cfg.DefaultPageSize, cfg.SlowQueryThreshold = 20, "slow"
+	require.Error(t, db.ApplyConfig(ctx, cfg))
+	require.Equal(t, uint32(200), paginator.Config().MaxSize)
+}
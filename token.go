@@ -0,0 +1,73 @@
+package pgkit
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// ErrInvalidPageToken is returned by PageFromToken for malformed or tampered tokens.
+var ErrInvalidPageToken = errors.New("pgkit: invalid page token")
+
+type pageToken struct {
+	Page   uint32 `json:"p,omitempty"`
+	Size   uint32 `json:"n,omitempty"`
+	Sort   []Sort `json:"s,omitempty"`
+	Cursor string `json:"c,omitempty"`
+}
+
+// Token returns an opaque token holding the page number or cursor, size and sort of the page,
+// signed with secret, so clients can be handed a single string they can't tamper with, see
+// PageFromToken.
+func (p *Page) Token(secret []byte) (string, error) {
+	t := pageToken{Page: p.Page, Size: p.Size, Sort: p.GetOrder(), Cursor: p.Cursor}
+	data, err := json.Marshal(t)
+	if err != nil {
+		return "", wrapErr(err)
+	}
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(sign(payload, secret)), nil
+}
+
+// NextPageToken returns the token of the page following p, once it's prepared by
+// PrepareResult, or an empty string when it's the last page.
+func (p *Page) NextPageToken(secret []byte) (string, error) {
+	if !p.More {
+		return "", nil
+	}
+	next := &Page{Page: p.Page + 1, Size: p.Size, Column: p.Column, Order: p.Order}
+	if p.NextCursor != "" {
+		next.Cursor = p.NextCursor
+	}
+	return next.Token(secret)
+}
+
+// PageFromToken returns the page of a token created by Page.Token with the same secret.
+func PageFromToken(token string, secret []byte) (*Page, error) {
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrInvalidPageToken
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, sign(payload, secret)) {
+		return nil, ErrInvalidPageToken
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, ErrInvalidPageToken
+	}
+	var t pageToken
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, ErrInvalidPageToken
+	}
+	return &Page{Page: t.Page, Size: t.Size, Order: t.Sort, Cursor: t.Cursor}, nil
+}
+
+func sign(payload string, secret []byte) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(payload))
+	return h.Sum(nil)
+}
@@ -0,0 +1,44 @@
+package pgkit_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/goware/pgkit/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPageToken(t *testing.T) {
+	secret := []byte("secret")
+
+	page := &pgkit.Page{Page: 2, Size: 25, Column: "-created_at,id", More: true}
+	token, err := page.NextPageToken(secret)
+	require.NoError(t, err)
+
+	next, err := pgkit.PageFromToken(token, secret)
+	require.NoError(t, err)
+	require.Equal(t, &pgkit.Page{Page: 3, Size: 25, Order: []pgkit.Sort{
+		{Column: "created_at", Order: pgkit.Desc},
+		{Column: "id", Order: pgkit.Asc},
+	}}, next)
+
+	_, err = pgkit.PageFromToken(token, []byte("other"))
+	require.ErrorIs(t, err, pgkit.ErrInvalidPageToken)
+
+	payload, signature, _ := strings.Cut(token, ".")
+	_, err = pgkit.PageFromToken(payload[1:]+"."+signature, secret)
+	require.ErrorIs(t, err, pgkit.ErrInvalidPageToken)
+	_, err = pgkit.PageFromToken("garbage", secret)
+	require.ErrorIs(t, err, pgkit.ErrInvalidPageToken)
+
+	page = &pgkit.Page{Size: 10, NextCursor: "abc", More: true}
+	token, err = page.NextPageToken(secret)
+	require.NoError(t, err)
+	next, err = pgkit.PageFromToken(token, secret)
+	require.NoError(t, err)
+	require.Equal(t, "abc", next.Cursor)
+
+	token, err = (&pgkit.Page{Page: 3}).NextPageToken(secret)
+	require.NoError(t, err)
+	require.Empty(t, token)
+}
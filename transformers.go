@@ -0,0 +1,115 @@
+package pgkit
+
+import (
+	"strings"
+
+	"github.com/georgysavva/scany/v2/dbscan"
+)
+
+// ColumnTransformer transforms the sort columns of a paginator, see WithColumnTransformers.
+type ColumnTransformer struct {
+	Name string
+	// Columns are the sort columns it applies to, as they enter the chain, all of them when
+	// empty.
+	Columns []string
+	Func    func(column string) string
+}
+
+// applies returns true when the transformer applies to the column.
+func (t ColumnTransformer) applies(column string) bool {
+	if len(t.Columns) == 0 {
+		return true
+	}
+	for _, c := range t.Columns {
+		if c == column {
+			return true
+		}
+	}
+	return false
+}
+
+// WithColumnTransformers adds transformers to the chain applied to the sort columns, after
+// they are checked against the allowed columns. They run in the order they're added, each
+// one receiving the column returned by the previous one, ie. for a joined query:
+//
+//	pgkit.WithColumnTransformers(
+//		pgkit.SnakeCase(),
+//		pgkit.TablePrefix("u", "name", "email"),
+//		pgkit.TablePrefix("p"),
+//	)
+//
+// sorts "createdAt" by "p.created_at" and "name" by "u.name". A transformer replaces the one
+// with the same name, keeping its position, so the options of a query can override the ones
+// of the paginator. The sort expressions aren't transformed, see WithSortExpressions.
+func WithColumnTransformers(transformers ...ColumnTransformer) func(*PaginatorOption) {
+	transformers = append([]ColumnTransformer(nil), transformers...)
+	return func(o *PaginatorOption) {
+		chain := append([]ColumnTransformer(nil), o.columnTransformers...)
+	next:
+		for _, t := range transformers {
+			for i := range chain {
+				if chain[i].Name == t.Name {
+					chain[i] = t
+					continue next
+				}
+			}
+			chain = append(chain, t)
+		}
+		o.columnTransformers = chain
+	}
+}
+
+// transformColumn applies the transformers to the column.
+func (o PaginatorOption) transformColumn(column string) string {
+	original := column
+	for _, t := range o.columnTransformers {
+		if t.Func != nil && t.applies(original) {
+			column = t.Func(column)
+		}
+	}
+	return column
+}
+
+// SnakeCase is the transformer converting the columns to snake_case, ie. "createdAt" to
+// "created_at", as the fields are mapped by Scan.
+func SnakeCase() ColumnTransformer {
+	return ColumnTransformer{Name: "snake_case", Func: func(column string) string {
+		if strings.Contains(column, ".") {
+			parts := strings.Split(column, ".")
+			for i := range parts {
+				parts[i] = dbscan.SnakeCaseMapper(parts[i])
+			}
+			return strings.Join(parts, ".")
+		}
+		return dbscan.SnakeCaseMapper(column)
+	}}
+}
+
+// TablePrefix is the transformer qualifying the columns, all of them when none is given,
+// with a table name or alias, ie. "name" to "u.name", named after it. The qualified columns
+// are left as is.
+func TablePrefix(table string, columns ...string) ColumnTransformer {
+	return ColumnTransformer{Name: "prefix:" + table, Columns: columns, Func: func(column string) string {
+		if strings.Contains(column, ".") {
+			return column
+		}
+		return table + "." + column
+	}}
+}
+
+// Substitute is the transformer replacing the columns by the expressions they're mapped to,
+// ie. "name" to "lower(u.name)", as received from the previous transformers. Unlike
+// WithSortExpressions, the columns must be allowed. The transformers after it receive the
+// expression, so it's usually the last one.
+func Substitute(expressions map[string]string) ColumnTransformer {
+	m := make(map[string]string, len(expressions))
+	for k, v := range expressions {
+		m[k] = v
+	}
+	return ColumnTransformer{Name: "substitute", Func: func(column string) string {
+		if expr, ok := m[column]; ok {
+			return expr
+		}
+		return column
+	}}
+}
@@ -0,0 +1,45 @@
+package pgkit_test
+
+import (
+	"strings"
+	"testing"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/goware/pgkit/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestColumnTransformers(t *testing.T) {
+	paginator := pgkit.NewPaginator[T](
+		pgkit.WithSort("id"),
+		pgkit.WithSortExpressions(map[string]string{"rank": "p.score * 2"}),
+		pgkit.WithColumnTransformers(
+			pgkit.SnakeCase(),
+			pgkit.TablePrefix("u", "userName", "email"),
+			pgkit.TablePrefix("p"),
+			pgkit.Substitute(map[string]string{"u.email": "lower(u.email)"}),
+		),
+	)
+
+	for column, expected := range map[string]string{
+		"-createdAt":          "p.created_at DESC",
+		"userName,id":         "u.user_name ASC, p.id ASC",
+		"email":               "lower(u.email) ASC",
+		"rank,x.createdAt":    "p.score * 2 ASC, x.created_at ASC",
+		"-UserName:nullslast": "p.user_name DESC NULLS LAST",
+	} {
+		_, query := paginator.PrepareQuery(sq.Select("*").From("posts p").Join("users u ON u.id = p.user_id"), &pgkit.Page{Column: column})
+		sql, _, err := query.ToSql()
+		require.NoError(t, err)
+		require.Equal(t, "SELECT * FROM posts p JOIN users u ON u.id = p.user_id ORDER BY "+expected+" LIMIT 11 OFFSET 0", sql, column)
+	}
+
+	// a transformer of the query replaces the one with the same name, in place
+	_, query := paginator.PrepareQuery(sq.Select("*").From("posts"), &pgkit.Page{Column: "createdAt"},
+		pgkit.WithColumnTransformers(pgkit.ColumnTransformer{Name: "prefix:p", Func: func(c string) string { return "posts." + c }}),
+		pgkit.WithColumnFunc(strings.ToUpper),
+	)
+	sql, _, err := query.ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "SELECT * FROM posts ORDER BY POSTS.CREATED_AT ASC LIMIT 11 OFFSET 0", sql)
+}
@@ -0,0 +1,57 @@
+package pgkit
+
+// TreeNode is a node of a tree built by BuildTree.
+type TreeNode[T any] struct {
+	Item     T              `json:"item"`
+	Children []*TreeNode[T] `json:"children,omitempty"`
+}
+
+// BuildTree nests rows into trees, ie. the rows of a recursive CTE with id and parent_id
+// columns, using the id and parent functions, parent reporting false for the roots. Rows
+// whose parent isn't in rows are roots too. The children keep the order of rows, so
+// sorting the query, ie. by depth and name, sorts each level. Rows in a cycle are left out.
+func BuildTree[T any, K comparable](rows []T, id func(T) K, parent func(T) (K, bool)) []*TreeNode[T] {
+	nodes := make(map[K]*TreeNode[T], len(rows))
+	for _, row := range rows {
+		nodes[id(row)] = &TreeNode[T]{Item: row}
+	}
+	var roots []*TreeNode[T]
+	for _, row := range rows {
+		node := nodes[id(row)]
+		if key, ok := parent(row); ok {
+			if p := nodes[key]; p != nil {
+				p.Children = append(p.Children, node)
+				continue
+			}
+		}
+		roots = append(roots, node)
+	}
+	return roots
+}
+
+// Walk visits the nodes of the tree depth first, parents before their children, with
+// their depth, starting at 0. Returning false skips the children of the node.
+func (n *TreeNode[T]) Walk(fn func(node *TreeNode[T], depth int) bool) {
+	n.walk(fn, 0)
+}
+
+func (n *TreeNode[T]) walk(fn func(node *TreeNode[T], depth int) bool, depth int) {
+	if !fn(n, depth) {
+		return
+	}
+	for _, c := range n.Children {
+		c.walk(fn, depth+1)
+	}
+}
+
+// FlattenTree returns the items of the trees depth first, parents before their children.
+func FlattenTree[T any](roots []*TreeNode[T]) []T {
+	var items []T
+	for _, root := range roots {
+		root.Walk(func(node *TreeNode[T], depth int) bool {
+			items = append(items, node.Item)
+			return true
+		})
+	}
+	return items
+}
@@ -0,0 +1,51 @@
+package pgkit_test
+
+import (
+	"testing"
+
+	"github.com/goware/pgkit/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTree(t *testing.T) {
+	type category struct {
+		ID       int
+		ParentID *int
+		Name     string
+	}
+	ref := func(n int) *int { return &n }
+	rows := []category{
+		{1, nil, "root"},
+		{2, ref(1), "a"},
+		{3, ref(1), "b"},
+		{4, ref(2), "a1"},
+		{5, ref(99), "orphan"},
+		{6, ref(7), "cycle"},
+		{7, ref(6), "cycle"},
+	}
+	roots := pgkit.BuildTree(rows, func(c category) int { return c.ID }, func(c category) (int, bool) {
+		if c.ParentID == nil {
+			return 0, false
+		}
+		return *c.ParentID, true
+	})
+
+	require.Len(t, roots, 2)
+	require.Equal(t, "root", roots[0].Item.Name)
+	require.Len(t, roots[0].Children, 2)
+	require.Equal(t, "a1", roots[0].Children[0].Children[0].Item.Name)
+	require.Equal(t, "orphan", roots[1].Item.Name)
+
+	var names []string
+	for _, c := range pgkit.FlattenTree(roots) {
+		names = append(names, c.Name)
+	}
+	require.Equal(t, []string{"root", "a", "a1", "b", "orphan"}, names)
+
+	var depths []int
+	roots[0].Walk(func(node *pgkit.TreeNode[category], depth int) bool {
+		depths = append(depths, depth)
+		return node.Item.Name != "a"
+	})
+	require.Equal(t, []int{0, 1, 1}, depths)
+}
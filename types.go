@@ -0,0 +1,77 @@
+package pgkit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// RegisterCompositeType registers a user defined composite type, and its array type, in the
+// type map of every connection of the pool. The types of its fields must be registered first.
+// The name may be qualified with the schema, ie. "public.mood", its parts are quoted, as for
+// the tables.
+func RegisterCompositeType(cfg *pgxpool.Config, name string) {
+	registerType(cfg, name, 'c', true)
+}
+
+// RegisterEnum registers a user defined enum type, and its array type, in the type map of
+// every connection of the pool. The name is quoted as in RegisterCompositeType.
+func RegisterEnum(cfg *pgxpool.Config, name string) {
+	registerType(cfg, name, 'e', true)
+}
+
+// RegisterDomain registers a user defined domain in the type map of every connection of
+// the pool. Its base type must be registered first. The name is quoted as in
+// RegisterCompositeType.
+func RegisterDomain(cfg *pgxpool.Config, name string) {
+	registerType(cfg, name, 'd', false)
+}
+
+// registerType chains an AfterConnect hook that looks up the type OID and loads its codec,
+// types must be registered in dependency order.
+func registerType(cfg *pgxpool.Config, name string, kind byte, array bool) {
+	afterConnect := cfg.AfterConnect
+	cfg.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+		if afterConnect != nil {
+			if err := afterConnect(ctx, conn); err != nil {
+				return err
+			}
+		}
+		return loadType(ctx, conn, name, kind, array)
+	}
+}
+
+func loadType(ctx context.Context, conn *pgx.Conn, name string, kind byte, array bool) error {
+	var typtype string
+	err := conn.QueryRow(ctx, `SELECT typtype::text FROM pg_type WHERE oid = $1::regtype`, quoteIdent(name)).Scan(&typtype)
+	if err != nil {
+		return fmt.Errorf("pgkit: failed to lookup type %q: %w", name, err)
+	}
+	if typtype != string(kind) {
+		return fmt.Errorf("pgkit: type %q is of kind %q, expecting %q", name, typtype, string(kind))
+	}
+
+	names := []string{quoteIdent(name)}
+	if array {
+		names = append(names, arrayTypeName(name))
+	}
+	for _, name := range names {
+		t, err := conn.LoadType(ctx, name)
+		if err != nil {
+			return fmt.Errorf("pgkit: failed to load type %q: %w", name, err)
+		}
+		conn.TypeMap().RegisterType(t)
+	}
+	return nil
+}
+
+// arrayTypeName returns the quoted name of the array type of name, ie. "public"."_mood" for
+// "public.mood".
+func arrayTypeName(name string) string {
+	parts := strings.Split(name, ".")
+	parts[len(parts)-1] = "_" + parts[len(parts)-1]
+	return pgx.Identifier(parts).Sanitize()
+}
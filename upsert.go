@@ -0,0 +1,130 @@
+package pgkit
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// upsertAlias is the alias of the table of an upsert, used by the guard and RETURNING.
+const upsertAlias = "pgkit_upsert"
+
+// UpsertRecords returns the insert of the records, updating the rows conflicting on the
+// conflict columns with the other columns of the records. Conflicting rows whose values
+// wouldn't change are left untouched. The columns are the ones of the first record, as in
+// InsertRecords.
+func (s StatementBuilder) UpsertRecords(recordsSlice interface{}, conflict []string, optTableName ...string) InsertBuilder {
+	q := s.InsertRecords(recordsSlice, optTableName...)
+	if q.err != nil {
+		return q
+	}
+	if len(conflict) == 0 {
+		return InsertBuilder{InsertBuilder: q.InsertBuilder, err: wrapErr(fmt.Errorf("upsert without conflict columns"))}
+	}
+	cols, _, _ := Map(reflect.ValueOf(recordsSlice).Index(0).Interface())
+	isConflict := make(map[string]bool, len(conflict))
+	for _, c := range conflict {
+		isConflict[c] = true
+	}
+	var set, old, excluded []string
+	for _, c := range cols {
+		if !isConflict[c] {
+			set = append(set, c+" = EXCLUDED."+c)
+			old = append(old, upsertAlias+"."+c)
+			excluded = append(excluded, "EXCLUDED."+c)
+		}
+	}
+	table, _ := get(q.InsertBuilder, "Into").(string)
+	q.InsertBuilder = q.Into(table + " AS " + upsertAlias)
+	if len(set) == 0 {
+		q.InsertBuilder = q.Suffix(fmt.Sprintf("ON CONFLICT (%s) DO NOTHING", strings.Join(conflict, ", ")))
+		return q
+	}
+	q.InsertBuilder = q.Suffix(fmt.Sprintf("ON CONFLICT (%s) DO UPDATE SET %s WHERE (%s) IS DISTINCT FROM (%s)",
+		strings.Join(conflict, ", "), strings.Join(set, ", "), strings.Join(old, ", "), strings.Join(excluded, ", ")))
+	return q
+}
+
+// UpsertOutcome is what an upsert did with a record.
+type UpsertOutcome int
+
+const (
+	UpsertUnchanged UpsertOutcome = iota
+	UpsertInserted
+	UpsertUpdated
+)
+
+func (o UpsertOutcome) String() string {
+	switch o {
+	case UpsertInserted:
+		return "inserted"
+	case UpsertUpdated:
+		return "updated"
+	}
+	return "unchanged"
+}
+
+// UpsertResult is the outcome of the upsert of a record, see Upsert.
+type UpsertResult[T any] struct {
+	// Record is the row as returned by the database, ie. with its defaults, or the record
+	// itself when unchanged.
+	Record  T
+	Outcome UpsertOutcome
+}
+
+// Upsert upserts the records into table with StatementBuilder.UpsertRecords, and reports
+// whether each one was inserted, updated or left unchanged, in the order of records, ie. to
+// count the changes of a sync job. The rows returned are matched to the records by key,
+// which should return the values of the conflict columns. It relies on the xmax system
+// column being zero for the inserted rows.
+func Upsert[T any, K comparable](ctx context.Context, querier *Querier, table string, records []T, conflict []string, key func(T) K) ([]UpsertResult[T], error) {
+	if len(records) == 0 {
+		return nil, nil
+	}
+	q := querier.SQL.UpsertRecords(records, conflict, table)
+	if q.err == nil {
+		q.InsertBuilder = q.Suffix("RETURNING *, (" + upsertAlias + ".xmax = 0)::int")
+	}
+	rows, err := querier.QueryRows(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	r := &upsertRows{inlineCountRows: &inlineCountRows{Rows: rows}}
+	returned, err := Scan[T](r)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]UpsertResult[T], len(records))
+	index := make(map[K]int, len(records))
+	for i, record := range records {
+		results[i] = UpsertResult[T]{Record: record, Outcome: UpsertUnchanged}
+		index[key(record)] = i
+	}
+	for i, row := range returned {
+		j, ok := index[key(row)]
+		if !ok {
+			return nil, fmt.Errorf("pgkit: upserted row %v doesn't match any record", key(row))
+		}
+		results[j].Record, results[j].Outcome = row, UpsertUpdated
+		if r.inserted[i] {
+			results[j].Outcome = UpsertInserted
+		}
+	}
+	return results, nil
+}
+
+// upsertRows strips the inserted flag from the rows returned by Upsert, collecting it.
+type upsertRows struct {
+	*inlineCountRows
+	inserted []bool
+}
+
+func (r *upsertRows) Scan(dest ...interface{}) error {
+	if err := r.inlineCountRows.Scan(dest...); err != nil {
+		return err
+	}
+	r.inserted = append(r.inserted, r.total == 1)
+	return nil
+}
@@ -0,0 +1,36 @@
+package pgkit_test
+
+import (
+	"testing"
+
+	"github.com/goware/pgkit/v2"
+	"github.com/stretchr/testify/require"
+)
+
+type upsertItem struct {
+	SKU   string `db:"sku"`
+	Name  string `db:"name"`
+	Price int    `db:"price"`
+}
+
+func TestUpsertRecords(t *testing.T) {
+	sql := pgkit.NewQuerier(nil).SQL
+	items := []upsertItem{{"a", "Apple", 10}, {"b", "Banana", 5}}
+
+	query, args, err := sql.UpsertRecords(items, []string{"sku"}, "items").ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "INSERT INTO items AS pgkit_upsert (name,price,sku) VALUES ($1,$2,$3),($4,$5,$6) "+
+		"ON CONFLICT (sku) DO UPDATE SET name = EXCLUDED.name, price = EXCLUDED.price "+
+		"WHERE (pgkit_upsert.name, pgkit_upsert.price) IS DISTINCT FROM (EXCLUDED.name, EXCLUDED.price)", query)
+	require.Len(t, args, 6)
+
+	query, _, err = sql.UpsertRecords(items, []string{"sku", "name", "price"}, "items").ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "INSERT INTO items AS pgkit_upsert (name,price,sku) VALUES ($1,$2,$3),($4,$5,$6) ON CONFLICT (sku, name, price) DO NOTHING", query)
+
+	require.Error(t, sql.UpsertRecords(items, nil, "items").Err())
+	require.Error(t, sql.UpsertRecords([]upsertItem{}, []string{"sku"}, "items").Err())
+
+	require.Equal(t, "inserted", pgkit.UpsertInserted.String())
+	require.Equal(t, "unchanged", pgkit.UpsertUnchanged.String())
+}
@@ -1,6 +1,9 @@
 package pgkit
 
-import "github.com/jackc/pgx/v5"
+import (
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
 
 var ErrNoRows = pgx.ErrNoRows
 
@@ -9,3 +12,19 @@ type errRow struct {
 }
 
 func (e errRow) Scan(dest ...interface{}) error { return e.err }
+
+type errBatchResults struct {
+	err error
+}
+
+func (e errBatchResults) Exec() (pgconn.CommandTag, error) { return pgconn.CommandTag{}, e.err }
+func (e errBatchResults) Query() (pgx.Rows, error)         { return nil, e.err }
+func (e errBatchResults) QueryRow() pgx.Row                { return errRow{e.err} }
+func (e errBatchResults) Close() error                     { return e.err }
+
+// errSqlizer fails the query it's added to.
+type errSqlizer struct {
+	err error
+}
+
+func (e errSqlizer) ToSql() (string, []interface{}, error) { return "", nil, e.err }
@@ -0,0 +1,65 @@
+package pgkit
+
+import (
+	"context"
+	"sync"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Warmup establishes n connections of the pool, at most its max size, validates them with a
+// ping and prepares the queries on each of them, so the first requests after a deploy don't
+// wait for the connections and the statement caches. It also loads the server version and
+// the capabilities of the database. The connections stay in the pool until they're idle for
+// too long, see Config.MinConns to keep them.
+func (d *DB) Warmup(ctx context.Context, n int, queries ...string) error {
+	if max := int(d.Pool().Config().MaxConns); n > max {
+		n = max
+	}
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		conns = make([]*pgxpool.Conn, 0, n)
+		first error
+	)
+	// the connections are held until all of them are ready, so they're distinct
+	defer func() {
+		for _, conn := range conns {
+			conn.Release()
+		}
+	}()
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conn, err := d.Pool().Acquire(ctx)
+			if err == nil {
+				err = conn.Ping(ctx)
+				for _, sql := range queries {
+					if err != nil {
+						break
+					}
+					// named after the query, so it's used by the queries with the same SQL
+					_, err = conn.Conn().Prepare(ctx, sql, sql)
+				}
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if conn != nil {
+				conns = append(conns, conn)
+			}
+			if err != nil && first == nil {
+				first = wrapErr(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if first != nil {
+		return first
+	}
+	if _, err := d.ServerVersion(ctx); err != nil {
+		return err
+	}
+	_, err := d.Capabilities(ctx)
+	return err
+}